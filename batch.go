@@ -0,0 +1,142 @@
+package queue
+
+import "sync"
+
+// Batcher is implemented by queues that can hand out their front items for
+// processing and remove them for good only once the processing succeeds.
+// Queues created by New, NewPriority, NewBlocking, NewDeque and NewUnsafe
+// implement it.
+type Batcher[T any] interface {
+	// BeginBatch takes up to n items from the front of the queue, in the order
+	// Dequeue would return them, and returns them as a batch to be ended with
+	// Commit or Rollback. Fewer than n items are taken if the queue holds
+	// fewer, and none if n <= 0 or the queue is empty.
+	//
+	// While the batch is open its items are invisible to every other
+	// operation, so no other consumer can take them, but they keep their room
+	// within the capacity and byte limit, and their keys under WithDedup and
+	// WithKeyIndex. A queue has at most one open batch: BeginBatch blocks
+	// until the previous batch ends, so the goroutine holding a batch must end
+	// it before beginning another. Other operations are not blocked.
+	BeginBatch(n int) *Batch[T]
+}
+
+// Batch is a set of items taken from the front of a queue by BeginBatch.
+//
+// Commit removes the items for good, as Dequeue would: they are counted as
+// dequeued and the dequeue hooks run for them. Rollback puts them back at the
+// front of the queue, in their original order, as if they had never been
+// taken. Exactly one of the two should be called, promptly; later calls have
+// no effect. Ending a batch that holds no items does nothing.
+//
+// Example:
+//
+//	b := q.(queue.Batcher[Job]).BeginBatch(100)
+//	if err := store(b.Items()); err != nil {
+//		b.Rollback() // Retry the same jobs later
+//		return err
+//	}
+//	b.Commit()
+type Batch[T any] struct {
+	q     *queue[T] // Nil for a batch that holds no items
+	items []T
+	once  sync.Once
+}
+
+// Items returns the items of the batch, in the order Dequeue would have
+// returned them. The slice must not be modified.
+func (b *Batch[T]) Items() []T {
+	return b.items
+}
+
+// Commit removes the items of the batch from the queue for good.
+func (b *Batch[T]) Commit() {
+	b.end(true)
+}
+
+// Rollback returns the items of the batch to the front of the queue.
+func (b *Batch[T]) Rollback() {
+	b.end(false)
+}
+
+// end implements Commit and Rollback, releasing the batch lock of the queue.
+func (b *Batch[T]) end(commit bool) {
+	if b.q == nil {
+		return
+	}
+
+	b.once.Do(func() {
+		q := b.q
+		q.mu.Lock()
+		q.held, q.heldBytes = nil, 0
+		if commit {
+			for _, val := range b.items {
+				q.logDequeue()
+				q.untrack(val)
+			}
+			q.changed() // The items were already hidden, but their removal is new to the change log
+			q.stats.Dequeued += uint64(len(b.items))
+			q.notFull.broadcast()
+		} else {
+			q.restore(b.items)
+		}
+		q.unlock()
+		q.batch.Unlock()
+
+		if commit {
+			for _, val := range b.items {
+				runHooks(q.onDequeue, val)
+			}
+		}
+	})
+}
+
+func (q *queue[T]) BeginBatch(n int) *Batch[T] {
+	if n <= 0 {
+		return &Batch[T]{}
+	}
+
+	q.batch.Lock()
+	q.mu.Lock()
+	n = min(n, q.items.len())
+	if n == 0 {
+		q.unlock()
+		q.batch.Unlock()
+		return &Batch[T]{}
+	}
+
+	items := make([]T, n)
+	for i := range items {
+		items[i] = q.detachFront()
+		q.heldBytes += q.itemBytes(items[i])
+	}
+	q.changed()
+	q.held = items
+	q.unlock()
+
+	return &Batch[T]{q: q, items: items}
+}
+
+// restore puts vals, taken by detachFront, back at the front of the queue so
+// that vals[0] becomes the front. Their room and keys were kept, so nothing
+// is checked. The caller must hold the write lock.
+func (q *queue[T]) restore(vals []T) {
+	if len(vals) == 0 {
+		return
+	}
+
+	for i := len(vals) - 1; i >= 0; i-- {
+		if q.items.full() {
+			q.grow()
+		}
+		if q.less != nil {
+			q.items.pushBack(vals[i])
+			q.siftUp(q.items.len() - 1)
+		} else {
+			q.items.pushFront(vals[i])
+		}
+		q.bytes += q.itemBytes(vals[i])
+	}
+	q.changed()
+	q.notEmpty.broadcast()
+}
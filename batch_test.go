@@ -0,0 +1,183 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBatchCommit(t *testing.T) {
+	var dequeued []int
+	q := New(WithCapacity[int](5), WithOnDequeue(func(v int) { dequeued = append(dequeued, v) }))
+	_, _ = q.EnqueueSlice([]int{1, 2, 3, 4})
+	b := q.(Batcher[int]).BeginBatch(2)
+
+	if got := b.Items(); !slices.Equal(got, []int{1, 2}) {
+		t.Fatalf("BeginBatch(2).Items() = %v, want [1 2]", got)
+	}
+
+	// Other consumers cannot see the batch, but it keeps its room
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{3, 4}) {
+		t.Errorf("All() during the batch = %v, want [3 4]", got)
+	}
+	if val, _ := q.Dequeue(); val != 3 {
+		t.Errorf("Dequeue() during the batch = %d, want 3", val)
+	}
+	if n := q.Remaining(); n != 2 {
+		t.Errorf("Remaining() during the batch = %d, want 2", n)
+	}
+	_ = q.Enqueue(5)
+	_ = q.Enqueue(6)
+	if err := q.Enqueue(7); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Enqueue() into the room held by the batch = %v, want %v", err, ErrOverflow)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Errorf("CheckInvariants() during the batch = %v", err)
+	}
+
+	b.Commit()
+	b.Commit()   // Has no effect
+	b.Rollback() // Has no effect
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{4, 5, 6}) {
+		t.Errorf("All() after Commit() = %v, want [4 5 6]", got)
+	}
+	if !slices.Equal(dequeued, []int{3, 1, 2}) {
+		t.Errorf("dequeue hooks saw %v, want [3 1 2]", dequeued)
+	}
+	if s := q.Stats(); s.Dequeued != 3 {
+		t.Errorf("Stats().Dequeued = %d, want 3", s.Dequeued)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Errorf("CheckInvariants() after Commit() = %v", err)
+	}
+}
+
+func TestBatchRollback(t *testing.T) {
+	for name, q := range map[string]Queue[int]{
+		"queue":    New[int](),
+		"priority": NewPriority(intLess),
+		"lifo":     New(WithLIFO[int]()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _ = q.EnqueueSlice([]int{1, 2, 3, 4})
+			want, _ := q.PeekN(4)
+
+			b := q.(Batcher[int]).BeginBatch(3)
+			if got := b.Items(); !slices.Equal(got, want[:3]) {
+				t.Fatalf("BeginBatch(3).Items() = %v, want %v", got, want[:3])
+			}
+			if size := q.Size(); size != 1 {
+				t.Errorf("Size() during the batch = %d, want 1", size)
+			}
+			b.Rollback()
+
+			// The items are available again, in their original order
+			if got := q.Drain(); !slices.Equal(got, want) {
+				t.Errorf("Drain() after Rollback() = %v, want %v", got, want)
+			}
+			if s := q.Stats(); s.Dequeued != 4 {
+				t.Errorf("Stats().Dequeued = %d, want 4 from Drain() alone", s.Dequeued)
+			}
+			if err := CheckInvariants(q); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBatchKeys(t *testing.T) {
+	q := New(WithDedup(eventID), WithKeyIndex(eventID))
+	_, _ = q.EnqueueSlice([]event{{"a", 1}, {"b", 1}})
+
+	b := q.(Batcher[event]).BeginBatch(1)
+	if err := q.Enqueue(event{"a", 2}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Enqueue() of a key held by the batch = %v, want %v", err, ErrDuplicate)
+	}
+	if !ContainsKey(q, "a") {
+		t.Error("ContainsKey() of a key held by the batch = false, want true")
+	}
+	q.Reset()
+	if err := CheckInvariants(q); err != nil {
+		t.Errorf("CheckInvariants() after Reset() during the batch = %v", err)
+	}
+
+	b.Commit()
+	if ContainsKey(q, "a") {
+		t.Error("ContainsKey() after Commit() = true, want false")
+	}
+	if err := q.Enqueue(event{"a", 3}); err != nil {
+		t.Errorf("Enqueue() after Commit() = %v, want nil", err)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatchExclusive(t *testing.T) {
+	q := New[int]()
+	_, _ = q.EnqueueSlice([]int{1, 2, 3})
+	first := q.(Batcher[int]).BeginBatch(1)
+
+	// A second batch waits for the first to end
+	next := make(chan *Batch[int])
+	go func() { next <- q.(Batcher[int]).BeginBatch(5) }()
+	select {
+	case <-next:
+		t.Fatal("BeginBatch() returned while another batch was open")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// Other operations are not blocked
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := q.EnqueueWait(ctx, 4); err != nil {
+		t.Errorf("EnqueueWait() during the batch = %v, want nil", err)
+	}
+
+	first.Rollback()
+	second := <-next
+	if got := second.Items(); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("Items() of the second batch = %v, want [1 2 3 4]", got)
+	}
+	second.Commit()
+
+	// Empty batches hold nothing and need no ending
+	if got := q.(Batcher[int]).BeginBatch(2).Items(); len(got) != 0 {
+		t.Errorf("BeginBatch(2) on an empty queue = %v, want []", got)
+	}
+	_ = q.Enqueue(5)
+	if got := q.(Batcher[int]).BeginBatch(0).Items(); len(got) != 0 {
+		t.Errorf("BeginBatch(0) = %v, want []", got)
+	}
+	b := q.(Batcher[int]).BeginBatch(1)
+	defer b.Commit()
+	if got := b.Items(); !slices.Equal(got, []int{5}) {
+		t.Errorf("BeginBatch(1) after empty batches = %v, want [5]", got)
+	}
+}
+
+func TestBatchSetCapacity(t *testing.T) {
+	q := New(WithCapacity[int](4), WithShrinkPolicy[int](ShrinkDropOldest))
+	_, _ = q.EnqueueSlice([]int{1, 2, 3, 4})
+	b := q.(Batcher[int]).BeginBatch(2)
+
+	if err := q.SetCapacity(1); !errors.Is(err, ErrCapacityTooSmall) {
+		t.Errorf("SetCapacity() below the batch = %v, want %v", err, ErrCapacityTooSmall)
+	}
+	if err := q.SetCapacity(3); err != nil {
+		t.Errorf("SetCapacity(3) = %v, want nil", err)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{4}) {
+		t.Errorf("All() after SetCapacity(3) = %v, want [4]", got)
+	}
+
+	b.Rollback()
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 2, 4}) {
+		t.Errorf("All() after Rollback() = %v, want [1 2 4]", got)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"slices"
+)
+
+// NewBlocking creates a bounded queue for producer/consumer setups, whose
+// Enqueue waits while the queue is full and whose Dequeue waits while it is
+// empty, instead of returning ErrOverflow or ErrUnderflow.
+//
+// Enqueue and Dequeue behave like EnqueueWait and DequeueWait with a
+// background context: they return only once they succeed or the queue is
+// closed, in which case they return ErrClosed. Use EnqueueWait, DequeueWait
+// or the timeout variants to bound the wait, and TryEnqueue or TryDequeue to
+// avoid it. All other behavior matches New with WithCapacity(capacity).
+//
+// Example:
+//
+//	q := queue.NewBlocking[Job](100)
+//	go func() {
+//		for job := range jobs {
+//			q.Enqueue(job) // Waits while 100 jobs are pending
+//		}
+//		q.Close()
+//	}()
+//	for {
+//		job, err := q.Dequeue() // Waits for the next job
+//		if errors.Is(err, queue.ErrClosed) {
+//			break
+//		}
+//		process(job)
+//	}
+//
+// Panics if capacity < 1.
+func NewBlocking[T any](capacity int, opts ...Option[T]) Queue[T] {
+	if capacity < 1 {
+		panic("cannot specify non-positive capacity for a blocking queue")
+	}
+
+	// The capacity argument takes precedence over any WithCapacity in opts
+	opts = append(slices.Clip(opts), WithCapacity[T](capacity))
+
+	b := &blocking[T]{queue: newQueue(opts...)}
+	b.flushes(b)
+
+	return b
+}
+
+type blocking[T any] struct {
+	*queue[T]
+}
+
+func (b *blocking[T]) Enqueue(val T) (err error) {
+	if b.tracer != nil {
+		end := b.tracer(b.name, OpEnqueue)
+		defer func() { end(traced(err), err) }()
+	}
+
+	return b.EnqueueWait(context.Background(), val)
+}
+
+func (b *blocking[T]) Dequeue() (result T, err error) {
+	if b.tracer != nil {
+		end := b.tracer(b.name, OpDequeue)
+		defer func() { end(traced(err), err) }()
+	}
+
+	return b.DequeueWait(context.Background())
+}
+
+func (b *blocking[T]) Split(n int) (Queue[T], error) {
+	head, err := b.queue.Split(n)
+	if err != nil {
+		return nil, err
+	}
+
+	split := &blocking[T]{queue: head.(*queue[T])}
+	split.flushes(split)
+
+	return split, nil
+}
+
+// asQueue returns the locked queue behind q, if it has one.
+func asQueue[T any](q Queue[T]) (*queue[T], bool) {
+	switch q := q.(type) {
+	case *queue[T]:
+		return q, true
+	case *blocking[T]:
+		return q.queue, true
+	case *deque[T]:
+		return q.queue, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBlocking(t *testing.T) {
+	q := NewBlocking[int](1)
+	_ = q.Enqueue(1)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- q.Enqueue(2)
+	}()
+
+	// Enqueue waits while the queue is full
+	select {
+	case err := <-done:
+		t.Fatalf("Enqueue() on full blocking queue returned %v, want it to block", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if val, err := q.Dequeue(); val != 1 || err != nil {
+		t.Fatalf("Dequeue() = (%d, %v), want (1, nil)", val, err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("blocked Enqueue() = %v, want nil", err)
+	}
+	if val, _ := q.Dequeue(); val != 2 {
+		t.Errorf("Dequeue() = %d, want 2", val)
+	}
+
+	// The non-blocking variants still fail fast
+	if _, ok := q.TryDequeue(); ok {
+		t.Error("TryDequeue() on empty blocking queue = true, want false")
+	}
+	_ = q.Enqueue(3)
+	if q.TryEnqueue(4) {
+		t.Error("TryEnqueue() on full blocking queue = true, want false")
+	}
+}
+
+func TestBlockingClose(t *testing.T) {
+	q := NewBlocking[int](1)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := q.Dequeue()
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	_ = q.Close()
+
+	if err := <-done; !errors.Is(err, ErrClosed) {
+		t.Errorf("blocked Dequeue() after Close() = %v, want %v", err, ErrClosed)
+	}
+	if err := q.Enqueue(1); !errors.Is(err, ErrClosed) {
+		t.Errorf("Enqueue() after Close() = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestBlockingCapacity(t *testing.T) {
+	// The capacity argument wins over WithCapacity
+	q := NewBlocking[int](2, WithCapacity[int](UnlimitedCapacity))
+	if r := q.Remaining(); r != 2 {
+		t.Errorf("Remaining() = %d, want 2", r)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("NewBlocking(0) did not panic")
+		}
+	}()
+	NewBlocking[int](0)
+}
+
+func TestBlockingProducersConsumers(t *testing.T) {
+	const (
+		producers = 4
+		consumers = 4
+		perWorker = 500
+	)
+
+	q := NewBlocking[int](8)
+	var produced, consumed atomic.Int64
+
+	var prodWG, consWG sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		prodWG.Add(1)
+		go func() {
+			defer prodWG.Done()
+			for i := 1; i <= perWorker; i++ {
+				if err := q.Enqueue(i); err != nil {
+					t.Errorf("Enqueue() = %v, want nil", err)
+					return
+				}
+				produced.Add(int64(i))
+			}
+		}()
+	}
+	for c := 0; c < consumers; c++ {
+		consWG.Add(1)
+		go func() {
+			defer consWG.Done()
+			for {
+				val, err := q.Dequeue()
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+				consumed.Add(int64(val))
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		prodWG.Wait()
+		_ = q.Close()
+		consWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("producers and consumers deadlocked")
+	}
+
+	if p, c := produced.Load(), consumed.Load(); p != c || p != producers*perWorker*(perWorker+1)/2 {
+		t.Errorf("consumed total %d, produced total %d, want both %d", c, p, producers*perWorker*(perWorker+1)/2)
+	}
+}
+
+func TestBlockingMergeAndSplit(t *testing.T) {
+	a := NewBlocking[int](4)
+	b := NewBlocking[int](4)
+	_ = a.Enqueue(1)
+	_ = b.Enqueue(2)
+	_ = b.Enqueue(3)
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge() = %v, want nil", err)
+	}
+	if s := a.String(); s != "Queue[len=3/cap=4]: [1 2 3]" {
+		t.Errorf("String() after Merge() = %q, want [1 2 3]", s)
+	}
+
+	head, _ := a.Split(1)
+	if _, ok := head.(*blocking[int]); !ok {
+		t.Error("Split() of a blocking queue did not return a blocking queue")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _ = head.Dequeue()
+	if _, err := head.DequeueWait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DequeueWait() on empty split queue = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,71 @@
+package queue
+
+import "sync"
+
+// Borrower is implemented by queues whose front items can be processed in
+// place, without copying them out of the queue's storage. Queues created by
+// New, NewPriority, NewBlocking, NewDeque and NewUnsafe implement it.
+type Borrower[T any] interface {
+	// BorrowFront returns up to n items from the front of the queue as a slice
+	// of its storage, and a function that removes them from the queue.
+	//
+	// The borrow holds the queue's write lock until release is called, so
+	// every other operation on the queue, including reads, blocks meanwhile.
+	// The contract for the caller is:
+	//   - Call release exactly once, and promptly; later calls have no effect
+	//   - Do not call the queue from the goroutine holding the borrow before
+	//     releasing it, which would deadlock
+	//   - Do not modify the slice, and do not use it after release, since the
+	//     storage is cleared and reused
+	//
+	// Fewer than n items are returned when the queue holds fewer, where its
+	// storage wraps around, and always for a priority queue, which lends only
+	// its front item. Release removes the items as Dequeue would, running the
+	// dequeue hooks after the lock is released. An empty slice is returned,
+	// with a release that does nothing, if n <= 0 or the queue is empty.
+	BorrowFront(n int) (items []T, release func())
+}
+
+func (q *queue[T]) BorrowFront(n int) ([]T, func()) {
+	if n <= 0 {
+		return nil, func() {}
+	}
+
+	q.mu.Lock()
+	if q.less != nil {
+		n = 1
+	}
+	items := q.items.front(n)
+	if len(items) == 0 {
+		q.unlock()
+		return items, func() {}
+	}
+
+	var once sync.Once
+	return items, func() {
+		once.Do(func() { q.giveBack(len(items)) })
+	}
+}
+
+// giveBack ends a borrow of the first n items by removing them, and releases
+// the write lock taken by BorrowFront.
+func (q *queue[T]) giveBack(n int) {
+	var removed []T
+	if len(q.onDequeue) > 0 {
+		removed = make([]T, 0, n)
+	}
+	for range n {
+		val := q.takeFront()
+		if removed != nil {
+			removed = append(removed, val)
+		}
+	}
+	q.autoShrink()
+	q.stats.Dequeued += uint64(n)
+	q.notFull.broadcast()
+	q.unlock()
+
+	for _, val := range removed {
+		runHooks(q.onDequeue, val)
+	}
+}
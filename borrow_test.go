@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBorrowFront(t *testing.T) {
+	var dequeued []int
+	q := New(WithOnDequeue(func(v int) { dequeued = append(dequeued, v) }))
+	_, _ = q.EnqueueSlice([]int{1, 2, 3, 4, 5})
+	b := q.(Borrower[int])
+
+	items, release := b.BorrowFront(3)
+	if !slices.Equal(items, []int{1, 2, 3}) {
+		t.Fatalf("BorrowFront(3) = %v, want [1 2 3]", items)
+	}
+	release()
+	release() // Has no effect
+
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{4, 5}) {
+		t.Errorf("All() after release = %v, want [4 5]", got)
+	}
+	if !slices.Equal(dequeued, []int{1, 2, 3}) {
+		t.Errorf("dequeue hook saw %v, want [1 2 3]", dequeued)
+	}
+	if s := q.Stats(); s.Dequeued != 3 {
+		t.Errorf("Stats().Dequeued = %d, want 3", s.Dequeued)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Errorf("CheckInvariants() = %v", err)
+	}
+
+	// Asking for more than the queue holds lends what there is
+	items, release = b.BorrowFront(10)
+	if !slices.Equal(items, []int{4, 5}) {
+		t.Errorf("BorrowFront(10) = %v, want [4 5]", items)
+	}
+	release()
+	if items, release = b.BorrowFront(1); len(items) != 0 {
+		t.Errorf("BorrowFront(1) on empty queue = %v, want []", items)
+	}
+	release()
+}
+
+func TestBorrowFrontWrapped(t *testing.T) {
+	q := newQueue[int](WithCapacity[int](4))
+	_, _ = q.EnqueueSlice([]int{0, 0, 1, 2})
+	_, _ = q.Dequeue()
+	_, _ = q.Dequeue()
+	_, _ = q.EnqueueSlice([]int{3, 4})
+
+	// The items wrap around the end of the storage, so only the first part is lent
+	items, release := q.BorrowFront(4)
+	if !slices.Equal(items, []int{1, 2}) {
+		t.Errorf("BorrowFront(4) of wrapped items = %v, want [1 2]", items)
+	}
+	release()
+	items, release = q.BorrowFront(4)
+	if !slices.Equal(items, []int{3, 4}) {
+		t.Errorf("BorrowFront(4) after release = %v, want [3 4]", items)
+	}
+	release()
+}
+
+func TestBorrowFrontPriority(t *testing.T) {
+	q := NewPriority(intLess)
+	_, _ = q.EnqueueSlice([]int{3, 1, 2})
+
+	items, release := q.(Borrower[int]).BorrowFront(3)
+	if !slices.Equal(items, []int{1}) {
+		t.Errorf("BorrowFront(3) of priority queue = %v, want [1]", items)
+	}
+	release()
+	if got := q.Drain(); !slices.Equal(got, []int{2, 3}) {
+		t.Errorf("Drain() after release = %v, want [2 3]", got)
+	}
+}
+
+func TestBorrowFrontBlocksWriters(t *testing.T) {
+	q := New[int]()
+	_ = q.Enqueue(1)
+	items, release := q.(Borrower[int]).BorrowFront(1)
+
+	done := make(chan struct{})
+	go func() {
+		_ = q.Enqueue(2)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Enqueue() completed while the front was borrowed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if items[0] != 1 {
+		t.Errorf("borrowed item = %d, want 1", items[0])
+	}
+
+	release()
+	<-done
+	if got := q.Drain(); !slices.Equal(got, []int{2}) {
+		t.Errorf("Drain() = %v, want [2]", got)
+	}
+}
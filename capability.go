@@ -0,0 +1,122 @@
+package queue
+
+// Closer is implemented by every Queue and by the RetryQueue, SequencedQueue
+// and TimedQueue wrappers, letting shutdown code close any of them without
+// knowing its type.
+type Closer interface {
+	// Close stops the queue accepting new items.
+	// Returns ErrClosed if the queue was already closed.
+	Close() error
+}
+
+// Sized is implemented by every Queue and by MultiQueue and the RetryQueue,
+// SequencedQueue and TimedQueue wrappers, for code such as metrics that only
+// reports sizes.
+type Sized interface {
+	// Size returns the number of items in the queue.
+	Size() int
+}
+
+// Capability is a behavior that only some queues have, as reported by
+// Supports.
+//
+// Capabilities that add methods are also available as interfaces, which a
+// type assertion checks and unwraps in one step:
+//
+//	if d, ok := q.(queue.Deque[int]); ok {
+//		d.EnqueueFront(1)
+//	}
+//	if e, ok := q.(queue.Expiring[int]); ok {
+//		e.Purge()
+//	}
+//
+// Supports also covers behaviors that do not change the method set, such as
+// the order of a priority queue.
+type Capability int
+
+const (
+	// CapabilityDeque means the queue implements Deque, as queues created by
+	// NewDeque do.
+	CapabilityDeque Capability = iota
+
+	// CapabilityExpiring means the queue implements Expiring, as queues created
+	// by NewExpiring do.
+	CapabilityExpiring
+
+	// CapabilityPriority means items are dequeued in priority order rather than
+	// FIFO order, as in queues created by NewPriority.
+	CapabilityPriority
+
+	// CapabilityBlocking means Enqueue and Dequeue wait for room or items
+	// instead of failing, as in queues created by NewBlocking.
+	CapabilityBlocking
+
+	// CapabilityConcurrent means the queue is safe for concurrent use, as every
+	// queue in this package is except those created by NewUnsafe, and those
+	// created by NewSPSC, which only allow one producer and one consumer.
+	CapabilityConcurrent
+
+	// CapabilityBorrow means the queue implements Borrower, as queues created
+	// by New and NewPriority do.
+	CapabilityBorrow
+)
+
+// Supports reports whether q has the capability c. It returns false for
+// unknown capabilities and for queues implemented outside this package,
+// except that CapabilityDeque, CapabilityExpiring and CapabilityBorrow are
+// reported for any queue implementing Deque, Expiring or Borrower.
+//
+// Example:
+//
+//	if !queue.Supports(q, queue.CapabilityConcurrent) {
+//		q = queue.New[int]()
+//	}
+func Supports[T any](q Queue[T], c Capability) bool {
+	// A view dequeues in the order of its source and is as safe as it is
+	if v, ok := q.(*view[T]); ok && (c == CapabilityPriority || c == CapabilityConcurrent) {
+		q = v.src
+	}
+
+	switch c {
+	case CapabilityDeque:
+		_, ok := q.(Deque[T])
+		return ok
+	case CapabilityExpiring:
+		_, ok := q.(Expiring[T])
+		return ok
+	case CapabilityPriority:
+		inner, ok := asQueue(q)
+		return ok && inner.less != nil
+	case CapabilityBlocking:
+		_, ok := q.(*blocking[T])
+		return ok
+	case CapabilityBorrow:
+		_, ok := q.(Borrower[T])
+		return ok
+	case CapabilityConcurrent:
+		return concurrent(q)
+	default:
+		return false
+	}
+}
+
+// concurrent reports whether q is one of this package's queues and is guarded
+// by a lock.
+func concurrent[T any](q Queue[T]) bool {
+	var mu locker
+	switch q := q.(type) {
+	case *sharded[T]:
+		mu = q.shards[0].mu
+	case *expiring[T]:
+		mu = q.q.mu
+	default:
+		inner, ok := asQueue(q)
+		if !ok {
+			return false
+		}
+		mu = inner.mu
+	}
+	_, unlocked := mu.(noLock)
+
+	return !unlocked
+}
@@ -0,0 +1,60 @@
+package queue
+
+import "testing"
+
+func TestSupports(t *testing.T) {
+	tests := []struct {
+		name string
+		q    Queue[int]
+		want []Capability
+	}{
+		{name: "queue", q: New[int](), want: []Capability{CapabilityConcurrent, CapabilityBorrow}},
+		{name: "unsafe", q: NewUnsafe[int](), want: []Capability{CapabilityBorrow}},
+		{name: "deque", q: NewDeque[int](), want: []Capability{CapabilityDeque, CapabilityConcurrent, CapabilityBorrow}},
+		{name: "priority", q: NewPriority(intLess), want: []Capability{CapabilityPriority, CapabilityConcurrent, CapabilityBorrow}},
+		{name: "blocking", q: NewBlocking[int](1), want: []Capability{CapabilityBlocking, CapabilityConcurrent, CapabilityBorrow}},
+		{name: "sharded", q: NewSharded[int](2), want: []Capability{CapabilityConcurrent}},
+		{name: "expiring", q: NewExpiring[int](), want: []Capability{CapabilityExpiring, CapabilityConcurrent}},
+	}
+
+	all := []Capability{CapabilityDeque, CapabilityExpiring, CapabilityPriority, CapabilityBlocking, CapabilityConcurrent, CapabilityBorrow}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, c := range all {
+				want := false
+				for _, w := range tt.want {
+					want = want || w == c
+				}
+				if got := Supports(tt.q, c); got != want {
+					t.Errorf("Supports(%d) = %v, want %v", c, got, want)
+				}
+			}
+			if Supports(tt.q, Capability(-1)) {
+				t.Error("Supports() of an unknown capability = true, want false")
+			}
+		})
+	}
+}
+
+func TestCapabilityAssertions(t *testing.T) {
+	var q Queue[int] = New[int]()
+	if _, ok := q.(Deque[int]); ok {
+		t.Error("queue from New satisfies Deque")
+	}
+	q = NewDeque[int]()
+	if _, ok := q.(Deque[int]); !ok {
+		t.Error("queue from NewDeque does not satisfy Deque")
+	}
+
+	// Closer and Sized cover the wrappers, which are not Queues
+	for _, c := range []Closer{New[int](), NewRetryQueue[int](1), NewSequenced[int](), NewTimed[int]()} {
+		if err := c.Close(); err != nil {
+			t.Errorf("Close() on %T = %v, want nil", c, err)
+		}
+	}
+	for _, s := range []Sized{New[int](), NewMultiQueue[int](), NewRetryQueue[int](1), NewSequenced[int](), NewTimed[int]()} {
+		if size := s.Size(); size != 0 {
+			t.Errorf("Size() on %T = %d, want 0", s, size)
+		}
+	}
+}
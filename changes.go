@@ -0,0 +1,165 @@
+package queue
+
+// changeRecorder records the items added to and removed from a queue created
+// with WithChangeLog, so that Changes can list them.
+type changeRecorder[T any] interface {
+	// record notes that val was added, or removed if added is false, by the
+	// change in progress.
+	record(val T, added bool)
+
+	// stamp marks the changes recorded since the last stamp as made at
+	// version.
+	stamp(version uint64)
+
+	// fresh returns an empty log with the same retention.
+	fresh() changeRecorder[T]
+}
+
+// change is one entry of a changeLog.
+type change[T any] struct {
+	version uint64 // Zero until stamped
+	val     T
+	added   bool
+}
+
+// changeLog is the changeRecorder of a queue created with WithChangeLog: a
+// ring of its most recent changes.
+type changeLog[T any] struct {
+	entries []change[T]
+	head    int    // Index of the oldest entry
+	n       int    // Number of entries
+	pending int    // Number of newest entries not yet stamped
+	floor   uint64 // Newest version with an entry that was dropped
+	lost    bool   // Whether an entry was dropped before it was stamped
+}
+
+func newChangeLog[T any](n int) *changeLog[T] {
+	return &changeLog[T]{entries: make([]change[T], n)}
+}
+
+func (l *changeLog[T]) record(val T, added bool) {
+	if l.n == len(l.entries) {
+		dropped := l.entries[l.head]
+		if l.pending == l.n {
+			l.lost = true
+			l.pending--
+		} else {
+			l.floor = dropped.version
+		}
+		l.entries[l.head] = change[T]{}
+		l.head = (l.head + 1) % len(l.entries)
+		l.n--
+	}
+
+	l.entries[(l.head+l.n)%len(l.entries)] = change[T]{val: val, added: added}
+	l.n++
+	l.pending++
+}
+
+func (l *changeLog[T]) stamp(version uint64) {
+	for i := l.n - l.pending; i < l.n; i++ {
+		l.entries[(l.head+i)%len(l.entries)].version = version
+	}
+	l.pending = 0
+	if l.lost {
+		l.floor, l.lost = version, false
+	}
+}
+
+func (l *changeLog[T]) fresh() changeRecorder[T] {
+	return newChangeLog[T](len(l.entries))
+}
+
+// since returns the items added and removed by the changes made after
+// version, oldest first.
+func (l *changeLog[T]) since(version uint64) (added, removed []T, err error) {
+	if version < l.floor {
+		return nil, nil, ErrVersionTooOld
+	}
+
+	for i := range l.n - l.pending {
+		if e := l.entries[(l.head+i)%len(l.entries)]; e.version > version {
+			if e.added {
+				added = append(added, e.val)
+			} else {
+				removed = append(removed, e.val)
+			}
+		}
+	}
+
+	return added, removed, nil
+}
+
+// fieldChanges is a changeRecorder of W values that records the T that field
+// returns a pointer to, for queues built with adapt.
+type fieldChanges[T, W any] struct {
+	changes changeRecorder[T]
+	field   func(*W) *T
+}
+
+func (f *fieldChanges[T, W]) record(w W, added bool) {
+	f.changes.record(*f.field(&w), added)
+}
+
+func (f *fieldChanges[T, W]) stamp(version uint64) {
+	f.changes.stamp(version)
+}
+
+func (f *fieldChanges[T, W]) fresh() changeRecorder[W] {
+	return &fieldChanges[T, W]{changes: f.changes.fresh(), field: f.field}
+}
+
+// Changes returns the items added to and removed from q since it was at
+// version since, as returned by Version or Snapshot, each oldest first. q
+// must have been created with WithChangeLog.
+//
+// Applying the changes to a copy of the items taken at since yields the
+// current items, though not necessarily in their current order: an item
+// added and then removed again appears in both lists, the reordering
+// operations Reverse, Rotate, Shuffle and Swap are not changes, and items
+// replaced in place, as by ReplaceFront, appear as removed and added. Items
+// held by an open batch count as removed only once it is committed. For an
+// expiring queue, expired items count as removed once they are discarded.
+//
+// The log keeps only the most recent changes, as many as given to
+// WithChangeLog. If some of the changes since then have been dropped,
+// Changes returns ErrVersionTooOld, and the caller must take a fresh
+// Snapshot instead.
+//
+// Example:
+//
+//	items, version := q.Snapshot()
+//	// ...
+//	added, removed, err := queue.Changes(q, version)
+//
+// Panics if q was not created with WithChangeLog.
+func Changes[T any](q Queue[T], since uint64) (added, removed []T, err error) {
+	if base, ok := asQueue(q); ok {
+		return changesSince(base, since, logOf(base.changes))
+	}
+
+	if q, ok := q.(*expiring[T]); ok {
+		if f, ok := q.q.changes.(*fieldChanges[T, timed[T]]); ok {
+			return changesSince(q.q, since, logOf(f.changes))
+		}
+	}
+
+	panic("cannot list the changes of a queue without a change log")
+}
+
+// logOf returns changes as a changeLog, panicking if it is not one.
+func logOf[T any](changes changeRecorder[T]) *changeLog[T] {
+	l, ok := changes.(*changeLog[T])
+	if !ok {
+		panic("cannot list the changes of a queue without a change log")
+	}
+
+	return l
+}
+
+func changesSince[W, T any](q *queue[W], since uint64, l *changeLog[T]) (added, removed []T, err error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return l.since(since)
+}
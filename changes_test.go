@@ -0,0 +1,133 @@
+package queue
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestChanges(t *testing.T) {
+	q := New(WithChangeLog[int](100))
+	_, _ = q.EnqueueSlice([]int{1, 2, 3})
+	_, start := q.Snapshot()
+
+	_, _ = q.Dequeue()
+	_ = q.Enqueue(4)
+	_ = q.Enqueue(5)
+	q.Filter(func(v int) bool { return v != 3 })
+	_ = q.ReplaceFront(6)
+	q.Reverse() // Not a change to the set of items
+
+	added, removed, err := Changes(q, start)
+	if err != nil {
+		t.Fatalf("Changes() = %v, want nil", err)
+	}
+	if !slices.Equal(added, []int{4, 5, 6}) {
+		t.Errorf("Changes() added = %v, want [4 5 6]", added)
+	}
+	if !slices.Equal(removed, []int{1, 3, 2}) {
+		t.Errorf("Changes() removed = %v, want [1 3 2]", removed)
+	}
+
+	// Only changes after the given version are reported
+	mid := q.Version()
+	q.Reset()
+	added, removed, _ = Changes(q, mid)
+	if len(added) != 0 || !slices.Equal(removed, []int{5, 4, 6}) {
+		t.Errorf("Changes() after Reset() = (%v, %v), want ([], [5 4 6])", added, removed)
+	}
+	if added, removed, _ := Changes(q, q.Version()); len(added) != 0 || len(removed) != 0 {
+		t.Errorf("Changes() since the current version = (%v, %v), want none", added, removed)
+	}
+}
+
+func TestChangesRetention(t *testing.T) {
+	q := New(WithChangeLog[int](3))
+	v0 := q.Version()
+	_ = q.Enqueue(1)
+	v1 := q.Version()
+	_ = q.Enqueue(2)
+	_ = q.Enqueue(3)
+	_ = q.Enqueue(4) // Drops the entry for 1
+
+	if _, _, err := Changes(q, v0); !errors.Is(err, ErrVersionTooOld) {
+		t.Errorf("Changes() since a dropped change = %v, want %v", err, ErrVersionTooOld)
+	}
+	added, _, err := Changes(q, v1)
+	if err != nil || !slices.Equal(added, []int{2, 3, 4}) {
+		t.Errorf("Changes() since the oldest retained change = (%v, %v), want ([2 3 4], nil)", added, err)
+	}
+
+	// A single change larger than the log cannot be reported
+	v4 := q.Version()
+	q.Reset()
+	if _, _, err := Changes(q, v4); !errors.Is(err, ErrVersionTooOld) {
+		t.Errorf("Changes() since before a change larger than the log = %v, want %v", err, ErrVersionTooOld)
+	}
+	if _, _, err := Changes(q, q.Version()); err != nil {
+		t.Errorf("Changes() since the current version = %v, want nil", err)
+	}
+}
+
+func TestChangesBatch(t *testing.T) {
+	q := New(WithChangeLog[int](10))
+	_, _ = q.EnqueueSlice([]int{1, 2, 3})
+	start := q.Version()
+
+	b := q.(Batcher[int]).BeginBatch(2)
+	if _, removed, _ := Changes(q, start); len(removed) != 0 {
+		t.Errorf("Changes() during the batch removed = %v, want []", removed)
+	}
+	b.Rollback()
+	if _, removed, _ := Changes(q, start); len(removed) != 0 {
+		t.Errorf("Changes() after Rollback() removed = %v, want []", removed)
+	}
+
+	q.(Batcher[int]).BeginBatch(2).Commit()
+	if _, removed, _ := Changes(q, start); !slices.Equal(removed, []int{1, 2}) {
+		t.Errorf("Changes() after Commit() removed = %v, want [1 2]", removed)
+	}
+}
+
+func TestChangesExpiring(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring(WithClock[int](clock), WithChangeLog[int](10))
+	start := q.Version()
+	_ = q.EnqueueWithTTL(1, time.Minute)
+	_ = q.EnqueueWithTTL(2, time.Hour)
+
+	clock.Advance(time.Minute)
+	q.Purge()
+	added, removed, err := Changes[int](q, start)
+	if err != nil || !slices.Equal(added, []int{1, 2}) || !slices.Equal(removed, []int{1}) {
+		t.Errorf("Changes() = (%v, %v, %v), want ([1 2], [1], nil)", added, removed, err)
+	}
+}
+
+func TestWithChangeLogPanics(t *testing.T) {
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("WithChangeLog(0) did not panic")
+			}
+		}()
+		WithChangeLog[int](0)
+	}()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Changes() without WithChangeLog did not panic")
+			}
+		}()
+		_, _, _ = Changes(New[int](), 0)
+	}()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewSharded() with WithChangeLog did not panic")
+		}
+	}()
+	NewSharded(2, WithChangeLog[int](1))
+}
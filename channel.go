@@ -0,0 +1,37 @@
+package queue
+
+import "context"
+
+func (q *queue[T]) Channel(ctx context.Context) <-chan T {
+	return channel(ctx, q)
+}
+
+// channel implements Channel in terms of DequeueWait.
+//
+// A goroutine moves items from q into the returned unbuffered channel,
+// blocking in DequeueWait rather than polling while q is empty. The goroutine
+// exits and closes the channel once ctx is done or q is closed and drained.
+// An item that has been dequeued but not yet received when ctx is done is
+// dropped.
+func channel[T any](ctx context.Context, q Queue[T]) <-chan T {
+	ch := make(chan T)
+
+	go func() {
+		defer close(ch)
+
+		for {
+			val, err := q.DequeueWait(ctx)
+			if err != nil {
+				return
+			}
+
+			select {
+			case ch <- val:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,92 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestChannel(t *testing.T) {
+	q := New[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := q.Channel(ctx)
+
+	// Items enqueued before and after the consumer starts are delivered in order
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = q.Enqueue(3)
+	}()
+
+	for _, expected := range []int{1, 2, 3} {
+		select {
+		case val := <-ch:
+			if val != expected {
+				t.Errorf("received %d from channel, want %d", val, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %d", expected)
+		}
+	}
+}
+
+func TestChannelCancel(t *testing.T) {
+	q := New[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch := q.Channel(ctx)
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("received an item after cancellation, want closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel was not closed after cancellation")
+	}
+
+	// The consumer goroutine has exited, so items stay in the queue
+	_ = q.Enqueue(1)
+	time.Sleep(10 * time.Millisecond)
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size after cancellation = %d, want 1", size)
+	}
+}
+
+func TestChannelClose(t *testing.T) {
+	q := New[int]()
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+	_ = q.Close()
+
+	// Remaining items are drained before the channel closes
+	var got []int
+	for val := range q.Channel(context.Background()) {
+		got = append(got, val)
+	}
+
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("received %v from channel, want [1 2]", got)
+	}
+}
+
+func TestShardedChannel(t *testing.T) {
+	q := NewSharded[int](4)
+	for i := 0; i < 10; i++ {
+		_ = q.Enqueue(i)
+	}
+	_ = q.Close()
+
+	count := 0
+	for range q.Channel(context.Background()) {
+		count++
+	}
+
+	if count != 10 {
+		t.Errorf("received %d items from channel, want 10", count)
+	}
+}
@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the source of time for TTL expiry and timeouts.
+//
+// The default clock reads the system time. Tests can supply their own with
+// WithClock to control time deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// AfterFunc calls f in its own goroutine once d has passed, unless the
+	// returned Timer is stopped first.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call scheduled by Clock.AfterFunc.
+type Timer interface {
+	// Stop prevents the call from running, reporting whether it did so.
+	Stop() bool
+}
+
+// realClock is the default Clock, backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// withTimeout returns a context that is cancelled with the cause
+// context.DeadlineExceeded once d has passed on clock.
+func withTimeout(clock Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	timer := clock.AfterFunc(d, func() {
+		cancel(context.DeadlineExceeded)
+	})
+
+	return ctx, func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
@@ -0,0 +1,177 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	when  time.Time
+	f     func()
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Waiting reports the number of timers that have not fired or been stopped.
+func (c *fakeClock) Waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// Advance moves the clock forward by d and runs every timer that falls due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	for _, t := range due {
+		t.f()
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// awaitTimers blocks until clock has n pending timers.
+func awaitTimers(t *testing.T, clock *fakeClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for clock.Waiting() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d pending timers", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithClockExpiry(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring[int](WithClock[int](clock))
+	_ = q.EnqueueWithTTL(1, time.Minute)
+	_ = q.EnqueueWithTTL(2, time.Hour)
+
+	clock.Advance(time.Minute - time.Nanosecond)
+	if val, err := q.Peek(); val != 1 || err != nil {
+		t.Errorf("Peek() before TTL = (%d, %v), want (1, nil)", val, err)
+	}
+
+	// An item expires once its deadline is reached
+	clock.Advance(time.Nanosecond)
+	if val, err := q.Peek(); val != 2 || err != nil {
+		t.Errorf("Peek() at TTL = (%d, %v), want (2, nil)", val, err)
+	}
+}
+
+func TestWithClockDequeueTimeout(t *testing.T) {
+	clock := newFakeClock()
+	q := New[int](WithClock[int](clock))
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := q.DequeueTimeout(time.Minute)
+		errc <- err
+	}()
+	awaitTimers(t, clock, 1)
+
+	clock.Advance(time.Minute)
+	err := <-errc
+	if !errors.Is(err, ErrUnderflow) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DequeueTimeout() = %v, want %v and %v", err, ErrUnderflow, context.DeadlineExceeded)
+	}
+}
+
+func TestWithClockEnqueueTimeout(t *testing.T) {
+	clock := newFakeClock()
+	q := New[int](WithClock[int](clock), WithCapacity[int](1))
+	_ = q.Enqueue(1)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- q.EnqueueTimeout(2, time.Minute)
+	}()
+	awaitTimers(t, clock, 1)
+
+	clock.Advance(time.Second)
+	select {
+	case err := <-errc:
+		t.Fatalf("EnqueueTimeout() returned %v before its deadline", err)
+	default:
+	}
+
+	clock.Advance(time.Minute)
+	err := <-errc
+	if !errors.Is(err, ErrOverflow) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("EnqueueTimeout() = %v, want %v and %v", err, ErrOverflow, context.DeadlineExceeded)
+	}
+}
+
+func TestWithClockTimerStopped(t *testing.T) {
+	clock := newFakeClock()
+	q := New[int](WithClock[int](clock))
+	_ = q.Enqueue(1)
+
+	// A timeout that is not needed leaves no timer behind
+	if val, err := q.DequeueTimeout(time.Minute); val != 1 || err != nil {
+		t.Errorf("DequeueTimeout() = (%d, %v), want (1, nil)", val, err)
+	}
+	if n := clock.Waiting(); n != 0 {
+		t.Errorf("pending timers after DequeueTimeout() = %d, want 0", n)
+	}
+}
+
+func TestWithClockNilPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("WithClock(nil) did not panic")
+		}
+	}()
+	WithClock[int](nil)
+}
@@ -1,5 +1,12 @@
 package queue
 
+import (
+	"io"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
 // Option represents a configuration function that can be applied to a queue during creation.
 // Options follow the functional options pattern for flexible and extensible configuration.
 type Option[T any] func(*queue[T])
@@ -10,6 +17,20 @@ const (
 	UnlimitedCapacity = -1
 )
 
+// ShrinkPolicy controls how SetCapacity behaves when the new capacity is
+// smaller than the number of items currently in the queue.
+type ShrinkPolicy int
+
+const (
+	// ShrinkReject makes SetCapacity return ErrCapacityTooSmall, leaving the
+	// queue unchanged. This is the default policy.
+	ShrinkReject ShrinkPolicy = iota
+
+	// ShrinkDropOldest makes SetCapacity discard items from the front of the
+	// queue until the remaining items fit.
+	ShrinkDropOldest
+)
+
 // WithCapacity returns an option that sets the maximum capacity of the queue.
 //
 // The capacity must be >= 0 or equal to UnlimitedCapacity (-1).
@@ -36,3 +57,794 @@ func WithCapacity[T any](cap int) Option[T] {
 		q.capacity = cap
 	}
 }
+
+// WithCapacityChecked is like WithCapacity, but returns ErrInvalidCapacity
+// instead of panicking if cap < UnlimitedCapacity, for capacities that come
+// from configuration or user input.
+//
+// Example:
+//
+//	opt, err := queue.WithCapacityChecked[Job](cfg.QueueSize)
+//	if err != nil {
+//		return fmt.Errorf("queue size %d: %w", cfg.QueueSize, err)
+//	}
+//	q := queue.New[Job](opt)
+func WithCapacityChecked[T any](cap int) (Option[T], error) {
+	if cap < UnlimitedCapacity {
+		return nil, ErrInvalidCapacity
+	}
+
+	return WithCapacity[T](cap), nil
+}
+
+// WithCircular returns an option that turns the queue into a fixed-size
+// rolling buffer holding the newest items.
+//
+// When the queue is full, Enqueue silently overwrites the front item instead
+// of returning ErrOverflow, so the queue always retains the most recently
+// added items. Peek and Dequeue still return the oldest retained item.
+// Blocking enqueues never wait, since there is always room.
+//
+// A circular queue requires a finite positive capacity, set with WithCapacity
+// in any order. SetCapacity rejects 0 and UnlimitedCapacity with
+// ErrInvalidCapacity.
+//
+// Example:
+//
+//	q := queue.New[int](queue.WithCapacity[int](3), queue.WithCircular[int]())
+//	for i := 1; i <= 5; i++ {
+//		q.Enqueue(i) // Never returns ErrOverflow
+//	}
+//	val, err := q.Dequeue() // returns 3, nil
+//
+// Panics if the queue does not have a finite positive capacity.
+func WithCircular[T any]() Option[T] {
+	return func(q *queue[T]) {
+		q.circular = true
+	}
+}
+
+// WithLIFO returns an option that turns the queue into a stack: Enqueue and
+// its variants add items at the front, so Dequeue and Peek return the most
+// recently added item first. This breaks the FIFO order the rest of this
+// package documents: All, ForEach and the other traversals visit items
+// newest first, Back and DequeueBack refer to the oldest item, and
+// EnqueueDedupBack compares with the newest. Merge and Transfer add the moved
+// items one at a time, as Enqueue would, while Split, CopyInto and LoadFrom
+// keep the dequeue order of the items they copy. Capacity limits, overflow
+// and the other options apply as usual.
+//
+// Example:
+//
+//	q := queue.New[int](queue.WithLIFO[int]())
+//	q.Enqueue(1)
+//	q.Enqueue(2)
+//	val, err := q.Dequeue() // returns 2, nil
+//
+// Panics if combined with WithCircular, or given to NewPriority or NewSharded.
+func WithLIFO[T any]() Option[T] {
+	return func(q *queue[T]) {
+		q.lifo = true
+	}
+}
+
+// WithShrinkPolicy returns an option that sets how SetCapacity handles a new
+// capacity smaller than the current number of items.
+//
+// Parameters:
+//   - policy: ShrinkReject (default) or ShrinkDropOldest
+//
+// Example:
+//
+//	q := queue.New[int](queue.WithShrinkPolicy[int](queue.ShrinkDropOldest))
+//	q.Enqueue(1)
+//	q.Enqueue(2)
+//	q.Enqueue(3)
+//	q.SetCapacity(2) // Drops 1, keeps 2 and 3
+//
+// Panics if policy is not a known ShrinkPolicy.
+func WithShrinkPolicy[T any](policy ShrinkPolicy) Option[T] {
+	return func(q *queue[T]) {
+		if policy != ShrinkReject && policy != ShrinkDropOldest {
+			panic("cannot specify unknown shrink policy")
+		}
+		q.shrinkPolicy = policy
+	}
+}
+
+// WithInitialCapacity returns an option that preallocates room for n items
+// without limiting how many items the queue can hold.
+//
+// This avoids repeated reallocation when the expected size of an unlimited
+// queue is known in advance. When combined with WithCapacity, the initial
+// capacity is clamped to the maximum capacity, regardless of option order.
+//
+// Parameters:
+//   - n: The number of items to preallocate room for
+//   - Use 0 to allocate lazily
+//
+// Example:
+//
+//	q := queue.New[int](queue.WithInitialCapacity[int](10000)) // Unlimited, room for 10k items
+//	q := queue.New[int](
+//		queue.WithCapacity[int](100),
+//		queue.WithInitialCapacity[int](500), // Clamped to 100
+//	)
+//
+// Panics if n < 0.
+func WithInitialCapacity[T any](n int) Option[T] {
+	return func(q *queue[T]) {
+		if n < 0 {
+			panic("cannot specify negative initial capacity")
+		}
+		q.initCap = n
+	}
+}
+
+// WithGrowthFactor returns an option that sets how much the backing storage
+// grows when a queue runs out of room: its size is multiplied by factor,
+// adding at least one slot. The default factor is 2.
+//
+// A smaller factor wastes less memory on large item types at the cost of
+// more frequent reallocation during bursts. Storage never grows beyond a
+// finite capacity, so the factor matters most for unlimited queues.
+//
+// Example:
+//
+//	q := queue.New[Frame](queue.WithGrowthFactor[Frame](1.25)) // Grow by 25%
+//
+// Panics if factor <= 1 or is not finite.
+func WithGrowthFactor[T any](factor float64) Option[T] {
+	return func(q *queue[T]) {
+		if math.IsNaN(factor) || math.IsInf(factor, 0) {
+			panic("cannot specify non-finite growth factor")
+		}
+		if factor <= 1 {
+			panic("cannot specify growth factor of 1 or less")
+		}
+		q.growth = factor
+	}
+}
+
+// WithAutoShrink returns an option that makes dequeues release memory on
+// their own, instead of waiting for a call to Compact: whenever a dequeue
+// leaves fewer than ratio of the backing storage's slots in use, the storage
+// is halved, repeatedly if need be, keeping room for every item.
+//
+// This trades an occasional copy of the remaining items for memory use that
+// follows the queue's size after a burst. Storage never shrinks below a small
+// minimum, and grows again as usual when items arrive.
+//
+// Example:
+//
+//	// Give memory back once less than a quarter of it is in use
+//	q := queue.New[Event](queue.WithAutoShrink[Event](0.25))
+//
+// Panics unless 0 < ratio < 1.
+func WithAutoShrink[T any](ratio float64) Option[T] {
+	if !(ratio > 0 && ratio < 1) {
+		panic("cannot specify auto-shrink ratio outside (0, 1)")
+	}
+	return func(q *queue[T]) {
+		q.shrinkRatio = ratio
+	}
+}
+
+// WithOnEnqueue returns an option that registers a hook invoked after every
+// successful Enqueue.
+//
+// The hook receives the item that was added. It is not called when Enqueue
+// fails (e.g., with ErrOverflow). The option may be given multiple times;
+// hooks are invoked in registration order.
+//
+// Concurrency guarantees:
+//   - Hooks run on the goroutine that called Enqueue, after the queue lock is released
+//   - Hooks may therefore call back into the queue without deadlocking
+//   - Hooks may run concurrently when the queue is used from multiple goroutines
+//   - By the time a hook runs, other operations may already have observed or removed the item
+//
+// Example:
+//
+//	q := queue.New[int](queue.WithOnEnqueue[int](func(v int) {
+//		log.Printf("enqueued %d", v)
+//	}))
+//
+// Panics if fn is nil.
+func WithOnEnqueue[T any](fn func(T)) Option[T] {
+	if fn == nil {
+		panic("cannot register nil enqueue hook")
+	}
+	return func(q *queue[T]) {
+		q.onEnqueue = append(q.onEnqueue, fn)
+	}
+}
+
+// WithOnDequeue returns an option that registers a hook invoked after every
+// successful Dequeue.
+//
+// The hook receives the item that was removed. It is not called when Dequeue
+// fails (e.g., with ErrUnderflow). The option may be given multiple times;
+// hooks are invoked in registration order.
+//
+// Hooks follow the same concurrency guarantees as WithOnEnqueue.
+//
+// Example:
+//
+//	q := queue.New[int](queue.WithOnDequeue[int](func(v int) {
+//		log.Printf("dequeued %d", v)
+//	}))
+//
+// Panics if fn is nil.
+func WithOnDequeue[T any](fn func(T)) Option[T] {
+	if fn == nil {
+		panic("cannot register nil dequeue hook")
+	}
+	return func(q *queue[T]) {
+		q.onDequeue = append(q.onDequeue, fn)
+	}
+}
+
+// WithRecoverHooks returns an option that recovers panics in hooks and passes
+// the recovered value to handler instead of letting it unwind the operation.
+//
+// It covers the hooks registered with WithOnEnqueue, WithOnDequeue,
+// WithOnExpire and WithOnDeadLetter, whichever order the options are given
+// in. Hooks run after the queue lock is released, so without this option a
+// panicking hook leaves the queue consistent but propagates to the caller of
+// the operation, which has by then taken effect, and skips the hooks and
+// items after it. With it, the operation returns normally and the remaining
+// hooks still run. handler runs on the goroutine that ran the hook, once per
+// panic.
+//
+// Example:
+//
+//	q := queue.New[Job](
+//		queue.WithOnEnqueue(notify),
+//		queue.WithRecoverHooks[Job](func(r any) {
+//			log.Printf("queue hook panicked: %v", r)
+//		}),
+//	)
+//
+// Panics if handler is nil.
+func WithRecoverHooks[T any](handler func(any)) Option[T] {
+	if handler == nil {
+		panic("cannot register nil hook panic handler")
+	}
+	return func(q *queue[T]) {
+		q.onPanic = handler
+	}
+}
+
+// WithFlushThreshold returns an option that calls fn whenever the size of the
+// queue reaches n from below, so that a batch writer can drain the queue at a
+// high-water mark instead of polling Size.
+//
+// fn runs once per crossing: it is not called again until the size has
+// dropped below n and then reached it once more. It receives the queue and
+// is called after the lock is released, by the goroutine whose operation
+// made the size reach n, so it may drain the queue itself. A queue returned
+// by Split that starts at or above n calls fn on its first operation. A
+// sharded queue checks its aggregate size.
+//
+// Example:
+//
+//	q := queue.New[Event](queue.WithFlushThreshold(1000, func(q queue.Queue[Event]) {
+//		batch := q.Drain()
+//		go writeBatch(batch)
+//	}))
+//
+// Panics if n < 1 or fn is nil.
+func WithFlushThreshold[T any](n int, fn func(Queue[T])) Option[T] {
+	if n < 1 {
+		panic("cannot specify non-positive flush threshold")
+	}
+	if fn == nil {
+		panic("cannot register nil flush callback")
+	}
+	return func(q *queue[T]) {
+		q.onFlush = fn
+		q.flushAt = n
+	}
+}
+
+// WithMaxBytes returns an option that limits the total size of the items in
+// the queue, as measured by sizeOf, instead of or in addition to their number.
+//
+// An enqueue that would take the total above max fails with ErrOverflow, or
+// evicts front items of a circular queue until the new item fits. An item
+// larger than max is always rejected. When combined with WithCapacity, both
+// limits are enforced. Remaining still reports free item slots.
+//
+// Parameters:
+//   - max: Maximum total size; must not be negative
+//   - sizeOf: Function returning the size of an item; called under the queue lock
+//
+// Example:
+//
+//	q := queue.New[string](queue.WithMaxBytes(1024, func(s string) int {
+//		return len(s)
+//	}))
+//
+// Panics if max < 0 or sizeOf is nil.
+func WithMaxBytes[T any](max int, sizeOf func(T) int) Option[T] {
+	if max < 0 {
+		panic("cannot specify negative max bytes")
+	}
+	if sizeOf == nil {
+		panic("cannot specify nil size function")
+	}
+	return func(q *queue[T]) {
+		q.maxBytes = max
+		q.sizeOf = sizeOf
+	}
+}
+
+// WithDedup returns an option that keeps at most one item per key in the
+// queue, for event queues that must not hold two identical pending items.
+//
+// An enqueue of an item whose key, as returned by key, matches that of an
+// item already queued fails with ErrDuplicate, or returns false from
+// TryEnqueue, and leaves the queue unchanged. Operations that add several
+// items at once, such as EnqueueFrontAll and Merge, fail without adding any
+// if two of them share a key or one is already queued. ReplaceFront and
+// ReplaceBack fail with ErrDuplicate if another item has the key of the new
+// value. Once an item is dequeued or otherwise removed, its key can be
+// enqueued again.
+//
+// The keys of the queued items are kept in a set updated as items come and
+// go, which trades memory proportional to Size for O(1) checks. key is called
+// under the queue lock and must not call back into the queue.
+//
+// Example:
+//
+//	q := queue.New[Event](queue.WithDedup(func(e Event) string { return e.ID }))
+//	q.Enqueue(Event{ID: "a"})
+//	err := q.Enqueue(Event{ID: "a"}) // Returns ErrDuplicate
+//
+// Panics if key is nil.
+func WithDedup[T any, K comparable](key func(T) K) Option[T] {
+	if key == nil {
+		panic("cannot specify nil dedup key function")
+	}
+	return func(q *queue[T]) {
+		q.dedup = newKeys(key)
+	}
+}
+
+// WithResultPool returns an option that makes Drain, PeekN, Snapshot and
+// DequeueBatchWait take the slices they return from a sync.Pool, so that hot
+// loops that call them repeatedly stop allocating a new slice each time.
+//
+// A pooled slice is borrowed: the caller owns it until it passes it to
+// Release, after which the queue may hand the same storage to another call.
+// Using a slice after releasing it is a use-after-free bug, so release it
+// only once its items are no longer needed. Slices that are never released
+// are garbage collected as usual, so releasing is an optimization, not an
+// obligation.
+//
+// The option only affects queues created by New, NewDeque, NewPriority and
+// NewBlocking, and queues split from them, which share the pool. It has no
+// effect on other queues, for which Release does nothing.
+//
+// Example:
+//
+//	q := queue.New[int](queue.WithResultPool[int]())
+//	items := q.Drain()
+//	sum(items)
+//	queue.Release(q, items) // items must not be used after this
+func WithResultPool[T any]() Option[T] {
+	return func(q *queue[T]) {
+		q.results = new(resultPool[T])
+	}
+}
+
+// WithKeyIndex returns an option that maintains an index of the keys of the
+// queued items, as returned by key, so that ContainsKey can tell whether an
+// item with a given key is queued in O(1) instead of scanning the queue.
+//
+// Unlike WithDedup, several items may share a key: the index counts the
+// items with each key and is updated as items come and go, including through
+// Remove, Filter and Reset. This trades memory proportional to the number of
+// distinct keys for fast lookups. key is called under the queue lock and must
+// not call back into the queue.
+//
+// Example:
+//
+//	q := queue.New[Job](queue.WithKeyIndex(func(j Job) string { return j.ID }))
+//	q.Enqueue(Job{ID: "a"})
+//	queue.ContainsKey(q, "a") // true
+//
+// Panics if key is nil.
+func WithKeyIndex[T any, K comparable](key func(T) K) Option[T] {
+	if key == nil {
+		panic("cannot specify nil key index function")
+	}
+	return func(q *queue[T]) {
+		q.index = newCounts(key)
+	}
+}
+
+// WithChangeLog returns an option that logs the last n items added to and
+// removed from the queue, so that Changes can report what changed since a
+// version returned by Version or Snapshot.
+//
+// Each item added or removed takes one entry, and the oldest entries are
+// dropped once n are logged, so n bounds both the memory used and how far
+// back Changes can look: after n more additions and removals, a version is
+// too old. Reset and other operations that remove every item take one entry
+// per item removed.
+//
+// Example:
+//
+//	q := queue.New[int](queue.WithChangeLog[int](1000))
+//	_, version := q.Snapshot()
+//	q.Enqueue(1)
+//	added, _, _ := queue.Changes(q, version) // [1]
+//
+// Panics if n < 1.
+func WithChangeLog[T any](n int) Option[T] {
+	if n < 1 {
+		panic("cannot specify change log size less than 1")
+	}
+	return func(q *queue[T]) {
+		q.changes = newChangeLog[T](n)
+	}
+}
+
+// WithRejectNil returns an option that makes enqueues fail with ErrNilValue
+// when given a nil value.
+//
+// T must be a type that can be nil: a pointer, interface, map, slice, channel
+// or function type. The type is inspected once when the option is created, so
+// the per-item check costs no reflection.
+//
+// Example:
+//
+//	q := queue.New[*Job](queue.WithRejectNil[*Job]())
+//	err := q.Enqueue(nil) // Returns ErrNilValue
+//
+// Panics if T cannot be nil.
+func WithRejectNil[T any]() Option[T] {
+	isNil := nilCheck[T]()
+	if isNil == nil {
+		panic("cannot reject nil values of a non-nilable type")
+	}
+	return func(q *queue[T]) {
+		q.isNil = isNil
+	}
+}
+
+// WithValidator returns an option that checks every item before it is added,
+// rejecting it if fn returns an error.
+//
+// Enqueue and every other operation that adds items, including
+// EnqueueFrontAll, Merge, CopyInto, ReplaceFront and ReplaceBack, return
+// fn's error wrapped with ErrInvalidItem and leave the queue unchanged. Merge
+// and CopyInto check every item before moving or copying any, also when the
+// other queue is not one they can lock together with this one. A
+// rejected item is not counted as rejected in Stats and is not forwarded to
+// the dead-letter queue. The option may be given multiple times; validators
+// run in registration order, and the first error stops the rest. Validators
+// run while the queue is locked, so they must not call back into it.
+//
+// Example:
+//
+//	q := queue.New[Job](queue.WithValidator(func(j Job) error {
+//		if j.ID == "" {
+//			return errors.New("missing ID")
+//		}
+//		return nil
+//	}))
+//	err := q.Enqueue(Job{}) // Returns an error wrapping ErrInvalidItem
+//
+// Panics if fn is nil.
+func WithValidator[T any](fn func(T) error) Option[T] {
+	if fn == nil {
+		panic("cannot register nil validator")
+	}
+	return func(q *queue[T]) {
+		q.validators = append(q.validators, fn)
+	}
+}
+
+// nilCheck returns a function reporting whether a T is nil, or nil if T
+// cannot be nil.
+func nilCheck[T any]() func(T) bool {
+	switch reflect.TypeFor[T]().Kind() {
+	case reflect.Interface:
+		return func(v T) bool {
+			return any(v) == nil
+		}
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Map, reflect.Chan, reflect.Func, reflect.Slice:
+		// Each of these is nil exactly when its first word, the pointer to
+		// its data, is nil
+		return func(v T) bool {
+			return *(*unsafe.Pointer)(unsafe.Pointer(&v)) == nil
+		}
+	default:
+		return nil
+	}
+}
+
+// WithOnExpire returns an option that registers a hook invoked for every item
+// an expiring queue discards because its TTL has passed.
+//
+// Hooks run after the queue lock has been released, in registration order,
+// and follow the same concurrency guarantees as WithOnEnqueue. The option has
+// no effect on queues not created with NewExpiring.
+//
+// Example:
+//
+//	q := queue.NewExpiring[string](queue.WithOnExpire[string](func(s string) {
+//		log.Printf("expired %s", s)
+//	}))
+//
+// Panics if fn is nil.
+func WithOnExpire[T any](fn func(T)) Option[T] {
+	if fn == nil {
+		panic("cannot register nil expire hook")
+	}
+	return func(q *queue[T]) {
+		q.onExpire = append(q.onExpire, fn)
+	}
+}
+
+// WithRateLimit returns an option that paces blocking dequeues to at most
+// perSecond items per second, using a token bucket that holds one token.
+//
+// DequeueWait and the operations built on it, such as Channel, DequeueCtx and
+// the Dequeue of a blocking queue, wait for a token before taking an item,
+// even if items are already waiting. Non-blocking dequeues such as Dequeue
+// and TryDequeue are not paced. The limiter reads time from the queue's
+// Clock, so tests can drive it with WithClock.
+//
+// Example:
+//
+//	// Call the downstream API at most 10 times a second
+//	q := queue.New[Request](queue.WithRateLimit[Request](10))
+//	for req := range q.Channel(ctx) {
+//		send(req)
+//	}
+//
+// Panics if perSecond <= 0 or is not finite.
+func WithRateLimit[T any](perSecond float64) Option[T] {
+	if !(perSecond > 0) || math.IsInf(perSecond, 1) {
+		panic("cannot specify non-positive or non-finite rate limit")
+	}
+	return func(q *queue[T]) {
+		q.limiter = newLimiter(perSecond, 1)
+	}
+}
+
+// WithEnqueueRateLimit returns an option that limits enqueues to perSecond
+// items per second on average, using a token bucket that holds burst tokens.
+//
+// The bucket starts full, so up to burst items can be enqueued at once before
+// the steady rate applies. Enqueue and the operations built on it, such as
+// TryEnqueue, EnqueuePush and EnqueueSlice, return ErrRateLimited when no
+// token is available; EnqueueSlice stops at the first item refused. A refused
+// item is not counted as rejected and is not forwarded to the dead-letter
+// queue. EnqueueWait and the operations built on it, such as EnqueueCtx and
+// the Enqueue of a blocking queue, wait for a token instead. Operations that
+// add several items at once, such as EnqueueFrontAll, EnqueueAllCtx, Merge,
+// CopyInto and CommitReserved, are not limited, nor are LoadFrom and Replay
+// while they fill the queue they return. The limiter reads time from the
+// queue's Clock, so tests can drive it with WithClock.
+//
+// Example:
+//
+//	// Let producers send in bursts of up to 50 jobs, and 100 a second overall
+//	q := queue.New[Job](queue.WithEnqueueRateLimit[Job](100, 50))
+//	if err := q.Enqueue(job); errors.Is(err, queue.ErrRateLimited) {
+//		// Shed load
+//	}
+//
+// Panics if perSecond <= 0 or is not finite, or if burst < 1.
+func WithEnqueueRateLimit[T any](perSecond float64, burst int) Option[T] {
+	if !(perSecond > 0) || math.IsInf(perSecond, 1) {
+		panic("cannot specify non-positive or non-finite rate limit")
+	}
+	if burst < 1 {
+		panic("cannot specify burst less than 1")
+	}
+	return func(q *queue[T]) {
+		q.enqueueLimit = newLimiter(perSecond, burst)
+	}
+}
+
+// WithClock returns an option that makes the queue read time from clock
+// instead of the system clock.
+//
+// The clock drives TTL expiry in expiring queues and the deadlines of
+// EnqueueTimeout and DequeueTimeout. It is mainly useful in tests, where a
+// fake clock lets expiry be exercised without sleeping.
+//
+// Example:
+//
+//	q := queue.NewExpiring[string](queue.WithClock[string](fakeClock))
+//
+// Panics if clock is nil.
+func WithClock[T any](clock Clock) Option[T] {
+	if clock == nil {
+		panic("cannot specify nil clock")
+	}
+	return func(q *queue[T]) {
+		q.clock = clock
+	}
+}
+
+// WithName returns an option that names the queue, for telling queues apart in
+// logs and metrics. The name is returned by Name, reported in Stats and shown
+// by String, and passed to the TraceFunc of WithTracer. Hooks are not passed
+// the name, so capture it when registering them if they need it.
+//
+// Example:
+//
+//	q := queue.New[Job](queue.WithName[Job]("emails"))
+//	fmt.Println(q) // Queue(emails)[len=0/cap=unlimited]: []
+//
+// Panics if name is empty.
+func WithName[T any](name string) Option[T] {
+	if name == "" {
+		panic("cannot specify empty name")
+	}
+	return func(q *queue[T]) {
+		q.name = name
+	}
+}
+
+// WithCopyOnPeek returns an option that makes Peek, Front and Back return
+// copyFn applied to the stored item instead of the item itself.
+//
+// By default these return the stored value, so when T is a slice, map or a
+// struct holding one, a caller mutating the result also mutates the queued
+// item. copyFn should return a deep enough copy to prevent that. It is called
+// under the queue lock and must not call back into the queue. Dequeue and
+// iteration still return the stored values.
+//
+// Example:
+//
+//	q := queue.New[[]byte](queue.WithCopyOnPeek(bytes.Clone))
+//
+// Panics if copyFn is nil.
+func WithCopyOnPeek[T any](copyFn func(T) T) Option[T] {
+	if copyFn == nil {
+		panic("cannot specify nil copy function")
+	}
+	return func(q *queue[T]) {
+		q.copyOnPeek = copyFn
+	}
+}
+
+// WithWAL returns an option that appends a record to w for every item added
+// to the back of the queue and every item removed from its front, so that
+// Replay can rebuild the queue after a crash.
+//
+// Records are written under the queue lock, in the order the operations take
+// effect, with one Write call each; encode turns an item into the bytes
+// logged for it. The log is a best-effort durability layer, not a
+// transaction log:
+//   - An operation succeeds even if its record cannot be encoded or written;
+//     such failures are counted in Stats.LogErrors
+//   - Nothing is synced; wrap w to flush or fsync as often as needed
+//   - Only enqueues at the back and removals from the front are logged. Filter,
+//     Remove, DequeueMatch, Swap, Reverse, Rotate, EnqueueFrontAll, the Deque
+//     methods and Reset change the queue without a record, capacity changes
+//     are not logged, and priority queues do not replay in priority order
+//   - Queues returned by Split are not logged
+//
+// Example:
+//
+//	f, _ := os.OpenFile("jobs.wal", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+//	q := queue.New[Job](queue.WithWAL(f, func(job Job) ([]byte, error) {
+//		return json.Marshal(job)
+//	}))
+//
+// Panics if w or encode is nil.
+func WithWAL[T any](w io.Writer, encode func(T) ([]byte, error)) Option[T] {
+	if w == nil {
+		panic("cannot specify nil log writer")
+	}
+	if encode == nil {
+		panic("cannot specify nil encode function")
+	}
+	return func(q *queue[T]) {
+		q.wal = &wal[T]{w: w, encode: encode}
+	}
+}
+
+// WithTracer returns an option that calls fn around every Enqueue, Dequeue
+// and Peek, so that each operation can be recorded as a tracing span.
+//
+// fn is called when an operation starts and the function it returns when the
+// operation ends, with the number of items affected and the error returned.
+// Both run without the queue lock held, in the calling goroutine. Other
+// methods, including TryEnqueue, TryDequeue and the wait variants, are not
+// traced; Front is traced as Peek and EnqueueWithTTL as Enqueue. See
+// TraceFunc for an example adapter.
+//
+// Example:
+//
+//	q := queue.New[int](queue.WithTracer[int](func(name, op string) func(int, error) {
+//		start := time.Now()
+//		return func(n int, err error) {
+//			log.Printf("%s %s: %d items in %v (err=%v)", name, op, n, time.Since(start), err)
+//		}
+//	}))
+//
+// Panics if fn is nil.
+func WithTracer[T any](fn TraceFunc) Option[T] {
+	if fn == nil {
+		panic("cannot specify nil tracer")
+	}
+	return func(q *queue[T]) {
+		q.tracer = fn
+	}
+}
+
+// WithOnDeadLetter returns an option that registers a hook invoked for every
+// item a retry queue gives up on, either because it was nacked after its last
+// retry or because it could not be requeued.
+//
+// Hooks run in the goroutine calling Nack, in registration order, without any
+// lock held. The option has no effect on queues not created with
+// NewRetryQueue.
+//
+// Example:
+//
+//	q := queue.NewRetryQueue[Job](3, queue.WithOnDeadLetter(func(job Job) {
+//		failed.Enqueue(job)
+//	}))
+//
+// Panics if fn is nil.
+func WithOnDeadLetter[T any](fn func(T)) Option[T] {
+	if fn == nil {
+		panic("cannot register nil dead-letter hook")
+	}
+	return func(q *queue[T]) {
+		q.onDeadLetter = append(q.onDeadLetter, fn)
+	}
+}
+
+// WithRequeueFront returns an option that makes a retry queue put nacked
+// items at the front, so they are retried before items waiting for their
+// first delivery. By default they go to the back. The option has no effect
+// on queues not created with NewRetryQueue.
+//
+// Example:
+//
+//	q := queue.NewRetryQueue[Job](3, queue.WithRequeueFront[Job]())
+func WithRequeueFront[T any]() Option[T] {
+	return func(q *queue[T]) {
+		q.requeueFront = true
+	}
+}
+
+// WithDeadLetter returns an option that captures items the queue would
+// otherwise lose by enqueueing them into dlq.
+//
+// An item is forwarded when an enqueue fails with ErrOverflow, when a circular
+// queue overwrites its oldest item, and when SetCapacity drops items under
+// ShrinkDropOldest. EnqueueSlice forwards only the item that overflowed, since
+// the items after it are never offered.
+//
+// Items are forwarded after the queue lock is released, so dlq may be any
+// queue except the queue itself. If dlq cannot take an item, its own policy
+// applies: a full dlq rejects it, or a circular one overwrites its oldest item.
+//
+// Example:
+//
+//	dlq := queue.New[Job]()
+//	q := queue.New[Job](queue.WithCapacity[Job](100), queue.WithDeadLetter(dlq))
+//
+// Panics if dlq is nil.
+func WithDeadLetter[T any](dlq Queue[T]) Option[T] {
+	if dlq == nil {
+		panic("cannot specify nil dead-letter queue")
+	}
+	return func(q *queue[T]) {
+		q.deadLetter = func(val T) {
+			_ = dlq.Enqueue(val)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package queue
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDeadLetterRejectNew(t *testing.T) {
+	dlq := New[int]()
+	q := New(WithCapacity[int](2), WithDeadLetter(dlq))
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+
+	if err := q.Enqueue(3); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Enqueue() into full queue = %v, want %v", err, ErrOverflow)
+	}
+	if ok := q.TryEnqueue(4); ok {
+		t.Error("TryEnqueue() into full queue = true, want false")
+	}
+	// EnqueueSlice stops at the item that overflows; the rest are never offered
+	if n, _ := q.EnqueueSlice([]int{5, 6}); n != 0 {
+		t.Errorf("EnqueueSlice() into full queue added %d items, want 0", n)
+	}
+	if err := q.EnqueueFrontAll(7, 8); !errors.Is(err, ErrOverflow) {
+		t.Errorf("EnqueueFrontAll() into full queue = %v, want %v", err, ErrOverflow)
+	}
+
+	if got := slices.Collect(dlq.All()); !slices.Equal(got, []int{3, 4, 5, 7, 8}) {
+		t.Errorf("dead-letter queue holds %v, want [3 4 5 7 8]", got)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("queue holds %v, want [1 2]", got)
+	}
+}
+
+func TestDeadLetterDropOldest(t *testing.T) {
+	dlq := New[int]()
+	q := New(WithCapacity[int](2), WithCircular[int](), WithDeadLetter(dlq))
+	for i := 1; i <= 5; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	if got := slices.Collect(dlq.All()); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("dead-letter queue holds %v, want [1 2 3]", got)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{4, 5}) {
+		t.Errorf("queue holds %v, want [4 5]", got)
+	}
+}
+
+func TestDeadLetterShrink(t *testing.T) {
+	dlq := New[int]()
+	q := New(WithShrinkPolicy[int](ShrinkDropOldest), WithDeadLetter(dlq))
+	_, _ = q.EnqueueSlice([]int{1, 2, 3, 4})
+
+	if err := q.SetCapacity(1); err != nil {
+		t.Fatalf("SetCapacity(1) = %v, want nil", err)
+	}
+	if got := slices.Collect(dlq.All()); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("dead-letter queue holds %v, want [1 2 3]", got)
+	}
+}
+
+func TestDeadLetterFull(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option[int]
+		want []int
+	}{
+		// A full reject-new dead-letter queue keeps what it already has
+		{name: "reject new", opts: []Option[int]{WithCapacity[int](2)}, want: []int{2, 3}},
+		// A full circular one keeps the most recent drops
+		{name: "drop oldest", opts: []Option[int]{WithCapacity[int](2), WithCircular[int]()}, want: []int{4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dlq := New(tt.opts...)
+			q := New(WithCapacity[int](1), WithDeadLetter(dlq))
+			for i := 1; i <= 5; i++ {
+				_ = q.Enqueue(i)
+			}
+			if got := slices.Collect(dlq.All()); !slices.Equal(got, tt.want) {
+				t.Errorf("dead-letter queue holds %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadLetterChained(t *testing.T) {
+	// Items forwarded to a full dead-letter queue can be forwarded again
+	last := New[int]()
+	dlq := New(WithCapacity[int](1), WithDeadLetter(last))
+	q := New(WithCapacity[int](1), WithDeadLetter(dlq))
+	for i := 1; i <= 4; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	if got := slices.Collect(last.All()); !slices.Equal(got, []int{3, 4}) {
+		t.Errorf("last queue in chain holds %v, want [3 4]", got)
+	}
+}
+
+func TestShardedDeadLetter(t *testing.T) {
+	dlq := New[int]()
+	s := NewSharded(2, WithCapacity[int](2), WithDeadLetter(dlq))
+	_, _ = s.EnqueueSlice([]int{1, 2, 3})
+	_ = s.EnqueueFrontAll(4)
+
+	if got := slices.Collect(dlq.All()); !slices.Equal(got, []int{3, 4}) {
+		t.Errorf("dead-letter queue holds %v, want [3 4]", got)
+	}
+
+	dlq = New[int]()
+	s = NewSharded(2, WithCapacity[int](2), WithCircular[int](), WithDeadLetter(dlq))
+	for i := 1; i <= 3; i++ {
+		_ = s.Enqueue(i)
+	}
+	if size := dlq.Size(); size != 1 {
+		t.Errorf("dead-letter queue size after eviction = %d, want 1", size)
+	}
+	if got := slices.Concat(slices.Collect(dlq.All()), slices.Collect(s.All())); len(got) != 3 {
+		t.Errorf("items across queues = %v, want 3 items", got)
+	}
+}
+
+func TestExpiringDeadLetter(t *testing.T) {
+	dlq := New[int]()
+	e := NewExpiring(WithCapacity[int](1), WithDeadLetter(dlq))
+	_ = e.EnqueueWithTTL(1, longTTL)
+	_ = e.EnqueueWithTTL(2, longTTL)
+
+	if got := slices.Collect(dlq.All()); !slices.Equal(got, []int{2}) {
+		t.Errorf("dead-letter queue holds %v, want [2]", got)
+	}
+}
+
+func TestWithDeadLetterPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("WithDeadLetter(nil) did not panic")
+		}
+	}()
+	WithDeadLetter[int](nil)
+}
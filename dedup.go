@@ -0,0 +1,175 @@
+package queue
+
+// keySet records the keys of the items held by a queue created with
+// WithDedup or WithKeyIndex. It is guarded by the lock of the owning queue.
+type keySet[T any] interface {
+	// has reports whether an item with the key of val is recorded.
+	has(val T) bool
+
+	// add records the key of val.
+	add(val T)
+
+	// remove forgets the key of val.
+	remove(val T)
+
+	// clear forgets every key.
+	clear()
+
+	// len returns the number of items whose keys are recorded.
+	len() int
+
+	// fresh returns an empty set with the same key function.
+	fresh() keySet[T]
+}
+
+// keys is the keySet of items of type T with keys of type K.
+type keys[T any, K comparable] struct {
+	key func(T) K
+	set map[K]struct{}
+}
+
+func newKeys[T any, K comparable](key func(T) K) *keys[T, K] {
+	return &keys[T, K]{key: key, set: make(map[K]struct{})}
+}
+
+func (k *keys[T, K]) has(val T) bool {
+	_, ok := k.set[k.key(val)]
+	return ok
+}
+
+func (k *keys[T, K]) add(val T) {
+	k.set[k.key(val)] = struct{}{}
+}
+
+func (k *keys[T, K]) remove(val T) {
+	delete(k.set, k.key(val))
+}
+
+func (k *keys[T, K]) clear() {
+	clear(k.set)
+}
+
+func (k *keys[T, K]) len() int {
+	return len(k.set)
+}
+
+func (k *keys[T, K]) fresh() keySet[T] {
+	return newKeys(k.key)
+}
+
+// fieldKeys is a keySet of W values keyed by the T that field returns a
+// pointer to, for queues built with adapt.
+type fieldKeys[T, W any] struct {
+	keys  keySet[T]
+	field func(*W) *T
+}
+
+func (f *fieldKeys[T, W]) has(w W) bool {
+	return f.keys.has(*f.field(&w))
+}
+
+func (f *fieldKeys[T, W]) add(w W) {
+	f.keys.add(*f.field(&w))
+}
+
+func (f *fieldKeys[T, W]) remove(w W) {
+	f.keys.remove(*f.field(&w))
+}
+
+func (f *fieldKeys[T, W]) clear() {
+	f.keys.clear()
+}
+
+func (f *fieldKeys[T, W]) len() int {
+	return f.keys.len()
+}
+
+func (f *fieldKeys[T, W]) fresh() keySet[W] {
+	return &fieldKeys[T, W]{keys: f.keys.fresh(), field: f.field}
+}
+
+// duplicate reports whether the queue skips duplicates and already holds an
+// item with the key of val. The caller must hold the lock.
+func (q *queue[T]) duplicate(val T) bool {
+	return q.dedup != nil && q.dedup.has(val)
+}
+
+// duplicates reports whether the queue skips duplicates and two of vals share
+// a key, or, if held is set, one of them shares a key with an item already
+// held. The caller must hold the lock.
+func (q *queue[T]) duplicates(vals []T, held bool) bool {
+	if q.dedup == nil {
+		return false
+	}
+
+	seen := q.dedup.fresh()
+	for _, val := range vals {
+		if held && q.dedup.has(val) || seen.has(val) {
+			return true
+		}
+		seen.add(val)
+	}
+
+	return false
+}
+
+// track records the key of val, which has just been added, in the key sets
+// of the queue. The caller must hold the write lock.
+func (q *queue[T]) track(val T) {
+	if q.dedup != nil {
+		q.dedup.add(val)
+	}
+	if q.index != nil {
+		q.index.add(val)
+	}
+	if q.changes != nil {
+		q.changes.record(val, true)
+	}
+}
+
+// untrack forgets the key of val, which has just been removed, in the key
+// sets of the queue. The caller must hold the write lock.
+func (q *queue[T]) untrack(val T) {
+	if q.dedup != nil {
+		q.dedup.remove(val)
+	}
+	if q.index != nil {
+		q.index.remove(val)
+	}
+	if q.changes != nil {
+		q.changes.record(val, false)
+	}
+}
+
+// untrackAll forgets every key in the key sets of the queue, except those of
+// items held by an open batch, as all of its items are about to be removed.
+// The caller must hold the write lock.
+func (q *queue[T]) untrackAll() {
+	if q.changes != nil {
+		for i := range q.items.len() {
+			q.changes.record(q.items.at(i), false)
+		}
+	}
+	for _, keys := range []keySet[T]{q.dedup, q.index} {
+		if keys == nil {
+			continue
+		}
+		keys.clear()
+		for _, val := range q.held {
+			keys.add(val)
+		}
+	}
+}
+
+// keySets returns the key sets of the queue, for checking their consistency.
+func (q *queue[T]) keySets() map[string]keySet[T] {
+	sets := make(map[string]keySet[T], 2)
+	if q.dedup != nil {
+		sets["dedup"] = q.dedup
+	}
+	if q.index != nil {
+		sets["index"] = q.index
+	}
+
+	return sets
+}
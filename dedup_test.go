@@ -0,0 +1,136 @@
+package queue
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+type event struct {
+	id    string
+	value int
+}
+
+func eventID(e event) string { return e.id }
+
+func TestWithDedup(t *testing.T) {
+	q := New[event](WithDedup(eventID))
+	keys := q.(*queue[event]).dedup
+
+	if err := q.Enqueue(event{"a", 1}); err != nil {
+		t.Fatalf("Enqueue(a) = %v, want nil", err)
+	}
+	if err := q.Enqueue(event{"a", 2}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Enqueue(a) again = %v, want %v", err, ErrDuplicate)
+	}
+	if q.TryEnqueue(event{"a", 3}) {
+		t.Error("TryEnqueue(a) again = true, want false")
+	}
+	n, err := q.EnqueueSlice([]event{{"b", 1}, {"a", 4}, {"c", 1}})
+	if n != 1 || !errors.Is(err, ErrDuplicate) {
+		t.Errorf("EnqueueSlice(b, a, c) = (%d, %v), want (1, %v)", n, err, ErrDuplicate)
+	}
+
+	if got, want := slices.Collect(q.All()), []event{{"a", 1}, {"b", 1}}; !slices.Equal(got, want) {
+		t.Errorf("All() = %v, want %v", got, want)
+	}
+	if got := keys.len(); got != 2 {
+		t.Errorf("Tracked keys = %d, want 2", got)
+	}
+	if st := q.Stats(); st.Rejected != 0 {
+		t.Errorf("Stats().Rejected = %d, want 0 for duplicates", st.Rejected)
+	}
+
+	// Dequeuing an item frees its key
+	if val, err := q.Dequeue(); val.id != "a" || err != nil {
+		t.Fatalf("Dequeue() = (%v, %v), want (a, nil)", val, err)
+	}
+	if got := keys.len(); got != 1 {
+		t.Errorf("Tracked keys after Dequeue = %d, want 1", got)
+	}
+	if err := q.Enqueue(event{"a", 5}); err != nil {
+		t.Errorf("Enqueue(a) after Dequeue = %v, want nil", err)
+	}
+
+	q.Filter(func(e event) bool { return e.id != "b" })
+	if err := q.Enqueue(event{"b", 2}); err != nil {
+		t.Errorf("Enqueue(b) after Filter = %v, want nil", err)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Error(err)
+	}
+
+	q.Reset()
+	if got := keys.len(); got != 0 {
+		t.Errorf("Tracked keys after Reset = %d, want 0", got)
+	}
+
+	t.Run("nil key (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("WithDedup(nil) should panic, but it didn't")
+			}
+		}()
+
+		WithDedup[event, string](nil)
+	})
+}
+
+func TestDedupBatches(t *testing.T) {
+	q := New[event](WithDedup(eventID))
+	_ = q.Enqueue(event{"a", 1})
+
+	if err := q.EnqueueFrontAll(event{"b", 1}, event{"b", 2}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("EnqueueFrontAll(b, b) = %v, want %v", err, ErrDuplicate)
+	}
+	if err := q.EnqueueFrontAll(event{"b", 1}, event{"a", 2}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("EnqueueFrontAll(b, a) = %v, want %v", err, ErrDuplicate)
+	}
+
+	src := New[event]()
+	_, _ = src.EnqueueSlice([]event{{"c", 1}, {"a", 3}})
+	if err := q.Merge(src); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Merge() with a held key = %v, want %v", err, ErrDuplicate)
+	}
+	if size := src.Size(); size != 2 {
+		t.Errorf("Source Size() after failed Merge = %d, want 2", size)
+	}
+
+	_ = q.Enqueue(event{"b", 1})
+	if err := q.ReplaceBack(event{"a", 4}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("ReplaceBack(a) = %v, want %v", err, ErrDuplicate)
+	}
+	if err := q.ReplaceBack(event{"b", 5}); err != nil {
+		t.Errorf("ReplaceBack(b) with its own key = %v, want nil", err)
+	}
+
+	if got, want := slices.Collect(q.All()), []event{{"a", 1}, {"b", 5}}; !slices.Equal(got, want) {
+		t.Errorf("All() = %v, want %v", got, want)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDedupExpiring(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring[event](WithDedup(eventID), WithClock[event](clock))
+
+	_ = q.EnqueueWithTTL(event{"a", 1}, time.Second)
+	if err := q.Enqueue(event{"a", 2}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Enqueue(a) while live = %v, want %v", err, ErrDuplicate)
+	}
+
+	// An expired item does not hold on to its key
+	clock.Advance(2 * time.Second)
+	if err := q.Enqueue(event{"a", 3}); err != nil {
+		t.Errorf("Enqueue(a) once expired = %v, want nil", err)
+	}
+	if got, want := slices.Collect(q.All()), []event{{"a", 3}}; !slices.Equal(got, want) {
+		t.Errorf("All() = %v, want %v", got, want)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,79 @@
+package queue
+
+// Deque is a queue that can also add items at its front and remove them from
+// its back.
+type Deque[T any] interface {
+	Queue[T]
+
+	// EnqueueFront adds an item to the front of the queue, so that it is the
+	// next item Dequeue returns.
+	// Returns ErrOverflow if the queue is at capacity, or ErrClosed if it is closed.
+	EnqueueFront(val T) error
+
+	// EnqueueHead is an alias of EnqueueFront, for callers that name the ends
+	// of the queue head and tail.
+	EnqueueHead(val T) error
+
+	// DequeueBack removes and returns the back item, the one Back returns.
+	// Returns ErrUnderflow if the queue is empty.
+	DequeueBack() (T, error)
+}
+
+// NewDeque creates a double-ended queue with the specified options. Apart from
+// the methods of Deque, it behaves exactly like a queue created by New.
+//
+// Neither EnqueueFront nor DequeueBack is recorded in a write-ahead log.
+//
+// Example:
+//
+//	d := queue.NewDeque[int]()
+//	d.Enqueue(2)
+//	d.EnqueueFront(1)
+//	last, _ := d.DequeueBack() // 2
+func NewDeque[T any](opts ...Option[T]) Deque[T] {
+	d := &deque[T]{queue: newQueue(opts...)}
+	d.flushes(d)
+
+	return d
+}
+
+type deque[T any] struct {
+	*queue[T]
+}
+
+func (d *deque[T]) EnqueueFront(val T) error {
+	return d.EnqueueFrontAll(val)
+}
+
+func (d *deque[T]) EnqueueHead(val T) error {
+	return d.EnqueueFront(val)
+}
+
+func (d *deque[T]) DequeueBack() (T, error) {
+	d.mu.Lock()
+	if d.items.len() == 0 {
+		d.mu.Unlock()
+		var zero T
+		return zero, ErrUnderflow
+	}
+	result := d.removeAt(d.items.len() - 1)
+	d.autoShrink()
+	d.stats.Dequeued++
+	d.unlock()
+
+	runHooks(d.onDequeue, result)
+
+	return result, nil
+}
+
+func (d *deque[T]) Split(n int) (Queue[T], error) {
+	head, err := d.queue.Split(n)
+	if err != nil {
+		return nil, err
+	}
+
+	split := &deque[T]{queue: head.(*queue[T])}
+	split.flushes(split)
+
+	return split, nil
+}
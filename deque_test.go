@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDeque(t *testing.T) {
+	var dequeued []int
+	d := NewDeque(WithCapacity[int](3), WithOnDequeue(func(v int) { dequeued = append(dequeued, v) }))
+	_ = d.Enqueue(2)
+	_ = d.Enqueue(3)
+	if err := d.EnqueueFront(1); err != nil {
+		t.Fatalf("EnqueueFront() = %v, want nil", err)
+	}
+	if err := d.EnqueueFront(0); !errors.Is(err, ErrOverflow) {
+		t.Errorf("EnqueueFront() into full deque = %v, want %v", err, ErrOverflow)
+	}
+	if got := slices.Collect(d.All()); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("All() = %v, want [1 2 3]", got)
+	}
+
+	if val, err := d.DequeueBack(); val != 3 || err != nil {
+		t.Errorf("DequeueBack() = (%d, %v), want (3, nil)", val, err)
+	}
+	if val, err := d.Dequeue(); val != 1 || err != nil {
+		t.Errorf("Dequeue() = (%d, %v), want (1, nil)", val, err)
+	}
+	if val, err := d.DequeueBack(); val != 2 || err != nil {
+		t.Errorf("DequeueBack() = (%d, %v), want (2, nil)", val, err)
+	}
+	if _, err := d.DequeueBack(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("DequeueBack() on empty deque = %v, want %v", err, ErrUnderflow)
+	}
+
+	if !slices.Equal(dequeued, []int{3, 1, 2}) {
+		t.Errorf("dequeue hook saw %v, want [3 1 2]", dequeued)
+	}
+	if s := d.Stats(); s.Dequeued != 3 {
+		t.Errorf("Stats().Dequeued = %d, want 3", s.Dequeued)
+	}
+	if err := CheckInvariants[int](d); err != nil {
+		t.Errorf("CheckInvariants() = %v", err)
+	}
+}
+
+func TestDequeEnqueueHead(t *testing.T) {
+	d := NewDeque[int]()
+	_ = d.Enqueue(2)
+	if err := d.EnqueueHead(1); err != nil {
+		t.Fatalf("EnqueueHead() = %v, want nil", err)
+	}
+	if got := slices.Collect(d.All()); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("All() = %v, want [1 2]", got)
+	}
+}
+
+func TestDequeSplit(t *testing.T) {
+	d := NewDeque[int]()
+	_, _ = d.EnqueueSlice([]int{1, 2, 3})
+	head, err := d.Split(2)
+	if err != nil {
+		t.Fatalf("Split(2) = %v, want nil", err)
+	}
+	hd, ok := head.(Deque[int])
+	if !ok {
+		t.Fatal("Split() of a deque did not return a Deque")
+	}
+	if val, _ := hd.DequeueBack(); val != 2 {
+		t.Errorf("DequeueBack() on split head = %d, want 2", val)
+	}
+}
@@ -0,0 +1,65 @@
+package queue
+
+import "slices"
+
+// Equal reports whether a and b hold the same items in the same order, as
+// visited by All.
+//
+// Two queues created with New, NewPriority or NewBlocking are compared atomically, with
+// both locked for the duration of the comparison. Other queues, such as those
+// created with NewSharded, are compared item by item from separate snapshots.
+// Priority queues compare in internal heap order, so two priority queues
+// holding the same items may compare unequal.
+//
+// Example:
+//
+//	a := queue.New[int]()
+//	b := queue.New[int]()
+//	a.Enqueue(1)
+//	b.Enqueue(1)
+//	fmt.Println(queue.Equal(a, b)) // true
+func Equal[T comparable](a, b Queue[T]) bool {
+	return EqualFunc(a, b, func(x, y T) bool {
+		return x == y
+	})
+}
+
+// EqualFunc is like Equal but compares items with eq, for item types that are
+// not comparable.
+func EqualFunc[T any](a, b Queue[T], eq func(T, T) bool) bool {
+	qa, okA := asQueue(a)
+	qb, okB := asQueue(b)
+	if okA && okB {
+		return equalLocked(qa, qb, eq)
+	}
+
+	if a.Size() != b.Size() {
+		return false
+	}
+	return slices.EqualFunc(slices.Collect(a.All()), slices.Collect(b.All()), eq)
+}
+
+// equalLocked compares a and b while holding both read locks.
+func equalLocked[T any](a, b *queue[T], eq func(T, T) bool) bool {
+	if a == b {
+		return true
+	}
+
+	first, second := lockOrder(a, b)
+	first.mu.RLock()
+	defer first.mu.RUnlock()
+	second.mu.RLock()
+	defer second.mu.RUnlock()
+
+	n := a.items.len()
+	if n != b.items.len() {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if !eq(a.items.at(i), b.items.at(i)) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,111 @@
+package queue
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []int
+		want bool
+	}{
+		{name: "equal", a: []int{1, 2, 3}, b: []int{1, 2, 3}, want: true},
+		{name: "both empty", a: nil, b: nil, want: true},
+		{name: "different order", a: []int{1, 2, 3}, b: []int{3, 2, 1}, want: false},
+		{name: "different length", a: []int{1, 2, 3}, b: []int{1, 2}, want: false},
+		{name: "different items", a: []int{1, 2, 3}, b: []int{1, 2, 4}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := New[int](), New[int]()
+			for _, v := range tt.a {
+				_ = a.Enqueue(v)
+			}
+			for _, v := range tt.b {
+				_ = b.Enqueue(v)
+			}
+
+			if got := Equal(a, b); got != tt.want {
+				t.Errorf("Equal(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := Equal(b, a); got != tt.want {
+				t.Errorf("Equal(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+
+			// Sharded queues take the snapshot path
+			s := NewSharded[int](1)
+			for _, v := range tt.b {
+				_ = s.Enqueue(v)
+			}
+			if got := Equal(a, s); got != tt.want {
+				t.Errorf("Equal(%v, sharded %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEqualWrapped(t *testing.T) {
+	// The same logical contents compare equal regardless of ring layout
+	a := New[int](WithCapacity[int](3))
+	b := New[int](WithCapacity[int](3))
+	_ = a.Enqueue(0)
+	_, _ = a.Dequeue()
+	for i := 1; i <= 3; i++ {
+		_ = a.Enqueue(i)
+		_ = b.Enqueue(i)
+	}
+
+	if !Equal(a, b) {
+		t.Error("Equal() = false for queues with the same items, want true")
+	}
+}
+
+func TestEqualSelf(t *testing.T) {
+	q := New[int]()
+	_ = q.Enqueue(1)
+	if !Equal(q, q) {
+		t.Error("Equal(q, q) = false, want true")
+	}
+}
+
+func TestEqualFunc(t *testing.T) {
+	a, b := New[[]int](), New[[]int]()
+	_ = a.Enqueue([]int{1, 2})
+	_ = b.Enqueue([]int{1, 2})
+
+	if !EqualFunc(a, b, slices.Equal[[]int]) {
+		t.Error("EqualFunc() = false for equal slices, want true")
+	}
+
+	_ = b.Enqueue([]int{3})
+	if EqualFunc(a, b, slices.Equal[[]int]) {
+		t.Error("EqualFunc() = true for queues of different length, want false")
+	}
+}
+
+func TestEqualConcurrent(t *testing.T) {
+	a, b := New[int](), New[int]()
+
+	// Opposite argument orders must not deadlock against writers
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if i%2 == 0 {
+					Equal(a, b)
+					_ = a.Enqueue(j)
+				} else {
+					Equal(b, a)
+					_ = b.Enqueue(j)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -11,6 +11,9 @@ var (
 	//   - The current size equals the specified capacity
 	//   - Enqueue() is called on the full queue
 	//
+	// It also occurs when a queue created with WithMaxBytes cannot fit an item
+	// within its byte limit.
+	//
 	// Example:
 	//
 	//	q := queue.New[int](queue.WithCapacity[int](2))
@@ -38,4 +41,333 @@ var (
 	//		fmt.Println("Queue is empty")
 	//	}
 	ErrUnderflow = errors.New("queue underflow")
+
+	// ErrClosed is returned when attempting to add items to a closed queue, or
+	// when waiting for items on a queue that is closed and empty.
+	//
+	// This error occurs when:
+	//   - Enqueue() or a blocking enqueue variant is called after Close()
+	//   - A blocking dequeue variant is waiting, or called, once the queue is closed and empty
+	//   - Close() is called on a queue that is already closed
+	//
+	// Items queued before Close() can still be dequeued.
+	//
+	// Example:
+	//
+	//	q := queue.New[int]()
+	//	q.Close()
+	//	err := q.Enqueue(1) // Returns ErrClosed
+	//	if errors.Is(err, queue.ErrClosed) {
+	//		fmt.Println("Queue is closed")
+	//	}
+	ErrClosed = errors.New("queue closed")
+
+	// ErrInvalidCapacity is returned when attempting to set a capacity that is
+	// neither >= 0 nor UnlimitedCapacity.
+	//
+	// This error occurs when:
+	//   - SetCapacity() is called with a value < UnlimitedCapacity (i.e., < -1)
+	//   - SetCapacity() is called with 0 or UnlimitedCapacity on a circular queue
+	//   - Grow() is called with a negative value
+	//   - WithCapacityChecked() is given a value < UnlimitedCapacity
+	//
+	// The queue's capacity is left unchanged when this error is returned.
+	//
+	// Example:
+	//
+	//	q := queue.New[int]()
+	//	err := q.SetCapacity(-5) // Returns ErrInvalidCapacity
+	//	if errors.Is(err, queue.ErrInvalidCapacity) {
+	//		fmt.Println("Bad capacity")
+	//	}
+	ErrInvalidCapacity = errors.New("queue invalid capacity")
+
+	// ErrCapacityTooSmall is returned when attempting to shrink a queue's
+	// capacity below the number of items it currently holds.
+	//
+	// This error occurs when:
+	//   - The queue uses the default ShrinkReject policy
+	//   - SetCapacity() is called with a value smaller than Size()
+	//
+	// The queue's capacity and contents are left unchanged when this error is returned.
+	//
+	// Example:
+	//
+	//	q := queue.New[int]()
+	//	q.Enqueue(1)
+	//	q.Enqueue(2)
+	//	err := q.SetCapacity(1) // Returns ErrCapacityTooSmall
+	//	if errors.Is(err, queue.ErrCapacityTooSmall) {
+	//		fmt.Println("Drain the queue first")
+	//	}
+	ErrCapacityTooSmall = errors.New("queue capacity too small")
+
+	// ErrNilValue is returned when attempting to add a nil value to a queue
+	// created with WithRejectNil.
+	//
+	// This error occurs when:
+	//   - Enqueue() or any of its variants is called with a nil pointer,
+	//     interface, map, slice, channel or function value
+	//
+	// The queue is left unchanged when this error is returned.
+	//
+	// Example:
+	//
+	//	q := queue.New[*Job](queue.WithRejectNil[*Job]())
+	//	err := q.Enqueue(nil) // Returns ErrNilValue
+	//	if errors.Is(err, queue.ErrNilValue) {
+	//		fmt.Println("Nil job")
+	//	}
+	ErrNilValue = errors.New("queue nil value")
+
+	// ErrInvalidItem is returned when a validator registered with
+	// WithValidator rejects an item.
+	//
+	// This error occurs when:
+	//   - Enqueue() or any other operation that adds items is given an item a validator fails
+	//
+	// The validator's error is wrapped and can be inspected with errors.Is or
+	// errors.As. The queue is left unchanged when this error is returned.
+	//
+	// Example:
+	//
+	//	q := queue.New[int](queue.WithValidator(func(v int) error {
+	//		if v < 0 {
+	//			return errNegative
+	//		}
+	//		return nil
+	//	}))
+	//	err := q.Enqueue(-1) // Returns an error wrapping ErrInvalidItem and errNegative
+	//	if errors.Is(err, queue.ErrInvalidItem) {
+	//		fmt.Println("Rejected:", err)
+	//	}
+	ErrInvalidItem = errors.New("queue invalid item")
+
+	// ErrDuplicate is returned when attempting to add an item to a queue
+	// created with WithDedup that already holds an item with the same key.
+	//
+	// This error occurs when:
+	//   - Enqueue() or any of its variants is called with an item whose key is queued
+	//   - EnqueueFrontAll(), Merge() or CopyInto() is given two items with the same key
+	//   - ReplaceFront() or ReplaceBack() is given a value whose key another item has
+	//
+	// The queue is left unchanged when this error is returned.
+	//
+	// Example:
+	//
+	//	q := queue.New[Event](queue.WithDedup(func(e Event) string { return e.ID }))
+	//	q.Enqueue(Event{ID: "a"})
+	//	err := q.Enqueue(Event{ID: "a"}) // Returns ErrDuplicate
+	//	if errors.Is(err, queue.ErrDuplicate) {
+	//		fmt.Println("Already pending")
+	//	}
+	ErrDuplicate = errors.New("queue duplicate item")
+
+	// ErrRateLimited is returned when attempting to add an item to a queue
+	// created with WithEnqueueRateLimit faster than its rate allows.
+	//
+	// This error occurs when:
+	//   - Enqueue() or a non-blocking variant is called while the token bucket is empty
+	//
+	// The queue is left unchanged when this error is returned. Blocking
+	// variants such as EnqueueWait() wait for a token instead.
+	//
+	// Example:
+	//
+	//	q := queue.New[Job](queue.WithEnqueueRateLimit[Job](10, 1))
+	//	q.Enqueue(a) // OK
+	//	err := q.Enqueue(b) // Returns ErrRateLimited
+	//	if errors.Is(err, queue.ErrRateLimited) {
+	//		fmt.Println("Slow down")
+	//	}
+	ErrRateLimited = errors.New("queue rate limited")
+
+	// ErrVersionTooOld is returned when asking for the changes made to a
+	// queue since a version its change log no longer covers.
+	//
+	// This error occurs when:
+	//   - Changes() is called with a version older than the oldest change retained by WithChangeLog
+	//
+	// Take a fresh Snapshot() and ask for the changes since its version
+	// instead.
+	//
+	// Example:
+	//
+	//	_, _, err := queue.Changes(q, version)
+	//	if errors.Is(err, queue.ErrVersionTooOld) {
+	//		items, version = q.Snapshot()
+	//	}
+	ErrVersionTooOld = errors.New("queue version too old")
+
+	// ErrNegativeCount is returned when an operation is asked to act on a
+	// negative number of items.
+	//
+	// This error occurs when:
+	//   - Split() is called with a value < 0
+	//   - DequeueBatchWait() or PeekN() is called with n < 0
+	//
+	// The queue is left unchanged when this error is returned.
+	//
+	// Example:
+	//
+	//	q := queue.New[int]()
+	//	_, err := q.Split(-1) // Returns ErrNegativeCount
+	//	if errors.Is(err, queue.ErrNegativeCount) {
+	//		fmt.Println("Bad count")
+	//	}
+	ErrNegativeCount = errors.New("queue negative count")
+
+	// ErrIndexOutOfRange is returned when an index into the queue does not
+	// refer to an item.
+	//
+	// This error occurs when:
+	//   - Remove() or Swap() is called with an index < 0 or >= Size()
+	//
+	// The queue is left unchanged when this error is returned.
+	//
+	// Example:
+	//
+	//	q := queue.New[int]()
+	//	q.Enqueue(1)
+	//	_, err := q.Remove(1) // Returns ErrIndexOutOfRange
+	//	if errors.Is(err, queue.ErrIndexOutOfRange) {
+	//		fmt.Println("No such item")
+	//	}
+	ErrIndexOutOfRange = errors.New("queue index out of range")
+
+	// ErrReadOnly is returned when attempting to change a read-only view
+	// created with NewView, other than by removing items.
+	//
+	// This error occurs when:
+	//   - Enqueue() or any of its variants is called on a view
+	//   - ReplaceFront(), ReplaceBack(), Swap(), Merge(), SetCapacity(), Grow()
+	//     or Close() is called on a view
+	//   - CopyInto() is given a view as its destination
+	//
+	// The view and its source are left unchanged when this error is returned.
+	//
+	// Example:
+	//
+	//	v := queue.NewView(q, isUrgent)
+	//	err := v.Enqueue(job) // Returns ErrReadOnly
+	//	if errors.Is(err, queue.ErrReadOnly) {
+	//		q.Enqueue(job) // Add to the source instead
+	//	}
+	ErrReadOnly = errors.New("queue read only")
+
+	// ErrUnsupported is returned when a queue cannot perform an operation
+	// safely given how it is implemented.
+	//
+	// This error occurs when:
+	//   - EnqueueDedupBack(), EnqueueFrontAll(), EnqueuePush() or
+	//     SetCapacity() is called on a queue created with NewSPSC
+	//
+	// The queue is left unchanged when this error is returned.
+	//
+	// Example:
+	//
+	//	q := queue.NewSPSC[int](64)
+	//	err := q.SetCapacity(128) // Returns ErrUnsupported
+	//	if errors.Is(err, queue.ErrUnsupported) {
+	//		fmt.Println("Capacity is fixed")
+	//	}
+	ErrUnsupported = errors.New("queue unsupported operation")
+
+	// ErrNotFound is returned when no item in the queue satisfies a
+	// predicate that an operation needs to match.
+	//
+	// This error occurs when:
+	//   - DequeueMatch() is called on a non-empty queue and no item matches
+	//
+	// The queue is left unchanged when this error is returned.
+	//
+	// Example:
+	//
+	//	_, err := q.DequeueMatch(func(t Task) bool { return t.ID == id })
+	//	if errors.Is(err, queue.ErrNotFound) {
+	//		fmt.Println("Task already started")
+	//	}
+	ErrNotFound = errors.New("queue item not found")
+
+	// ErrUnknownQueue is returned when a MultiQueue is given a name that no
+	// queue is registered under.
+	//
+	// This error occurs when:
+	//   - MultiQueue.Enqueue() is called with a name not passed to AddQueue()
+	//
+	// Example:
+	//
+	//	m := queue.NewMultiQueue[int]()
+	//	err := m.Enqueue("missing", 1) // Returns ErrUnknownQueue
+	//	if errors.Is(err, queue.ErrUnknownQueue) {
+	//		fmt.Println("No such queue")
+	//	}
+	ErrUnknownQueue = errors.New("queue unknown name")
+
+	// ErrDuplicateQueue is returned when a MultiQueue already has a queue
+	// registered under a name.
+	//
+	// This error occurs when:
+	//   - MultiQueue.AddQueue() is called twice with the same name
+	//
+	// The queue registered first is kept.
+	//
+	// Example:
+	//
+	//	m := queue.NewMultiQueue[int]()
+	//	m.AddQueue("jobs", 1, queue.New[int]())
+	//	err := m.AddQueue("jobs", 2, queue.New[int]()) // Returns ErrDuplicateQueue
+	//	if errors.Is(err, queue.ErrDuplicateQueue) {
+	//		fmt.Println("Name taken")
+	//	}
+	ErrDuplicateQueue = errors.New("queue duplicate name")
+
+	// ErrInvalidSnapshot is returned when data read as a queue snapshot is not
+	// one.
+	//
+	// This error occurs when:
+	//   - LoadFrom() reads data that was not written by PersistTo()
+	//   - The snapshot is truncated or an item does not decode into T
+	//
+	// The underlying cause is wrapped and can be inspected with errors.Is or
+	// errors.As.
+	//
+	// Example:
+	//
+	//	_, err := queue.LoadFrom[int](strings.NewReader("garbage"))
+	//	if errors.Is(err, queue.ErrInvalidSnapshot) {
+	//		fmt.Println("Corrupt snapshot")
+	//	}
+	ErrInvalidSnapshot = errors.New("queue invalid snapshot")
+
+	// ErrInvalidLog is returned when data read as a write-ahead log is not one.
+	//
+	// This error occurs when:
+	//   - Replay() reads a record of an unknown kind, or an item that does not decode
+	//   - Replay() reads a dequeue record while the replayed queue is empty
+	//
+	// A record cut short at the end of the log is not an error; Replay()
+	// ignores it.
+	//
+	// Example:
+	//
+	//	_, err := queue.Replay(strings.NewReader("garbage"), decode)
+	//	if errors.Is(err, queue.ErrInvalidLog) {
+	//		fmt.Println("Corrupt log")
+	//	}
+	ErrInvalidLog = errors.New("queue invalid log")
+
+	// ErrInvariantViolated is returned when a queue's internal state is
+	// inconsistent, which indicates a bug in this package.
+	//
+	// This error occurs when:
+	//   - CheckInvariants() finds counters that disagree with the stored items
+	//   - CheckInvariants() finds a removed item still held in storage
+	//
+	// Example:
+	//
+	//	if err := queue.CheckInvariants(q); errors.Is(err, queue.ErrInvariantViolated) {
+	//		t.Fatal(err)
+	//	}
+	ErrInvariantViolated = errors.New("queue invariant violated")
 )
@@ -0,0 +1,1079 @@
+package queue
+
+import (
+	"context"
+	"iter"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Expiring is a queue whose items can carry a time-to-live.
+//
+// Items whose TTL has passed are never returned: Dequeue and Peek discard
+// expired items at the front until they find a live one, traversals such as
+// All and ForEach skip them, and an enqueue into a full queue discards expired
+// items to make room. Size, Remaining and Stats count expired items until they
+// have been discarded; call Purge first for an exact count. Every discarded
+// item is reported to the hooks registered with WithOnExpire.
+type Expiring[T any] interface {
+	Queue[T]
+
+	// EnqueueWithTTL adds an item to the back of the queue that expires once
+	// ttl has passed. A ttl <= 0 means the item never expires, as with Enqueue
+	// on a queue created by NewExpiring.
+	// Returns ErrOverflow if the queue is at capacity, or ErrClosed if it is closed.
+	EnqueueWithTTL(val T, ttl time.Duration) error
+
+	// Purge discards every expired item and returns the number discarded.
+	Purge() int
+}
+
+// NewExpiring creates a queue whose items can be given a time-to-live with
+// EnqueueWithTTL. Items added with Enqueue and its other variants never expire.
+//
+// Expired items are reclaimed lazily while the queue is used, and all at once
+// by Purge; no background goroutine is started.
+//
+// Example:
+//
+//	q := queue.NewExpiring[string](queue.WithOnExpire(func(s string) {
+//		log.Printf("expired %s", s)
+//	}))
+//	q.EnqueueWithTTL("session", time.Minute)
+//
+// Panics if WithWAL is given.
+func NewExpiring[T any](opts ...Option[T]) Expiring[T] {
+	return newExpiring(opts)
+}
+
+func newExpiring[T any](opts []Option[T]) *expiring[T] {
+	base := configure(opts)
+	if base.wal != nil {
+		panic("cannot use a write-ahead log with an expiring queue")
+	}
+	q := adapt(base, func(item *timed[T]) *T {
+		return &item.val
+	})
+	e := &expiring[T]{q: q, onExpire: base.onExpire, onFlush: base.onFlush}
+	e.flushes()
+
+	return e
+}
+
+// timed is an item of an expiring queue. A zero deadline never expires.
+type timed[T any] struct {
+	val      T
+	deadline time.Time
+}
+
+// expiredAt reports whether the item has expired at now.
+func (t timed[T]) expiredAt(now time.Time) bool {
+	return !t.deadline.IsZero() && !now.Before(t.deadline)
+}
+
+type expiring[T any] struct {
+	q        *queue[timed[T]]
+	onExpire []func(T)
+	onFlush  func(Queue[T])
+	window   time.Duration // TTL of items added without one; 0 unless created by NewRolling
+}
+
+// stamp returns val as a new item that expires once ttl has passed, or never
+// if ttl <= 0.
+func (e *expiring[T]) stamp(val T, ttl time.Duration) timed[T] {
+	item := timed[T]{val: val}
+	if ttl > 0 {
+		item.deadline = e.q.clock.Now().Add(ttl)
+	}
+
+	return item
+}
+
+// flushes makes the flush callback of the underlying queue, if there is one,
+// receive e.
+func (e *expiring[T]) flushes() {
+	if e.onFlush != nil {
+		e.q.flush = func() { e.onFlush(e) }
+	}
+}
+
+func (e *expiring[T]) Enqueue(val T) error {
+	return e.EnqueueWithTTL(val, e.window)
+}
+
+// EnqueueWithTTL is traced as Enqueue.
+func (e *expiring[T]) EnqueueWithTTL(val T, ttl time.Duration) (err error) {
+	if e.q.tracer != nil {
+		end := e.q.tracer(e.q.name, OpEnqueue)
+		defer func() { end(traced(err), err) }()
+	}
+
+	return e.enqueue(val, ttl)
+}
+
+// enqueue implements EnqueueWithTTL without tracing.
+func (e *expiring[T]) enqueue(val T, ttl time.Duration) error {
+	item := e.stamp(val, ttl)
+
+	e.q.mu.Lock()
+	expired := e.makeRoom(e.q.itemBytes(item), item)
+	err := e.q.push(item)
+	e.q.unlock()
+
+	e.report(expired)
+	if err == nil {
+		runHooks(e.q.onEnqueue, item)
+	}
+
+	return err
+}
+
+// EnqueueDedupBack compares val with the newest live item, ignoring expired
+// ones. The new item expires as one added with Enqueue does.
+func (e *expiring[T]) EnqueueDedupBack(val T, eq func(a, b T) bool) (bool, error) {
+	item := e.stamp(val, e.window)
+
+	e.q.mu.Lock()
+	if e.q.closed {
+		e.q.unlock()
+		return false, ErrClosed
+	}
+	if newest, ok := e.newest(); ok && eq(newest.val, val) {
+		e.q.unlock()
+		return false, nil
+	}
+	expired := e.makeRoom(e.q.itemBytes(item), item)
+	err := e.q.push(item)
+	e.q.unlock()
+
+	e.report(expired)
+	if err != nil {
+		return false, err
+	}
+	runHooks(e.q.onEnqueue, item)
+
+	return true, nil
+}
+
+// EnqueuePush discards expired items before evicting a live one, so the
+// evicted item is never expired. The new item expires as one added with
+// Enqueue does.
+func (e *expiring[T]) EnqueuePush(val T) (evicted T, didEvict bool, err error) {
+	item := e.stamp(val, e.window)
+
+	e.q.mu.Lock()
+	expired := e.makeRoom(e.q.itemBytes(item), item)
+	front, didEvict, err := e.q.pushEvicting(item)
+	e.q.unlock()
+
+	e.report(expired)
+	if err == nil {
+		runHooks(e.q.onEnqueue, item)
+	}
+
+	return front.val, didEvict, err
+}
+
+// EnqueueFrontAll adds items that expire as those added with Enqueue do.
+func (e *expiring[T]) EnqueueFrontAll(vals ...T) error {
+	items := make([]timed[T], len(vals))
+	bytes := 0
+	for i, val := range vals {
+		items[i] = e.stamp(val, e.window)
+		bytes += e.q.itemBytes(items[i])
+	}
+
+	e.q.mu.Lock()
+	expired := e.makeRoom(bytes, items...)
+	err := e.q.pushFront(items)
+	e.q.unlock()
+
+	e.report(expired)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		runHooks(e.q.onEnqueue, item)
+	}
+
+	return nil
+}
+
+func (e *expiring[T]) TryEnqueue(val T) bool {
+	return e.enqueue(val, e.window) == nil
+}
+
+func (e *expiring[T]) Offer(val T) bool {
+	return e.TryEnqueue(val)
+}
+
+func (e *expiring[T]) EnqueueSlice(vals []T) (int, error) {
+	items := make([]timed[T], len(vals))
+	bytes := 0
+	for i, val := range vals {
+		items[i] = e.stamp(val, e.window)
+		bytes += e.q.itemBytes(items[i])
+	}
+
+	e.q.mu.Lock()
+	expired := e.makeRoom(bytes, items...)
+	inserted := 0
+	var err error
+	for _, item := range items {
+		if err = e.q.push(item); err != nil {
+			break
+		}
+		inserted++
+	}
+	e.q.unlock()
+
+	e.report(expired)
+	for _, item := range items[:inserted] {
+		runHooks(e.q.onEnqueue, item)
+	}
+
+	return inserted, err
+}
+
+// EnqueueWait stamps the item when it is added, not when the call starts
+// waiting.
+func (e *expiring[T]) EnqueueWait(ctx context.Context, val T) error {
+	item := timed[T]{val: val}
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in e.q.producers
+	defer e.q.producers.leave(&blocked)
+	for {
+		e.q.mu.Lock()
+		if e.q.closed {
+			e.q.unlock()
+			return ErrClosed
+		}
+		if err := e.q.invalid(item); err != nil {
+			e.q.unlock()
+			return err
+		}
+		expired := e.makeRoom(e.q.itemBytes(item), item)
+		if e.q.duplicate(item) {
+			e.q.unlock()
+			e.report(expired)
+			return ErrDuplicate
+		}
+		if d := e.q.throttled(); d > 0 {
+			since = e.q.blockedSince(since)
+			e.q.producers.enter(&blocked)
+			e.q.unlock()
+
+			e.report(expired)
+			if err := sleep(ctx, e.q.clock, d); err != nil {
+				return err
+			}
+			continue
+		}
+		if e.q.accepts(item) {
+			item = e.stamp(val, e.window)
+			e.q.admit()
+			e.q.add(item)
+			e.q.waited(&e.q.stats.EnqueueWait, since)
+			e.q.unlock()
+
+			e.report(expired)
+			runHooks(e.q.onEnqueue, item)
+			return nil
+		}
+		ready := e.q.notFull.wait()
+		since = e.q.blockedSince(since)
+		e.q.producers.enter(&blocked)
+		e.q.unlock()
+
+		err := waitFor(ctx, ready)
+		e.q.notFull.done()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// EnqueueAllCtx discards expired items to make room before waiting. The
+// items are stamped when they are added, not when the call starts waiting.
+func (e *expiring[T]) EnqueueAllCtx(ctx context.Context, vals ...T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	items := make([]timed[T], len(vals))
+	bytes := 0
+	for i, val := range vals {
+		items[i] = timed[T]{val: val}
+		bytes += e.q.itemBytes(items[i])
+	}
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in e.q.producers
+	defer e.q.producers.leave(&blocked)
+	for {
+		e.q.mu.Lock()
+		expired := e.makeRoom(bytes, items...)
+		if err := e.q.checkBatch(items, bytes); err != nil {
+			e.q.unlock()
+			e.report(expired)
+			return err
+		}
+		if e.q.circular || e.q.fits(len(items)) && e.q.fitsBytes(bytes) {
+			for i, item := range items {
+				items[i] = e.stamp(item.val, e.window)
+				e.q.accepts(items[i]) // Makes room in a circular queue
+				e.q.add(items[i])
+			}
+			e.q.waited(&e.q.stats.EnqueueWait, since)
+			e.q.unlock()
+
+			e.report(expired)
+			for _, item := range items {
+				runHooks(e.q.onEnqueue, item)
+			}
+			return nil
+		}
+		ready := e.q.notFull.wait()
+		since = e.q.blockedSince(since)
+		e.q.producers.enter(&blocked)
+		e.q.unlock()
+
+		e.report(expired)
+		err := waitFor(ctx, ready)
+		e.q.notFull.done()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (e *expiring[T]) EnqueueCtx(ctx context.Context, val T) error {
+	return enqueueCtx(ctx, e, val)
+}
+
+func (e *expiring[T]) EnqueueTimeout(val T, d time.Duration) error {
+	return enqueueTimeout(e, e.q.clock, val, d)
+}
+
+func (e *expiring[T]) Dequeue() (result T, err error) {
+	if e.q.tracer != nil {
+		end := e.q.tracer(e.q.name, OpDequeue)
+		defer func() { end(traced(err), err) }()
+	}
+
+	result, ok := e.TryDequeue()
+	if !ok {
+		return result, ErrUnderflow
+	}
+
+	return result, nil
+}
+
+func (e *expiring[T]) TryDequeue() (T, bool) {
+	e.q.mu.Lock()
+	expired := e.dropExpiredFront()
+	item, ok := e.q.pop()
+	e.q.unlock()
+
+	e.report(expired)
+	if ok {
+		runHooks(e.q.onDequeue, item)
+	}
+
+	return item.val, ok
+}
+
+func (e *expiring[T]) Poll() (T, bool) {
+	return e.TryDequeue()
+}
+
+func (e *expiring[T]) DequeueOr(def T) T {
+	if val, ok := e.TryDequeue(); ok {
+		return val
+	}
+
+	return def
+}
+
+// DequeueUntil never passes expired items to pred.
+func (e *expiring[T]) DequeueUntil(pred func(T) bool) (T, error) {
+	e.q.mu.Lock()
+	expired := e.purge()
+	item, removed, found := e.q.popUntil(func(item timed[T]) bool {
+		return pred(item.val)
+	})
+	e.q.unlock()
+
+	e.report(expired)
+	for _, item := range removed {
+		runHooks(e.q.onDequeue, item)
+	}
+	if !found {
+		return item.val, ErrUnderflow
+	}
+
+	return item.val, nil
+}
+
+// DequeueMatch never passes expired items to pred.
+func (e *expiring[T]) DequeueMatch(pred func(T) bool) (T, error) {
+	e.q.mu.Lock()
+	expired := e.purge()
+	item, err := e.q.popMatch(func(item timed[T]) bool {
+		return pred(item.val)
+	})
+	e.q.unlock()
+
+	e.report(expired)
+	if err == nil {
+		runHooks(e.q.onDequeue, item)
+	}
+
+	return item.val, err
+}
+
+// Drain discards expired items instead of returning them.
+func (e *expiring[T]) Drain() []T {
+	e.q.mu.Lock()
+	expired := e.purge()
+	result := make([]T, e.q.items.len())
+	e.popInto(result)
+	e.q.unlock()
+
+	e.report(expired)
+	e.dequeued(result)
+
+	return result
+}
+
+// DrainTo discards expired items instead of copying them.
+func (e *expiring[T]) DrainTo(dst []T) int {
+	e.q.mu.Lock()
+	expired := e.purge()
+	n := e.popInto(dst)
+	e.q.unlock()
+
+	e.report(expired)
+	e.dequeued(dst[:n])
+
+	return n
+}
+
+// popInto pops up to len(dst) items into dst and returns the number popped.
+// The caller must hold the write lock.
+func (e *expiring[T]) popInto(dst []T) int {
+	n := 0
+	for ; n < len(dst); n++ {
+		item, ok := e.q.pop()
+		if !ok {
+			break
+		}
+		dst[n] = item.val
+	}
+
+	return n
+}
+
+// dequeued runs the dequeue hooks for each removed item. The caller must not
+// hold the lock.
+func (e *expiring[T]) dequeued(vals []T) {
+	if len(e.q.onDequeue) == 0 {
+		return
+	}
+	for _, val := range vals {
+		runHooks(e.q.onDequeue, timed[T]{val: val})
+	}
+}
+
+func (e *expiring[T]) BlockedProducers() int {
+	return e.q.BlockedProducers()
+}
+
+func (e *expiring[T]) BlockedConsumers() int {
+	return e.q.BlockedConsumers()
+}
+
+func (e *expiring[T]) DequeueWait(ctx context.Context) (T, error) {
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in e.q.consumers
+	defer e.q.consumers.leave(&blocked)
+	if err := pace(ctx, e.q.limiter, e.q.clock, &e.q.consumers, &blocked); err != nil {
+		var zero T
+		return zero, err
+	}
+	for {
+		e.q.mu.Lock()
+		expired := e.dropExpiredFront()
+		if item, ok := e.q.pop(); ok {
+			e.q.waited(&e.q.stats.DequeueWait, since)
+			e.q.unlock()
+
+			e.report(expired)
+			runHooks(e.q.onDequeue, item)
+			return item.val, nil
+		}
+		if e.q.closed {
+			e.q.unlock()
+			e.report(expired)
+
+			var zero T
+			return zero, ErrClosed
+		}
+		ready := e.q.notEmpty.wait()
+		since = e.q.blockedSince(since)
+		e.q.consumers.enter(&blocked)
+		e.q.unlock()
+		e.report(expired)
+
+		err := waitFor(ctx, ready)
+		e.q.notEmpty.done()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+func (e *expiring[T]) DequeueCtx(ctx context.Context) (T, error) {
+	return dequeueCtx(ctx, e)
+}
+
+func (e *expiring[T]) DequeueTimeout(d time.Duration) (T, error) {
+	return dequeueTimeout(e, e.q.clock, d)
+}
+
+// DequeueBatchWait only counts live items towards n.
+func (e *expiring[T]) DequeueBatchWait(ctx context.Context, n int, maxWait time.Duration) ([]T, error) {
+	return dequeueBatchWait(ctx, e, e.q.clock, &e.q.notEmpty, &e.q.consumers, e.batchState, n, maxWait)
+}
+
+// batchState discards expired items and reports the number of live items and
+// whether the queue is closed, for dequeueBatchWait.
+func (e *expiring[T]) batchState() (int, bool) {
+	e.q.mu.Lock()
+	expired := e.purge()
+	size, closed := e.q.items.len(), e.q.closed
+	e.q.unlock()
+
+	e.report(expired)
+
+	return size, closed
+}
+
+func (e *expiring[T]) NotEmpty() <-chan struct{} {
+	return e.q.NotEmpty()
+}
+
+// NotFull counts expired items as taking room until they are discarded, as
+// Remaining does.
+func (e *expiring[T]) NotFull() <-chan struct{} {
+	e.pruneRolling()
+	return e.q.NotFull()
+}
+
+func (e *expiring[T]) Channel(ctx context.Context) <-chan T {
+	return channel(ctx, e)
+}
+
+func (e *expiring[T]) Close() error {
+	return e.q.Close()
+}
+
+// CloseAndDrain discards expired items instead of returning them.
+func (e *expiring[T]) CloseAndDrain(fn func(T)) []T {
+	e.q.mu.Lock()
+	e.q.close()
+	expired := e.purge()
+	result := make([]T, e.q.items.len())
+	e.popInto(result)
+	e.q.unlock()
+
+	e.report(expired)
+	e.dequeued(result)
+	handOff(result, fn)
+
+	return result
+}
+
+func (e *expiring[T]) Reset() {
+	e.q.Reset()
+}
+
+// Size discards expired items before counting for a rolling queue.
+func (e *expiring[T]) Size() int {
+	e.pruneRolling()
+	return e.q.Size()
+}
+
+// ApproxSize counts expired items that have not been discarded yet, as Size does.
+func (e *expiring[T]) ApproxSize() int {
+	return e.q.ApproxSize()
+}
+
+// SizeBytes includes items that have expired but not yet been discarded.
+func (e *expiring[T]) SizeBytes() int {
+	return e.q.SizeBytes()
+}
+
+// Remaining discards expired items before counting for a rolling queue.
+func (e *expiring[T]) Remaining() int {
+	e.pruneRolling()
+	return e.q.Remaining()
+}
+
+func (e *expiring[T]) SetCapacity(n int) error {
+	return e.q.SetCapacity(n)
+}
+
+func (e *expiring[T]) Grow(n int) error {
+	return e.q.Grow(n)
+}
+
+func (e *expiring[T]) Compact() {
+	e.q.Compact()
+}
+
+func (e *expiring[T]) Peek() (result T, err error) {
+	if e.q.tracer != nil {
+		end := e.q.tracer(e.q.name, OpPeek)
+		defer func() { end(traced(err), err) }()
+	}
+
+	e.q.mu.Lock()
+	expired := e.dropExpiredFront()
+	var item timed[T]
+	ok := e.q.items.len() > 0
+	if ok {
+		item = e.q.peeked(e.q.items.at(0))
+	}
+	e.q.unlock()
+
+	e.report(expired)
+	if !ok {
+		return item.val, ErrUnderflow
+	}
+
+	return item.val, nil
+}
+
+func (e *expiring[T]) Front() (T, error) {
+	return e.Peek()
+}
+
+func (e *expiring[T]) PeekOr(def T) T {
+	if val, err := e.Peek(); err == nil {
+		return val
+	}
+
+	return def
+}
+
+// Back skips expired items at the back without discarding them.
+func (e *expiring[T]) Back() (T, error) {
+	e.q.mu.RLock()
+	defer e.q.mu.RUnlock()
+
+	item, ok := e.back()
+	if !ok {
+		return item.val, ErrUnderflow
+	}
+
+	return e.q.peeked(item).val, nil
+}
+
+// PeekN skips expired items without discarding them.
+func (e *expiring[T]) PeekN(n int) ([]T, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+
+	e.q.mu.RLock()
+	defer e.q.mu.RUnlock()
+
+	now := e.q.clock.Now()
+	result := []T{}
+	found := false
+	for i := range e.q.items.len() {
+		item := e.q.items.at(i)
+		if item.expiredAt(now) {
+			continue
+		}
+		found = true
+		if len(result) == n {
+			break
+		}
+		result = append(result, e.q.peeked(item).val)
+	}
+	if !found {
+		return result, ErrUnderflow
+	}
+
+	return result, nil
+}
+
+// back returns the last item that has not expired, reporting whether there
+// is one. The caller must hold the lock.
+func (e *expiring[T]) back() (timed[T], bool) {
+	i, ok := e.backIndex()
+	if !ok {
+		return timed[T]{}, false
+	}
+
+	return e.q.items.at(i), true
+}
+
+// newest returns the most recently added item that has not expired, which is
+// the last one unless the queue is LIFO, reporting whether there is one. The
+// caller must hold the lock.
+func (e *expiring[T]) newest() (timed[T], bool) {
+	if !e.q.lifo {
+		return e.back()
+	}
+
+	now := e.q.clock.Now()
+	for i := range e.q.items.len() {
+		if item := e.q.items.at(i); !item.expiredAt(now) {
+			return item, true
+		}
+	}
+
+	return timed[T]{}, false
+}
+
+// backIndex returns the logical index of the last item that has not expired,
+// reporting whether there is one. The caller must hold the lock.
+func (e *expiring[T]) backIndex() (int, bool) {
+	now := e.q.clock.Now()
+	for i := e.q.items.len() - 1; i >= 0; i-- {
+		if !e.q.items.at(i).expiredAt(now) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// ReplaceFront keeps the TTL of the item it replaces.
+func (e *expiring[T]) ReplaceFront(val T) error {
+	e.q.mu.Lock()
+	expired := e.dropExpiredFront()
+	err := ErrUnderflow
+	if e.q.items.len() > 0 {
+		err = e.replaceAt(0, val)
+	}
+	e.q.unlock()
+
+	e.report(expired)
+
+	return err
+}
+
+// ReplaceBack keeps the TTL of the item it replaces.
+func (e *expiring[T]) ReplaceBack(val T) error {
+	e.q.mu.Lock()
+	defer e.q.unlock()
+
+	i, ok := e.backIndex()
+	if !ok {
+		return ErrUnderflow
+	}
+
+	return e.replaceAt(i, val)
+}
+
+// replaceAt overwrites the value of the item at logical index i, keeping its
+// deadline. The caller must hold the write lock.
+func (e *expiring[T]) replaceAt(i int, val T) error {
+	item := e.q.items.at(i)
+	item.val = val
+
+	return e.q.replaceAt(i, item)
+}
+
+func (e *expiring[T]) Reverse() {
+	e.q.Reverse()
+}
+
+func (e *expiring[T]) Rotate(n int) {
+	e.q.Rotate(n)
+}
+
+func (e *expiring[T]) Shuffle(r *rand.Rand) {
+	e.q.Shuffle(r)
+}
+
+// ForEach skips expired items without discarding them.
+func (e *expiring[T]) ForEach(fn func(T) bool) {
+	now := e.q.clock.Now()
+	e.q.ForEach(func(item timed[T]) bool {
+		return item.expiredAt(now) || fn(item.val)
+	})
+}
+
+// ForEachCtx skips expired items without discarding them.
+func (e *expiring[T]) ForEachCtx(ctx context.Context, fn func(T) bool) error {
+	now := e.q.clock.Now()
+	return e.q.ForEachCtx(ctx, func(item timed[T]) bool {
+		return item.expiredAt(now) || fn(item.val)
+	})
+}
+
+// All skips expired items without discarding them.
+func (e *expiring[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		now := e.q.clock.Now()
+		for item := range e.q.All() {
+			if !item.expiredAt(now) && !yield(item.val) {
+				return
+			}
+		}
+	}
+}
+
+// AllIndexed counts live items only, as Remove does.
+func (e *expiring[T]) AllIndexed() iter.Seq2[int, T] {
+	return allIndexed(e.All())
+}
+
+// Version does not change when items expire, only when they are discarded.
+func (e *expiring[T]) Version() uint64 {
+	return e.q.Version()
+}
+
+// Snapshot skips expired items without discarding them.
+func (e *expiring[T]) Snapshot() ([]T, uint64) {
+	e.q.mu.RLock()
+	defer e.q.mu.RUnlock()
+
+	now := e.q.clock.Now()
+	result := make([]T, 0, e.q.items.len())
+	for i := range e.q.items.len() {
+		if item := e.q.items.at(i); !item.expiredAt(now) {
+			result = append(result, item.val)
+		}
+	}
+
+	return result, e.q.version
+}
+
+// Filter passes expired items that have not been discarded yet to keep as well.
+func (e *expiring[T]) Filter(keep func(T) bool) int {
+	return e.q.Filter(func(item timed[T]) bool {
+		return keep(item.val)
+	})
+}
+
+// FilterCtx passes expired items that have not been discarded yet to keep as
+// well.
+func (e *expiring[T]) FilterCtx(ctx context.Context, keep func(T) bool) (int, error) {
+	return e.q.FilterCtx(ctx, func(item timed[T]) bool {
+		return keep(item.val)
+	})
+}
+
+// Merge keeps the TTLs of items moved from another expiring queue. Items from
+// other queues expire as those added with Enqueue do.
+func (e *expiring[T]) Merge(other Queue[T]) error {
+	if src, ok := other.(*expiring[T]); ok {
+		return e.q.Merge(src.q)
+	}
+
+	return mergeEach(e, other)
+}
+
+func (e *expiring[T]) admits(vals []T, replace bool) error {
+	items := make([]timed[T], len(vals))
+	for i, val := range vals {
+		items[i] = timed[T]{val: val}
+	}
+
+	return e.q.admits(items, replace)
+}
+
+func (e *expiring[T]) Split(n int) (Queue[T], error) {
+	head, err := e.q.Split(n)
+	if err != nil {
+		return nil, err
+	}
+
+	split := &expiring[T]{q: head.(*queue[timed[T]]), onExpire: e.onExpire, onFlush: e.onFlush, window: e.window}
+	split.flushes()
+
+	return split, nil
+}
+
+// Remove counts i over live items only, in the order All visits them.
+func (e *expiring[T]) Remove(i int) (T, error) {
+	e.q.mu.Lock()
+	defer e.q.unlock()
+
+	j, ok := e.liveIndex(i)
+	if !ok {
+		var zero T
+		return zero, ErrIndexOutOfRange
+	}
+
+	return e.q.removeAt(j).val, nil
+}
+
+// FindIndex counts live items only, in the order All visits them, so the
+// index it returns is valid for Remove.
+func (e *expiring[T]) FindIndex(pred func(T) bool) int {
+	found, i := false, 0
+	e.ForEach(func(v T) bool {
+		found = pred(v)
+		if !found {
+			i++
+		}
+		return !found
+	})
+	if !found {
+		return -1
+	}
+
+	return i
+}
+
+// CopyInto copies each item's deadline along with it when dst is also an
+// expiring queue. Otherwise it copies only the live items, which expire in
+// dst as those added with its Enqueue do, and is not atomic.
+func (e *expiring[T]) CopyInto(dst Queue[T]) error {
+	if d, ok := dst.(*expiring[T]); ok {
+		return e.q.CopyInto(d.q)
+	}
+	vals, _ := e.Snapshot()
+
+	return copyEach(vals, dst)
+}
+
+// Swap counts i and j over live items only, in the order All visits them.
+func (e *expiring[T]) Swap(i, j int) error {
+	e.q.mu.Lock()
+	defer e.q.unlock()
+
+	li, okI := e.liveIndex(i)
+	lj, okJ := e.liveIndex(j)
+	if !okI || !okJ {
+		return ErrIndexOutOfRange
+	}
+	e.q.swapItems(li, lj)
+	e.q.changed()
+
+	return nil
+}
+
+// liveIndex returns the logical index of the i-th item that has not expired,
+// reporting whether there is one. The caller must hold the lock.
+func (e *expiring[T]) liveIndex(i int) (int, bool) {
+	now := e.q.clock.Now()
+	for j := 0; j < e.q.items.len() && i >= 0; j++ {
+		if e.q.items.at(j).expiredAt(now) {
+			continue
+		}
+		if i == 0 {
+			return j, true
+		}
+		i--
+	}
+
+	return 0, false
+}
+
+func (e *expiring[T]) Purge() int {
+	e.q.mu.Lock()
+	expired := e.purge()
+	e.q.unlock()
+
+	e.report(expired)
+
+	return len(expired)
+}
+
+func (e *expiring[T]) Stats() Stats {
+	return e.q.Stats()
+}
+
+func (e *expiring[T]) String() string {
+	e.q.mu.RLock()
+	capacity := e.q.capacity
+	e.q.mu.RUnlock()
+
+	items := slices.Collect(e.All())
+	return formatQueue(e.q.name, len(items), capacity, func(i int) T {
+		return items[i]
+	})
+}
+
+func (e *expiring[T]) Name() string {
+	return e.q.name
+}
+
+// makeRoom discards every expired item if items, which total bytes, would
+// not otherwise fit or one of them has the key of a queued item, and returns
+// the items discarded. The caller must hold the write lock.
+func (e *expiring[T]) makeRoom(bytes int, items ...timed[T]) []T {
+	if e.q.fits(len(items)) && e.q.fitsBytes(bytes) && !e.q.duplicates(items, true) {
+		return nil
+	}
+
+	return e.purge()
+}
+
+// dropExpiredFront discards expired items at the front until the front item
+// is live, and returns the items discarded. The caller must hold the write lock.
+func (e *expiring[T]) dropExpiredFront() []T {
+	var expired []T
+	now := e.q.clock.Now()
+	for e.q.items.len() > 0 && e.q.items.at(0).expiredAt(now) {
+		expired = append(expired, e.q.takeFront().val)
+	}
+	e.discarded(len(expired))
+
+	return expired
+}
+
+// purge discards every expired item, preserving the order of the others, and
+// returns the items discarded. The caller must hold the write lock.
+func (e *expiring[T]) purge() []T {
+	var expired []T
+	now := e.q.clock.Now()
+	n := 0
+	for i := 0; i < e.q.items.len(); i++ {
+		item := e.q.items.at(i)
+		if item.expiredAt(now) {
+			expired = append(expired, item.val)
+			e.q.untrack(item)
+			e.q.bytes -= e.q.itemBytes(item)
+			continue
+		}
+		e.q.items.set(n, item)
+		n++
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	e.q.items.truncate(n)
+	e.q.changed()
+	e.discarded(len(expired))
+
+	return expired
+}
+
+// discarded records that n expired items were removed. The caller must hold
+// the write lock.
+func (e *expiring[T]) discarded(n int) {
+	if n == 0 {
+		return
+	}
+
+	e.q.stats.Expired += uint64(n)
+	e.q.notFull.broadcast()
+}
+
+// report runs the expiry hooks for each discarded item. The caller must not
+// hold the lock.
+func (e *expiring[T]) report(expired []T) {
+	for _, val := range expired {
+		runHooks(e.onExpire, val)
+	}
+}
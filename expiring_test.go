@@ -0,0 +1,284 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	shortTTL = 10 * time.Millisecond
+	longTTL  = time.Hour
+)
+
+func TestExpiringSkipsExpired(t *testing.T) {
+	clock := newFakeClock()
+	var expired []int
+	q := NewExpiring[int](WithClock[int](clock), WithOnExpire(func(v int) { expired = append(expired, v) }))
+
+	_ = q.EnqueueWithTTL(1, shortTTL)
+	_ = q.EnqueueWithTTL(2, longTTL)
+	_ = q.EnqueueWithTTL(3, shortTTL)
+	_ = q.Enqueue(4)
+	clock.Advance(2 * shortTTL)
+
+	if val, err := q.Peek(); val != 2 || err != nil {
+		t.Errorf("Peek() = (%d, %v), want (2, nil)", val, err)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{2, 4}) {
+		t.Errorf("All() = %v, want [2 4]", got)
+	}
+
+	for _, expected := range []int{2, 4} {
+		if val, err := q.Dequeue(); val != expected || err != nil {
+			t.Errorf("Dequeue() = (%d, %v), want (%d, nil)", val, err, expected)
+		}
+	}
+	if _, err := q.Dequeue(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("Dequeue() on queue of expired items = %v, want %v", err, ErrUnderflow)
+	}
+
+	if !slices.Equal(expired, []int{1, 3}) {
+		t.Errorf("expire hook saw %v, want [1 3]", expired)
+	}
+	if s := q.Stats(); s.Expired != 2 || s.Dequeued != 2 || s.CurrentSize != 0 {
+		t.Errorf("Stats() = %+v, want Expired=2 Dequeued=2 CurrentSize=0", s)
+	}
+}
+
+func TestExpiringPurge(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring[int](WithClock[int](clock))
+	_ = q.EnqueueWithTTL(1, longTTL)
+	_ = q.EnqueueWithTTL(2, shortTTL)
+	_ = q.EnqueueWithTTL(3, longTTL)
+	clock.Advance(2 * shortTTL)
+
+	// Expired items are counted until they are discarded
+	if size := q.Size(); size != 3 {
+		t.Errorf("Size before Purge() = %d, want 3", size)
+	}
+	if n := q.Purge(); n != 1 {
+		t.Errorf("Purge() = %d, want 1", n)
+	}
+	if size := q.Size(); size != 2 {
+		t.Errorf("Size after Purge() = %d, want 2", size)
+	}
+	if s := q.String(); s != "Queue[len=2/cap=unlimited]: [1 3]" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestExpiringMakesRoom(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring[int](WithClock[int](clock), WithCapacity[int](2))
+	_ = q.EnqueueWithTTL(1, longTTL)
+	_ = q.EnqueueWithTTL(2, shortTTL)
+	clock.Advance(2 * shortTTL)
+
+	// A full queue discards expired items before overflowing
+	if err := q.Enqueue(3); err != nil {
+		t.Fatalf("Enqueue() with an expired item in a full queue = %v, want nil", err)
+	}
+	if err := q.Enqueue(4); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Enqueue() on full queue = %v, want %v", err, ErrOverflow)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 3}) {
+		t.Errorf("All() = %v, want [1 3]", got)
+	}
+}
+
+func TestExpiringDequeueWait(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring[int](WithClock[int](clock))
+	_ = q.EnqueueWithTTL(1, shortTTL)
+	clock.Advance(2 * shortTTL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shortTTL)
+	defer cancel()
+	if _, err := q.DequeueWait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DequeueWait() with only expired items = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	go func() {
+		time.Sleep(shortTTL)
+		_ = q.EnqueueWithTTL(2, longTTL)
+	}()
+	if val, err := q.DequeueWait(context.Background()); val != 2 || err != nil {
+		t.Errorf("DequeueWait() = (%d, %v), want (2, nil)", val, err)
+	}
+}
+
+func TestExpiringMergeAndSplit(t *testing.T) {
+	clock := newFakeClock()
+	a := NewExpiring[int](WithClock[int](clock))
+	b := NewExpiring[int](WithClock[int](clock))
+	_ = a.EnqueueWithTTL(1, longTTL)
+	_ = b.EnqueueWithTTL(2, shortTTL)
+	_ = b.EnqueueWithTTL(3, longTTL)
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge() = %v, want nil", err)
+	}
+
+	head, err := a.Split(2)
+	if err != nil {
+		t.Fatalf("Split() = %v, want nil", err)
+	}
+	clock.Advance(2 * shortTTL)
+
+	// TTLs travel with items through Merge and Split
+	if got := slices.Collect(head.All()); !slices.Equal(got, []int{1}) {
+		t.Errorf("head All() = %v, want [1]", got)
+	}
+	if _, ok := head.(Expiring[int]); !ok {
+		t.Error("Split() of an expiring queue did not return an Expiring queue")
+	}
+	if got := slices.Collect(a.All()); !slices.Equal(got, []int{3}) {
+		t.Errorf("rest All() = %v, want [3]", got)
+	}
+}
+
+func TestExpiringDequeueUntil(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring[int](WithClock[int](clock))
+	_ = q.EnqueueWithTTL(1, longTTL)
+	_ = q.EnqueueWithTTL(2, shortTTL)
+	_ = q.EnqueueWithTTL(3, longTTL)
+	clock.Advance(2 * shortTTL)
+
+	var seen []int
+	val, err := q.DequeueUntil(func(v int) bool {
+		seen = append(seen, v)
+		return v == 3
+	})
+	if val != 3 || err != nil {
+		t.Errorf("DequeueUntil() = (%d, %v), want (3, nil)", val, err)
+	}
+	if !slices.Equal(seen, []int{1, 3}) {
+		t.Errorf("DequeueUntil() passed %v to pred, want [1 3]", seen)
+	}
+}
+
+func TestExpiringMaxBytes(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring[string](WithClock[string](clock), WithMaxBytes(6, func(s string) int { return len(s) }))
+	_ = q.EnqueueWithTTL("aaa", shortTTL)
+	_ = q.EnqueueWithTTL("bbb", longTTL)
+	clock.Advance(2 * shortTTL)
+
+	// The expired item's bytes are reclaimed to make room
+	if err := q.Enqueue("ccc"); err != nil {
+		t.Fatalf("Enqueue() with an expired item holding the bytes = %v, want nil", err)
+	}
+	if err := q.Enqueue("d"); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Enqueue() past byte limit = %v, want %v", err, ErrOverflow)
+	}
+}
+
+func TestExpiringBack(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring[int](WithClock[int](clock))
+	_ = q.EnqueueWithTTL(1, longTTL)
+	_ = q.EnqueueWithTTL(2, shortTTL)
+	clock.Advance(2 * shortTTL)
+
+	if val, err := q.Back(); val != 1 || err != nil {
+		t.Errorf("Back() = (%d, %v), want (1, nil)", val, err)
+	}
+}
+
+func TestExpiringRemove(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring[int](WithClock[int](clock))
+	_ = q.EnqueueWithTTL(1, shortTTL)
+	_ = q.EnqueueWithTTL(2, longTTL)
+	_ = q.EnqueueWithTTL(3, longTTL)
+	clock.Advance(2 * shortTTL)
+
+	// Indexes count live items only
+	if val, err := q.Remove(1); val != 3 || err != nil {
+		t.Errorf("Remove(1) = (%d, %v), want (3, nil)", val, err)
+	}
+	if _, err := q.Remove(1); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("Remove(1) with one live item = %v, want %v", err, ErrIndexOutOfRange)
+	}
+}
+
+func TestExpiringDrain(t *testing.T) {
+	clock := newFakeClock()
+	var expired []int
+	q := NewExpiring[int](WithClock[int](clock), WithOnExpire(func(v int) { expired = append(expired, v) }))
+	_ = q.EnqueueWithTTL(1, longTTL)
+	_ = q.EnqueueWithTTL(2, shortTTL)
+	_ = q.EnqueueWithTTL(3, longTTL)
+	_ = q.EnqueueWithTTL(4, longTTL)
+	clock.Advance(2 * shortTTL)
+
+	// Expired items are discarded rather than copied
+	dst := make([]int, 2)
+	if n := q.DrainTo(dst); n != 2 || !slices.Equal(dst, []int{1, 3}) {
+		t.Errorf("DrainTo() = %d with %v, want 2 with [1 3]", n, dst)
+	}
+	if !slices.Equal(expired, []int{2}) {
+		t.Errorf("expire hook saw %v, want [2]", expired)
+	}
+	if got := q.Drain(); !slices.Equal(got, []int{4}) {
+		t.Errorf("Drain() = %v, want [4]", got)
+	}
+}
+
+func TestExpiringEnqueueDedupBack(t *testing.T) {
+	clock := newFakeClock()
+	eq := func(a, b int) bool { return a == b }
+	q := NewExpiring[int](WithClock[int](clock))
+	_ = q.EnqueueWithTTL(1, longTTL)
+	_ = q.EnqueueWithTTL(2, shortTTL)
+
+	if ok, err := q.EnqueueDedupBack(2, eq); ok || err != nil {
+		t.Errorf("EnqueueDedupBack() of live duplicate = (%v, %v), want (false, nil)", ok, err)
+	}
+	clock.Advance(2 * shortTTL)
+
+	// An expired back item is not a duplicate
+	if ok, err := q.EnqueueDedupBack(2, eq); !ok || err != nil {
+		t.Errorf("EnqueueDedupBack() after back expired = (%v, %v), want (true, nil)", ok, err)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("All() = %v, want [1 2]", got)
+	}
+}
+
+func TestExpiringCopyOnPeek(t *testing.T) {
+	q := NewExpiring[[]int](WithCopyOnPeek(slices.Clone[[]int]))
+	_ = q.Enqueue([]int{1})
+
+	front, _ := q.Peek()
+	front[0] = 99
+	back, _ := q.Back()
+	if back[0] != 1 {
+		t.Errorf("Back() after mutating Peek() result = %v, want [1]", back)
+	}
+	back[0] = 99
+	if val, _ := q.Dequeue(); !slices.Equal(val, []int{1}) {
+		t.Errorf("Dequeue() = %v, want [1]", val)
+	}
+}
+
+func TestExpiringEnqueueFrontAll(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring[int](WithClock[int](clock), WithCapacity[int](3))
+	_ = q.EnqueueWithTTL(9, shortTTL)
+	_ = q.EnqueueWithTTL(3, longTTL)
+	clock.Advance(2 * shortTTL)
+
+	// The expired item is discarded to make room
+	if err := q.EnqueueFrontAll(1, 2); err != nil {
+		t.Fatalf("EnqueueFrontAll() = %v, want nil", err)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("All() = %v, want [1 2 3]", got)
+	}
+}
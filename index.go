@@ -0,0 +1,106 @@
+package queue
+
+// counts is the keySet of a queue created with WithKeyIndex: it records how
+// many queued items have each key, so that several items may share one.
+type counts[T any, K comparable] struct {
+	key   func(T) K
+	items map[K]int
+	n     int
+}
+
+func newCounts[T any, K comparable](key func(T) K) *counts[T, K] {
+	return &counts[T, K]{key: key, items: make(map[K]int)}
+}
+
+func (c *counts[T, K]) has(val T) bool {
+	return c.contains(c.key(val))
+}
+
+// contains reports whether an item with key k is recorded.
+func (c *counts[T, K]) contains(k K) bool {
+	return c.items[k] > 0
+}
+
+func (c *counts[T, K]) add(val T) {
+	c.items[c.key(val)]++
+	c.n++
+}
+
+func (c *counts[T, K]) remove(val T) {
+	k := c.key(val)
+	if n := c.items[k]; n > 1 {
+		c.items[k] = n - 1
+	} else {
+		delete(c.items, k)
+	}
+	c.n--
+}
+
+func (c *counts[T, K]) clear() {
+	clear(c.items)
+	c.n = 0
+}
+
+func (c *counts[T, K]) len() int {
+	return c.n
+}
+
+func (c *counts[T, K]) fresh() keySet[T] {
+	return newCounts(c.key)
+}
+
+// ContainsKey reports whether q holds an item whose key, as returned by the
+// function given to WithKeyIndex, is k. It looks k up in the index rather
+// than scanning the items, so it is O(1), or O(shards) for a sharded queue.
+//
+// An expiring queue counts items that have expired but not yet been
+// discarded, as Size does.
+//
+// Example:
+//
+//	q := queue.New[Job](queue.WithKeyIndex(func(j Job) string { return j.ID }))
+//	q.Enqueue(Job{ID: "a"})
+//	queue.ContainsKey(q, "a") // true
+//	queue.ContainsKey(q, "b") // false
+//
+// Panics if q was not created with WithKeyIndex for keys of type K.
+func ContainsKey[T any, K comparable](q Queue[T], k K) bool {
+	if base, ok := asQueue(q); ok {
+		return containsKey(base, k, indexOf[T, K](base.index))
+	}
+
+	switch q := q.(type) {
+	case *expiring[T]:
+		if f, ok := q.q.index.(*fieldKeys[T, timed[T]]); ok {
+			return containsKey(q.q, k, indexOf[T, K](f.keys))
+		}
+	case *sharded[T]:
+		for _, shard := range q.shards {
+			if containsKey(shard, k, indexOf[T, K](shard.index)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	panic("cannot look up a key in a queue without a key index")
+}
+
+// indexOf returns index as the counts of keys of type K, panicking if it is
+// not one.
+func indexOf[T any, K comparable](index keySet[T]) *counts[T, K] {
+	c, ok := index.(*counts[T, K])
+	if !ok {
+		panic("cannot look up a key in a queue without a key index of that type")
+	}
+
+	return c
+}
+
+// containsKey looks k up in c, the index of q, under the read lock of q.
+func containsKey[W any, T any, K comparable](q *queue[W], k K, c *counts[T, K]) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return c.contains(k)
+}
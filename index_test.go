@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestContainsKey(t *testing.T) {
+	q := New[event](WithKeyIndex(eventID))
+	_, _ = q.EnqueueSlice([]event{{"a", 1}, {"b", 1}, {"a", 2}, {"c", 1}})
+
+	for _, tt := range []struct {
+		step string
+		op   func()
+		want map[string]bool
+	}{
+		{"enqueue", func() {}, map[string]bool{"a": true, "b": true, "c": true, "d": false}},
+		{"Dequeue of one of two", func() { _, _ = q.Dequeue() }, map[string]bool{"a": true, "b": true}},
+		{"Remove", func() { _, _ = q.Remove(0) }, map[string]bool{"a": true, "b": false}},
+		{"Filter", func() { q.Filter(func(e event) bool { return e.id != "a" }) }, map[string]bool{"a": false, "c": true}},
+		{"ReplaceFront", func() { _ = q.ReplaceFront(event{"d", 1}) }, map[string]bool{"c": false, "d": true}},
+		{"Reset", func() { q.Reset() }, map[string]bool{"d": false}},
+	} {
+		tt.op()
+		for k, want := range tt.want {
+			if got := ContainsKey(q, k); got != want {
+				t.Errorf("after %s: ContainsKey(%q) = %v, want %v", tt.step, k, got, want)
+			}
+		}
+		if err := CheckInvariants(q); err != nil {
+			t.Errorf("after %s: %v", tt.step, err)
+		}
+	}
+
+	t.Run("without index (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("ContainsKey() without WithKeyIndex should panic, but it didn't")
+			}
+		}()
+
+		ContainsKey(New[event](), "a")
+	})
+
+	t.Run("wrong key type (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("ContainsKey() with the wrong key type should panic, but it didn't")
+			}
+		}()
+
+		ContainsKey(q, 1)
+	})
+}
+
+func TestKeyIndexMatchesScan(t *testing.T) {
+	key := func(v int) int { return v % 10 }
+	for name, q := range map[string]Queue[int]{
+		"queue":    New(WithKeyIndex(key)),
+		"priority": NewPriority(intLess, WithKeyIndex(key)),
+		"sharded":  NewSharded(3, WithKeyIndex(key)),
+		"expiring": NewExpiring(WithKeyIndex(key)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(1, 2))
+			for range 2000 {
+				switch r.IntN(6) {
+				case 0, 1:
+					_ = q.Enqueue(r.IntN(100))
+				case 2:
+					_, _ = q.Dequeue()
+				case 3:
+					if size := q.Size(); size > 0 {
+						_, _ = q.Remove(r.IntN(size))
+					}
+				case 4:
+					drop := r.IntN(10)
+					q.Filter(func(v int) bool { return key(v) != drop })
+				case 5:
+					if size := q.Size(); size > 1 {
+						_ = q.Swap(r.IntN(size), r.IntN(size))
+					}
+				}
+
+				items, _ := q.Snapshot()
+				for k := range 10 {
+					want := slices.ContainsFunc(items, func(v int) bool { return key(v) == k })
+					if got := ContainsKey(q, k); got != want {
+						t.Fatalf("ContainsKey(%d) = %v, want %v from a scan of %v", k, got, want, items)
+					}
+				}
+			}
+			if err := CheckInvariants(q); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// CheckInvariants verifies the internal consistency of q, returning an error
+// wrapping ErrInvariantViolated that describes the first violation found, or
+// nil if q is consistent or is not one of this package's queues.
+//
+// It is meant for tests and fuzzing: it inspects every slot of the backing
+// storage, so it is O(capacity) and should not be used on hot paths. Counters
+// that concurrent operations update in several steps, such as the aggregate
+// size of a sharded queue, are only consistent while no other operation is in
+// progress.
+//
+// Example:
+//
+//	for _, op := range randomOps {
+//		op(q)
+//		if err := queue.CheckInvariants(q); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+func CheckInvariants[T any](q Queue[T]) error {
+	if base, ok := asQueue(q); ok {
+		return base.checkInvariants()
+	}
+
+	switch q := q.(type) {
+	case *sharded[T]:
+		return q.checkInvariants()
+	case *expiring[T]:
+		return q.q.checkInvariants()
+	default:
+		return nil
+	}
+}
+
+// checkInvariants implements CheckInvariants under the read lock.
+func (q *queue[T]) checkInvariants() error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if err := q.items.checkInvariants(); err != nil {
+		return err
+	}
+
+	n := q.items.len()
+	if q.reserved < 0 {
+		return violated("%d reserved slots", q.reserved)
+	}
+	if q.capacity >= 0 && n+q.pending() > q.capacity {
+		return violated("%d items and %d pending slots exceed capacity %d", n, q.pending(), q.capacity)
+	}
+	if size := q.approxSize.Load(); size != int64(n) {
+		return violated("approximate size %d, want %d", size, n)
+	}
+	if q.stats.PeakSize < n {
+		return violated("peak size %d below size %d", q.stats.PeakSize, n)
+	}
+	if len(q.dropped) > 0 {
+		return violated("%d dropped items not forwarded", len(q.dropped))
+	}
+
+	if q.sizeOf != nil {
+		bytes := 0
+		for i := range n {
+			bytes += q.sizeOf(q.items.at(i))
+		}
+		if bytes != q.bytes {
+			return violated("byte count %d, want %d", q.bytes, bytes)
+		}
+		held := 0
+		for _, val := range q.held {
+			held += q.sizeOf(val)
+		}
+		if held != q.heldBytes {
+			return violated("held byte count %d, want %d", q.heldBytes, held)
+		}
+	}
+
+	for name, keys := range q.keySets() {
+		for i := range n {
+			if !keys.has(q.items.at(i)) {
+				return violated("key of item at index %d missing from %s keys", i, name)
+			}
+		}
+		for i, val := range q.held {
+			if !keys.has(val) {
+				return violated("key of held item %d missing from %s keys", i, name)
+			}
+		}
+		if tracked, want := keys.len(), n+len(q.held); tracked != want {
+			return violated("%d items tracked by %s keys, want %d", tracked, name, want)
+		}
+	}
+
+	if q.less != nil {
+		for i := 1; i < n; i++ {
+			parent := (i - 1) / 2
+			if q.less(q.items.at(i), q.items.at(parent)) {
+				return violated("heap order broken between indexes %d and %d", parent, i)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkInvariants verifies the indexes of the ring and that every slot beyond
+// its items is zeroed, so nothing removed is kept reachable.
+func (r *ring[T]) checkInvariants() error {
+	if r.count < 0 || r.count > len(r.buf) {
+		return violated("count %d outside buffer of %d slots", r.count, len(r.buf))
+	}
+	if r.head < 0 || (r.head >= len(r.buf) && r.head != 0) {
+		return violated("head %d outside buffer of %d slots", r.head, len(r.buf))
+	}
+
+	for i := r.count; i < len(r.buf); i++ {
+		if !reflect.ValueOf(&r.buf[r.index(i)]).Elem().IsZero() {
+			return violated("free slot %d holds a value", r.index(i))
+		}
+	}
+
+	return nil
+}
+
+// checkInvariants checks every shard and that the aggregate counters agree
+// with them.
+func (s *sharded[T]) checkInvariants() error {
+	total := 0
+	for i, shard := range s.shards {
+		if err := shard.checkInvariants(); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+		total += shard.Size()
+	}
+
+	size := s.size.Load()
+	if size != int64(total) {
+		return violated("size %d, want %d across shards", size, total)
+	}
+	if c := s.capacity.Load(); c >= 0 && size > c {
+		return violated("%d items exceed capacity %d", size, c)
+	}
+	if peak := s.peak.Load(); peak < size {
+		return violated("peak size %d below size %d", peak, size)
+	}
+
+	return nil
+}
+
+// violated returns an error wrapping ErrInvariantViolated with a description.
+func violated(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvariantViolated, fmt.Sprintf(format, args...))
+}
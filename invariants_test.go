@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+)
+
+// randomOp is an operation applied to a queue by TestInvariantsRandomOps.
+type randomOp struct {
+	name string
+	fn   func(q Queue[int], r *rand.Rand)
+}
+
+var randomOps = []randomOp{
+	{"Enqueue", func(q Queue[int], r *rand.Rand) { _ = q.Enqueue(r.IntN(100)) }},
+	{"EnqueueSlice", func(q Queue[int], r *rand.Rand) { _, _ = q.EnqueueSlice([]int{r.IntN(100), r.IntN(100)}) }},
+	{"EnqueueFrontAll", func(q Queue[int], r *rand.Rand) { _ = q.EnqueueFrontAll(r.IntN(100), r.IntN(100)) }},
+	{"EnqueueDedupBack", func(q Queue[int], r *rand.Rand) {
+		_, _ = q.EnqueueDedupBack(r.IntN(3), func(a, b int) bool { return a == b })
+	}},
+	{"Dequeue", func(q Queue[int], r *rand.Rand) { _, _ = q.Dequeue() }},
+	{"Poll", func(q Queue[int], r *rand.Rand) { _, _ = q.Poll() }},
+	{"DequeueUntil", func(q Queue[int], r *rand.Rand) { _, _ = q.DequeueUntil(func(v int) bool { return v%3 == 0 }) }},
+	{"DrainTo", func(q Queue[int], r *rand.Rand) { q.DrainTo(make([]int, r.IntN(4))) }},
+	{"Remove", func(q Queue[int], r *rand.Rand) { _, _ = q.Remove(r.IntN(q.Size() + 1)) }},
+	{"Swap", func(q Queue[int], r *rand.Rand) { _ = q.Swap(r.IntN(q.Size()+1), r.IntN(q.Size()+1)) }},
+	{"Filter", func(q Queue[int], r *rand.Rand) { q.Filter(func(v int) bool { return v%7 != 0 }) }},
+	{"Rotate", func(q Queue[int], r *rand.Rand) { q.Rotate(r.IntN(7) - 3) }},
+	{"Reverse", func(q Queue[int], r *rand.Rand) { q.Reverse() }},
+	{"SetCapacity", func(q Queue[int], r *rand.Rand) { _ = q.SetCapacity(r.IntN(20) + 1) }},
+	{"Grow", func(q Queue[int], r *rand.Rand) { _ = q.Grow(r.IntN(10)) }},
+	{"Compact", func(q Queue[int], r *rand.Rand) { q.Compact() }},
+	{"Split", func(q Queue[int], r *rand.Rand) { _, _ = q.Split(r.IntN(3)) }},
+}
+
+func TestInvariantsRandomOps(t *testing.T) {
+	clock := newFakeClock()
+	tests := []struct {
+		name  string
+		newFn func() Queue[int]
+		extra []randomOp
+	}{
+		{name: "fifo", newFn: func() Queue[int] { return New(WithCapacity[int](16)) }},
+		{name: "circular", newFn: func() Queue[int] { return New(WithCapacity[int](8), WithCircular[int]()) }},
+		{name: "shrink drop oldest", newFn: func() Queue[int] {
+			return New(WithCapacity[int](16), WithShrinkPolicy[int](ShrinkDropOldest))
+		}},
+		{name: "priority", newFn: func() Queue[int] { return NewPriority(intLess, WithCapacity[int](16)) }},
+		{name: "max bytes", newFn: func() Queue[int] {
+			return New(WithMaxBytes(30, func(v int) int { return v%5 + 1 }))
+		}},
+		{name: "unsafe", newFn: func() Queue[int] { return NewUnsafe(WithCapacity[int](16)) }},
+		{name: "sharded", newFn: func() Queue[int] { return NewSharded(3, WithCapacity[int](16)) }},
+		{
+			name:  "expiring",
+			newFn: func() Queue[int] { return NewExpiring(WithCapacity[int](16), WithClock[int](clock)) },
+			extra: []randomOp{{"EnqueueWithTTL", func(q Queue[int], r *rand.Rand) {
+				_ = q.(Expiring[int]).EnqueueWithTTL(r.IntN(100), time.Duration(r.IntN(60)+1)*time.Second)
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(1, 2))
+			q := tt.newFn()
+			ops := append(randomOps[:len(randomOps):len(randomOps)], tt.extra...)
+			for step := range 2000 {
+				// Favour the first op so queues fill up beyond a few items
+				op := ops[0]
+				if r.IntN(2) == 0 {
+					op = ops[r.IntN(len(ops))]
+				}
+				op.fn(q, r)
+				clock.Advance(time.Duration(r.IntN(20)) * time.Second)
+
+				if err := CheckInvariants(q); err != nil {
+					t.Fatalf("step %d (%s): %v", step, op.name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckInvariantsDetects(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(q *queue[int])
+		want    string
+	}{
+		{name: "count", corrupt: func(q *queue[int]) { q.items.count = q.items.cap() + 1 }, want: "count"},
+		{name: "head", corrupt: func(q *queue[int]) { q.items.head = -1 }, want: "head"},
+		{name: "free slot", corrupt: func(q *queue[int]) { q.items.buf[q.items.index(q.items.len())] = 9 }, want: "free slot"},
+		{name: "approximate size", corrupt: func(q *queue[int]) { q.approxSize.Add(1) }, want: "approximate size"},
+		{name: "capacity", corrupt: func(q *queue[int]) { q.capacity = 1 }, want: "capacity"},
+		{name: "heap order", corrupt: func(q *queue[int]) {
+			q.less = intLess
+			q.items.set(0, 100)
+		}, want: "heap order"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := New(WithCapacity[int](8))
+			_, _ = q.EnqueueSlice([]int{1, 2, 3})
+			if err := CheckInvariants(q); err != nil {
+				t.Fatalf("CheckInvariants() before corruption = %v, want nil", err)
+			}
+
+			tt.corrupt(q.(*queue[int]))
+			err := CheckInvariants(q)
+			if !errors.Is(err, ErrInvariantViolated) {
+				t.Fatalf("CheckInvariants() = %v, want %v", err, ErrInvariantViolated)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CheckInvariants() = %q, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	s := NewSharded[int](2)
+	_ = s.Enqueue(1)
+	s.(*sharded[int]).size.Add(1)
+	if err := CheckInvariants(s); !errors.Is(err, ErrInvariantViolated) {
+		t.Errorf("CheckInvariants() on sharded queue with wrong size = %v, want %v", err, ErrInvariantViolated)
+	}
+}
@@ -0,0 +1,156 @@
+package queue
+
+import "slices"
+
+// Map returns a new queue holding fn applied to each item of q, in the order
+// All visits them. q is not modified.
+//
+// The new queue is created as by New and has the same capacity as q. Other
+// options, such as hooks, are tied to the item type and are not carried over.
+// fn is called after the items have been copied, without holding q's lock.
+//
+// Example:
+//
+//	ints := queue.New[int]()
+//	ints.Enqueue(1)
+//	ints.Enqueue(2)
+//	strs := queue.Map(ints, strconv.Itoa) // Holds "1", "2"
+func Map[T, U any](q Queue[T], fn func(T) U) Queue[U] {
+	items, capacity := contents(q)
+
+	out := newQueue(WithCapacity[U](capacity))
+	for _, val := range items {
+		out.add(fn(val))
+	}
+
+	return out
+}
+
+// Fold combines the items of q into a single value, calling fn with the result
+// so far and each item in the order ForEach visits them, starting from init.
+// q is not modified.
+//
+// Items are visited with ForEach, so queues created with New, NewPriority or
+// NewBlocking are folded from a consistent view under the read lock. fn is
+// called under that lock and must not call back into the queue.
+//
+// Example:
+//
+//	q := queue.New[int]()
+//	q.Enqueue(1)
+//	q.Enqueue(2)
+//	sum := queue.Fold(q, 0, func(acc, v int) int { return acc + v }) // 3
+func Fold[T, A any](q Queue[T], init A, fn func(A, T) A) A {
+	acc := init
+	q.ForEach(func(val T) bool {
+		acc = fn(acc, val)
+		return true
+	})
+
+	return acc
+}
+
+// Min returns the smallest item of q, where a is smaller than b if less(a, b)
+// is true, reporting false if q is empty. Of several smallest items it returns
+// the first in the order ForEach visits them. q is not modified.
+//
+// Items are scanned with ForEach, as Fold scans them, in O(n). A nil less
+// uses the order of a queue created by NewPriority, whose smallest item is the
+// one Peek would return, found in O(1).
+//
+// Example:
+//
+//	q := queue.New[int]()
+//	q.EnqueueSlice([]int{3, 1, 2})
+//	lo, ok := queue.Min(q, func(a, b int) bool { return a < b }) // 1, true
+//
+// Panics if less is nil and q was not created by NewPriority.
+func Min[T any](q Queue[T], less func(a, b T) bool) (T, bool) {
+	if less == nil {
+		return ordered(q).extreme(false)
+	}
+
+	return scanExtreme(q, func(best, v T) bool { return less(v, best) })
+}
+
+// Max returns the largest item of q, where a is smaller than b if less(a, b)
+// is true, reporting false if q is empty. Of several largest items it returns
+// the first in the order ForEach visits them. q is not modified.
+//
+// Items are scanned with ForEach in O(n). A nil less uses the order of a
+// queue created by NewPriority, whose largest item is the one Back would
+// return, found among the leaves of its heap in O(n/2).
+//
+// Panics if less is nil and q was not created by NewPriority.
+func Max[T any](q Queue[T], less func(a, b T) bool) (T, bool) {
+	if less == nil {
+		return ordered(q).extreme(true)
+	}
+
+	return scanExtreme(q, less)
+}
+
+// scanExtreme visits the items of q with ForEach and returns the best one,
+// where replaces(best, v) reports whether v beats the best item so far.
+func scanExtreme[T any](q Queue[T], replaces func(best, v T) bool) (T, bool) {
+	var best T
+	found := false
+	q.ForEach(func(v T) bool {
+		if !found || replaces(best, v) {
+			best, found = v, true
+		}
+		return true
+	})
+
+	return best, found
+}
+
+// ordered returns the priority queue behind q, for Min and Max with a nil
+// less.
+func ordered[T any](q Queue[T]) *queue[T] {
+	inner, ok := asQueue(q)
+	if !ok || inner.less == nil {
+		panic("cannot specify nil less for a queue without priority order")
+	}
+
+	return inner
+}
+
+// extreme returns the item Peek would return, or the one Back would return
+// if last is set, reporting false if the queue is empty. The queue must be a
+// priority queue.
+func (q *queue[T]) extreme(last bool) (T, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.items.len() == 0 {
+		var zero T
+		return zero, false
+	}
+	if last {
+		return q.items.at(q.lastIndex()), true
+	}
+
+	return q.items.at(0), true
+}
+
+// contents returns a copy of the items of q and its capacity. Queues with a
+// lock of their own are copied atomically.
+func contents[T any](q Queue[T]) ([]T, int) {
+	if inner, ok := asQueue(q); ok {
+		inner.mu.RLock()
+		defer inner.mu.RUnlock()
+
+		items := make([]T, inner.items.len())
+		inner.items.copyTo(items)
+		return items, inner.capacity
+	}
+
+	items := slices.Collect(q.All())
+	capacity := q.Remaining()
+	if capacity != UnlimitedCapacity {
+		capacity += len(items)
+	}
+
+	return items, capacity
+}
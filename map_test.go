@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	q := New[int](WithCapacity[int](5))
+	for i := 1; i <= 3; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	mapped := Map(q, strconv.Itoa)
+
+	if got := slices.Collect(mapped.All()); !slices.Equal(got, []string{"1", "2", "3"}) {
+		t.Errorf("Map() items = %v, want [1 2 3]", got)
+	}
+	if r := mapped.Remaining(); r != 2 {
+		t.Errorf("Remaining() of mapped queue = %d, want 2", r)
+	}
+
+	// The queues are independent of each other
+	_, _ = mapped.Dequeue()
+	_ = q.Enqueue(4)
+	if size := q.Size(); size != 4 {
+		t.Errorf("source Size = %d, want 4", size)
+	}
+	if size := mapped.Size(); size != 2 {
+		t.Errorf("mapped Size = %d, want 2", size)
+	}
+}
+
+func TestMapUnlimited(t *testing.T) {
+	mapped := Map(New[int](), func(v int) int { return v * 2 })
+	if r := mapped.Remaining(); r != UnlimitedCapacity {
+		t.Errorf("Remaining() of mapped unlimited queue = %d, want %d", r, UnlimitedCapacity)
+	}
+}
+
+func TestMapSharded(t *testing.T) {
+	q := NewSharded[int](2, WithCapacity[int](4))
+	for i := 1; i <= 3; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	mapped := Map(q, strconv.Itoa)
+	want := slices.Collect(q.All())
+	got := slices.Collect(mapped.All())
+	for i := range want {
+		if got[i] != strconv.Itoa(want[i]) {
+			t.Errorf("mapped item %d = %q, want %q", i, got[i], strconv.Itoa(want[i]))
+		}
+	}
+	if r := mapped.Remaining(); r != 1 {
+		t.Errorf("Remaining() of mapped queue = %d, want 1", r)
+	}
+}
+
+func TestFold(t *testing.T) {
+	ints := New[int]()
+	_, _ = ints.EnqueueSlice([]int{1, 2, 3, 4})
+	if sum := Fold(ints, 0, func(acc, v int) int { return acc + v }); sum != 10 {
+		t.Errorf("Fold() sum = %d, want 10", sum)
+	}
+
+	strs := New[string]()
+	_, _ = strs.EnqueueSlice([]string{"a", "b", "c"})
+	if got := Fold(strs, ">", func(acc, s string) string { return acc + s }); got != ">abc" {
+		t.Errorf("Fold() concatenation = %q, want %q", got, ">abc")
+	}
+
+	// The queues are left intact
+	if got := slices.Collect(ints.All()); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("items after Fold() = %v, want [1 2 3 4]", got)
+	}
+	if got := slices.Collect(strs.All()); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("items after Fold() = %v, want [a b c]", got)
+	}
+	if s := strs.Stats(); s.Dequeued != 0 {
+		t.Errorf("Stats().Dequeued after Fold() = %d, want 0", s.Dequeued)
+	}
+}
+
+func TestFoldEmptyAndSharded(t *testing.T) {
+	if got := Fold(New[int](), 7, func(acc, v int) int { return acc + v }); got != 7 {
+		t.Errorf("Fold() of empty queue = %d, want init 7", got)
+	}
+
+	s := NewSharded[int](3)
+	_, _ = s.EnqueueSlice([]int{1, 2, 3, 4, 5})
+	got := Fold(s, []int(nil), func(acc []int, v int) []int { return append(acc, v) })
+	if want := slices.Collect(s.All()); !slices.Equal(got, want) {
+		t.Errorf("Fold() over sharded queue = %v, want %v", got, want)
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	q := New[int]()
+	if _, ok := Min(q, less); ok {
+		t.Error("Min() of empty queue reported an item")
+	}
+	if _, ok := Max(q, less); ok {
+		t.Error("Max() of empty queue reported an item")
+	}
+
+	_, _ = q.EnqueueSlice([]int{3, 1, 4, 1, 5, 9, 2, 6})
+	if v, ok := Min(q, less); v != 1 || !ok {
+		t.Errorf("Min() = (%d, %v), want (1, true)", v, ok)
+	}
+	if v, ok := Max(q, less); v != 9 || !ok {
+		t.Errorf("Max() = (%d, %v), want (9, true)", v, ok)
+	}
+	if size := q.Size(); size != 8 {
+		t.Errorf("Size() after Min() and Max() = %d, want 8", size)
+	}
+
+	// A nil less uses the order of a priority queue
+	p := NewPriority[int](less)
+	_, _ = p.EnqueueSlice([]int{3, 1, 4, 1, 5, 9, 2, 6})
+	if v, ok := Min(p, nil); v != 1 || !ok {
+		t.Errorf("Min(nil) of priority queue = (%d, %v), want (1, true)", v, ok)
+	}
+	if v, ok := Max(p, nil); v != 9 || !ok {
+		t.Errorf("Max(nil) of priority queue = (%d, %v), want (9, true)", v, ok)
+	}
+}
+
+func TestMinMaxStructs(t *testing.T) {
+	type job struct {
+		name     string
+		priority int
+	}
+	byPriority := func(a, b job) bool { return a.priority < b.priority }
+
+	q := NewSharded[job](2)
+	_, _ = q.EnqueueSlice([]job{{"a", 2}, {"b", 7}, {"c", 0}, {"d", 7}})
+	if v, _ := Min(q, byPriority); v.name != "c" {
+		t.Errorf("Min() = %+v, want job c", v)
+	}
+	if v, _ := Max(q, byPriority); v.priority != 7 {
+		t.Errorf("Max() = %+v, want a job of priority 7", v)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Min(nil) of a FIFO queue should panic, but it didn't")
+		}
+	}()
+	Min(q, nil)
+}
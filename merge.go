@@ -0,0 +1,429 @@
+package queue
+
+import "errors"
+
+func (q *queue[T]) Merge(other Queue[T]) error {
+	src, ok := asQueue(other)
+	if !ok {
+		return mergeEach(q, other)
+	}
+
+	// Appending a queue's items to itself while draining it is a no-op
+	if src == q {
+		return nil
+	}
+
+	first, second := lockOrder(q, src)
+	first.mu.Lock()
+	second.mu.Lock()
+	moved, err := q.mergeLocked(src)
+	dropped := q.takeDropped()
+	second.mu.Unlock()
+	first.mu.Unlock()
+	q.forward(dropped)
+	q.flushIfDue()
+	src.flushIfDue()
+
+	for _, val := range moved {
+		runHooks(src.onDequeue, val)
+		runHooks(q.onEnqueue, val)
+	}
+
+	return err
+}
+
+// mergeLocked moves every item of src to the back of q and returns the items
+// moved. Nothing is moved if an error is returned. The caller must hold the
+// write locks of both queues.
+func (q *queue[T]) mergeLocked(src *queue[T]) ([]T, error) {
+	vals := make([]T, src.items.len())
+	src.items.copyTo(vals)
+	if err := q.checkAll(vals, false); err != nil {
+		return nil, err
+	}
+
+	moved := make([]T, 0, len(vals))
+	for {
+		val, ok := src.pop()
+		if !ok {
+			break
+		}
+		q.accepts(val)
+		q.add(val)
+		moved = append(moved, val)
+	}
+
+	return moved, nil
+}
+
+// checkAll returns the error adding vals to q fails with, or replacing the
+// items of q with vals if replace is set, if any: ErrClosed, ErrNilValue,
+// ErrInvalidItem, ErrDuplicate, or ErrOverflow if they do not fit, which a
+// circular queue only checks when replacing. The caller must hold the write
+// lock.
+func (q *queue[T]) checkAll(vals []T, replace bool) error {
+	if q.closed {
+		return ErrClosed
+	}
+
+	bytes := 0
+	for _, val := range vals {
+		if err := q.invalid(val); err != nil {
+			return err
+		}
+		size := q.itemBytes(val)
+		if q.sizeOf != nil && size > q.maxBytes {
+			q.stats.Rejected++
+			return ErrOverflow
+		}
+		bytes += size
+	}
+	if q.duplicates(vals, !replace) {
+		return ErrDuplicate
+	}
+	n := len(vals)
+	if replace {
+		n, bytes = n-q.items.len(), bytes-q.bytes
+	} else if q.circular {
+		return nil
+	}
+	if !q.fits(n) || !q.fitsBytes(bytes) {
+		q.stats.Rejected++
+		return ErrOverflow
+	}
+
+	return nil
+}
+
+// admitter is implemented by the queues of this package, so that Merge and
+// CopyInto can check every item against the destination before changing
+// either queue.
+type admitter[T any] interface {
+	// admits returns the error adding vals to the queue would fail with, or
+	// replacing its items with vals if replace is set, if any.
+	admits(vals []T, replace bool) error
+}
+
+func (q *queue[T]) admits(vals []T, replace bool) error {
+	q.mu.Lock()
+	defer q.unlock()
+
+	return q.checkAll(vals, replace)
+}
+
+// mergeEach implements Merge for queues that cannot be locked together. It
+// checks every item of src against dst up front, so nothing is moved if dst
+// would reject one, and then moves them one at a time, so it is not atomic:
+// concurrent operations on either queue may interleave with it. An item that
+// no longer fits is returned to the front of src.
+func mergeEach[T any](dst, src Queue[T]) error {
+	vals, _ := src.Snapshot()
+	if a, ok := dst.(admitter[T]); ok {
+		if err := a.admits(vals, false); err != nil {
+			return err
+		}
+	}
+
+	for range vals {
+		if ok, err := moveFront(src, dst); !ok || err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// moveFront moves the front item of from to the back of to, reporting whether
+// from held one. If to rejects the item, it stays at the front of from and
+// the error Enqueue returned is returned, joined with the error putting it
+// back failed with, if any.
+func moveFront[T any](from, to Queue[T]) (bool, error) {
+	// An item taken from an SPSC queue cannot be put back, but as its only
+	// consumer the caller can take the front item once to has accepted it
+	if consumedAlone(from) {
+		val, err := from.Peek()
+		if err != nil {
+			return false, nil
+		}
+		if err := to.Enqueue(val); err != nil {
+			return true, err
+		}
+		from.TryDequeue()
+		return true, nil
+	}
+
+	val, ok := from.TryDequeue()
+	if !ok {
+		return false, nil
+	}
+	if err := to.Enqueue(val); err != nil {
+		// A view puts the item back into its source, where it is again the
+		// first one the view sees
+		if v, ok := from.(interface{ source() Queue[T] }); ok {
+			from = v.source()
+		}
+		if putErr := from.EnqueueFrontAll(val); putErr != nil {
+			return true, errors.Join(err, putErr)
+		}
+		return true, err
+	}
+
+	return true, nil
+}
+
+// consumedAlone reports whether q is an SPSC queue, or a view of one, which
+// only the caller may dequeue from.
+func consumedAlone[T any](q Queue[T]) bool {
+	if v, ok := q.(interface{ source() Queue[T] }); ok {
+		q = v.source()
+	}
+	_, ok := q.(interface{ singleConsumer() })
+
+	return ok
+}
+
+// Transfer moves up to n items from the front of from to the back of to, in
+// the order from would dequeue them, and returns the number moved. It stops
+// early if from runs out of items, or at the first item to cannot take; that
+// item stays at the front of from, and the error to would have returned from
+// Enqueue is returned with the number moved before it.
+//
+// When both queues were created by New, NewPriority or NewBlocking, they are
+// locked together for the whole transfer, in an order that cannot deadlock
+// even if another Transfer runs between the same queues in the opposite
+// direction, so no other operation sees a partial transfer. Otherwise items
+// are moved one at a time and an item to rejects is put back with
+// EnqueueFrontAll, into the source of a view, or left in place for an SPSC
+// queue, so concurrent operations may interleave with the transfer.
+// from and to may be the same queue, which moves items from its front to its
+// back.
+//
+// Example:
+//
+//	// Hand the next 10 jobs to the fast lane, as many as it has room for
+//	moved, err := queue.Transfer(backlog, fastLane, 10)
+//	if errors.Is(err, queue.ErrOverflow) {
+//		log.Printf("fast lane full after %d jobs", moved)
+//	}
+//
+// Returns ErrNegativeCount if n < 0.
+func Transfer[T any](from, to Queue[T], n int) (int, error) {
+	if n < 0 {
+		return 0, ErrNegativeCount
+	}
+
+	src, okSrc := asQueue(from)
+	dst, okDst := asQueue(to)
+	if !okSrc || !okDst {
+		return transferEach(from, to, n)
+	}
+
+	first, second := lockOrder(src, dst)
+	first.mu.Lock()
+	if second != first {
+		second.mu.Lock()
+	}
+	moved, err := dst.transferLocked(src, n)
+	dropped := dst.takeDropped()
+	if second != first {
+		second.mu.Unlock()
+	}
+	first.mu.Unlock()
+	dst.forward(dropped)
+	dst.flushIfDue()
+	src.flushIfDue()
+
+	for _, val := range moved {
+		runHooks(src.onDequeue, val)
+		runHooks(dst.onEnqueue, val)
+	}
+
+	return len(moved), err
+}
+
+// transferLocked moves up to n items from the front of src to the back of q,
+// stopping at the first item q cannot take, and returns the items moved. src
+// may be q itself. The caller must hold the write locks of both queues.
+func (q *queue[T]) transferLocked(src *queue[T], n int) ([]T, error) {
+	if q.closed {
+		return nil, ErrClosed
+	}
+
+	moved := make([]T, 0, min(n, src.items.len()))
+	for len(moved) < n && src.items.len() > 0 {
+		val := src.items.at(0)
+		if err := q.invalid(val); err != nil {
+			return moved, err
+		}
+		if src != q && q.duplicate(val) {
+			return moved, ErrDuplicate
+		}
+		// A queue moving items to itself frees the room each item needs
+		if src != q && !q.accepts(val) {
+			q.stats.Rejected++
+			return moved, ErrOverflow
+		}
+		src.pop()
+		q.add(val)
+		moved = append(moved, val)
+	}
+
+	return moved, nil
+}
+
+// transferEach implements Transfer for queues that cannot be locked together.
+func transferEach[T any](from, to Queue[T], n int) (int, error) {
+	moved := 0
+	for ; moved < n; moved++ {
+		ok, err := moveFront(from, to)
+		if err != nil {
+			return moved, err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	return moved, nil
+}
+
+func (q *queue[T]) CopyInto(dst Queue[T]) error {
+	d, ok := asQueue(dst)
+	if !ok {
+		return copyEach(q.snapshot(), dst)
+	}
+
+	// A queue already holds its own items
+	if d == q {
+		return nil
+	}
+
+	first, second := lockOrder(q, d)
+	first.mu.Lock()
+	second.mu.Lock()
+	copied, err := d.copyLocked(q)
+	second.mu.Unlock()
+	first.mu.Unlock()
+	d.flushIfDue()
+
+	for _, val := range copied {
+		runHooks(d.onEnqueue, val)
+	}
+
+	return err
+}
+
+// copyLocked replaces the items of q with those of src, in storage order, and
+// returns the items copied. Nothing changes if an error is returned. The
+// caller must hold the write locks of both queues.
+func (q *queue[T]) copyLocked(src *queue[T]) ([]T, error) {
+	copied := make([]T, src.items.len())
+	src.items.copyTo(copied)
+	if err := q.checkAll(copied, true); err != nil {
+		return nil, err
+	}
+
+	q.untrackAll()
+	q.items.truncate(0)
+	q.bytes = 0
+	q.changed()
+	q.notFull.broadcast()
+	q.addInOrder(copied)
+
+	return copied, nil
+}
+
+// copyEach implements CopyInto for queues that cannot be locked together. It
+// checks every item against dst up front and then clears dst and enqueues
+// vals into it, so it is not atomic: concurrent operations on dst may
+// interleave with it.
+func copyEach[T any](vals []T, dst Queue[T]) error {
+	if _, ok := dst.(interface{ readOnly() }); ok {
+		return ErrReadOnly
+	}
+	if a, ok := dst.(admitter[T]); ok {
+		if err := a.admits(vals, true); err != nil {
+			return err
+		}
+	} else if r := dst.Remaining(); r != UnlimitedCapacity && len(vals) > r+dst.Size() {
+		return ErrOverflow
+	}
+
+	dst.Filter(func(T) bool { return false })
+	_, err := dst.EnqueueSlice(vals)
+
+	return err
+}
+
+func (q *queue[T]) Split(n int) (Queue[T], error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+
+	q.mu.Lock()
+	head := q.derive()
+	moved := make([]T, 0, min(n, q.items.len()))
+	for len(moved) < n {
+		val, ok := q.pop()
+		if !ok {
+			break
+		}
+		moved = append(moved, val)
+	}
+	head.addInOrder(moved)
+	q.mu.Unlock()
+	q.flushIfDue() // head runs its callback, if due, once its owner has it
+
+	for _, val := range moved {
+		runHooks(q.onDequeue, val)
+		runHooks(head.onEnqueue, val)
+	}
+
+	return head, nil
+}
+
+// derive returns an empty queue configured like q, with q's current capacity.
+// The caller must hold the lock.
+func (q *queue[T]) derive() *queue[T] {
+	d := &queue[T]{
+		mu:           q.mu.fresh(),
+		capacity:     q.capacity,
+		initCap:      q.initCap,
+		growth:       q.growth,
+		shrinkRatio:  q.shrinkRatio,
+		limiter:      q.limiter.clone(),
+		enqueueLimit: q.enqueueLimit.clone(),
+		shrinkPolicy: q.shrinkPolicy,
+		circular:     q.circular,
+		lifo:         q.lifo,
+		clock:        q.clock,
+		name:         q.name,
+		onEnqueue:    q.onEnqueue,
+		onDequeue:    q.onDequeue,
+		onExpire:     q.onExpire,
+		less:         q.less,
+		isNil:        q.isNil,
+		validators:   q.validators,
+		sizeOf:       q.sizeOf,
+		maxBytes:     q.maxBytes,
+		copyOnPeek:   q.copyOnPeek,
+		results:      q.results,
+		tracer:       q.tracer,
+		deadLetter:   q.deadLetter,
+		onFlush:      q.onFlush,
+		flushAt:      q.flushAt,
+	}
+	if q.dedup != nil {
+		d.dedup = q.dedup.fresh()
+	}
+	if q.index != nil {
+		d.index = q.index.fresh()
+	}
+	if q.changes != nil {
+		d.changes = q.changes.fresh()
+	}
+	d.items = newRing[T](d.initialSize())
+	d.flushes(d)
+
+	return d
+}
@@ -0,0 +1,589 @@
+package queue
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	dst := New[int]()
+	src := New[int]()
+	_ = dst.Enqueue(1)
+	_ = src.Enqueue(2)
+	_ = src.Enqueue(3)
+
+	if err := dst.Merge(src); err != nil {
+		t.Fatalf("Merge() = %v, want nil", err)
+	}
+
+	if size := src.Size(); size != 0 {
+		t.Errorf("source Size after Merge() = %d, want 0", size)
+	}
+	for _, expected := range []int{1, 2, 3} {
+		if val, _ := dst.Dequeue(); val != expected {
+			t.Errorf("Dequeue() after Merge() = %d, want %d", val, expected)
+		}
+	}
+	if s := src.Stats(); s.Dequeued != 2 {
+		t.Errorf("source Stats().Dequeued = %d, want 2", s.Dequeued)
+	}
+}
+
+func TestMergeCapacity(t *testing.T) {
+	t.Run("fits exactly", func(t *testing.T) {
+		dst := New[int](WithCapacity[int](3))
+		src := New[int]()
+		_ = dst.Enqueue(1)
+		_ = src.Enqueue(2)
+		_ = src.Enqueue(3)
+
+		if err := dst.Merge(src); err != nil {
+			t.Fatalf("Merge() = %v, want nil", err)
+		}
+		if size := dst.Size(); size != 3 {
+			t.Errorf("Size after Merge() = %d, want 3", size)
+		}
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		dst := New[int](WithCapacity[int](2))
+		src := New[int]()
+		_ = dst.Enqueue(1)
+		_ = src.Enqueue(2)
+		_ = src.Enqueue(3)
+
+		if err := dst.Merge(src); !errors.Is(err, ErrOverflow) {
+			t.Fatalf("Merge() = %v, want %v", err, ErrOverflow)
+		}
+		if size := dst.Size(); size != 1 {
+			t.Errorf("destination Size after failed Merge() = %d, want 1", size)
+		}
+		if size := src.Size(); size != 2 {
+			t.Errorf("source Size after failed Merge() = %d, want 2", size)
+		}
+	})
+
+	t.Run("circular", func(t *testing.T) {
+		dst := New[int](WithCapacity[int](2), WithCircular[int]())
+		src := New[int]()
+		for i := 1; i <= 3; i++ {
+			_ = src.Enqueue(i)
+		}
+
+		if err := dst.Merge(src); err != nil {
+			t.Fatalf("Merge() = %v, want nil", err)
+		}
+		for _, expected := range []int{2, 3} {
+			if val, _ := dst.Dequeue(); val != expected {
+				t.Errorf("Dequeue() after Merge() = %d, want %d", val, expected)
+			}
+		}
+	})
+}
+
+func TestMergeClosed(t *testing.T) {
+	dst := New[int]()
+	src := New[int]()
+	_ = src.Enqueue(1)
+	_ = dst.Close()
+
+	if err := dst.Merge(src); !errors.Is(err, ErrClosed) {
+		t.Errorf("Merge() into closed queue = %v, want %v", err, ErrClosed)
+	}
+	if size := src.Size(); size != 1 {
+		t.Errorf("source Size after failed Merge() = %d, want 1", size)
+	}
+}
+
+func TestMergeSelf(t *testing.T) {
+	q := New[int]()
+	_ = q.Enqueue(1)
+
+	if err := q.Merge(q); err != nil {
+		t.Errorf("Merge(self) = %v, want nil", err)
+	}
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size after Merge(self) = %d, want 1", size)
+	}
+}
+
+func TestMergePriority(t *testing.T) {
+	dst := New[int]()
+	src := NewPriority[int](intLess)
+	for _, v := range []int{3, 1, 2} {
+		_ = src.Enqueue(v)
+	}
+
+	_ = dst.Merge(src)
+
+	// Items arrive in the order the source would have dequeued them
+	for _, expected := range []int{1, 2, 3} {
+		if val, _ := dst.Dequeue(); val != expected {
+			t.Errorf("Dequeue() after Merge() = %d, want %d", val, expected)
+		}
+	}
+}
+
+func TestMergeSharded(t *testing.T) {
+	dst := NewSharded[int](2, WithCapacity[int](4))
+	src := New[int]()
+	for i := 1; i <= 3; i++ {
+		_ = src.Enqueue(i)
+	}
+
+	if err := dst.Merge(src); err != nil {
+		t.Fatalf("Merge() = %v, want nil", err)
+	}
+	if size := dst.Size(); size != 3 {
+		t.Errorf("Size after Merge() = %d, want 3", size)
+	}
+
+	_ = src.Enqueue(4)
+	_ = src.Enqueue(5)
+	if err := dst.Merge(src); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Merge() = %v, want %v", err, ErrOverflow)
+	}
+	if size := src.Size(); size != 2 {
+		t.Errorf("source Size after failed Merge() = %d, want 2", size)
+	}
+
+	// Draining a sharded queue into a plain one
+	back := New[int]()
+	if err := back.Merge(dst); err != nil {
+		t.Fatalf("Merge() from sharded = %v, want nil", err)
+	}
+	if size, left := back.Size(), dst.Size(); size != 3 || left != 0 {
+		t.Errorf("Sizes after Merge() from sharded = (%d, %d), want (3, 0)", size, left)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		head []int
+		rest []int
+	}{
+		{name: "none", n: 0, head: nil, rest: []int{1, 2, 3, 4}},
+		{name: "half", n: 2, head: []int{1, 2}, rest: []int{3, 4}},
+		{name: "all", n: 4, head: []int{1, 2, 3, 4}, rest: nil},
+		{name: "more than size", n: 10, head: []int{1, 2, 3, 4}, rest: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := New[int]()
+			for i := 1; i <= 4; i++ {
+				_ = q.Enqueue(i)
+			}
+
+			head, err := q.Split(tt.n)
+			if err != nil {
+				t.Fatalf("Split(%d) = %v, want nil", tt.n, err)
+			}
+
+			for name, check := range map[string]struct {
+				q    Queue[int]
+				want []int
+			}{"head": {head, tt.head}, "rest": {q, tt.rest}} {
+				if size := check.q.Size(); size != len(check.want) {
+					t.Errorf("%s Size after Split(%d) = %d, want %d", name, tt.n, size, len(check.want))
+				}
+				for _, expected := range check.want {
+					if val, _ := check.q.Dequeue(); val != expected {
+						t.Errorf("%s Dequeue() after Split(%d) = %d, want %d", name, tt.n, val, expected)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("negative", func(t *testing.T) {
+		if _, err := New[int]().Split(-1); !errors.Is(err, ErrNegativeCount) {
+			t.Errorf("Split(-1) = %v, want %v", err, ErrNegativeCount)
+		}
+	})
+}
+
+func TestSplitCopiesOptions(t *testing.T) {
+	var hooked []int
+	q := New[*int](
+		WithCapacity[*int](3),
+		WithCircular[*int](),
+		WithRejectNil[*int](),
+		WithOnEnqueue(func(v *int) { hooked = append(hooked, *v) }),
+	)
+	for i := 1; i <= 2; i++ {
+		_ = q.Enqueue(&i)
+	}
+	hooked = nil
+
+	head, _ := q.Split(1)
+
+	if r := head.Remaining(); r != 2 {
+		t.Errorf("Remaining() of split queue = %d, want 2", r)
+	}
+	if err := head.Enqueue(nil); !errors.Is(err, ErrNilValue) {
+		t.Errorf("Enqueue(nil) on split queue = %v, want %v", err, ErrNilValue)
+	}
+	for i := 0; i < 3; i++ {
+		if err := head.Enqueue(&i); err != nil {
+			t.Errorf("Enqueue() on full circular split queue = %v, want nil", err)
+		}
+	}
+	if len(hooked) != 4 {
+		t.Errorf("enqueue hook calls on split queue = %d, want 4", len(hooked))
+	}
+}
+
+func TestSplitPriority(t *testing.T) {
+	q := NewPriority[int](intLess)
+	for _, v := range []int{4, 2, 3, 1} {
+		_ = q.Enqueue(v)
+	}
+
+	head, _ := q.Split(2)
+	_ = head.Enqueue(0)
+
+	for _, expected := range []int{0, 1, 2} {
+		if val, _ := head.Dequeue(); val != expected {
+			t.Errorf("head Dequeue() = %d, want %d", val, expected)
+		}
+	}
+	for _, expected := range []int{3, 4} {
+		if val, _ := q.Dequeue(); val != expected {
+			t.Errorf("rest Dequeue() = %d, want %d", val, expected)
+		}
+	}
+}
+
+func TestShardedSplit(t *testing.T) {
+	q := NewSharded[int](2, WithCapacity[int](4))
+	for i := 1; i <= 4; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	head, err := q.Split(3)
+	if err != nil {
+		t.Fatalf("Split(3) = %v, want nil", err)
+	}
+	if size, rest := head.Size(), q.Size(); size != 3 || rest != 1 {
+		t.Errorf("Sizes after Split(3) = (%d, %d), want (3, 1)", size, rest)
+	}
+	if r := head.Remaining(); r != 1 {
+		t.Errorf("Remaining() of split queue = %d, want 1", r)
+	}
+}
+
+func TestMergeRejected(t *testing.T) {
+	sources := map[string]func(vals []int) Queue[int]{
+		"view": func(vals []int) Queue[int] {
+			q := New[int]()
+			_, _ = q.EnqueueSlice(vals)
+			return NewView(q, func(int) bool { return true })
+		},
+		"expiring": func(vals []int) Queue[int] {
+			q := NewExpiring[int]()
+			_, _ = q.EnqueueSlice(vals)
+			return q
+		},
+	}
+	for name, newSrc := range sources {
+		t.Run(name, func(t *testing.T) {
+			src := newSrc([]int{1, 2, 3})
+			dst := New(WithValidator(func(v int) error {
+				if v == 2 {
+					return errors.New("two")
+				}
+				return nil
+			}))
+
+			// Every item is checked before any is moved
+			if err := dst.Merge(src); !errors.Is(err, ErrInvalidItem) {
+				t.Fatalf("Merge() = %v, want %v", err, ErrInvalidItem)
+			}
+			if got := slices.Collect(src.All()); !slices.Equal(got, []int{1, 2, 3}) {
+				t.Errorf("source items after failed Merge() = %v, want [1 2 3]", got)
+			}
+			if size := dst.Size(); size != 0 {
+				t.Errorf("destination Size after failed Merge() = %d, want 0", size)
+			}
+		})
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	variants := []struct {
+		name  string
+		newFn func(opts ...Option[int]) Queue[int]
+	}{
+		{name: "queue", newFn: func(opts ...Option[int]) Queue[int] { return New(opts...) }},
+		{name: "expiring", newFn: func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) }},
+	}
+
+	for _, v := range variants {
+		t.Run(v.name+"/full", func(t *testing.T) {
+			from, to := v.newFn(), v.newFn()
+			_, _ = from.EnqueueSlice([]int{1, 2, 3, 4})
+			_ = to.Enqueue(0)
+
+			moved, err := Transfer(from, to, 3)
+			if moved != 3 || err != nil {
+				t.Fatalf("Transfer(3) = (%d, %v), want (3, nil)", moved, err)
+			}
+			if got := slices.Collect(to.All()); !slices.Equal(got, []int{0, 1, 2, 3}) {
+				t.Errorf("destination items = %v, want [0 1 2 3]", got)
+			}
+			if got := slices.Collect(from.All()); !slices.Equal(got, []int{4}) {
+				t.Errorf("source items = %v, want [4]", got)
+			}
+
+			// Asking for more items than there are moves what is left
+			if moved, err := Transfer(from, to, 10); moved != 1 || err != nil {
+				t.Errorf("Transfer(10) of 1 item = (%d, %v), want (1, nil)", moved, err)
+			}
+		})
+
+		t.Run(v.name+"/overflow", func(t *testing.T) {
+			from, to := v.newFn(), v.newFn(WithCapacity[int](3))
+			_, _ = from.EnqueueSlice([]int{1, 2, 3, 4})
+			_ = to.Enqueue(0)
+
+			moved, err := Transfer(from, to, 4)
+			if moved != 2 || !errors.Is(err, ErrOverflow) {
+				t.Fatalf("Transfer(4) into 2 free slots = (%d, %v), want (2, %v)", moved, err, ErrOverflow)
+			}
+			if got := slices.Collect(to.All()); !slices.Equal(got, []int{0, 1, 2}) {
+				t.Errorf("destination items = %v, want [0 1 2]", got)
+			}
+			// The item that did not fit is back at the front, in order
+			if got := slices.Collect(from.All()); !slices.Equal(got, []int{3, 4}) {
+				t.Errorf("source items after overflow = %v, want [3 4]", got)
+			}
+			if err := CheckInvariants(from); err != nil {
+				t.Errorf("CheckInvariants(source) = %v", err)
+			}
+		})
+	}
+
+	t.Run("sources that cannot take items back", func(t *testing.T) {
+		spsc := NewSPSC[int](4)
+		_, _ = spsc.EnqueueSlice([]int{1, 2})
+		base := New[int]()
+		_, _ = base.EnqueueSlice([]int{1, 2})
+
+		for name, from := range map[string]Queue[int]{
+			"spsc": spsc,
+			"view": NewView(base, func(int) bool { return true }),
+		} {
+			to := New[int](WithCapacity[int](1))
+			moved, err := Transfer(from, to, 2)
+			if moved != 1 || !errors.Is(err, ErrOverflow) {
+				t.Fatalf("Transfer(2) from %s into 1 free slot = (%d, %v), want (1, %v)", name, moved, err, ErrOverflow)
+			}
+			if got := slices.Collect(to.All()); !slices.Equal(got, []int{1}) {
+				t.Errorf("destination items = %v, want [1]", got)
+			}
+			// The item that did not fit stays at the front of the source
+			if got := slices.Collect(from.All()); !slices.Equal(got, []int{2}) {
+				t.Errorf("%s items after overflow = %v, want [2]", name, got)
+			}
+		}
+	})
+
+	t.Run("hooks and stats", func(t *testing.T) {
+		var dequeued, enqueued []int
+		from := New(WithOnDequeue(func(v int) { dequeued = append(dequeued, v) }))
+		to := New(WithOnEnqueue(func(v int) { enqueued = append(enqueued, v) }))
+		_, _ = from.EnqueueSlice([]int{1, 2})
+
+		_, _ = Transfer(from, to, 2)
+		if !slices.Equal(dequeued, []int{1, 2}) || !slices.Equal(enqueued, []int{1, 2}) {
+			t.Errorf("hooks saw dequeues %v and enqueues %v, want [1 2] for both", dequeued, enqueued)
+		}
+		if s := from.Stats(); s.Dequeued != 2 {
+			t.Errorf("source Stats().Dequeued = %d, want 2", s.Dequeued)
+		}
+	})
+
+	t.Run("self", func(t *testing.T) {
+		q := New[int](WithCapacity[int](3))
+		_, _ = q.EnqueueSlice([]int{1, 2, 3})
+		if moved, err := Transfer(q, q, 2); moved != 2 || err != nil {
+			t.Fatalf("Transfer(q, q, 2) = (%d, %v), want (2, nil)", moved, err)
+		}
+		if got := slices.Collect(q.All()); !slices.Equal(got, []int{3, 1, 2}) {
+			t.Errorf("items after moving 2 to the back = %v, want [3 1 2]", got)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		from, to := New[int](), New[int]()
+		_ = from.Enqueue(1)
+		if _, err := Transfer(from, to, -1); !errors.Is(err, ErrNegativeCount) {
+			t.Errorf("Transfer(-1) = %v, want %v", err, ErrNegativeCount)
+		}
+		_ = to.Close()
+		if moved, err := Transfer(from, to, 1); moved != 0 || !errors.Is(err, ErrClosed) {
+			t.Errorf("Transfer() into closed queue = (%d, %v), want (0, %v)", moved, err, ErrClosed)
+		}
+		if size := from.Size(); size != 1 {
+			t.Errorf("source Size after failed Transfer() = %d, want 1", size)
+		}
+	})
+
+	t.Run("opposite directions", func(t *testing.T) {
+		a, b := New[int](), New[int]()
+		for i := range 100 {
+			_ = a.Enqueue(i)
+			_ = b.Enqueue(i)
+		}
+
+		// Transfers in both directions at once must not deadlock
+		var wg sync.WaitGroup
+		for _, pair := range [][2]Queue[int]{{a, b}, {b, a}} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 1000 {
+					_, _ = Transfer(pair[0], pair[1], 3)
+				}
+			}()
+		}
+		wg.Wait()
+		if total := a.Size() + b.Size(); total != 200 {
+			t.Errorf("items left after concurrent transfers = %d, want 200", total)
+		}
+	})
+}
+
+func TestCopyInto(t *testing.T) {
+	t.Run("replaces contents", func(t *testing.T) {
+		var enqueued []int
+		src := New[int]()
+		dst := New(WithCapacity[int](5), WithOnEnqueue(func(v int) { enqueued = append(enqueued, v) }))
+		_, _ = src.EnqueueSlice([]int{1, 2, 3})
+		_, _ = dst.EnqueueSlice([]int{9, 8})
+		enqueued = nil
+
+		if err := src.CopyInto(dst); err != nil {
+			t.Fatalf("CopyInto() = %v, want nil", err)
+		}
+		if got := slices.Collect(dst.All()); !slices.Equal(got, []int{1, 2, 3}) {
+			t.Errorf("destination items = %v, want [1 2 3]", got)
+		}
+		if got := slices.Collect(src.All()); !slices.Equal(got, []int{1, 2, 3}) {
+			t.Errorf("source items after CopyInto() = %v, want [1 2 3]", got)
+		}
+		if r := dst.Remaining(); r != 2 {
+			t.Errorf("destination Remaining = %d, want 2", r)
+		}
+		if !slices.Equal(enqueued, []int{1, 2, 3}) {
+			t.Errorf("destination enqueue hook saw %v, want [1 2 3]", enqueued)
+		}
+		if err := CheckInvariants(dst); err != nil {
+			t.Errorf("CheckInvariants(destination) = %v", err)
+		}
+
+		// The copy is independent of the source
+		_, _ = src.Dequeue()
+		if size := dst.Size(); size != 3 {
+			t.Errorf("destination Size after source Dequeue() = %d, want 3", size)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		src := New[int]()
+		_, _ = src.EnqueueSlice([]int{1, 2, 3})
+
+		tests := []struct {
+			name   string
+			dst    Queue[int]
+			closed bool
+			want   error
+		}{
+			{name: "too small", dst: New(WithCapacity[int](2)), want: ErrOverflow},
+			{name: "too small circular", dst: New(WithCapacity[int](2), WithCircular[int]()), want: ErrOverflow},
+			{name: "sharded too small", dst: NewSharded(2, WithCapacity[int](2)), want: ErrOverflow},
+			{name: "closed", dst: New[int](), closed: true, want: ErrClosed},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_ = tt.dst.Enqueue(7)
+				if tt.closed {
+					_ = tt.dst.Close()
+				}
+
+				if err := src.CopyInto(tt.dst); !errors.Is(err, tt.want) {
+					t.Errorf("CopyInto() = %v, want %v", err, tt.want)
+				}
+				if got := slices.Collect(tt.dst.All()); !slices.Equal(got, []int{7}) {
+					t.Errorf("destination items after failed CopyInto() = %v, want [7]", got)
+				}
+				if size := src.Size(); size != 3 {
+					t.Errorf("source Size after failed CopyInto() = %d, want 3", size)
+				}
+			})
+		}
+	})
+
+	t.Run("across kinds", func(t *testing.T) {
+		kinds := []struct {
+			name  string
+			newFn func() Queue[int]
+		}{
+			{name: "queue", newFn: func() Queue[int] { return New[int]() }},
+			{name: "sharded", newFn: func() Queue[int] { return NewSharded[int](3) }},
+			{name: "expiring", newFn: func() Queue[int] { return NewExpiring[int]() }},
+		}
+		for _, from := range kinds {
+			for _, to := range kinds {
+				t.Run(from.name+" to "+to.name, func(t *testing.T) {
+					src, dst := from.newFn(), to.newFn()
+					_, _ = src.EnqueueSlice([]int{1, 2, 3, 4})
+					_ = dst.Enqueue(9)
+					want := slices.Collect(src.All())
+
+					if err := src.CopyInto(dst); err != nil {
+						t.Fatalf("CopyInto() = %v, want nil", err)
+					}
+					// A sharded destination spreads the items, so compare as sets
+					got := slices.Sorted(dst.All())
+					if !slices.Equal(got, slices.Sorted(slices.Values(want))) {
+						t.Errorf("destination items = %v, want %v", got, want)
+					}
+					if got := slices.Collect(src.All()); !slices.Equal(got, want) {
+						t.Errorf("source items after CopyInto() = %v, want %v", got, want)
+					}
+				})
+			}
+		}
+	})
+
+	t.Run("keeps deadlines", func(t *testing.T) {
+		clock := newFakeClock()
+		src := NewExpiring[int](WithClock[int](clock))
+		dst := NewExpiring[int](WithClock[int](clock))
+		_ = src.EnqueueWithTTL(1, shortTTL)
+		_ = src.Enqueue(2)
+
+		_ = src.CopyInto(dst)
+		clock.Advance(2 * shortTTL)
+		if got := slices.Collect(dst.All()); !slices.Equal(got, []int{2}) {
+			t.Errorf("destination items after TTL = %v, want [2]", got)
+		}
+	})
+
+	t.Run("self", func(t *testing.T) {
+		q := New[int]()
+		_, _ = q.EnqueueSlice([]int{1, 2})
+		if err := q.CopyInto(q); err != nil {
+			t.Errorf("CopyInto(self) = %v, want nil", err)
+		}
+		if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 2}) {
+			t.Errorf("items after CopyInto(self) = %v, want [1 2]", got)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package queue
+
+import "sync"
+
+// MultiQueue dequeues from several named queues, sharing dequeues between
+// them in proportion to their weights.
+//
+// Dequeue uses smooth weighted round-robin: over any run of dequeues during
+// which every queue has items, a queue with weight w supplies w out of every
+// W items, where W is the sum of the weights, and its turns are spread evenly
+// rather than taken in bursts. Empty queues are skipped and do not build up
+// credit while empty.
+//
+// A MultiQueue is safe for concurrent use. The member queues remain usable on
+// their own; items enqueued into them directly are dequeued as usual.
+type MultiQueue[T any] struct {
+	mu      sync.RWMutex
+	members []*member[T]
+	byName  map[string]*member[T]
+}
+
+// member is a queue registered with a MultiQueue.
+type member[T any] struct {
+	q       Queue[T]
+	weight  int
+	current int // Smooth round-robin credit; guarded by the write lock
+}
+
+// NewMultiQueue creates a MultiQueue with no queues. Add queues with AddQueue.
+//
+// Example:
+//
+//	m := queue.NewMultiQueue[Job]()
+//	m.AddQueue("interactive", 3, queue.New[Job]())
+//	m.AddQueue("batch", 1, queue.New[Job]())
+//	m.Enqueue("batch", job)
+//	job, err := m.Dequeue() // Three interactive jobs for every batch job
+func NewMultiQueue[T any]() *MultiQueue[T] {
+	return &MultiQueue[T]{byName: make(map[string]*member[T])}
+}
+
+// AddQueue registers q under name with the given weight.
+// Returns ErrDuplicateQueue if a queue is already registered under name.
+//
+// Panics if weight < 1 or q is nil.
+func (m *MultiQueue[T]) AddQueue(name string, weight int, q Queue[T]) error {
+	if weight < 1 {
+		panic("cannot specify non-positive weight")
+	}
+	if q == nil {
+		panic("cannot add nil queue")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.byName[name]; ok {
+		return ErrDuplicateQueue
+	}
+	mem := &member[T]{q: q, weight: weight}
+	m.members = append(m.members, mem)
+	m.byName[name] = mem
+
+	return nil
+}
+
+// Queue returns the queue registered under name, reporting whether there is one.
+func (m *MultiQueue[T]) Queue(name string) (Queue[T], bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mem, ok := m.byName[name]
+	if !ok {
+		return nil, false
+	}
+
+	return mem.q, true
+}
+
+// Enqueue adds val to the back of the queue registered under name.
+// Returns ErrUnknownQueue if there is no such queue, or any error from the
+// queue's Enqueue.
+func (m *MultiQueue[T]) Enqueue(name string, val T) error {
+	q, ok := m.Queue(name)
+	if !ok {
+		return ErrUnknownQueue
+	}
+
+	return q.Enqueue(val)
+}
+
+// Dequeue removes and returns the front item of the queue whose turn it is,
+// skipping empty queues. Returns ErrUnderflow if every queue is empty.
+func (m *MultiQueue[T]) Dequeue() (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// An item may be taken from the chosen queue concurrently, in which case
+	// the round continues without it
+	for range m.members {
+		best := m.next()
+		if best == nil {
+			break
+		}
+		if val, ok := best.q.TryDequeue(); ok {
+			return val, nil
+		}
+	}
+
+	var zero T
+	return zero, ErrUnderflow
+}
+
+// next advances the round-robin over the non-empty queues and returns the
+// one whose turn it is, or nil if every queue is empty. The caller must hold
+// the write lock.
+func (m *MultiQueue[T]) next() *member[T] {
+	var best *member[T]
+	total := 0
+	for _, mem := range m.members {
+		if mem.q.Size() == 0 {
+			continue
+		}
+		mem.current += mem.weight
+		total += mem.weight
+		if best == nil || mem.current > best.current {
+			best = mem
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+
+	return best
+}
+
+// Size returns the total number of items across every queue.
+func (m *MultiQueue[T]) Size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for _, mem := range m.members {
+		n += mem.q.Size()
+	}
+
+	return n
+}
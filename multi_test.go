@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMultiQueueWeights(t *testing.T) {
+	weights := map[string]int{"a": 5, "b": 3, "c": 2}
+	m := NewMultiQueue[string]()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := m.AddQueue(name, weights[name], New[string]()); err != nil {
+			t.Fatalf("AddQueue(%q) = %v, want nil", name, err)
+		}
+		for i := 0; i < 1000; i++ {
+			_ = m.Enqueue(name, name)
+		}
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		val, err := m.Dequeue()
+		if err != nil {
+			t.Fatalf("Dequeue() = %v, want nil", err)
+		}
+		counts[val]++
+	}
+
+	// Every item is drawn in proportion to the weights out of 10
+	for name, weight := range weights {
+		if want := weight * 100; counts[name] != want {
+			t.Errorf("dequeued %d items from %q, want %d", counts[name], name, want)
+		}
+	}
+}
+
+func TestMultiQueueInterleaves(t *testing.T) {
+	m := NewMultiQueue[string]()
+	_ = m.AddQueue("a", 2, New[string]())
+	_ = m.AddQueue("b", 1, New[string]())
+	for i := 0; i < 4; i++ {
+		_ = m.Enqueue("a", "a")
+		_ = m.Enqueue("b", "b")
+	}
+
+	got := ""
+	for i := 0; i < 6; i++ {
+		val, _ := m.Dequeue()
+		got += val
+	}
+	if got != "abaaba" {
+		t.Errorf("dequeue order = %q, want %q", got, "abaaba")
+	}
+}
+
+func TestMultiQueueSkipsEmpty(t *testing.T) {
+	m := NewMultiQueue[int]()
+	_ = m.AddQueue("busy", 1, New[int]())
+	_ = m.AddQueue("idle", 10, New[int]())
+	for i := 1; i <= 3; i++ {
+		_ = m.Enqueue("busy", i)
+	}
+
+	for want := 1; want <= 3; want++ {
+		if val, err := m.Dequeue(); val != want || err != nil {
+			t.Errorf("Dequeue() = (%d, %v), want (%d, nil)", val, err, want)
+		}
+	}
+	if _, err := m.Dequeue(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("Dequeue() with every queue empty = %v, want %v", err, ErrUnderflow)
+	}
+
+	// Being empty earns no credit, so the idle queue does not take a burst of turns
+	_ = m.Enqueue("idle", 10)
+	_ = m.Enqueue("busy", 4)
+	if val, _ := m.Dequeue(); val != 10 {
+		t.Errorf("Dequeue() = %d, want 10 from the heavier queue", val)
+	}
+	if size := m.Size(); size != 1 {
+		t.Errorf("Size = %d, want 1", size)
+	}
+}
+
+func TestMultiQueueNames(t *testing.T) {
+	m := NewMultiQueue[int]()
+	first := New[int]()
+	_ = m.AddQueue("jobs", 1, first)
+
+	if err := m.AddQueue("jobs", 2, New[int]()); !errors.Is(err, ErrDuplicateQueue) {
+		t.Errorf("AddQueue() with a taken name = %v, want %v", err, ErrDuplicateQueue)
+	}
+	if q, ok := m.Queue("jobs"); !ok || q != first {
+		t.Errorf("Queue(%q) = (%v, %v), want the first queue added", "jobs", q, ok)
+	}
+	if err := m.Enqueue("missing", 1); !errors.Is(err, ErrUnknownQueue) {
+		t.Errorf("Enqueue() with an unknown name = %v, want %v", err, ErrUnknownQueue)
+	}
+	if _, ok := m.Queue("missing"); ok {
+		t.Error("Queue() with an unknown name reported a queue")
+	}
+}
+
+func TestMultiQueueAddPanics(t *testing.T) {
+	tests := []struct {
+		name   string
+		weight int
+		q      Queue[int]
+	}{
+		{name: "zero weight", weight: 0, q: New[int]()},
+		{name: "nil queue", weight: 1, q: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("AddQueue() did not panic")
+				}
+			}()
+			_ = NewMultiQueue[int]().AddQueue("q", tt.weight, tt.q)
+		})
+	}
+}
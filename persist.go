@@ -0,0 +1,175 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// snapshotVersion identifies the format written by PersistTo.
+//
+// A snapshot is a sequence of unsigned varints: the version, the capacity
+// (zigzag encoded, so UnlimitedCapacity survives) and the item count. Each
+// item follows as a varint length and that many bytes of JSON.
+const snapshotVersion = 1
+
+func (q *queue[T]) PersistTo(w io.Writer) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return writeSnapshot(w, q.capacity, q.items.len(), q.items.at)
+}
+
+// PersistTo writes the shards one after another, so the snapshot is not
+// atomic with respect to concurrent operations.
+func (s *sharded[T]) PersistTo(w io.Writer) error {
+	items := slices.Collect(s.All())
+	return writeSnapshot(w, int(s.capacity.Load()), len(items), func(i int) T {
+		return items[i]
+	})
+}
+
+// PersistTo must be called by the consumer.
+func (s *spsc[T]) PersistTo(w io.Writer) error {
+	items, _ := s.items()
+	return writeSnapshot(w, len(s.buf), len(items), func(i int) T {
+		return items[i]
+	})
+}
+
+// PersistTo writes live items only, without their TTLs.
+func (e *expiring[T]) PersistTo(w io.Writer) error {
+	e.q.mu.RLock()
+	capacity := e.q.capacity
+	now := e.q.clock.Now()
+	var items []T
+	for i := 0; i < e.q.items.len(); i++ {
+		if item := e.q.items.at(i); !item.expiredAt(now) {
+			items = append(items, item.val)
+		}
+	}
+	e.q.mu.RUnlock()
+
+	return writeSnapshot(w, capacity, len(items), func(i int) T {
+		return items[i]
+	})
+}
+
+// writeSnapshot writes n items, as returned by at, and capacity to w.
+func writeSnapshot[T any](w io.Writer, capacity, n int, at func(int) T) error {
+	bw := bufio.NewWriter(w)
+	buf := binary.AppendUvarint(nil, snapshotVersion)
+	buf = binary.AppendVarint(buf, int64(capacity))
+	buf = binary.AppendUvarint(buf, uint64(n))
+	_, _ = bw.Write(buf)
+
+	for i := 0; i < n; i++ {
+		data, err := json.Marshal(at(i))
+		if err != nil {
+			return err
+		}
+		buf = binary.AppendUvarint(buf[:0], uint64(len(data)))
+		_, _ = bw.Write(buf)
+		_, _ = bw.Write(data)
+	}
+
+	// Write errors are sticky, so Flush reports the first one
+	return bw.Flush()
+}
+
+// LoadFrom reads a snapshot written by PersistTo and returns a new queue
+// holding its items in the same order, with the persisted capacity.
+//
+// The queue is created by New with WithCapacity set to the persisted capacity
+// followed by opts, so opts may override it. Enqueue hooks in opts run for
+// every loaded item. Items are decoded with encoding/json, so T must
+// round-trip through JSON. LoadFrom may read past the end of the snapshot
+// unless r implements io.ByteReader.
+//
+// Example:
+//
+//	f, _ := os.Open("queue.snapshot")
+//	defer f.Close()
+//	q, err := queue.LoadFrom[Job](f)
+//
+// Returns ErrInvalidSnapshot if r does not hold a valid snapshot, or
+// ErrOverflow if the items do not fit the capacity given in opts.
+func LoadFrom[T any](r io.Reader, opts ...Option[T]) (Queue[T], error) {
+	capacity, items, err := readSnapshot[T](r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+
+	q := New(append([]Option[T]{WithCapacity[T](capacity)}, opts...)...)
+	defer unthrottled(q)()
+	if q.(*queue[T]).lifo {
+		slices.Reverse(items) // Enqueue adds each item in front of the last
+	}
+	if _, err := q.EnqueueSlice(items); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// readSnapshot decodes the capacity and items of a snapshot.
+func readSnapshot[T any](r io.Reader) (int, []T, error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		b := bufio.NewReader(r)
+		r, br = b, b
+	}
+
+	version, err := binary.ReadUvarint(br)
+	if err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	if version != snapshotVersion {
+		return 0, nil, fmt.Errorf("unsupported version %d", version)
+	}
+	capacity, err := binary.ReadVarint(br)
+	if err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	if capacity < UnlimitedCapacity {
+		return 0, nil, fmt.Errorf("capacity %d out of range", capacity)
+	}
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+
+	// The count is not trusted for preallocation, as the input may be corrupt
+	items := make([]T, 0, min(n, minGrowSize))
+	var data bytes.Buffer
+	for i := uint64(0); i < n; i++ {
+		size, err := binary.ReadUvarint(br)
+		if err != nil {
+			return 0, nil, unexpectedEOF(err)
+		}
+		data.Reset()
+		if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+			return 0, nil, unexpectedEOF(err)
+		}
+		var val T
+		if err := json.Unmarshal(data.Bytes(), &val); err != nil {
+			return 0, nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		items = append(items, val)
+	}
+
+	return int(capacity), items, nil
+}
+
+// unexpectedEOF reports a snapshot that ends early as io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type persistedJob struct {
+	ID   int
+	Tags []string
+}
+
+// roundTrip persists q to a temporary file and loads it back.
+func roundTrip[T any](t *testing.T, q Queue[T], opts ...Option[T]) Queue[T] {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "queue.snapshot")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.PersistTo(f); err != nil {
+		t.Fatalf("PersistTo() = %v, want nil", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	loaded, err := LoadFrom(f, opts...)
+	if err != nil {
+		t.Fatalf("LoadFrom() = %v, want nil", err)
+	}
+
+	return loaded
+}
+
+func TestPersistRoundTrip(t *testing.T) {
+	q := New[persistedJob](WithCapacity[persistedJob](5))
+	want := []persistedJob{{ID: 1, Tags: []string{"a"}}, {ID: 2}, {ID: 3, Tags: []string{"b", "c"}}}
+	_, _ = q.EnqueueSlice(want)
+
+	loaded := roundTrip(t, q)
+	got := slices.Collect(loaded.All())
+	if !slices.EqualFunc(got, want, func(a, b persistedJob) bool {
+		return a.ID == b.ID && slices.Equal(a.Tags, b.Tags)
+	}) {
+		t.Errorf("loaded items = %v, want %v", got, want)
+	}
+	if r := loaded.Remaining(); r != 2 {
+		t.Errorf("Remaining after load = %d, want 2", r)
+	}
+}
+
+func TestPersistEmpty(t *testing.T) {
+	loaded := roundTrip(t, New[int]())
+
+	if size := loaded.Size(); size != 0 {
+		t.Errorf("Size after loading empty queue = %d, want 0", size)
+	}
+	if r := loaded.Remaining(); r != UnlimitedCapacity {
+		t.Errorf("Remaining after loading empty queue = %d, want %d", r, UnlimitedCapacity)
+	}
+}
+
+func TestPersistOptions(t *testing.T) {
+	q := New[int]()
+	_, _ = q.EnqueueSlice([]int{1, 2, 3})
+
+	var enqueued []int
+	loaded := roundTrip(t, q, WithCapacity[int](4), WithOnEnqueue(func(v int) { enqueued = append(enqueued, v) }))
+	if r := loaded.Remaining(); r != 1 {
+		t.Errorf("Remaining with overridden capacity = %d, want 1", r)
+	}
+	if !slices.Equal(enqueued, []int{1, 2, 3}) {
+		t.Errorf("enqueue hook saw %v, want [1 2 3]", enqueued)
+	}
+
+	var buf bytes.Buffer
+	_ = q.PersistTo(&buf)
+	if _, err := LoadFrom(&buf, WithCapacity[int](2)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("LoadFrom() into smaller capacity = %v, want %v", err, ErrOverflow)
+	}
+}
+
+func TestPersistShardedAndExpiring(t *testing.T) {
+	s := NewSharded[int](2, WithCapacity[int](10))
+	_, _ = s.EnqueueSlice([]int{1, 2, 3})
+	loaded := roundTrip(t, s)
+	if got, want := slices.Collect(loaded.All()), slices.Collect(s.All()); !slices.Equal(got, want) {
+		t.Errorf("loaded sharded items = %v, want %v", got, want)
+	}
+	if r := loaded.Remaining(); r != 7 {
+		t.Errorf("Remaining after loading sharded queue = %d, want 7", r)
+	}
+
+	clock := newFakeClock()
+	e := NewExpiring[int](WithClock[int](clock))
+	_ = e.EnqueueWithTTL(1, longTTL)
+	_ = e.EnqueueWithTTL(2, shortTTL)
+	_ = e.Enqueue(3)
+	clock.Advance(2 * shortTTL)
+	loaded = roundTrip(t, Queue[int](e))
+	if got := slices.Collect(loaded.All()); !slices.Equal(got, []int{1, 3}) {
+		t.Errorf("loaded expiring items = %v, want [1 3]", got)
+	}
+}
+
+func TestLoadFromInvalid(t *testing.T) {
+	q := New[string]()
+	_, _ = q.EnqueueSlice([]string{"alpha", "beta"})
+	var buf bytes.Buffer
+	_ = q.PersistTo(&buf)
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name  string
+		data  []byte
+		cause error
+	}{
+		{name: "empty", data: nil, cause: io.ErrUnexpectedEOF},
+		{name: "truncated", data: valid[:len(valid)-2], cause: io.ErrUnexpectedEOF},
+		{name: "unknown version", data: []byte{9, 0, 0}},
+		{name: "wrong item type", data: slices.Concat(valid[:3], []byte{1, '1'})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFrom[string](bytes.NewReader(tt.data))
+			if !errors.Is(err, ErrInvalidSnapshot) {
+				t.Errorf("LoadFrom() = %v, want %v", err, ErrInvalidSnapshot)
+			}
+			if tt.cause != nil && !errors.Is(err, tt.cause) {
+				t.Errorf("LoadFrom() = %v, want it to wrap %v", err, tt.cause)
+			}
+		})
+	}
+}
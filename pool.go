@@ -0,0 +1,85 @@
+package queue
+
+import "sync"
+
+// resultPool recycles the slices a queue created with WithResultPool returns
+// from its batch methods. A nil *resultPool allocates every slice afresh.
+type resultPool[T any] struct {
+	slices sync.Pool // *[]T holding a released slice
+	boxes  sync.Pool // *[]T emptied by get, reused by put so put does not allocate
+}
+
+// get returns a zeroed slice of length n, reusing a released one if it is
+// large enough.
+func (p *resultPool[T]) get(n int) []T {
+	if p == nil || n == 0 {
+		return make([]T, n)
+	}
+
+	box, ok := p.slices.Get().(*[]T)
+	if !ok {
+		return make([]T, n)
+	}
+	vals := *box
+	*box = nil
+	p.boxes.Put(box)
+	if cap(vals) < n {
+		return make([]T, n) // Too small; let the garbage collector have it
+	}
+
+	return vals[:n]
+}
+
+// put zeroes vals, so that the pool does not keep its items alive, and makes
+// its storage available to get.
+func (p *resultPool[T]) put(vals []T) {
+	if p == nil || cap(vals) == 0 {
+		return
+	}
+
+	vals = vals[:cap(vals)]
+	clear(vals)
+	box, ok := p.boxes.Get().(*[]T)
+	if !ok {
+		box = new([]T)
+	}
+	*box = vals
+	p.slices.Put(box)
+}
+
+// Release hands vals, a slice returned by Drain, PeekN, Snapshot or
+// DequeueBatchWait of q, back to q for reuse if q was created with
+// WithResultPool, and does nothing otherwise.
+//
+// Release ends the caller's borrow of vals: a later call on q may hand the
+// same storage out again, so vals, and any slice sharing its storage, must
+// not be read or written once Release is called. Copy out any item that must
+// outlive the borrow first, and release each slice at most once. A slice
+// that is never released is simply garbage collected.
+//
+// Example:
+//
+//	q := queue.New[Event](queue.WithResultPool[Event]())
+//	for {
+//		batch, err := q.DequeueBatchWait(ctx, 64, time.Second)
+//		if err != nil {
+//			break
+//		}
+//		process(batch)
+//		queue.Release(q, batch) // batch must not be used after this
+//	}
+func Release[T any](q Queue[T], vals []T) {
+	if base, ok := asQueue(q); ok {
+		base.results.put(vals)
+	}
+}
+
+// borrow returns a slice of length n for a batch result of q, taken from its
+// result pool if it has one.
+func borrow[T any](q Queue[T], n int) []T {
+	if base, ok := asQueue(q); ok {
+		return base.results.get(n)
+	}
+
+	return make([]T, n)
+}
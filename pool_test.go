@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestResultPool(t *testing.T) {
+	q := New[*int](WithResultPool[*int]())
+	for i := range 8 {
+		v := i
+		_ = q.Enqueue(&v)
+	}
+
+	items := q.Drain()
+	if len(items) != 8 || *items[7] != 7 {
+		t.Fatalf("Drain() = %d items, want 8 ending in 7", len(items))
+	}
+	held := items[:cap(items)]
+	Release(q, items)
+
+	// Released storage must not keep the drained items alive
+	for i, p := range held {
+		if p != nil {
+			t.Errorf("slot %d after Release = %v, want nil", i, p)
+		}
+	}
+
+	// Whether or not the storage is reused, results stay correct
+	for round := range 3 {
+		v := round
+		_ = q.Enqueue(&v)
+		_ = q.Enqueue(&v)
+		peeked, _ := q.PeekN(2)
+		snapshot, _ := q.Snapshot()
+		drained := q.Drain()
+		for name, got := range map[string][]*int{"PeekN": peeked, "Snapshot": snapshot, "Drain": drained} {
+			if len(got) != 2 || *got[0] != round || *got[1] != round {
+				t.Errorf("round %d: %s() returned %d items, want 2 holding %d", round, name, len(got), round)
+			}
+		}
+		Release(q, peeked)
+		Release(q, snapshot)
+		Release(q, drained)
+	}
+}
+
+func TestResultPoolPriority(t *testing.T) {
+	q := NewPriority(intLess, WithResultPool[int]())
+	_, _ = q.EnqueueSlice([]int{4, 1, 3, 2})
+
+	top, _ := q.PeekN(2)
+	if !slices.Equal(top, []int{1, 2}) {
+		t.Errorf("PeekN(2) = %v, want [1 2]", top)
+	}
+	Release(q, top)
+	if got, _ := q.PeekN(4); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("PeekN(4) after Release = %v, want [1 2 3 4]", got)
+	}
+}
+
+func TestReleaseUnpooled(t *testing.T) {
+	for name, q := range map[string]Queue[int]{
+		"unpooled": New[int](),
+		"sharded":  NewSharded[int](2, WithResultPool[int]()),
+	} {
+		_, _ = q.EnqueueSlice([]int{1, 2})
+		items := q.Drain()
+		Release(q, items)
+
+		// Without a pool the slice is left alone
+		if !slices.Equal(items, []int{1, 2}) {
+			t.Errorf("%s: items after Release = %v, want [1 2]", name, items)
+		}
+	}
+}
+
+func BenchmarkResultPool(b *testing.B) {
+	for name, opts := range map[string][]Option[int]{
+		"plain":  nil,
+		"pooled": {WithResultPool[int]()},
+	} {
+		b.Run(name, func(b *testing.B) {
+			q := New[int](opts...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for j := range 64 {
+					_ = q.Enqueue(j)
+				}
+				batch, _ := q.DequeueBatchWait(context.Background(), 64, 0)
+				Release(q, batch)
+			}
+		})
+	}
+}
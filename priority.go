@@ -0,0 +1,109 @@
+package queue
+
+// NewPriority creates a queue that returns items in priority order instead of
+// arrival order.
+//
+// Dequeue and Peek return the highest-priority item, where a has higher
+// priority than b if less(a, b) is true. Items that compare equal are returned
+// in an unspecified order. Traversals such as All, ForEach and String visit
+// items in internal heap order, which is neither arrival nor priority order,
+// and reordering operations such as Reverse do not change the dequeue order.
+// All other behavior, including capacity limits and options, matches New.
+//
+// The queue is backed by a binary heap: Enqueue and Dequeue are O(log n) and
+// Peek is O(1).
+//
+// Example:
+//
+//	q := queue.NewPriority[int](func(a, b int) bool { return a < b }) // Min-heap
+//	q.Enqueue(3)
+//	q.Enqueue(1)
+//	q.Enqueue(2)
+//	val, err := q.Dequeue() // returns 1, nil
+//
+// Panics if less is nil or WithLIFO is given.
+func NewPriority[T any](less func(a, b T) bool, opts ...Option[T]) Queue[T] {
+	if less == nil {
+		panic("cannot specify nil priority function")
+	}
+
+	q := newQueue(opts...)
+	if q.lifo {
+		panic("cannot use LIFO order with a priority queue")
+	}
+	q.less = less
+
+	return q
+}
+
+// lastIndex returns the logical index of the item Dequeue would return last.
+// For a priority queue this is the lowest-priority item, found among the
+// leaves of the heap. The queue must not be empty. The caller must hold the lock.
+func (q *queue[T]) lastIndex() int {
+	n := q.items.len()
+	if q.less == nil {
+		return n - 1
+	}
+
+	last := n / 2
+	for i := last + 1; i < n; i++ {
+		if q.less(q.items.at(last), q.items.at(i)) {
+			last = i
+		}
+	}
+
+	return last
+}
+
+// siftUp restores the heap order by moving the item at logical index i
+// towards the front. The caller must hold the write lock.
+func (q *queue[T]) siftUp(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.less(q.items.at(i), q.items.at(parent)) {
+			return
+		}
+		q.swapItems(i, parent)
+		i = parent
+	}
+}
+
+// siftDown restores the heap order by moving the item at logical index i
+// towards the back. The caller must hold the write lock.
+func (q *queue[T]) siftDown(i int) {
+	n := q.items.len()
+	for {
+		first := i
+		if left := 2*i + 1; left < n && q.less(q.items.at(left), q.items.at(first)) {
+			first = left
+		}
+		if right := 2*i + 2; right < n && q.less(q.items.at(right), q.items.at(first)) {
+			first = right
+		}
+		if first == i {
+			return
+		}
+		q.swapItems(i, first)
+		i = first
+	}
+}
+
+// heapify re-establishes the heap order over all items after an operation
+// that rearranged them. It does nothing for FIFO queues.
+// The caller must hold the write lock.
+func (q *queue[T]) heapify() {
+	if q.less == nil {
+		return
+	}
+	for i := q.items.len()/2 - 1; i >= 0; i-- {
+		q.siftDown(i)
+	}
+}
+
+// swapItems exchanges the items at logical indexes i and j.
+// The caller must hold the write lock.
+func (q *queue[T]) swapItems(i, j int) {
+	a, b := q.items.at(i), q.items.at(j)
+	q.items.set(i, b)
+	q.items.set(j, a)
+}
@@ -0,0 +1,195 @@
+package queue
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func intLess(a, b int) bool { return a < b }
+
+func TestPriorityOrdering(t *testing.T) {
+	q := NewPriority[int](intLess)
+	rng := rand.New(rand.NewSource(1))
+
+	values := make([]int, 200)
+	for i := range values {
+		values[i] = rng.Intn(1000)
+		_ = q.Enqueue(values[i])
+	}
+	sort.Ints(values)
+
+	for i, expected := range values {
+		if val, _ := q.Peek(); val != expected {
+			t.Fatalf("Peek() at position %d = %d, want %d", i, val, expected)
+		}
+		val, err := q.Dequeue()
+		if err != nil {
+			t.Fatalf("Dequeue() error = %v, want nil", err)
+		}
+		if val != expected {
+			t.Fatalf("Dequeue() at position %d = %d, want %d", i, val, expected)
+		}
+	}
+
+	if _, err := q.Dequeue(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("Dequeue() on empty priority queue error = %v, want ErrUnderflow", err)
+	}
+}
+
+func TestPriorityCustomLess(t *testing.T) {
+	type task struct {
+		name     string
+		priority int
+	}
+
+	// Highest priority first
+	q := NewPriority[task](func(a, b task) bool { return a.priority > b.priority })
+	_ = q.Enqueue(task{"low", 1})
+	_ = q.Enqueue(task{"high", 10})
+	_ = q.Enqueue(task{"medium", 5})
+
+	for _, expected := range []string{"high", "medium", "low"} {
+		val, _ := q.Dequeue()
+		if val.name != expected {
+			t.Errorf("Dequeue() = %q, want %q", val.name, expected)
+		}
+	}
+}
+
+func TestPriorityEqualItems(t *testing.T) {
+	type item struct {
+		id       int
+		priority int
+	}
+
+	q := NewPriority[item](func(a, b item) bool { return a.priority < b.priority })
+	for i := 0; i < 5; i++ {
+		_ = q.Enqueue(item{id: i, priority: 1})
+	}
+	_ = q.Enqueue(item{id: 99, priority: 0})
+
+	// The single highest-priority item comes first; equal items follow in any order
+	if val, _ := q.Dequeue(); val.id != 99 {
+		t.Errorf("Dequeue() id = %d, want 99", val.id)
+	}
+
+	seen := make(map[int]bool)
+	for i := 0; i < 5; i++ {
+		val, err := q.Dequeue()
+		if err != nil {
+			t.Fatalf("Dequeue() error = %v, want nil", err)
+		}
+		seen[val.id] = true
+	}
+	if len(seen) != 5 {
+		t.Errorf("Dequeued %d distinct equal-priority items, want 5", len(seen))
+	}
+}
+
+func TestPriorityCapacity(t *testing.T) {
+	q := NewPriority[int](intLess, WithCapacity[int](2))
+	_ = q.Enqueue(5)
+	_ = q.Enqueue(3)
+
+	if err := q.Enqueue(1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Enqueue(1) exceeding capacity error = %v, want ErrOverflow", err)
+	}
+	if val, _ := q.Peek(); val != 3 {
+		t.Errorf("Peek() after overflow = %d, want 3", val)
+	}
+}
+
+func TestPriorityFilterKeepsOrder(t *testing.T) {
+	q := NewPriority[int](intLess)
+	for _, v := range []int{9, 4, 7, 1, 8, 2, 6, 3, 5} {
+		_ = q.Enqueue(v)
+	}
+
+	q.Filter(func(v int) bool { return v != 1 && v != 4 })
+
+	for _, expected := range []int{2, 3, 5, 6, 7, 8, 9} {
+		if val, _ := q.Dequeue(); val != expected {
+			t.Errorf("Dequeue() after Filter() = %d, want %d", val, expected)
+		}
+	}
+}
+
+func TestPrioritySetCapacityDrop(t *testing.T) {
+	q := NewPriority[int](intLess, WithShrinkPolicy[int](ShrinkDropOldest))
+	for _, v := range []int{5, 1, 4, 2, 3} {
+		_ = q.Enqueue(v)
+	}
+
+	// Items are dropped from the front, i.e. in priority order
+	if err := q.SetCapacity(2); err != nil {
+		t.Fatalf("SetCapacity(2) error = %v, want nil", err)
+	}
+	for _, expected := range []int{4, 5} {
+		if val, _ := q.Dequeue(); val != expected {
+			t.Errorf("Dequeue() after shrink = %d, want %d", val, expected)
+		}
+	}
+}
+
+func TestNewPriorityNilLess(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("NewPriority(nil) should panic, but it didn't")
+		}
+	}()
+
+	NewPriority[int](nil)
+}
+
+func TestPriorityReverseKeepsOrder(t *testing.T) {
+	q := NewPriority[int](intLess)
+	for _, v := range []int{3, 1, 2} {
+		_ = q.Enqueue(v)
+	}
+
+	q.Reverse()
+
+	for _, expected := range []int{1, 2, 3} {
+		if val, _ := q.Dequeue(); val != expected {
+			t.Errorf("Dequeue() after Reverse() = %d, want %d", val, expected)
+		}
+	}
+}
+
+func TestPriorityBack(t *testing.T) {
+	q := NewPriority[int](intLess)
+	for _, v := range []int{5, 1, 9, 3, 7, 2} {
+		_ = q.Enqueue(v)
+	}
+
+	if val, err := q.Back(); val != 9 || err != nil {
+		t.Errorf("Back() = (%d, %v), want (9, nil)", val, err)
+	}
+	if val, _ := q.Front(); val != 1 {
+		t.Errorf("Front() = %d, want 1", val)
+	}
+}
+
+func TestPriorityRemove(t *testing.T) {
+	q := NewPriority[int](intLess)
+	for _, v := range []int{5, 1, 9, 3, 7, 2, 8} {
+		_ = q.Enqueue(v)
+	}
+
+	// Remove a non-root item, then check the heap still dequeues in order
+	items := slices.Collect(q.All())
+	removed, err := q.Remove(2)
+	if err != nil || removed != items[2] {
+		t.Fatalf("Remove(2) = (%d, %v), want (%d, nil)", removed, err, items[2])
+	}
+
+	want := slices.DeleteFunc(slices.Sorted(slices.Values(items)), func(v int) bool { return v == removed })
+	for _, expected := range want {
+		if val, _ := q.Dequeue(); val != expected {
+			t.Errorf("Dequeue() after Remove() = %d, want %d", val, expected)
+		}
+	}
+}
@@ -11,25 +11,390 @@
 //	val, err := q.Dequeue() // returns 1, nil
 package queue
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
 
 // Queue defines the interface for a generic queue data structure.
 // All operations are thread-safe and support any type T.
 type Queue[T any] interface {
 	// Enqueue adds an item to the back of the queue.
-	// Returns ErrOverflow if the queue is at capacity.
+	// Returns ErrOverflow if the queue is at capacity, or ErrClosed if it is closed.
 	Enqueue(val T) error
 
 	// Dequeue removes and returns the front item from the queue.
 	// Returns ErrUnderflow if the queue is empty.
 	Dequeue() (T, error)
 
-	// Size returns the current number of items in the queue.
+	// DequeueUntil removes items from the front until it finds one for which
+	// pred returns true, and returns that item. The items before it are
+	// discarded. Returns ErrUnderflow, leaving the queue empty, if no item
+	// matches. pred is called under the write lock and must not call back into
+	// the queue.
+	DequeueUntil(pred func(T) bool) (T, error)
+
+	// DequeueMatch removes and returns the first item, in the order All visits
+	// items, for which pred returns true, preserving the order of the others,
+	// for example to cancel a specific task by one of its attributes. The item
+	// counts as dequeued. Returns ErrUnderflow if the queue is empty or
+	// ErrNotFound, leaving the queue unchanged, if no item matches. pred is
+	// called under the write lock and must not call back into the queue.
+	DequeueMatch(pred func(T) bool) (T, error)
+
+	// Drain removes every item and returns them in the order Dequeue would
+	// have returned them. Returns an empty slice if the queue is empty.
+	Drain() []T
+
+	// DrainTo removes up to len(dst) items from the front, copies them into dst
+	// in dequeue order, and returns the number copied. Unlike Drain it does not
+	// allocate, so a caller can reuse dst across calls.
+	DrainTo(dst []T) int
+
+	// EnqueueDedupBack adds an item to the back of the queue unless the item
+	// Back would return satisfies eq with it, and reports whether it was added.
+	// Consecutive duplicates are thus collapsed into one. Returns ErrOverflow or
+	// ErrClosed as Enqueue does. eq is called under the write lock and must not
+	// call back into the queue.
+	EnqueueDedupBack(val T, eq func(a, b T) bool) (bool, error)
+
+	// EnqueueFrontAll adds vals to the front of the queue, preserving their
+	// order, so that vals[0] becomes the new front. Either every item is added
+	// or none is: returns ErrOverflow if they do not all fit, even if the queue
+	// is circular, or ErrClosed if the queue is closed. Priority queues place
+	// the items by priority, as Enqueue does.
+	EnqueueFrontAll(vals ...T) error
+
+	// EnqueuePush adds an item to the back of the queue. If the queue is at a
+	// capacity greater than zero, it first removes the front item and returns
+	// it with didEvict set, all in one step, so that the caller can log or
+	// reroute what was lost. The evicted item is neither forwarded to the
+	// dead-letter queue nor counted as dequeued. On error nothing is evicted.
+	// Returns ErrOverflow if val could never fit, or ErrClosed if the queue is
+	// closed.
+	EnqueuePush(val T) (evicted T, didEvict bool, err error)
+
+	// TryEnqueue adds an item to the back of the queue.
+	// Returns false if the queue is at capacity or closed.
+	TryEnqueue(val T) bool
+
+	// Offer is the same as TryEnqueue, named after java.util.Queue.offer. It
+	// pairs with Poll: Offer and Poll report failure with false, where Enqueue
+	// and Dequeue return ErrOverflow, ErrClosed or ErrUnderflow.
+	Offer(val T) bool
+
+	// EnqueueSlice adds as many items from vals as fit, in order, and returns the
+	// number added. Returns ErrOverflow if some items did not fit, or ErrClosed if
+	// the queue is closed.
+	EnqueueSlice(vals []T) (int, error)
+
+	// TryDequeue removes and returns the front item from the queue.
+	// Returns the zero value and false if the queue is empty.
+	TryDequeue() (T, bool)
+
+	// Poll is the same as TryDequeue, for loops that consume until the queue
+	// is empty:
+	//
+	//	for v, ok := q.Poll(); ok; v, ok = q.Poll() {
+	//		process(v)
+	//	}
+	//
+	// Items are returned in the order Dequeue would return them.
+	Poll() (T, bool)
+
+	// DequeueOr removes and returns the front item, or returns def and leaves
+	// the queue unchanged if it is empty.
+	DequeueOr(def T) T
+
+	// EnqueueWait adds an item to the back of the queue, blocking while the queue
+	// is at capacity. Returns ctx.Err() if ctx is done before the item fits, or
+	// ErrClosed if the queue is closed.
+	EnqueueWait(ctx context.Context, val T) error
+
+	// EnqueueCtx is like EnqueueWait, but returns ctx.Err() without touching the
+	// queue if ctx is already done, even when the item would fit.
+	EnqueueCtx(ctx context.Context, val T) error
+
+	// EnqueueAllCtx adds vals to the back of the queue, in order, blocking
+	// until they all fit at once or ctx is done. Either every item is added or
+	// none is: it returns ctx.Err() without adding any if ctx is done first,
+	// including when it is already done, ErrOverflow without waiting if they
+	// could never all fit, or ErrClosed if the queue is closed. A circular
+	// queue never waits and adds the items as EnqueueSlice does.
+	EnqueueAllCtx(ctx context.Context, vals ...T) error
+
+	// EnqueueTimeout adds an item to the back of the queue, blocking for up to d
+	// while the queue is at capacity. On expiry, returns an error wrapping both
+	// ErrOverflow and context.DeadlineExceeded. If d <= 0, it does not block and
+	// returns ErrOverflow if the queue is full.
+	EnqueueTimeout(val T, d time.Duration) error
+
+	// DequeueWait removes and returns the front item, blocking while the queue
+	// is empty. Returns ctx.Err() if ctx is done before an item arrives, or
+	// ErrClosed if the queue is closed and empty.
+	DequeueWait(ctx context.Context) (T, error)
+
+	// DequeueCtx is like DequeueWait, but returns ctx.Err() without touching the
+	// queue if ctx is already done, even when an item is available.
+	DequeueCtx(ctx context.Context) (T, error)
+
+	// DequeueTimeout removes and returns the front item, blocking for up to d
+	// while the queue is empty. On expiry, returns an error wrapping both
+	// ErrUnderflow and context.DeadlineExceeded. If d <= 0, it does not block and
+	// returns ErrUnderflow if the queue is empty.
+	DequeueTimeout(d time.Duration) (T, error)
+
+	// DequeueBatchWait removes and returns up to n items from the front,
+	// blocking until n items are available or maxWait has passed, whichever
+	// comes first. Once maxWait has passed it returns the items available, if
+	// any. Returns ctx.Err() if ctx is done first, an error wrapping both
+	// ErrUnderflow and context.DeadlineExceeded if the queue is still empty
+	// after maxWait, or ErrClosed if the queue is closed and empty. A closed
+	// queue returns the items it has without waiting. If maxWait <= 0, it
+	// does not block. Returns ErrNegativeCount if n < 0.
+	DequeueBatchWait(ctx context.Context, n int, maxWait time.Duration) ([]T, error)
+
+	// NotEmpty returns a channel that is closed once the queue holds an item or
+	// is closed, and is already closed if that is the case when called. Each
+	// channel fires once; call NotEmpty again to re-arm after consuming. Another
+	// consumer may take the item first, so treat a wakeup as a hint and re-arm
+	// if TryDequeue fails.
+	NotEmpty() <-chan struct{}
+
+	// NotFull returns a channel that is closed once the queue has room for
+	// another item or is closed, and is already closed if that is the case
+	// when called, as it always is for a queue without a capacity limit or a
+	// circular one. Each channel fires once; call NotFull again to re-arm
+	// after the queue fills up again. Another producer may take the room
+	// first, so treat a wakeup as a hint and re-arm if TryEnqueue fails.
+	NotFull() <-chan struct{}
+
+	// BlockedProducers returns the number of callers currently blocked waiting
+	// for room, in EnqueueWait or the operations built on it. A queue that
+	// regularly has blocked producers is saturated.
+	BlockedProducers() int
+
+	// BlockedConsumers returns the number of callers currently blocked waiting
+	// for items, in DequeueWait, DequeueBatchWait or the operations built on them.
+	BlockedConsumers() int
+
+	// Channel returns a channel that receives items dequeued in FIFO order until
+	// ctx is done or the queue is closed and empty, after which it is closed.
+	Channel(ctx context.Context) <-chan T
+
+	// Close stops the queue from accepting new items and wakes all blocked callers.
+	// Items already queued can still be dequeued. Returns ErrClosed if already closed.
+	Close() error
+
+	// CloseAndDrain closes the queue and removes every remaining item in one
+	// step, so no consumer can take an item in between. It calls fn, if not
+	// nil, on each item in the order Dequeue would have returned them, and
+	// returns the items in that order. Blocked callers are woken and return
+	// ErrClosed. Unlike Close it also drains an already closed queue.
+	CloseAndDrain(fn func(T)) []T
+
+	// Reset discards every item without running hooks, zeroes the Stats
+	// counters and reopens a closed queue. Capacity, options and hooks are kept.
+	// Blocked enqueuers are woken and retry against the empty queue; blocked
+	// dequeuers keep waiting for new items.
+	Reset()
+
+	// Size returns the current number of items in the queue. It does not wait
+	// for the lock unless writers keep it busy, so polling it does not stall
+	// enqueues, and unlike ApproxSize it never reports a count that an
+	// operation in progress has not finished producing.
 	Size() int
 
+	// ApproxSize returns the number of items in the queue without taking the
+	// lock, for cheap polling in hot loops. Under concurrent use the result
+	// may lag behind operations in progress and is only approximate; it is
+	// exact once the queue is quiescent.
+	ApproxSize() int
+
+	// SizeBytes returns the total size of the items in the queue, as measured
+	// by the sizeOf function given with WithMaxBytes, or 0 for a queue without
+	// a byte limit. The total is kept up to date as items come and go, so the
+	// call does not re-measure the items.
+	SizeBytes() int
+
+	// Remaining returns how many more items fit before Enqueue returns ErrOverflow.
+	// Returns UnlimitedCapacity (-1) if the queue has no capacity limit.
+	Remaining() int
+
+	// SetCapacity changes the maximum number of items the queue can hold.
+	// Growing and switching to UnlimitedCapacity always succeed. Shrinking below
+	// Size is handled according to the queue's ShrinkPolicy.
+	// Returns ErrInvalidCapacity if n < UnlimitedCapacity.
+	SetCapacity(n int) error
+
+	// Grow preallocates storage so that n more items can be added without
+	// reallocating, clamped to the queue's capacity.
+	// Returns ErrInvalidCapacity if n < 0.
+	Grow(n int) error
+
+	// Compact shrinks the queue's storage to fit its current items, releasing
+	// memory left over from an earlier burst.
+	Compact()
+
 	// Peek returns the front item without removing it from the queue.
 	// Returns ErrUnderflow if the queue is empty.
 	Peek() (T, error)
+
+	// Front is an alias for Peek.
+	Front() (T, error)
+
+	// PeekOr returns the item Peek would return, or def if the queue is empty.
+	PeekOr(def T) T
+
+	// Back returns the item Dequeue would return last without removing it.
+	// Returns ErrUnderflow if the queue is empty.
+	Back() (T, error)
+
+	// PeekN returns copies of up to the first n items, in the order Dequeue
+	// would return them, without removing them. It returns fewer than n items
+	// if the queue is shorter, and an empty slice if n is 0.
+	// Returns ErrUnderflow if the queue is empty, or ErrNegativeCount if n < 0.
+	PeekN(n int) ([]T, error)
+
+	// ReplaceFront overwrites the item Peek would return with val, keeping
+	// its position. Returns ErrUnderflow if the queue is empty, ErrNilValue
+	// as Enqueue does, or ErrOverflow if val would exceed the byte limit.
+	// Priority queues move val to where its priority belongs.
+	ReplaceFront(val T) error
+
+	// ReplaceBack overwrites the item Back would return with val, keeping its
+	// position, and returns errors as ReplaceFront does.
+	ReplaceBack(val T) error
+
+	// Reverse reverses the order of the items in place, so the former back of
+	// the queue becomes the front. Size and capacity are unchanged.
+	Reverse()
+
+	// Rotate moves the first n items to the back of the queue, preserving their
+	// order, as if they were dequeued and re-enqueued. n wraps around Size, and a
+	// negative n moves the last -n items to the front instead.
+	Rotate(n int)
+
+	// Shuffle reorders the items in place into a random order drawn from r, so
+	// that the same seed yields the same order, for feeding consumers
+	// unpredictable input in tests and fuzzing. It deliberately breaks FIFO
+	// order; priority queues keep dequeueing in priority order. Size and
+	// capacity are unchanged. A nil r uses the top-level functions of
+	// math/rand/v2.
+	Shuffle(r *rand.Rand)
+
+	// ForEach calls fn for each item in FIFO order, stopping early if fn returns false.
+	// fn is called under the read lock and must not call back into the queue.
+	ForEach(fn func(T) bool)
+
+	// ForEachCtx is like ForEach, but checks ctx every few items and stops,
+	// returning ctx.Err(), once ctx is done, so that a long traversal does not
+	// hold the read lock after its caller has given up. Returns nil if fn
+	// stopped the traversal or every item was visited.
+	ForEachCtx(ctx context.Context, fn func(T) bool) error
+
+	// All returns an iterator over a snapshot of the queue in FIFO order.
+	// The snapshot is taken when iteration starts; no lock is held while yielding.
+	All() iter.Seq[T]
+
+	// AllIndexed is like All, but also yields the index of each item, counted
+	// from 0 at the front as Remove counts it. Indexes refer to the snapshot,
+	// so they go stale once the queue changes.
+	AllIndexed() iter.Seq2[int, T]
+
+	// Version returns a counter that increases with every change to the items
+	// of the queue, for detecting whether the queue changed between two reads.
+	// Operations that leave the items unchanged, such as a failed Enqueue, do
+	// not affect it; some that rearrange items, such as Reverse, may bump it
+	// even when the order ends up the same.
+	Version() uint64
+
+	// Snapshot returns a copy of the items, in the order All visits them,
+	// together with the Version they were read at.
+	Snapshot() ([]T, uint64)
+
+	// Subscribe returns a channel that receives the new Size whenever an
+	// operation changes it, and a function that stops delivery and closes the
+	// channel. The channel buffers a few events; further events are dropped
+	// until the subscriber catches up, so a slow subscriber never stalls the
+	// queue. Calling the returned function more than once has no effect.
+	Subscribe() (<-chan int, func())
+
+	// Filter removes every item for which keep returns false, preserving the
+	// order of the remaining items, and returns the number of items removed.
+	// keep is called under the write lock and must not call back into the queue.
+	Filter(keep func(T) bool) int
+
+	// FilterCtx is like Filter, but checks ctx every few items while calling
+	// keep. If ctx is done before keep has seen every item, FilterCtx returns
+	// 0 and ctx.Err() and removes nothing, leaving the queue exactly as it was,
+	// although keep may already have been called on some items. Items are only
+	// removed once keep has seen all of them, and that step is not interrupted.
+	FilterCtx(ctx context.Context, keep func(T) bool) (int, error)
+
+	// Remove removes and returns the item at index i, counted from the front in
+	// the order All visits items, preserving the order of the others.
+	// Returns ErrIndexOutOfRange unless 0 <= i < Size.
+	Remove(i int) (T, error)
+
+	// FindIndex returns the index of the first item for which pred returns
+	// true, counted as Remove counts it, or -1 if there is none.
+	// pred is called under the read lock and must not call back into the queue.
+	FindIndex(pred func(T) bool) int
+
+	// Swap exchanges the items at indexes i and j, counted from the front in
+	// the order All visits items. Returns ErrIndexOutOfRange unless both are in
+	// 0 <= i < Size.
+	Swap(i, j int) error
+
+	// Merge moves every item of other, in the order other would dequeue them,
+	// to the back of the queue, leaving other empty. Every item is checked
+	// before any is moved: if the items do not all fit, Merge returns
+	// ErrOverflow, unless the queue is circular, and if the queue rejects one,
+	// it returns the error Enqueue would, leaving both queues unchanged. Merging
+	// a queue created by New, NewPriority or NewBlocking into another such queue
+	// is atomic.
+	Merge(other Queue[T]) error
+
+	// Split removes the first n items, or every item if n > Size, and returns
+	// them in a new queue with the same capacity and options.
+	// Returns ErrNegativeCount if n < 0.
+	Split(n int) (Queue[T], error)
+
+	// CopyInto replaces the items of dst with copies of the items of the
+	// queue, in the order All visits them, leaving the queue unchanged. dst
+	// keeps its own capacity and options, so a pooled queue can be reused
+	// instead of allocating a new one. Returns ErrOverflow if the items do not
+	// fit within dst's capacity or byte limit, even if dst is circular,
+	// ErrNilValue if dst rejects one of them, or ErrClosed if dst is closed;
+	// dst is left unchanged by these errors. Copying between queues created by
+	// New, NewPriority or NewBlocking is atomic.
+	CopyInto(dst Queue[T]) error
+
+	// Stats returns a snapshot of the queue's operation counters.
+	Stats() Stats
+
+	// PersistTo writes the capacity and items of the queue, in the order All
+	// visits them, to w in a form LoadFrom reads back. Items are encoded with
+	// encoding/json. The snapshot is taken under the read lock.
+	PersistTo(w io.Writer) error
+
+	// String returns a human-readable representation of the queue, front first,
+	// such as "Queue[len=3/cap=10]: [1 2 3]", or "Queue(jobs)[len=3/cap=10]:
+	// [1 2 3]" for a queue named with WithName. Long queues are truncated.
+	String() string
+
+	// Name returns the name given with WithName, or "" if the queue is unnamed.
+	Name() string
 }
 
 // New creates a new queue with the specified options.
@@ -44,68 +409,1479 @@ func New[T any](opts ...Option[T]) Queue[T] {
 }
 
 type queue[T any] struct {
-	mu       sync.RWMutex
-	capacity int
-	items    []T
+	mu           locker
+	capacity     int
+	initCap      int
+	growth       float64 // Factor by which storage grows when full
+	shrinkRatio  float64 // Fill ratio below which dequeues shrink storage; 0 if off
+	shrinkPolicy ShrinkPolicy
+	circular     bool
+	lifo         bool // Set when items are added at the front
+	clock        Clock
+	name         string
+	items        ring[T]
+	onEnqueue    []func(T)
+	onDequeue    []func(T)
+	onExpire     []func(T)         // Only used by expiring queues
+	onDeadLetter []func(T)         // Only used by retry queues
+	onPanic      func(any)         // Non-nil when hook panics are recovered
+	requeueFront bool              // Only used by retry queues
+	less         func(a, b T) bool // Non-nil for priority queues
+	isNil        func(T) bool      // Non-nil when nil values are rejected
+	validators   []func(T) error   // Run in order on every item added
+	sizeOf       func(T) int       // Non-nil when a byte limit is set
+	dedup        keySet[T]         // Non-nil when duplicate keys are rejected
+	index        keySet[T]         // Non-nil when ContainsKey is supported
+	changes      changeRecorder[T] // Non-nil when Changes is supported
+	results      *resultPool[T]    // Non-nil when batch results are pooled
+	copyOnPeek   func(T) T         // Non-nil when peeked items are copied
+	wal          *wal[T]           // Non-nil when operations are logged
+	tracer       TraceFunc         // Non-nil when operations are traced
+	deadLetter   func(T)           // Non-nil when dropped items are forwarded
+	limiter      *limiter          // Non-nil when blocking dequeues are paced
+	enqueueLimit *limiter          // Non-nil when enqueues are rate limited
+	onFlush      func(Queue[T])    // Non-nil when a flush threshold is set
+	flush        func()            // Calls onFlush with the queue handed to it
+	flushAt      int
+	aboveFlush   bool        // Whether the size was at least flushAt at the last change
+	flushDue     atomic.Bool // Set when the size reaches flushAt, cleared when flush runs
+	dropped      []T         // Items dropped under the lock, forwarded by unlock
+	maxBytes     int
+	bytes        int
+	batch        sync.Mutex // Held from BeginBatch until the batch ends
+	held         []T        // Items of the open batch, which keep their room and keys
+	heldBytes    int        // Total itemBytes of held
+	reserved     int        // Slots reserved by Reserve and not yet committed or cancelled
+	stats        Stats
+	notFull      signal
+	notEmpty     signal
+	closed       bool
+	approxSize   atomic.Int64 // Mirrors items.len() for Size and ApproxSize
+	version      uint64       // Bumped by every change to the items
+	producers    gauge        // Callers blocked in EnqueueWait
+	consumers    gauge        // Callers blocked in DequeueWait or DequeueBatchWait
+	subs         subscribers
 }
 
+const (
+	// maxStringItems is the number of items String prints before truncating.
+	maxStringItems = 100
+
+	// minGrowSize is the smallest backing storage allocated when an empty
+	// queue first grows.
+	minGrowSize = 8
+
+	// defaultGrowth is the factor by which storage grows unless WithGrowthFactor
+	// is given.
+	defaultGrowth = 2.0
+)
+
 func newQueue[T any](opts ...Option[T]) *queue[T] {
+	s := configure(opts)
+	s.items = newRing[T](s.initialSize())
+	s.flushes(s)
+
+	return s
+}
+
+// configure applies opts to a queue without allocating its storage.
+func configure[T any](opts []Option[T]) *queue[T] {
 	s := &queue[T]{
+		mu:       new(rwLock),
 		capacity: UnlimitedCapacity,
+		initCap:  -1,
+		growth:   defaultGrowth,
+		clock:    realClock{},
 	}
 	for _, opt := range opts {
 		opt(s)
 	}
 
-	s.items = make([]T, 0)
+	if s.circular && s.capacity <= 0 {
+		panic("cannot use circular mode without a finite positive capacity")
+	}
+	if s.circular && s.lifo {
+		panic("cannot use LIFO order with a circular queue")
+	}
+	if s.onPanic != nil {
+		for _, hooks := range []*[]func(T){&s.onEnqueue, &s.onDequeue, &s.onExpire, &s.onDeadLetter} {
+			*hooks = recovering(*hooks, s.onPanic)
+		}
+	}
 
 	return s
 }
 
-func (q *queue[T]) Enqueue(val T) error {
+// adapt returns an empty queue of W configured like base, for queues that
+// store each T inside a W alongside bookkeeping of their own. Hooks, the nil
+// check, validators, the size function, the key sets and the peek copy see
+// the T that field returns a pointer to. Write-ahead logging is not carried over.
+func adapt[T, W any](base *queue[T], field func(*W) *T) *queue[W] {
+	q := &queue[W]{
+		mu:           new(rwLock),
+		capacity:     base.capacity,
+		initCap:      base.initCap,
+		growth:       base.growth,
+		shrinkRatio:  base.shrinkRatio,
+		shrinkPolicy: base.shrinkPolicy,
+		circular:     base.circular,
+		lifo:         base.lifo,
+		clock:        base.clock,
+		name:         base.name,
+		tracer:       base.tracer,
+		limiter:      base.limiter,
+		enqueueLimit: base.enqueueLimit,
+		flushAt:      base.flushAt,
+		onEnqueue:    adaptHooks(base.onEnqueue, field),
+		onDequeue:    adaptHooks(base.onDequeue, field),
+	}
+	if base.deadLetter != nil {
+		q.deadLetter = func(w W) {
+			base.deadLetter(*field(&w))
+		}
+	}
+	if base.isNil != nil {
+		q.isNil = func(w W) bool {
+			return base.isNil(*field(&w))
+		}
+	}
+	for _, validate := range base.validators {
+		q.validators = append(q.validators, func(w W) error {
+			return validate(*field(&w))
+		})
+	}
+	if base.copyOnPeek != nil {
+		q.copyOnPeek = func(w W) W {
+			val := field(&w)
+			*val = base.copyOnPeek(*val)
+			return w
+		}
+	}
+	if base.sizeOf != nil {
+		q.sizeOf = func(w W) int {
+			return base.sizeOf(*field(&w))
+		}
+		q.maxBytes = base.maxBytes
+	}
+	if base.dedup != nil {
+		q.dedup = &fieldKeys[T, W]{keys: base.dedup.fresh(), field: field}
+	}
+	if base.index != nil {
+		q.index = &fieldKeys[T, W]{keys: base.index.fresh(), field: field}
+	}
+	if base.changes != nil {
+		q.changes = &fieldChanges[T, W]{changes: base.changes.fresh(), field: field}
+	}
+	q.items = newRing[W](q.initialSize())
+
+	return q
+}
+
+// adaptHooks adapts hooks on T to hooks on the W values holding them.
+func adaptHooks[T, W any](hooks []func(T), field func(*W) *T) []func(W) {
+	adapted := make([]func(W), len(hooks))
+	for i, hook := range hooks {
+		adapted[i] = func(w W) {
+			hook(*field(&w))
+		}
+	}
+
+	return adapted
+}
+
+// initialSize returns the number of slots to preallocate for a configured queue.
+func (q *queue[T]) initialSize() int {
+	// A known limit lets us allocate once instead of growing on demand
+	size := q.initCap
+	if size < 0 {
+		size = max(q.capacity, 0)
+	}
+	if q.capacity >= 0 {
+		size = min(size, q.capacity)
+	}
+
+	return size
+}
+
+func (q *queue[T]) Enqueue(val T) (err error) {
+	if q.tracer != nil {
+		end := q.tracer(q.name, OpEnqueue)
+		defer func() { end(traced(err), err) }()
+	}
+
+	return q.enqueue(val)
+}
+
+// enqueue implements Enqueue without tracing.
+func (q *queue[T]) enqueue(val T) error {
 	q.mu.Lock()
-	defer q.mu.Unlock()
+	err := q.push(val)
+	q.unlock()
 
-	if q.capacity >= 0 && len(q.items)+1 > q.capacity {
-		return ErrOverflow
+	if err == nil {
+		runHooks(q.onEnqueue, val)
+	}
+
+	return err
+}
+
+func (q *queue[T]) EnqueueDedupBack(val T, eq func(a, b T) bool) (bool, error) {
+	q.mu.Lock()
+	if q.closed {
+		q.unlock()
+		return false, ErrClosed
+	}
+	if q.items.len() > 0 && eq(q.items.at(q.newestIndex()), val) {
+		q.unlock()
+		return false, nil
+	}
+	err := q.push(val)
+	q.unlock()
+
+	if err != nil {
+		return false, err
 	}
+	runHooks(q.onEnqueue, val)
+
+	return true, nil
+}
+
+func (q *queue[T]) EnqueueFrontAll(vals ...T) error {
+	q.mu.Lock()
+	err := q.pushFront(vals)
+	q.unlock()
 
-	q.items = append(q.items, val)
+	if err != nil {
+		return err
+	}
+	for _, val := range vals {
+		runHooks(q.onEnqueue, val)
+	}
 
 	return nil
 }
 
-func (q *queue[T]) Dequeue() (T, error) {
+func (q *queue[T]) EnqueuePush(val T) (evicted T, didEvict bool, err error) {
 	q.mu.Lock()
-	defer q.mu.Unlock()
+	evicted, didEvict, err = q.pushEvicting(val)
+	q.unlock()
 
-	if len(q.items) == 0 {
-		var zero T
-		return zero, ErrUnderflow
+	if err == nil {
+		runHooks(q.onEnqueue, val)
+	}
+
+	return evicted, didEvict, err
+}
+
+func (q *queue[T]) TryEnqueue(val T) bool {
+	return q.enqueue(val) == nil
+}
+
+func (q *queue[T]) Offer(val T) bool {
+	return q.TryEnqueue(val)
+}
+
+func (q *queue[T]) EnqueueSlice(vals []T) (int, error) {
+	q.mu.Lock()
+	inserted := 0
+	var err error
+	for _, val := range vals {
+		if err = q.push(val); err != nil {
+			break
+		}
+		inserted++
+	}
+	q.unlock()
+
+	for _, val := range vals[:inserted] {
+		runHooks(q.onEnqueue, val)
+	}
+
+	return inserted, err
+}
+
+func (q *queue[T]) Dequeue() (result T, err error) {
+	if q.tracer != nil {
+		end := q.tracer(q.name, OpDequeue)
+		defer func() { end(traced(err), err) }()
 	}
 
-	result := q.items[0]
-	q.items = q.items[1:]
+	result, ok := q.TryDequeue()
+	if !ok {
+		return result, ErrUnderflow
+	}
 
 	return result, nil
 }
 
-func (q *queue[T]) Size() int {
-	q.mu.RLock()
-	defer q.mu.RUnlock()
+func (q *queue[T]) TryDequeue() (T, bool) {
+	q.mu.Lock()
+	result, ok := q.pop()
+	q.unlock()
 
-	return len(q.items)
+	if ok {
+		runHooks(q.onDequeue, result)
+	}
+
+	return result, ok
 }
 
-func (q *queue[T]) Peek() (T, error) {
-	q.mu.RLock()
-	defer q.mu.RUnlock()
+func (q *queue[T]) Poll() (T, bool) {
+	return q.TryDequeue()
+}
 
-	sz := len(q.items)
-	if sz == 0 {
-		var zero T
+func (q *queue[T]) DequeueOr(def T) T {
+	if val, ok := q.TryDequeue(); ok {
+		return val
+	}
+
+	return def
+}
+
+func (q *queue[T]) DequeueUntil(pred func(T) bool) (T, error) {
+	q.mu.Lock()
+	result, removed, found := q.popUntil(pred)
+	q.unlock()
+
+	for _, val := range removed {
+		runHooks(q.onDequeue, val)
+	}
+	if !found {
+		return result, ErrUnderflow
+	}
+
+	return result, nil
+}
+
+func (q *queue[T]) DequeueMatch(pred func(T) bool) (T, error) {
+	q.mu.Lock()
+	result, err := q.popMatch(pred)
+	q.unlock()
+
+	if err == nil {
+		runHooks(q.onDequeue, result)
+	}
+
+	return result, err
+}
+
+// popMatch removes and returns the first item matching pred, in storage
+// order, counting it as dequeued. Returns ErrUnderflow if the queue is empty
+// or ErrNotFound if no item matches. The caller must hold the write lock.
+func (q *queue[T]) popMatch(pred func(T) bool) (T, error) {
+	var zero T
+	if q.items.len() == 0 {
 		return zero, ErrUnderflow
 	}
 
-	return q.items[0], nil
+	for i := range q.items.len() {
+		if pred(q.items.at(i)) {
+			result := q.removeAt(i)
+			q.autoShrink()
+			q.stats.Dequeued++
+			return result, nil
+		}
+	}
+
+	return zero, ErrNotFound
+}
+
+// popUntil pops items until one matches pred, reporting the match and whether
+// there was one. The popped items are returned if dequeue hooks need them.
+// The caller must hold the write lock.
+func (q *queue[T]) popUntil(pred func(T) bool) (T, []T, bool) {
+	var removed []T
+	for {
+		val, ok := q.pop()
+		if !ok {
+			return val, removed, false
+		}
+		if len(q.onDequeue) > 0 {
+			removed = append(removed, val)
+		}
+		if pred(val) {
+			return val, removed, true
+		}
+	}
+}
+
+func (q *queue[T]) Drain() []T {
+	q.mu.Lock()
+	result := q.results.get(q.items.len())
+	q.popInto(result)
+	q.unlock()
+
+	for _, val := range result {
+		runHooks(q.onDequeue, val)
+	}
+
+	return result
+}
+
+func (q *queue[T]) DrainTo(dst []T) int {
+	q.mu.Lock()
+	n := q.popInto(dst)
+	q.unlock()
+
+	for _, val := range dst[:n] {
+		runHooks(q.onDequeue, val)
+	}
+
+	return n
+}
+
+// popInto pops up to len(dst) items into dst and returns the number popped.
+// The vacated slots are zeroed so the items can be garbage collected.
+// The caller must hold the write lock.
+func (q *queue[T]) popInto(dst []T) int {
+	n := min(len(dst), q.items.len())
+	for i := range n {
+		dst[i] = q.takeFront()
+	}
+	if n > 0 {
+		q.autoShrink()
+		q.stats.Dequeued += uint64(n)
+		q.notFull.broadcast()
+	}
+
+	return n
+}
+
+// push appends val to the back of the queue. Returns ErrClosed or ErrOverflow
+// if the item cannot be added. The caller must hold the write lock.
+func (q *queue[T]) push(val T) error {
+	if q.closed {
+		return ErrClosed
+	}
+	if err := q.invalid(val); err != nil {
+		return err
+	}
+	if q.duplicate(val) {
+		return ErrDuplicate
+	}
+	if q.throttled() > 0 {
+		return ErrRateLimited
+	}
+	if !q.accepts(val) {
+		q.stats.Rejected++
+		q.drop(val)
+		return ErrOverflow
+	}
+
+	q.admit()
+	q.add(val)
+
+	return nil
+}
+
+// pushEvicting is push, except that a full queue removes its front item to
+// make room and returns it instead of forwarding it. Nothing is evicted if val
+// would still not fit. The caller must hold the write lock.
+func (q *queue[T]) pushEvicting(val T) (T, bool, error) {
+	var evicted T
+	if q.closed || q.invalid(val) != nil || q.duplicate(val) || q.throttled() > 0 || q.fits(1) || q.items.len() == 0 {
+		return evicted, false, q.push(val)
+	}
+	n := q.itemBytes(val)
+	if q.sizeOf != nil && n > q.maxBytes || !q.circular && !q.fitsBytes(n-q.itemBytes(q.items.at(0))) {
+		return evicted, false, q.push(val) // Rejects val
+	}
+
+	evicted = q.takeFront()
+
+	return evicted, true, q.push(val)
+}
+
+// pushFront inserts vals at the front of the queue so that vals[0] becomes the
+// front, adding nothing if they do not all fit. The caller must hold the
+// write lock.
+func (q *queue[T]) pushFront(vals []T) error {
+	if q.closed {
+		return ErrClosed
+	}
+	bytes := 0
+	for _, val := range vals {
+		if err := q.invalid(val); err != nil {
+			return err
+		}
+		bytes += q.itemBytes(val)
+	}
+	if q.duplicates(vals, true) {
+		return ErrDuplicate
+	}
+	if !q.fits(len(vals)) || !q.fitsBytes(bytes) {
+		q.stats.Rejected++
+		for _, val := range vals {
+			q.drop(val)
+		}
+		return ErrOverflow
+	}
+
+	for i := len(vals) - 1; i >= 0; i-- {
+		if q.less != nil {
+			q.add(vals[i])
+			continue
+		}
+		if q.items.full() {
+			q.grow()
+		}
+		q.items.pushFront(vals[i])
+		q.track(vals[i])
+		q.bytes += q.itemBytes(vals[i])
+		q.stats.Enqueued++
+	}
+	q.changed()
+	if len(vals) > 0 {
+		q.stats.PeakSize = max(q.stats.PeakSize, q.items.len())
+		q.notEmpty.broadcast()
+	}
+
+	return nil
+}
+
+// invalid returns the error adding val fails with before any room is made for
+// it, if any: ErrNilValue if val is nil and the queue does not accept nil
+// values, or the first validator error wrapped with ErrInvalidItem.
+func (q *queue[T]) invalid(val T) error {
+	if q.isNil != nil && q.isNil(val) {
+		return ErrNilValue
+	}
+	for _, validate := range q.validators {
+		if err := validate(val); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidItem, err)
+		}
+	}
+
+	return nil
+}
+
+// fits reports whether n more items fit within the capacity.
+// The caller must hold the lock.
+func (q *queue[T]) fits(n int) bool {
+	return q.capacity < 0 || q.items.len()+q.pending()+n <= q.capacity
+}
+
+// pending returns the number of slots counted against the capacity that hold
+// no item: those of items held by an open batch, and those reserved by
+// Reserve. The caller must hold the lock.
+func (q *queue[T]) pending() int {
+	return len(q.held) + q.reserved
+}
+
+// fitsBytes reports whether n more bytes fit within the byte limit.
+// The caller must hold the lock.
+func (q *queue[T]) fitsBytes(n int) bool {
+	return q.sizeOf == nil || q.bytes+q.heldBytes+n <= q.maxBytes
+}
+
+// itemBytes returns the size of val counted against the byte limit.
+func (q *queue[T]) itemBytes(val T) int {
+	if q.sizeOf == nil {
+		return 0
+	}
+
+	return q.sizeOf(val)
+}
+
+// accepts reports whether val fits within the capacity and byte limit,
+// evicting front items of a circular queue to make room. Nothing is evicted
+// if val could never fit. The caller must hold the write lock.
+func (q *queue[T]) accepts(val T) bool {
+	n := q.itemBytes(val)
+	if q.sizeOf != nil && n > q.maxBytes {
+		return false
+	}
+	for !q.fits(1) || !q.fitsBytes(n) {
+		if !q.evict() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// evict makes room for one item in a full circular queue by discarding the
+// front item, reporting whether room was made. The caller must hold the write lock.
+func (q *queue[T]) evict() bool {
+	if !q.circular || q.items.len() == 0 {
+		return false
+	}
+
+	q.drop(q.takeFront())
+
+	return true
+}
+
+// add appends val to the back of the queue, or the front of a LIFO queue,
+// without checking the capacity. The caller must hold the write lock.
+func (q *queue[T]) add(val T) {
+	if q.items.full() {
+		q.grow()
+	}
+	if q.lifo {
+		q.items.pushFront(val)
+	} else {
+		q.items.pushBack(val)
+	}
+	q.track(val)
+	q.changed()
+	q.logEnqueue(val)
+	q.bytes += q.itemBytes(val)
+	if q.less != nil {
+		q.siftUp(q.items.len() - 1)
+	}
+	q.stats.Enqueued++
+	if q.items.len() > q.stats.PeakSize {
+		q.stats.PeakSize = q.items.len()
+	}
+	q.notEmpty.broadcast()
+}
+
+// addInOrder adds vals, given in the order Dequeue should return them, without
+// checking the capacity. The caller must hold the write lock.
+func (q *queue[T]) addInOrder(vals []T) {
+	if !q.lifo {
+		for _, val := range vals {
+			q.add(val)
+		}
+		return
+	}
+
+	for i := len(vals) - 1; i >= 0; i-- {
+		q.add(vals[i])
+	}
+}
+
+// newestIndex returns the logical index of the item added last by an
+// enqueue at the back. The queue must not be empty. The caller must hold the
+// lock.
+func (q *queue[T]) newestIndex() int {
+	if q.lifo {
+		return 0
+	}
+
+	return q.lastIndex()
+}
+
+// pop removes and returns the front item, reporting whether there was one.
+// The caller must hold the write lock.
+func (q *queue[T]) pop() (T, bool) {
+	if q.items.len() == 0 {
+		var zero T
+		return zero, false
+	}
+
+	result := q.takeFront()
+	q.autoShrink()
+	q.stats.Dequeued++
+	q.notFull.broadcast()
+
+	return result, true
+}
+
+// takeFront removes and returns the item Dequeue would return next. The queue
+// must not be empty. The caller must hold the write lock.
+func (q *queue[T]) takeFront() T {
+	result := q.detachFront()
+	q.untrack(result)
+	q.changed()
+	q.logDequeue()
+
+	return result
+}
+
+// detachFront is takeFront, except that the item's key stays tracked, its
+// removal is not logged and the caller must call changed, for items held by a
+// batch. The caller must hold the write lock.
+func (q *queue[T]) detachFront() T {
+	var result T
+	if q.less == nil {
+		result = q.items.popFront()
+	} else {
+		// Move the last item to the root and let it sink into place
+		last := q.items.len() - 1
+		result = q.items.at(0)
+		q.items.set(0, q.items.at(last))
+		q.items.truncate(last)
+		q.siftDown(0)
+	}
+	q.bytes -= q.itemBytes(result)
+
+	return result
+}
+
+// drop records that val was rejected for lack of room or evicted, so that
+// unlock can forward it to the dead-letter queue. The caller must hold the
+// write lock.
+func (q *queue[T]) drop(val T) {
+	if q.deadLetter != nil {
+		q.dropped = append(q.dropped, val)
+	}
+}
+
+// unlock releases the write lock and then forwards the items dropped while it
+// was held to the dead-letter queue, if there is one.
+func (q *queue[T]) unlock() {
+	dropped := q.takeDropped()
+	q.mu.Unlock()
+	q.forward(dropped)
+	q.flushIfDue()
+}
+
+// flushes makes the flush callback, if there is one, receive owner, the
+// queue the caller sees.
+func (q *queue[T]) flushes(owner Queue[T]) {
+	if q.onFlush != nil {
+		q.flush = func() { q.onFlush(owner) }
+	}
+}
+
+// flushIfDue runs the flush callback if the size reached the flush threshold
+// since it last ran. The caller must not hold the lock.
+func (q *queue[T]) flushIfDue() {
+	if q.flush != nil && q.flushDue.CompareAndSwap(true, false) {
+		q.flush()
+	}
+}
+
+// takeDropped returns and clears the items dropped since the last call.
+// The caller must hold the write lock.
+func (q *queue[T]) takeDropped() []T {
+	dropped := q.dropped
+	q.dropped = nil
+
+	return dropped
+}
+
+// forward enqueues dropped items into the dead-letter queue. It must be
+// called without holding the queue lock.
+func (q *queue[T]) forward(dropped []T) {
+	for _, val := range dropped {
+		q.deadLetter(val)
+	}
+}
+
+// autoShrink halves the backing storage while fewer than shrinkRatio of its
+// slots are in use, as long as the items and minGrowSize slots still fit.
+// Halving rather than fitting the storage to the items leaves room for new
+// items, so a queue hovering around the threshold does not reallocate on
+// every operation. It does nothing unless WithAutoShrink was given.
+// The caller must hold the write lock.
+func (q *queue[T]) autoShrink() {
+	if q.shrinkRatio == 0 {
+		return
+	}
+
+	n, size := q.items.len(), q.items.cap()
+	for float64(n) < q.shrinkRatio*float64(size) && size/2 >= max(n, minGrowSize) {
+		size /= 2
+	}
+	if size < q.items.cap() {
+		q.items.resize(size)
+	}
+}
+
+// grow enlarges the backing storage so at least one more item fits. Storage
+// grows by the growth factor but never exceeds a finite capacity.
+// The caller must hold the write lock.
+func (q *queue[T]) grow() {
+	current := q.items.cap()
+	size := max(int(float64(current)*q.growth), current+1, minGrowSize)
+	if q.capacity >= 0 {
+		size = min(size, q.capacity)
+	}
+	q.items.resize(size)
+}
+
+func (q *queue[T]) Close() error {
+	q.mu.Lock()
+	defer q.unlock()
+
+	if !q.close() {
+		return ErrClosed
+	}
+
+	return nil
+}
+
+func (q *queue[T]) CloseAndDrain(fn func(T)) []T {
+	q.mu.Lock()
+	q.close()
+	result := make([]T, q.items.len())
+	q.popInto(result)
+	q.unlock()
+
+	for _, val := range result {
+		runHooks(q.onDequeue, val)
+	}
+	handOff(result, fn)
+
+	return result
+}
+
+// close marks the queue closed and wakes blocked callers, reporting whether it
+// was open. The caller must hold the write lock.
+func (q *queue[T]) close() bool {
+	if q.closed {
+		return false
+	}
+	q.closed = true
+
+	// Blocked producers and consumers re-check and observe the closed state
+	q.notFull.broadcast()
+	q.notEmpty.broadcast()
+
+	return true
+}
+
+// handOff calls fn, if not nil, on each item removed by CloseAndDrain.
+func handOff[T any](vals []T, fn func(T)) {
+	if fn == nil {
+		return
+	}
+	for _, val := range vals {
+		fn(val)
+	}
+}
+
+func (q *queue[T]) Reset() {
+	q.mu.Lock()
+	q.reset()
+	q.unlock()
+}
+
+// reset implements Reset and returns the number of items discarded.
+// The caller must hold the write lock.
+func (q *queue[T]) reset() int {
+	n := q.items.len()
+	q.untrackAll()
+	q.items.truncate(0)
+	q.changed()
+	q.bytes = 0
+	q.stats = Stats{}
+	q.closed = false
+	q.notFull.broadcast()
+
+	return n
+}
+
+// sizeAttempts is the number of lock-free reads Size tries before waiting for
+// the read lock.
+const sizeAttempts = 4
+
+// Size reads the count changed mirrors as a seqlock reader, which only
+// accepts a count no write overlapped: one published by a completed write
+// operation rather than a step partway through one, such as the empty queue
+// CopyInto passes through.
+func (q *queue[T]) Size() int {
+	for range sizeAttempts {
+		if n, ok := q.mu.stable(q.approxSize.Load); ok {
+			return int(n)
+		}
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.items.len()
+}
+
+func (q *queue[T]) ApproxSize() int {
+	return int(q.approxSize.Load())
+}
+
+// changed bumps the version, records the current number of items for
+// ApproxSize and notifies subscribers if that number changed. It must be
+// called after every change to the items, with the write lock held.
+func (q *queue[T]) changed() {
+	q.version++
+	if q.changes != nil {
+		q.changes.stamp(q.version)
+	}
+	size := q.items.len()
+	if int(q.approxSize.Swap(int64(size))) != size {
+		q.subs.notify(size)
+	}
+	if q.flush != nil {
+		above := size >= q.flushAt
+		if above && !q.aboveFlush {
+			q.flushDue.Store(true)
+		}
+		q.aboveFlush = above
+	}
+}
+
+func (q *queue[T]) SizeBytes() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.bytes
+}
+
+func (q *queue[T]) Remaining() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.capacity == UnlimitedCapacity {
+		return UnlimitedCapacity
+	}
+
+	return q.capacity - q.items.len() - q.pending()
+}
+
+func (q *queue[T]) SetCapacity(n int) error {
+	if n < UnlimitedCapacity {
+		return ErrInvalidCapacity
+	}
+
+	q.mu.Lock()
+	defer q.unlock()
+
+	if q.circular && n <= 0 {
+		return ErrInvalidCapacity
+	}
+
+	if n >= 0 && n < q.items.len()+q.pending() {
+		// Items in an open batch and reserved slots cannot be dropped
+		if q.shrinkPolicy == ShrinkReject || n < q.pending() {
+			return ErrCapacityTooSmall
+		}
+		for q.items.len()+q.pending() > n {
+			q.drop(q.takeFront())
+		}
+	}
+
+	// Release storage that can no longer be used
+	if n >= 0 && q.items.cap() > n {
+		q.items.resize(n)
+	}
+	q.capacity = n
+	q.notFull.broadcast()
+
+	return nil
+}
+
+func (q *queue[T]) Grow(n int) error {
+	if n < 0 {
+		return ErrInvalidCapacity
+	}
+
+	q.mu.Lock()
+	defer q.unlock()
+
+	if q.capacity >= 0 {
+		n = min(n, q.capacity-q.items.len()-q.pending())
+	}
+	if size := q.items.len() + n; size > q.items.cap() {
+		q.items.resize(size)
+	}
+
+	return nil
+}
+
+func (q *queue[T]) Compact() {
+	q.mu.Lock()
+	defer q.unlock()
+
+	if q.items.cap() > q.items.len() {
+		q.items.resize(q.items.len())
+	}
+}
+
+func (q *queue[T]) Peek() (result T, err error) {
+	if q.tracer != nil {
+		end := q.tracer(q.name, OpPeek)
+		defer func() { end(traced(err), err) }()
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	sz := q.items.len()
+	if sz == 0 {
+		var zero T
+		return zero, ErrUnderflow
+	}
+
+	return q.peeked(q.items.at(0)), nil
+}
+
+// peeked returns the value Peek, Front and Back hand out for val: a copy if
+// WithCopyOnPeek was given, val itself otherwise.
+func (q *queue[T]) peeked(val T) T {
+	if q.copyOnPeek == nil {
+		return val
+	}
+
+	return q.copyOnPeek(val)
+}
+
+func (q *queue[T]) Front() (T, error) {
+	return q.Peek()
+}
+
+func (q *queue[T]) PeekOr(def T) T {
+	if val, err := q.Peek(); err == nil {
+		return val
+	}
+
+	return def
+}
+
+func (q *queue[T]) Back() (T, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.items.len() == 0 {
+		var zero T
+		return zero, ErrUnderflow
+	}
+
+	return q.peeked(q.items.at(q.lastIndex())), nil
+}
+
+func (q *queue[T]) PeekN(n int) ([]T, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.items.len() == 0 {
+		return []T{}, ErrUnderflow
+	}
+
+	return q.first(n), nil
+}
+
+// first returns copies of up to the first n items in dequeue order, as Peek
+// would hand them out. For a priority queue this sorts a copy of the heap.
+// The caller must hold the lock.
+func (q *queue[T]) first(n int) []T {
+	var result []T
+	if q.less == nil {
+		result = q.results.get(min(n, q.items.len()))
+		for i := range result {
+			result[i] = q.items.at(i)
+		}
+	} else {
+		all := q.results.get(q.items.len())
+		q.items.copyTo(all)
+		slices.SortFunc(all, func(a, b T) int {
+			switch {
+			case q.less(a, b):
+				return -1
+			case q.less(b, a):
+				return 1
+			}
+			return 0
+		})
+		k := min(n, len(all))
+		result = all[:k:k]
+		if q.results != nil {
+			result = all[:k] // Release pools the whole sorted copy
+		}
+	}
+	for i, val := range result {
+		result[i] = q.peeked(val)
+	}
+
+	return result
+}
+
+func (q *queue[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range q.snapshot() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func (q *queue[T]) AllIndexed() iter.Seq2[int, T] {
+	return allIndexed(q.All())
+}
+
+// allIndexed implements AllIndexed by numbering the items all yields.
+func allIndexed[T any](all iter.Seq[T]) iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		i := 0
+		for v := range all {
+			if !yield(i, v) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+func (q *queue[T]) ReplaceFront(val T) error {
+	q.mu.Lock()
+	defer q.unlock()
+
+	if q.items.len() == 0 {
+		return ErrUnderflow
+	}
+
+	return q.replaceAt(0, val)
+}
+
+func (q *queue[T]) ReplaceBack(val T) error {
+	q.mu.Lock()
+	defer q.unlock()
+
+	if q.items.len() == 0 {
+		return ErrUnderflow
+	}
+
+	return q.replaceAt(q.lastIndex(), val)
+}
+
+// replaceAt overwrites the item at logical index i, which must be in range,
+// with val. The caller must hold the write lock.
+func (q *queue[T]) replaceAt(i int, val T) error {
+	if err := q.invalid(val); err != nil {
+		return err
+	}
+	old := q.items.at(i)
+	bytes := q.bytes - q.itemBytes(old) + q.itemBytes(val)
+	if q.sizeOf != nil && bytes > q.maxBytes {
+		return ErrOverflow
+	}
+	if q.dedup != nil {
+		q.dedup.remove(old)
+		dup := q.dedup.has(val)
+		q.dedup.add(old)
+		if dup {
+			return ErrDuplicate
+		}
+	}
+	q.untrack(old)
+	q.track(val)
+
+	q.items.set(i, val)
+	q.bytes = bytes
+	if q.less != nil {
+		q.siftDown(i)
+		q.siftUp(i)
+	}
+	q.changed()
+
+	return nil
+}
+
+func (q *queue[T]) Reverse() {
+	q.mu.Lock()
+	defer q.unlock()
+
+	q.items.reverse()
+	q.heapify()
+	q.changed()
+}
+
+func (q *queue[T]) Rotate(n int) {
+	q.mu.Lock()
+	defer q.unlock()
+
+	q.items.rotate(n)
+	q.heapify()
+	q.changed()
+}
+
+func (q *queue[T]) Shuffle(r *rand.Rand) {
+	q.mu.Lock()
+	defer q.unlock()
+
+	shuffle := rand.Shuffle
+	if r != nil {
+		shuffle = r.Shuffle
+	}
+	shuffle(q.items.len(), q.swapItems)
+	q.heapify()
+	q.changed()
+}
+
+func (q *queue[T]) ForEach(fn func(T) bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	for i := 0; i < q.items.len(); i++ {
+		if !fn(q.items.at(i)) {
+			return
+		}
+	}
+}
+
+func (q *queue[T]) ForEachCtx(ctx context.Context, fn func(T) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	for i := range q.items.len() {
+		if err := interrupted(ctx, i); err != nil {
+			return err
+		}
+		if !fn(q.items.at(i)) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// ctxCheckInterval is the number of items FilterCtx and ForEachCtx visit
+// between checks of their context, so that the check stays cheap next to the
+// callback.
+const ctxCheckInterval = 64
+
+// interrupted returns ctx.Err() if i, the number of items visited so far, is
+// a positive multiple of ctxCheckInterval, and nil otherwise.
+func interrupted(ctx context.Context, i int) error {
+	if i == 0 || i%ctxCheckInterval != 0 {
+		return nil
+	}
+
+	return ctx.Err()
+}
+
+func (q *queue[T]) FindIndex(pred func(T) bool) int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	for i := range q.items.len() {
+		if pred(q.items.at(i)) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (q *queue[T]) Version() uint64 {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.version
+}
+
+func (q *queue[T]) Snapshot() ([]T, uint64) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	result := q.results.get(q.items.len())
+	q.items.copyTo(result)
+
+	return result, q.version
+}
+
+// snapshot returns a copy of the items in FIFO order.
+func (q *queue[T]) snapshot() []T {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	result := make([]T, q.items.len())
+	q.items.copyTo(result)
+
+	return result
+}
+
+// lockOrder returns a and b in the order in which they must be locked when
+// both are held at once, so that concurrent operations on the same pair, in
+// either argument order, cannot deadlock.
+func lockOrder[T any](a, b *queue[T]) (*queue[T], *queue[T]) {
+	if uintptr(unsafe.Pointer(b)) < uintptr(unsafe.Pointer(a)) {
+		return b, a
+	}
+
+	return a, b
+}
+
+// runHooks invokes each hook with val in registration order.
+// It must be called without holding the queue lock.
+func runHooks[T any](hooks []func(T), val T) {
+	for _, hook := range hooks {
+		hook(val)
+	}
+}
+
+// recovering wraps each hook so that a panic in it is recovered and passed to
+// handler.
+func recovering[T any](hooks []func(T), handler func(any)) []func(T) {
+	wrapped := make([]func(T), len(hooks))
+	for i, hook := range hooks {
+		wrapped[i] = func(val T) {
+			defer func() {
+				if r := recover(); r != nil {
+					handler(r)
+				}
+			}()
+			hook(val)
+		}
+	}
+
+	return wrapped
+}
+
+func (q *queue[T]) String() string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return formatQueue(q.name, q.items.len(), q.capacity, q.items.at)
+}
+
+func (q *queue[T]) Name() string {
+	return q.name
+}
+
+// formatQueue renders a queue of n items in the String format, reading the
+// item at each front-relative index with at.
+func formatQueue[T any](name string, n, capacity int, at func(int) T) string {
+	var b strings.Builder
+	b.WriteString("Queue")
+	if name != "" {
+		fmt.Fprintf(&b, "(%s)", name)
+	}
+	if capacity == UnlimitedCapacity {
+		fmt.Fprintf(&b, "[len=%d/cap=unlimited]: [", n)
+	} else {
+		fmt.Fprintf(&b, "[len=%d/cap=%d]: [", n, capacity)
+	}
+
+	for i := 0; i < n; i++ {
+		if i == maxStringItems {
+			fmt.Fprintf(&b, " ...+%d more", n-maxStringItems)
+			break
+		}
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprint(&b, at(i))
+	}
+	b.WriteByte(']')
+
+	return b.String()
+}
+
+func (q *queue[T]) Remove(i int) (T, error) {
+	q.mu.Lock()
+	defer q.unlock()
+
+	if i < 0 || i >= q.items.len() {
+		var zero T
+		return zero, ErrIndexOutOfRange
+	}
+
+	return q.removeAt(i), nil
+}
+
+func (q *queue[T]) Swap(i, j int) error {
+	q.mu.Lock()
+	defer q.unlock()
+
+	n := q.items.len()
+	if i < 0 || i >= n || j < 0 || j >= n {
+		return ErrIndexOutOfRange
+	}
+
+	q.swapItems(i, j)
+	q.heapify()
+	q.changed()
+
+	return nil
+}
+
+// removeAt removes and returns the item at logical index i, which must be in
+// range. The caller must hold the write lock.
+func (q *queue[T]) removeAt(i int) T {
+	var val T
+	if q.less == nil {
+		val = q.items.remove(i)
+	} else {
+		// Fill the hole with the last item and move it to where it belongs
+		last := q.items.len() - 1
+		val = q.items.at(i)
+		q.items.set(i, q.items.at(last))
+		q.items.truncate(last)
+		if i < last {
+			q.siftDown(i)
+			q.siftUp(i)
+		}
+	}
+	q.untrack(val)
+	q.changed()
+	q.bytes -= q.itemBytes(val)
+	q.notFull.broadcast()
+
+	return val
+}
+
+func (q *queue[T]) Filter(keep func(T) bool) int {
+	q.mu.Lock()
+	defer q.unlock()
+
+	return q.filter(func(_ int, v T) bool { return keep(v) })
+}
+
+func (q *queue[T]) FilterCtx(ctx context.Context, keep func(T) bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	defer q.unlock()
+
+	kept, err := q.decide(ctx, keep, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return q.filter(func(i int, _ T) bool { return kept[i] }), nil
+}
+
+// decide calls keep on every item and returns its results by index, or
+// ctx.Err() if ctx is done first. visited is the number of items already
+// visited by the caller, so that checks stay evenly spaced across queues. The
+// caller must hold the lock.
+func (q *queue[T]) decide(ctx context.Context, keep func(T) bool, visited int) ([]bool, error) {
+	kept := make([]bool, q.items.len())
+	for i := range kept {
+		if err := interrupted(ctx, visited+i); err != nil {
+			return nil, err
+		}
+		kept[i] = keep(q.items.at(i))
+	}
+
+	return kept, nil
+}
+
+// filter removes every item for which keep, given its index and value,
+// returns false, and returns the number removed. The caller must hold the
+// write lock.
+func (q *queue[T]) filter(keep func(int, T) bool) int {
+	n := 0
+	for i := 0; i < q.items.len(); i++ {
+		if v := q.items.at(i); keep(i, v) {
+			q.items.set(n, v)
+			n++
+		} else {
+			q.untrack(v)
+			q.bytes -= q.itemBytes(v)
+		}
+	}
+
+	// Zero the vacated tail so removed items can be garbage collected
+	removed := q.items.len() - n
+	q.items.truncate(n)
+	if removed > 0 {
+		q.changed()
+		q.heapify()
+		q.notFull.broadcast()
+	}
+
+	return removed
 }
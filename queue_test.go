@@ -1,10 +1,18 @@
 package queue
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -29,6 +37,24 @@ func TestNewWithCapacity(t *testing.T) {
 	}
 }
 
+func TestWithCapacityChecked(t *testing.T) {
+	for _, cap := range []int{-5, -2} {
+		if opt, err := WithCapacityChecked[int](cap); opt != nil || !errors.Is(err, ErrInvalidCapacity) {
+			t.Errorf("WithCapacityChecked(%d) = (%v, %v), want (nil, %v)", cap, opt != nil, err, ErrInvalidCapacity)
+		}
+	}
+
+	for _, cap := range []int{UnlimitedCapacity, 0, 3} {
+		opt, err := WithCapacityChecked[int](cap)
+		if err != nil {
+			t.Fatalf("WithCapacityChecked(%d) = %v, want nil", cap, err)
+		}
+		if r := New(opt).Remaining(); r != cap {
+			t.Errorf("Remaining() with WithCapacityChecked(%d) = %d, want %d", cap, r, cap)
+		}
+	}
+}
+
 func TestEnqueueDequeue(t *testing.T) {
 	q := New[int]()
 
@@ -503,65 +529,3411 @@ func TestQueueWithCapacityStress(t *testing.T) {
 	}
 }
 
-// Benchmark tests
-func BenchmarkEnqueue(b *testing.B) {
+func TestAll(t *testing.T) {
 	q := New[int]()
-	b.ResetTimer()
+	values := []int{1, 2, 3, 4, 5}
+	for _, v := range values {
+		_ = q.Enqueue(v)
+	}
 
-	for i := 0; i < b.N; i++ {
+	var got []int
+	for v := range q.All() {
+		got = append(got, v)
+	}
+
+	if len(got) != len(values) {
+		t.Fatalf("All() yielded %d items, want %d", len(got), len(values))
+	}
+	for i, expected := range values {
+		if got[i] != expected {
+			t.Errorf("All() at position %d = %d, want %d", i, got[i], expected)
+		}
+	}
+
+	// Iteration must not consume items
+	if size := q.Size(); size != len(values) {
+		t.Errorf("Size after All() = %d, want %d", size, len(values))
+	}
+}
+
+func TestAllEarlyBreak(t *testing.T) {
+	q := New[int]()
+	for i := 0; i < 10; i++ {
 		_ = q.Enqueue(i)
 	}
+
+	count := 0
+	for v := range q.All() {
+		if v == 3 {
+			break
+		}
+		count++
+	}
+
+	if count != 3 {
+		t.Errorf("All() yielded %d items before break, want 3", count)
+	}
+
+	// No lock may be left held after breaking out of the loop
+	if err := q.Enqueue(10); err != nil {
+		t.Errorf("Enqueue() after break error = %v, want nil", err)
+	}
+
+	val, err := q.Dequeue()
+	if err != nil {
+		t.Errorf("Dequeue() after break error = %v, want nil", err)
+	}
+	if val != 0 {
+		t.Errorf("Dequeue() after break = %d, want 0", val)
+	}
+
+	if size := q.Size(); size != 10 {
+		t.Errorf("Size after break = %d, want 10", size)
+	}
 }
 
-func BenchmarkDequeue(b *testing.B) {
+func TestAllSnapshot(t *testing.T) {
 	q := New[int]()
-	// Pre-populate queue
-	for i := 0; i < b.N; i++ {
+	for i := 0; i < 3; i++ {
 		_ = q.Enqueue(i)
 	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
+	// Mutating the queue while iterating must not affect the snapshot
+	var got []int
+	for v := range q.All() {
+		got = append(got, v)
+		_ = q.Enqueue(v + 100)
 		_, _ = q.Dequeue()
 	}
+
+	want := []int{0, 1, 2}
+	if len(got) != len(want) {
+		t.Fatalf("All() yielded %d items, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("All() at position %d = %d, want %d", i, got[i], want[i])
+		}
+	}
+
+	if size := q.Size(); size != 3 {
+		t.Errorf("Size after mutating iteration = %d, want 3", size)
+	}
 }
 
-func BenchmarkPeek(b *testing.B) {
+func TestAllIndexed(t *testing.T) {
 	q := New[int]()
-	_ = q.Enqueue(42)
+	for i := 0; i < 5; i++ {
+		_ = q.Enqueue(i * 10)
+	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, _ = q.Peek()
+	next := 0
+	for i, v := range q.AllIndexed() {
+		if i != next || v != i*10 {
+			t.Errorf("AllIndexed() yielded (%d, %d), want (%d, %d)", i, v, next, next*10)
+		}
+		next++
+	}
+	if next != q.Size() {
+		t.Errorf("AllIndexed() yielded %d items, want %d", next, q.Size())
 	}
 }
 
-func BenchmarkSize(b *testing.B) {
+func TestAllIndexedEarlyBreak(t *testing.T) {
 	q := New[int]()
-	for i := 0; i < 1000; i++ {
+	for i := 0; i < 10; i++ {
 		_ = q.Enqueue(i)
 	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		q.Size()
+	last := -1
+	for i := range q.AllIndexed() {
+		if i == 3 {
+			break
+		}
+		last = i
+	}
+	if last != 2 {
+		t.Errorf("AllIndexed() last index before break = %d, want 2", last)
+	}
+
+	// No lock may be left held after breaking out of the loop, and the index
+	// can be handed to Remove
+	if val, err := q.Remove(3); val != 3 || err != nil {
+		t.Errorf("Remove(3) after break = (%d, %v), want (3, nil)", val, err)
 	}
 }
 
-func BenchmarkMixedOperations(b *testing.B) {
+func TestAllEmpty(t *testing.T) {
 	q := New[int]()
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		switch i % 4 {
-		case 0:
+	for v := range q.All() {
+		t.Errorf("All() on empty queue yielded %d", v)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var enqueued, dequeued []int
+	q := New[int](
+		WithCapacity[int](2),
+		WithOnEnqueue[int](func(v int) { enqueued = append(enqueued, v) }),
+		WithOnDequeue[int](func(v int) { dequeued = append(dequeued, v) }),
+	)
+
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+
+	// Overflow must not fire the enqueue hook
+	if err := q.Enqueue(3); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Enqueue(3) error = %v, want ErrOverflow", err)
+	}
+
+	_, _ = q.Dequeue()
+	_, _ = q.Dequeue()
+
+	// Underflow must not fire the dequeue hook
+	if _, err := q.Dequeue(); !errors.Is(err, ErrUnderflow) {
+		t.Fatalf("Dequeue() error = %v, want ErrUnderflow", err)
+	}
+
+	want := []int{1, 2}
+	if len(enqueued) != len(want) || enqueued[0] != 1 || enqueued[1] != 2 {
+		t.Errorf("enqueue hook calls = %v, want %v", enqueued, want)
+	}
+	if len(dequeued) != len(want) || dequeued[0] != 1 || dequeued[1] != 2 {
+		t.Errorf("dequeue hook calls = %v, want %v", dequeued, want)
+	}
+}
+
+func TestHooksRegistrationOrder(t *testing.T) {
+	var calls []string
+	q := New[int](
+		WithOnEnqueue[int](func(int) { calls = append(calls, "first") }),
+		WithOnEnqueue[int](func(int) { calls = append(calls, "second") }),
+		WithOnEnqueue[int](func(int) { calls = append(calls, "third") }),
+	)
+
+	_ = q.Enqueue(1)
+
+	want := []string{"first", "second", "third"}
+	if len(calls) != len(want) {
+		t.Fatalf("hook calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("hook call %d = %q, want %q", i, calls[i], want[i])
+		}
+	}
+}
+
+func TestHooksCanReenterQueue(t *testing.T) {
+	var q Queue[int]
+	var sizes []int
+	q = New[int](
+		WithOnEnqueue[int](func(int) { sizes = append(sizes, q.Size()) }),
+		WithOnDequeue[int](func(int) { sizes = append(sizes, q.Size()) }),
+	)
+
+	// Would deadlock if hooks ran while the lock is held
+	_ = q.Enqueue(1)
+	_, _ = q.Dequeue()
+
+	if len(sizes) != 2 || sizes[0] != 1 || sizes[1] != 0 {
+		t.Errorf("sizes observed in hooks = %v, want [1 0]", sizes)
+	}
+}
+
+func TestRecoverHooks(t *testing.T) {
+	explode := func(v int) {
+		if v < 0 {
+			panic(fmt.Sprintf("hook saw %d", v))
+		}
+	}
+
+	for name, newFn := range map[string]func(opts ...Option[int]) Queue[int]{
+		"queue":    func(opts ...Option[int]) Queue[int] { return New(opts...) },
+		"sharded":  func(opts ...Option[int]) Queue[int] { return NewSharded(2, opts...) },
+		"expiring": func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var recovered []any
+			var after []int
+			q := newFn(
+				WithRecoverHooks[int](func(r any) { recovered = append(recovered, r) }),
+				WithOnEnqueue(explode),
+				WithOnEnqueue(func(v int) { after = append(after, v) }),
+				WithOnDequeue(explode),
+			)
+
+			// The operations succeed and later hooks still run
+			if err := q.Enqueue(-1); err != nil {
+				t.Errorf("Enqueue(-1) with a panicking hook = %v, want nil", err)
+			}
+			if val, err := q.Dequeue(); val != -1 || err != nil {
+				t.Errorf("Dequeue() with a panicking hook = (%d, %v), want (-1, nil)", val, err)
+			}
+			if fmt.Sprint(recovered) != "[hook saw -1 hook saw -1]" {
+				t.Errorf("recovered = %q, want two panics from the hooks", recovered)
+			}
+			if !slices.Equal(after, []int{-1}) {
+				t.Errorf("hook after the panicking one saw %v, want [-1]", after)
+			}
+
+			if err := q.Enqueue(1); err != nil {
+				t.Errorf("Enqueue(1) after recovered panics = %v, want nil", err)
+			}
+			if len(recovered) != 2 {
+				t.Errorf("recovered %d panics, want 2", len(recovered))
+			}
+		})
+	}
+
+	t.Run("default re-panics", func(t *testing.T) {
+		q := New(WithOnEnqueue(explode))
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("Enqueue(-1) with a panicking hook did not panic")
+				}
+			}()
+			_ = q.Enqueue(-1)
+		}()
+
+		// The lock was released before the hook ran
+		if err := q.Enqueue(1); err != nil {
+			t.Errorf("Enqueue(1) after a hook panicked = %v, want nil", err)
+		}
+		if size := q.Size(); size != 2 {
+			t.Errorf("Size() = %d, want 2", size)
+		}
+	})
+
+	t.Run("nil handler (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("WithRecoverHooks(nil) should panic, but it didn't")
+			}
+		}()
+
+		WithRecoverHooks[int](nil)
+	})
+}
+
+func TestWithName(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func(...Option[int]) Queue[int]
+	}{
+		{name: "fifo", newFn: func(opts ...Option[int]) Queue[int] { return New(opts...) }},
+		{name: "sharded", newFn: func(opts ...Option[int]) Queue[int] { return NewSharded(2, opts...) }},
+		{name: "expiring", newFn: func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.newFn(WithName[int]("emails"))
+			_ = q.Enqueue(7)
+
+			if got := q.Name(); got != "emails" {
+				t.Errorf("Name() = %q, want %q", got, "emails")
+			}
+			if got := q.String(); !strings.HasPrefix(got, "Queue(emails)[") {
+				t.Errorf("String() = %q, want it to start with %q", got, "Queue(emails)[")
+			}
+			if s := q.Stats(); s.Name != "emails" {
+				t.Errorf("Stats().Name = %q, want %q", s.Name, "emails")
+			}
+
+			// A split-off queue keeps the name
+			head, _ := q.Split(1)
+			if got := head.Name(); got != "emails" {
+				t.Errorf("Name() of split-off queue = %q, want %q", got, "emails")
+			}
+
+			if got := tt.newFn().Name(); got != "" {
+				t.Errorf("Name() of unnamed queue = %q, want empty", got)
+			}
+		})
+	}
+}
+
+func TestWithNamePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("WithName(\"\") did not panic")
+		}
+	}()
+	WithName[int]("")
+}
+
+func TestVersion(t *testing.T) {
+	q := New(WithCapacity[int](4))
+	mutations := []struct {
+		name string
+		fn   func()
+	}{
+		{"Enqueue", func() { _ = q.Enqueue(1) }},
+		{"EnqueueSlice", func() { _, _ = q.EnqueueSlice([]int{2, 3}) }},
+		{"EnqueueFrontAll", func() { _ = q.EnqueueFrontAll(0) }},
+		{"Dequeue", func() { _, _ = q.Dequeue() }},
+		{"Swap", func() { _ = q.Swap(0, 1) }},
+		{"Reverse", func() { q.Reverse() }},
+		{"Rotate", func() { q.Rotate(1) }},
+		{"Remove", func() { _, _ = q.Remove(0) }},
+		{"Filter", func() { q.Filter(func(v int) bool { return v != 3 }) }},
+		{"DrainTo", func() { q.DrainTo(make([]int, 1)) }},
+		{"Reset", func() { _ = q.Enqueue(5); q.Reset() }},
+	}
+	reads := []struct {
+		name string
+		fn   func()
+	}{
+		{"Size", func() { q.Size() }},
+		{"Peek", func() { _, _ = q.Peek() }},
+		{"Back", func() { _, _ = q.Back() }},
+		{"All", func() { _ = slices.Collect(q.All()) }},
+		{"ForEach", func() { q.ForEach(func(int) bool { return true }) }},
+		{"Stats", func() { q.Stats() }},
+		{"String", func() { _ = q.String() }},
+		{"Snapshot", func() { q.Snapshot() }},
+		{"Dequeue on empty", func() { _, _ = New[int]().Dequeue() }},
+	}
+
+	last := q.Version()
+	for _, m := range mutations {
+		m.fn()
+		v := q.Version()
+		if v <= last {
+			t.Errorf("Version() after %s = %d, want more than %d", m.name, v, last)
+		}
+		last = v
+
+		for _, r := range reads {
+			r.fn()
+			if v := q.Version(); v != last {
+				t.Fatalf("Version() after %s = %d, want unchanged %d", r.name, v, last)
+			}
+		}
+	}
+
+	// Failed and no-op mutations leave the version alone
+	_, _ = q.EnqueueSlice([]int{1, 2, 3, 4})
+	last = q.Version()
+	_ = q.Enqueue(5)
+	_ = q.Swap(0, 9)
+	_, _ = q.Remove(9)
+	q.Filter(func(int) bool { return true })
+	_, _ = NewView(q, func(int) bool { return false }).Dequeue()
+	if v := q.Version(); v != last {
+		t.Errorf("Version() after failed mutations = %d, want unchanged %d", v, last)
+	}
+}
+
+func TestSnapshotVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func() Queue[int]
+	}{
+		{name: "fifo", newFn: func() Queue[int] { return New[int]() }},
+		{name: "sharded", newFn: func() Queue[int] { return NewSharded[int](3) }},
+		{name: "expiring", newFn: func() Queue[int] { return NewExpiring[int]() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.newFn()
+			_, _ = q.EnqueueSlice([]int{1, 2, 3, 4})
+
+			items, version := q.Snapshot()
+			if want := slices.Collect(q.All()); !slices.Equal(items, want) {
+				t.Errorf("Snapshot() items = %v, want %v", items, want)
+			}
+			if v := q.Version(); v != version {
+				t.Errorf("Version() after Snapshot() = %d, want %d", v, version)
+			}
+
+			// A writer in between is detected by re-reading the version
+			_ = q.Enqueue(5)
+			if v := q.Version(); v <= version {
+				t.Errorf("Version() after Enqueue() = %d, want more than %d", v, version)
+			}
+			_ = q.Swap(0, 4)
+			if _, v := q.Snapshot(); v <= version+1 {
+				t.Errorf("Snapshot() version after Swap() = %d, want more than %d", v, version+1)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		q := New[int]()
+		if got, want := q.String(), "Queue[len=0/cap=unlimited]: []"; got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		q := New[int](WithCapacity[int](10))
+		_ = q.Enqueue(1)
+		_ = q.Enqueue(2)
+		_ = q.Enqueue(3)
+
+		if got, want := q.String(), "Queue[len=3/cap=10]: [1 2 3]"; got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("named", func(t *testing.T) {
+		q := New(WithCapacity[int](10), WithName[int]("jobs"))
+		_ = q.Enqueue(1)
+
+		if got, want := q.String(), "Queue(jobs)[len=1/cap=10]: [1]"; got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		q := New[int]()
+		for i := 0; i < maxStringItems+5; i++ {
 			_ = q.Enqueue(i)
-		case 1:
-			_, _ = q.Dequeue()
-		case 2:
-			_, _ = q.Peek()
-		case 3:
-			q.Size()
 		}
+
+		got := q.String()
+		if !strings.HasPrefix(got, "Queue[len=105/cap=unlimited]: [0 1 2 ") {
+			t.Errorf("String() prefix = %q", got[:40])
+		}
+		if !strings.HasSuffix(got, " 98 99 ...+5 more]") {
+			t.Errorf("String() suffix = %q", got[len(got)-30:])
+		}
+		if strings.Contains(got, " 100 ") {
+			t.Errorf("String() contains items beyond the truncation limit: %q", got)
+		}
+	})
+
+	t.Run("implements fmt.Stringer", func(t *testing.T) {
+		var s fmt.Stringer = New[string]()
+		if got, want := fmt.Sprint(s), "Queue[len=0/cap=unlimited]: []"; got != want {
+			t.Errorf("fmt.Sprint() = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestCapacityPreallocation(t *testing.T) {
+	q := newQueue[int](WithCapacity[int](8))
+	if c := q.items.cap(); c != 8 {
+		t.Errorf("cap(items) with capacity 8 = %d, want 8", c)
+	}
+
+	// Filling to capacity must not reallocate
+	for i := 0; i < 8; i++ {
+		_ = q.Enqueue(i)
+	}
+	if c := q.items.cap(); c != 8 {
+		t.Errorf("cap(items) after filling = %d, want 8", c)
+	}
+
+	u := newQueue[int]()
+	if c := u.items.cap(); c != 0 {
+		t.Errorf("cap(items) with unlimited capacity = %d, want 0", c)
+	}
+}
+
+func TestInitialCapacity(t *testing.T) {
+	t.Run("unlimited queue", func(t *testing.T) {
+		q := newQueue[int](WithInitialCapacity[int](100))
+		if c := q.items.cap(); c != 100 {
+			t.Errorf("cap(items) = %d, want 100", c)
+		}
+
+		// Preallocation imposes no limit
+		for i := 0; i < 150; i++ {
+			if err := q.Enqueue(i); err != nil {
+				t.Fatalf("Enqueue(%d) error = %v, want nil", i, err)
+			}
+		}
+	})
+
+	t.Run("no reallocation within initial capacity", func(t *testing.T) {
+		q := newQueue[int](WithInitialCapacity[int](64))
+		for i := 0; i < 64; i++ {
+			_ = q.Enqueue(i)
+		}
+		if c := q.items.cap(); c != 64 {
+			t.Errorf("cap(items) after 64 enqueues = %d, want 64", c)
+		}
+	})
+
+	t.Run("clamped to capacity", func(t *testing.T) {
+		q := newQueue[int](WithCapacity[int](10), WithInitialCapacity[int](500))
+		if c := q.items.cap(); c != 10 {
+			t.Errorf("cap(items) = %d, want 10", c)
+		}
+
+		// Order of options does not matter
+		q = newQueue[int](WithInitialCapacity[int](500), WithCapacity[int](10))
+		if c := q.items.cap(); c != 10 {
+			t.Errorf("cap(items) with reversed options = %d, want 10", c)
+		}
+	})
+
+	t.Run("smaller than capacity", func(t *testing.T) {
+		q := newQueue[int](WithCapacity[int](100), WithInitialCapacity[int](5))
+		if c := q.items.cap(); c != 5 {
+			t.Errorf("cap(items) = %d, want 5", c)
+		}
+	})
+
+	t.Run("negative (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("WithInitialCapacity(-1) should panic, but it didn't")
+			}
+		}()
+
+		New[int](WithInitialCapacity[int](-1))
+	})
+}
+
+func TestGrowthFactor(t *testing.T) {
+	const burst = 100_000
+	allocs := func(factor float64) float64 {
+		return testing.AllocsPerRun(5, func() {
+			q := New[int](WithGrowthFactor[int](factor))
+			for i := range burst {
+				_ = q.Enqueue(i)
+			}
+		})
+	}
+
+	// A small factor reallocates far more often during the burst
+	small, large := allocs(1.1), allocs(4)
+	if small <= large {
+		t.Errorf("allocations with factor 1.1 = %v, want more than with factor 4 (%v)", small, large)
+	}
+
+	t.Run("clamped to capacity", func(t *testing.T) {
+		q := newQueue[int](WithCapacity[int](20), WithGrowthFactor[int](10))
+		for i := range 20 {
+			if err := q.Enqueue(i); err != nil {
+				t.Fatalf("Enqueue(%d) error = %v, want nil", i, err)
+			}
+		}
+		if c := q.items.cap(); c != 20 {
+			t.Errorf("cap(items) = %d, want 20", c)
+		}
+	})
+
+	t.Run("grows by at least one", func(t *testing.T) {
+		q := newQueue[int](WithInitialCapacity[int](10), WithGrowthFactor[int](1.01))
+		for i := range 11 {
+			_ = q.Enqueue(i)
+		}
+		if c := q.items.cap(); c != 11 {
+			t.Errorf("cap(items) after growing = %d, want 11", c)
+		}
+	})
+
+	for _, factor := range []float64{1, 0.5, -2, math.NaN(), math.Inf(1)} {
+		t.Run(fmt.Sprintf("%v (should panic)", factor), func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("WithGrowthFactor(%v) should panic, but it didn't", factor)
+				}
+			}()
+
+			New[int](WithGrowthFactor[int](factor))
+		})
+	}
+}
+
+func TestTryEnqueueDequeue(t *testing.T) {
+	q := New[int](WithCapacity[int](2))
+
+	if !q.TryEnqueue(1) {
+		t.Error("TryEnqueue(1) = false, want true")
+	}
+	if !q.TryEnqueue(2) {
+		t.Error("TryEnqueue(2) = false, want true")
+	}
+
+	// Full queue reports false instead of ErrOverflow
+	if q.TryEnqueue(3) {
+		t.Error("TryEnqueue(3) exceeding capacity = true, want false")
+	}
+	if size := q.Size(); size != 2 {
+		t.Errorf("Size after failed TryEnqueue = %d, want 2", size)
+	}
+
+	for _, expected := range []int{1, 2} {
+		val, ok := q.TryDequeue()
+		if !ok {
+			t.Errorf("TryDequeue() ok = false, want true")
+		}
+		if val != expected {
+			t.Errorf("TryDequeue() = %d, want %d", val, expected)
+		}
+	}
+
+	// Empty queue reports false instead of ErrUnderflow
+	val, ok := q.TryDequeue()
+	if ok {
+		t.Error("TryDequeue() on empty queue ok = true, want false")
+	}
+	if val != 0 {
+		t.Errorf("TryDequeue() on empty queue value = %d, want 0 (zero value)", val)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func() Queue[int]
+	}{
+		{name: "fifo", newFn: func() Queue[int] { return New[int]() }},
+		{name: "priority", newFn: func() Queue[int] { return NewPriority(intLess) }},
+		{name: "sharded", newFn: func() Queue[int] { return NewSharded[int](3) }},
+		{name: "expiring", newFn: func() Queue[int] { return NewExpiring[int]() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polled, dequeued := tt.newFn(), tt.newFn()
+			vals := []int{5, 3, 8, 1, 9, 2}
+			_, _ = polled.EnqueueSlice(vals)
+			_, _ = dequeued.EnqueueSlice(vals)
+
+			var got []int
+			for v, ok := polled.Poll(); ok; v, ok = polled.Poll() {
+				got = append(got, v)
+			}
+			var want []int
+			for v, err := dequeued.Dequeue(); err == nil; v, err = dequeued.Dequeue() {
+				want = append(want, v)
+			}
+
+			if !slices.Equal(got, want) {
+				t.Errorf("Poll() loop = %v, want Dequeue order %v", got, want)
+			}
+			if size := polled.Size(); size != 0 {
+				t.Errorf("Size after Poll() loop = %d, want 0", size)
+			}
+			if v, ok := polled.Poll(); ok || v != 0 {
+				t.Errorf("Poll() on empty queue = (%d, %t), want (0, false)", v, ok)
+			}
+		})
+	}
+}
+
+func TestOffer(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func() Queue[int]
+	}{
+		{name: "fifo", newFn: func() Queue[int] { return New(WithCapacity[int](2)) }},
+		{name: "blocking", newFn: func() Queue[int] { return NewBlocking[int](2) }},
+		{name: "sharded", newFn: func() Queue[int] { return NewSharded(2, WithCapacity[int](2)) }},
+		{name: "expiring", newFn: func() Queue[int] { return NewExpiring(WithCapacity[int](2)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.newFn()
+			if !q.Offer(1) || !q.Offer(2) {
+				t.Fatal("Offer() within capacity = false, want true")
+			}
+
+			// Where a non-blocking enqueue fails with ErrOverflow, Offer returns false
+			if err := q.EnqueueTimeout(3, 0); !errors.Is(err, ErrOverflow) {
+				t.Errorf("EnqueueTimeout(0) into full queue = %v, want %v", err, ErrOverflow)
+			}
+			if q.Offer(3) {
+				t.Error("Offer() into full queue = true, want false")
+			}
+			if size := q.Size(); size != 2 {
+				t.Errorf("Size after failed Offer = %d, want 2", size)
+			}
+
+			_ = q.Close()
+			_, _ = q.Dequeue()
+			if q.Offer(4) {
+				t.Error("Offer() into closed queue = true, want false")
+			}
+		})
+	}
+}
+
+func TestTryOperationsZeroCapacity(t *testing.T) {
+	q := New[int](WithCapacity[int](0))
+	if q.TryEnqueue(1) {
+		t.Error("TryEnqueue() with zero capacity = true, want false")
+	}
+	if s := q.Stats(); s.Rejected != 1 {
+		t.Errorf("Stats().Rejected = %d, want 1", s.Rejected)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		drop    []int
+		want    []int
+		removed int
+	}{
+		{name: "front", drop: []int{1}, want: []int{2, 3, 4, 5}, removed: 1},
+		{name: "middle", drop: []int{2, 3, 4}, want: []int{1, 5}, removed: 3},
+		{name: "back", drop: []int{5}, want: []int{1, 2, 3, 4}, removed: 1},
+		{name: "scattered", drop: []int{1, 3, 5}, want: []int{2, 4}, removed: 3},
+		{name: "none", drop: nil, want: []int{1, 2, 3, 4, 5}, removed: 0},
+		{name: "all", drop: []int{1, 2, 3, 4, 5}, want: nil, removed: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newQueue[int]()
+			for i := 1; i <= 5; i++ {
+				_ = q.Enqueue(i)
+			}
+
+			removed := q.Filter(func(v int) bool {
+				for _, d := range tt.drop {
+					if v == d {
+						return false
+					}
+				}
+				return true
+			})
+			if removed != tt.removed {
+				t.Errorf("Filter() removed = %d, want %d", removed, tt.removed)
+			}
+
+			if size := q.Size(); size != len(tt.want) {
+				t.Fatalf("Size after Filter() = %d, want %d", size, len(tt.want))
+			}
+			for i, expected := range tt.want {
+				val, _ := q.Dequeue()
+				if val != expected {
+					t.Errorf("Dequeue() at position %d = %d, want %d", i, val, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterCtx(t *testing.T) {
+	const n = 1000
+	for name, q := range map[string]Queue[int]{
+		"queue":   New[int](),
+		"sharded": NewSharded[int](4),
+		"spsc":    NewSPSC[int](n),
+	} {
+		t.Run(name, func(t *testing.T) {
+			for i := range n {
+				_ = q.Enqueue(i)
+			}
+			before, version := q.Snapshot()
+
+			// keep would drop every item, but ctx is cancelled partway through
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			seen := 0
+			removed, err := q.FilterCtx(ctx, func(int) bool {
+				if seen++; seen == n/4 {
+					cancel()
+				}
+				return false
+			})
+			if removed != 0 || !errors.Is(err, context.Canceled) {
+				t.Errorf("FilterCtx() cancelled = (%d, %v), want (0, %v)", removed, err, context.Canceled)
+			}
+			if seen >= n {
+				t.Errorf("keep called %d times after cancel, want fewer than %d", seen, n)
+			}
+			if after, v := q.Snapshot(); v != version || !slices.Equal(after, before) {
+				t.Errorf("Queue changed by cancelled FilterCtx(): version %d -> %d", version, v)
+			}
+
+			removed, err = q.FilterCtx(context.Background(), func(v int) bool { return v%2 == 0 })
+			if removed != n/2 || err != nil {
+				t.Errorf("FilterCtx() = (%d, %v), want (%d, nil)", removed, err, n/2)
+			}
+			if size := q.Size(); size != n/2 {
+				t.Errorf("Size after FilterCtx() = %d, want %d", size, n/2)
+			}
+			if err := CheckInvariants(q); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestFilterZeroesFreedSlots(t *testing.T) {
+	q := newQueue[*int]()
+	for i := 0; i < 4; i++ {
+		v := i
+		_ = q.Enqueue(&v)
+	}
+
+	q.Filter(func(p *int) bool { return *p%2 == 0 })
+
+	// Slots beyond the logical length must not retain pointers
+	for i := q.items.len(); i < q.items.cap(); i++ {
+		if p := q.items.at(i); p != nil {
+			t.Errorf("slot %d beyond length = %v, want nil", i, p)
+		}
+	}
+}
+
+func TestForEach(t *testing.T) {
+	q := New[int]()
+	for i := 1; i <= 5; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	sum := 0
+	q.ForEach(func(v int) bool {
+		sum += v
+		return true
+	})
+	if sum != 15 {
+		t.Errorf("ForEach() sum = %d, want 15", sum)
+	}
+
+	// Queue is left untouched
+	if size := q.Size(); size != 5 {
+		t.Errorf("Size after ForEach() = %d, want 5", size)
+	}
+}
+
+func TestForEachCtx(t *testing.T) {
+	q := New[int]()
+	for i := range 1000 {
+		_ = q.Enqueue(i)
+	}
+
+	visited := 0
+	err := q.ForEachCtx(context.Background(), func(int) bool {
+		visited++
+		return true
+	})
+	if visited != 1000 || err != nil {
+		t.Errorf("ForEachCtx() visited %d items and returned %v, want 1000 and nil", visited, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	visited = 0
+	err = q.ForEachCtx(ctx, func(int) bool {
+		if visited++; visited == 100 {
+			cancel()
+		}
+		return true
+	})
+	if !errors.Is(err, context.Canceled) || visited >= 1000 {
+		t.Errorf("ForEachCtx() cancelled visited %d items and returned %v, want fewer than 1000 and %v", visited, err, context.Canceled)
+	}
+
+	// The read lock must be released after cancellation
+	if err := q.Enqueue(1000); err != nil {
+		t.Errorf("Enqueue() after ForEachCtx() error = %v, want nil", err)
+	}
+}
+
+func TestForEachEarlyReturn(t *testing.T) {
+	q := New[int]()
+	for i := 1; i <= 5; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	var visited []int
+	q.ForEach(func(v int) bool {
+		visited = append(visited, v)
+		return v < 2
+	})
+
+	if len(visited) != 2 || visited[0] != 1 || visited[1] != 2 {
+		t.Errorf("ForEach() visited = %v, want [1 2]", visited)
+	}
+
+	// The read lock must be released after an early return
+	if err := q.Enqueue(6); err != nil {
+		t.Errorf("Enqueue() after ForEach() error = %v, want nil", err)
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	t.Run("zero capacity", func(t *testing.T) {
+		q := New[int](WithCapacity[int](0))
+		if r := q.Remaining(); r != 0 {
+			t.Errorf("Remaining() = %d, want 0", r)
+		}
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		q := New[int](WithCapacity[int](5))
+		_ = q.Enqueue(1)
+		_ = q.Enqueue(2)
+		if r := q.Remaining(); r != 3 {
+			t.Errorf("Remaining() = %d, want 3", r)
+		}
+
+		_, _ = q.Dequeue()
+		if r := q.Remaining(); r != 4 {
+			t.Errorf("Remaining() after dequeue = %d, want 4", r)
+		}
+	})
+
+	t.Run("full", func(t *testing.T) {
+		q := New[int](WithCapacity[int](2))
+		_ = q.Enqueue(1)
+		_ = q.Enqueue(2)
+		if r := q.Remaining(); r != 0 {
+			t.Errorf("Remaining() = %d, want 0", r)
+		}
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		q := New[int]()
+		_ = q.Enqueue(1)
+		if r := q.Remaining(); r != UnlimitedCapacity {
+			t.Errorf("Remaining() = %d, want UnlimitedCapacity", r)
+		}
+	})
+}
+
+func TestSetCapacity(t *testing.T) {
+	t.Run("grow", func(t *testing.T) {
+		q := New[int](WithCapacity[int](2))
+		_ = q.Enqueue(1)
+		_ = q.Enqueue(2)
+
+		if err := q.SetCapacity(4); err != nil {
+			t.Fatalf("SetCapacity(4) error = %v, want nil", err)
+		}
+		for i := 3; i <= 4; i++ {
+			if err := q.Enqueue(i); err != nil {
+				t.Errorf("Enqueue(%d) after grow error = %v, want nil", i, err)
+			}
+		}
+		if err := q.Enqueue(5); !errors.Is(err, ErrOverflow) {
+			t.Errorf("Enqueue(5) exceeding new capacity error = %v, want ErrOverflow", err)
+		}
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		q := New[int](WithCapacity[int](1))
+		_ = q.Enqueue(1)
+
+		if err := q.SetCapacity(UnlimitedCapacity); err != nil {
+			t.Fatalf("SetCapacity(UnlimitedCapacity) error = %v, want nil", err)
+		}
+		for i := 0; i < 100; i++ {
+			if err := q.Enqueue(i); err != nil {
+				t.Fatalf("Enqueue(%d) after unlimiting error = %v, want nil", i, err)
+			}
+		}
+		if r := q.Remaining(); r != UnlimitedCapacity {
+			t.Errorf("Remaining() = %d, want UnlimitedCapacity", r)
+		}
+	})
+
+	t.Run("shrink reject", func(t *testing.T) {
+		q := New[int]()
+		for i := 1; i <= 3; i++ {
+			_ = q.Enqueue(i)
+		}
+
+		if err := q.SetCapacity(2); !errors.Is(err, ErrCapacityTooSmall) {
+			t.Errorf("SetCapacity(2) error = %v, want ErrCapacityTooSmall", err)
+		}
+		if size := q.Size(); size != 3 {
+			t.Errorf("Size after rejected shrink = %d, want 3", size)
+		}
+		if r := q.Remaining(); r != UnlimitedCapacity {
+			t.Errorf("Remaining() after rejected shrink = %d, want UnlimitedCapacity", r)
+		}
+
+		// Shrinking to exactly Size is allowed
+		if err := q.SetCapacity(3); err != nil {
+			t.Errorf("SetCapacity(3) error = %v, want nil", err)
+		}
+		if err := q.Enqueue(4); !errors.Is(err, ErrOverflow) {
+			t.Errorf("Enqueue(4) after shrink error = %v, want ErrOverflow", err)
+		}
+	})
+
+	t.Run("shrink drop oldest", func(t *testing.T) {
+		q := New[int](WithShrinkPolicy[int](ShrinkDropOldest))
+		for i := 1; i <= 5; i++ {
+			_ = q.Enqueue(i)
+		}
+
+		if err := q.SetCapacity(2); err != nil {
+			t.Fatalf("SetCapacity(2) error = %v, want nil", err)
+		}
+		if size := q.Size(); size != 2 {
+			t.Fatalf("Size after shrink = %d, want 2", size)
+		}
+		for _, expected := range []int{4, 5} {
+			val, _ := q.Dequeue()
+			if val != expected {
+				t.Errorf("Dequeue() after shrink = %d, want %d", val, expected)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		q := New[int](WithCapacity[int](3))
+		if err := q.SetCapacity(-5); !errors.Is(err, ErrInvalidCapacity) {
+			t.Errorf("SetCapacity(-5) error = %v, want ErrInvalidCapacity", err)
+		}
+		if r := q.Remaining(); r != 3 {
+			t.Errorf("Remaining() after invalid SetCapacity = %d, want 3", r)
+		}
+	})
+}
+
+func TestClose(t *testing.T) {
+	q := New[int]()
+	_ = q.Enqueue(1)
+
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v, want nil", err)
+	}
+	if err := q.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close() error = %v, want ErrClosed", err)
+	}
+
+	// No new items are accepted
+	if err := q.Enqueue(2); !errors.Is(err, ErrClosed) {
+		t.Errorf("Enqueue() after Close() error = %v, want ErrClosed", err)
+	}
+	if q.TryEnqueue(2) {
+		t.Error("TryEnqueue() after Close() = true, want false")
+	}
+
+	// Items queued before Close() can still be dequeued
+	val, err := q.Dequeue()
+	if err != nil || val != 1 {
+		t.Errorf("Dequeue() after Close() = %d, %v, want 1, nil", val, err)
+	}
+	if _, err := q.Dequeue(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("Dequeue() on closed empty queue error = %v, want ErrUnderflow", err)
+	}
+}
+
+func TestCloseAndDrain(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func() Queue[int]
+	}{
+		{name: "fifo", newFn: func() Queue[int] { return New(WithCapacity[int](3)) }},
+		{name: "sharded", newFn: func() Queue[int] { return NewSharded(2, WithCapacity[int](3)) }},
+		{name: "expiring", newFn: func() Queue[int] { return NewExpiring(WithCapacity[int](3)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.newFn()
+			_, _ = q.EnqueueSlice([]int{1, 2, 3})
+			want := slices.Collect(q.All())
+
+			// A producer blocked on the full queue is released by the close
+			producer := make(chan error, 1)
+			go func() { producer <- q.EnqueueWait(context.Background(), 4) }()
+
+			var handled []int
+			got := q.CloseAndDrain(func(v int) { handled = append(handled, v) })
+			if !slices.Equal(got, want) {
+				t.Errorf("CloseAndDrain() = %v, want %v", got, want)
+			}
+			if !slices.Equal(handled, want) {
+				t.Errorf("CloseAndDrain() handed %v to fn, want %v", handled, want)
+			}
+			if err := <-producer; !errors.Is(err, ErrClosed) {
+				t.Errorf("blocked EnqueueWait() = %v, want %v", err, ErrClosed)
+			}
+			if _, err := q.DequeueWait(context.Background()); !errors.Is(err, ErrClosed) {
+				t.Errorf("DequeueWait() after CloseAndDrain() = %v, want %v", err, ErrClosed)
+			}
+			if err := q.Close(); !errors.Is(err, ErrClosed) {
+				t.Errorf("Close() after CloseAndDrain() = %v, want %v", err, ErrClosed)
+			}
+
+			// Draining an already closed queue returns nothing new
+			if got := q.CloseAndDrain(nil); len(got) != 0 {
+				t.Errorf("second CloseAndDrain() = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestCloseAndDrainFIFO(t *testing.T) {
+	var dequeued []int
+	q := New(WithOnDequeue(func(v int) { dequeued = append(dequeued, v) }))
+	for i := 1; i <= 5; i++ {
+		_ = q.Enqueue(i)
+	}
+	_, _ = q.Dequeue()
+
+	var handled []int
+	got := q.CloseAndDrain(func(v int) { handled = append(handled, v) })
+	want := []int{2, 3, 4, 5}
+	if !slices.Equal(got, want) || !slices.Equal(handled, want) {
+		t.Errorf("CloseAndDrain() = %v and handed %v to fn, want %v for both", got, handled, want)
+	}
+	if !slices.Equal(dequeued, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("dequeue hook saw %v, want [1 2 3 4 5]", dequeued)
+	}
+	if s := q.Stats(); s.Dequeued != 5 || s.CurrentSize != 0 {
+		t.Errorf("Stats() = %+v, want Dequeued=5 CurrentSize=0", s)
+	}
+}
+
+func TestCircular(t *testing.T) {
+	t.Run("exact capacity", func(t *testing.T) {
+		q := New[int](WithCapacity[int](3), WithCircular[int]())
+		for i := 1; i <= 3; i++ {
+			_ = q.Enqueue(i)
+		}
+
+		// Filling to exactly capacity overwrites nothing
+		if val, _ := q.Peek(); val != 1 {
+			t.Errorf("Peek() at capacity = %d, want 1", val)
+		}
+		if size := q.Size(); size != 3 {
+			t.Errorf("Size at capacity = %d, want 3", size)
+		}
+	})
+
+	t.Run("overwrites oldest", func(t *testing.T) {
+		q := New[int](WithCapacity[int](3), WithCircular[int]())
+		for i := 1; i <= 4; i++ {
+			if err := q.Enqueue(i); err != nil {
+				t.Fatalf("Enqueue(%d) error = %v, want nil", i, err)
+			}
+		}
+
+		if val, _ := q.Peek(); val != 2 {
+			t.Errorf("Peek() after one overwrite = %d, want 2", val)
+		}
+		if size := q.Size(); size != 3 {
+			t.Errorf("Size after overwrite = %d, want 3", size)
+		}
+	})
+
+	t.Run("wraparound", func(t *testing.T) {
+		q := newQueue[int](WithCapacity[int](4), WithCircular[int]())
+		for i := 1; i <= 11; i++ {
+			_ = q.Enqueue(i)
+		}
+
+		// Storage never grows past the capacity
+		if c := q.items.cap(); c != 4 {
+			t.Errorf("cap(items) = %d, want 4", c)
+		}
+		for _, expected := range []int{8, 9, 10, 11} {
+			if val, _ := q.Dequeue(); val != expected {
+				t.Errorf("Dequeue() = %d, want %d", val, expected)
+			}
+		}
+		if s := q.Stats(); s.Rejected != 0 {
+			t.Errorf("Stats().Rejected = %d, want 0", s.Rejected)
+		}
+	})
+
+	t.Run("enqueue wait does not block", func(t *testing.T) {
+		q := New[int](WithCapacity[int](1), WithCircular[int]())
+		_ = q.Enqueue(1)
+
+		if err := q.EnqueueWait(context.Background(), 2); err != nil {
+			t.Errorf("EnqueueWait() on full circular queue error = %v, want nil", err)
+		}
+		if val, _ := q.Peek(); val != 2 {
+			t.Errorf("Peek() = %d, want 2", val)
+		}
+	})
+
+	t.Run("set capacity", func(t *testing.T) {
+		q := New[int](WithCapacity[int](2), WithCircular[int]())
+		if err := q.SetCapacity(UnlimitedCapacity); !errors.Is(err, ErrInvalidCapacity) {
+			t.Errorf("SetCapacity(UnlimitedCapacity) error = %v, want ErrInvalidCapacity", err)
+		}
+		if err := q.SetCapacity(0); !errors.Is(err, ErrInvalidCapacity) {
+			t.Errorf("SetCapacity(0) error = %v, want ErrInvalidCapacity", err)
+		}
+		if err := q.SetCapacity(5); err != nil {
+			t.Errorf("SetCapacity(5) error = %v, want nil", err)
+		}
+	})
+
+	t.Run("unlimited capacity (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("WithCircular() without capacity should panic, but it didn't")
+			}
+		}()
+
+		New[int](WithCircular[int]())
+	})
+}
+
+func TestLIFO(t *testing.T) {
+	t.Run("most recent first", func(t *testing.T) {
+		q := New[int](WithLIFO[int]())
+		for i := 1; i <= 4; i++ {
+			_ = q.Enqueue(i)
+		}
+
+		if val, _ := q.Peek(); val != 4 {
+			t.Errorf("Peek() = %d, want 4", val)
+		}
+		if got, want := slices.Collect(q.All()), []int{4, 3, 2, 1}; !slices.Equal(got, want) {
+			t.Errorf("All() = %v, want %v", got, want)
+		}
+		for _, expected := range []int{4, 3} {
+			if val, err := q.Dequeue(); val != expected || err != nil {
+				t.Errorf("Dequeue() = (%d, %v), want (%d, nil)", val, err, expected)
+			}
+		}
+		_ = q.Enqueue(5)
+		if val, _ := q.Dequeue(); val != 5 {
+			t.Errorf("Dequeue() after Enqueue(5) = %d, want 5", val)
+		}
+		if err := CheckInvariants(q); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("capacity", func(t *testing.T) {
+		q := New[int](WithCapacity[int](3), WithLIFO[int]())
+		n, err := q.EnqueueSlice([]int{1, 2, 3, 4})
+		if n != 3 || !errors.Is(err, ErrOverflow) {
+			t.Errorf("EnqueueSlice(1..4) = (%d, %v), want (3, %v)", n, err, ErrOverflow)
+		}
+		if err := q.Enqueue(5); !errors.Is(err, ErrOverflow) {
+			t.Errorf("Enqueue() on full stack = %v, want %v", err, ErrOverflow)
+		}
+		if val, _ := q.Peek(); val != 3 {
+			t.Errorf("Peek() on full stack = %d, want 3", val)
+		}
+		if s := q.Stats(); s.Rejected != 2 {
+			t.Errorf("Stats().Rejected = %d, want 2", s.Rejected)
+		}
+	})
+
+	t.Run("split keeps order", func(t *testing.T) {
+		q := New[int](WithLIFO[int]())
+		_, _ = q.EnqueueSlice([]int{1, 2, 3})
+		head, _ := q.Split(2)
+		if got, want := slices.Collect(head.All()), []int{3, 2}; !slices.Equal(got, want) {
+			t.Errorf("Split(2) head = %v, want %v", got, want)
+		}
+		_ = head.Enqueue(4)
+		if val, _ := head.Peek(); val != 4 {
+			t.Errorf("Peek() on split head = %d, want 4", val)
+		}
+	})
+
+	t.Run("circular (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("WithLIFO() with WithCircular() should panic, but it didn't")
+			}
+		}()
+
+		New[int](WithCapacity[int](2), WithCircular[int](), WithLIFO[int]())
+	})
+}
+
+func TestReverse(t *testing.T) {
+	q := newQueue[int](WithCapacity[int](5))
+
+	// Offset the head so the items wrap around the end of the ring
+	for i := 0; i < 3; i++ {
+		_ = q.Enqueue(0)
+	}
+	for i := 0; i < 3; i++ {
+		_, _ = q.Dequeue()
+	}
+	for i := 1; i <= 5; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	q.Reverse()
+
+	if size := q.Size(); size != 5 {
+		t.Errorf("Size after Reverse() = %d, want 5", size)
+	}
+	if r := q.Remaining(); r != 0 {
+		t.Errorf("Remaining() after Reverse() = %d, want 0", r)
+	}
+	for _, expected := range []int{5, 4, 3, 2, 1} {
+		if val, _ := q.Dequeue(); val != expected {
+			t.Errorf("Dequeue() after Reverse() = %d, want %d", val, expected)
+		}
+	}
+}
+
+func TestReverseSmall(t *testing.T) {
+	q := New[int]()
+	q.Reverse() // Empty queue is a no-op
+
+	_ = q.Enqueue(1)
+	q.Reverse()
+	if val, _ := q.Peek(); val != 1 {
+		t.Errorf("Peek() after Reverse() of single item = %d, want 1", val)
+	}
+
+	_ = q.Enqueue(2)
+	q.Reverse()
+	if val, _ := q.Peek(); val != 2 {
+		t.Errorf("Peek() after Reverse() of two items = %d, want 2", val)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want []int
+	}{
+		{name: "zero", n: 0, want: []int{1, 2, 3, 4, 5}},
+		{name: "one", n: 1, want: []int{2, 3, 4, 5, 1}},
+		{name: "most", n: 4, want: []int{5, 1, 2, 3, 4}},
+		{name: "size", n: 5, want: []int{1, 2, 3, 4, 5}},
+		{name: "larger than size", n: 12, want: []int{3, 4, 5, 1, 2}},
+		{name: "negative", n: -1, want: []int{5, 1, 2, 3, 4}},
+		{name: "negative larger than size", n: -7, want: []int{4, 5, 1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		for _, capacity := range []int{5, 8} {
+			t.Run(fmt.Sprintf("%s/cap=%d", tt.name, capacity), func(t *testing.T) {
+				// Offset the head so the items wrap around the end of the ring
+				q := newQueue[int](WithCapacity[int](capacity))
+				_ = q.Enqueue(0)
+				_ = q.Enqueue(0)
+				_, _ = q.Dequeue()
+				_, _ = q.Dequeue()
+				for i := 1; i <= 5; i++ {
+					_ = q.Enqueue(i)
+				}
+
+				q.Rotate(tt.n)
+
+				if size := q.Size(); size != 5 {
+					t.Fatalf("Size after Rotate(%d) = %d, want 5", tt.n, size)
+				}
+				for i, expected := range tt.want {
+					if val, _ := q.Dequeue(); val != expected {
+						t.Errorf("Dequeue() at position %d after Rotate(%d) = %d, want %d", i, tt.n, val, expected)
+					}
+				}
+			})
+		}
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		q := New[int]()
+		q.Rotate(3)
+		if size := q.Size(); size != 0 {
+			t.Errorf("Size after Rotate() of empty queue = %d, want 0", size)
+		}
+	})
+}
+
+func TestRotateZeroesVacatedSlots(t *testing.T) {
+	q := newQueue[int](WithCapacity[int](6))
+	var model []int
+	for i := 1; i <= 4; i++ {
+		_ = q.Enqueue(i)
+		model = append(model, i)
+	}
+
+	for step, n := range []int{1, 3, -2, 2, -1} {
+		q.Rotate(n)
+		k := ((n % len(model)) + len(model)) % len(model)
+		model = append(model[k:], model[:k]...)
+		checkRingState(t, step, &q.items, model)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	shuffled := func(seed uint64) []int {
+		// Start the items partway into the ring so it wraps around
+		q := newQueue[int](WithCapacity[int](8))
+		_, _ = q.EnqueueSlice([]int{-2, -1})
+		_, _ = q.Dequeue()
+		_, _ = q.Dequeue()
+		_, _ = q.EnqueueSlice([]int{0, 1, 2, 3, 4, 5, 6, 7})
+
+		q.Shuffle(rand.New(rand.NewPCG(seed, 0)))
+		if s := q.Stats(); q.Size() != 8 || s.Enqueued != 10 || s.Dequeued != 2 {
+			t.Errorf("Size, Stats after Shuffle() = %d, %+v, want 8 and unchanged counters", q.Size(), s)
+		}
+		return slices.Collect(q.All())
+	}
+
+	// The order is that of rand.Shuffle over the same items and seed
+	want := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	rand.New(rand.NewPCG(42, 0)).Shuffle(len(want), func(i, j int) {
+		want[i], want[j] = want[j], want[i]
+	})
+	if got := shuffled(42); !slices.Equal(got, want) {
+		t.Errorf("Shuffle() with seed 42 = %v, want %v", got, want)
+	}
+	if got := shuffled(42); !slices.Equal(got, want) {
+		t.Errorf("second Shuffle() with seed 42 = %v, want %v", got, want)
+	}
+	if slices.IsSorted(want) {
+		t.Errorf("Shuffle() with seed 42 left the items in order")
+	}
+
+	t.Run("priority", func(t *testing.T) {
+		q := NewPriority(intLess)
+		_, _ = q.EnqueueSlice([]int{5, 3, 1, 4, 2})
+		q.Shuffle(nil)
+		if got := q.Drain(); !slices.Equal(got, []int{1, 2, 3, 4, 5}) {
+			t.Errorf("Drain() after Shuffle() = %v, want [1 2 3 4 5]", got)
+		}
+	})
+}
+
+func TestEnqueueSlice(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefill  int
+		vals     []int
+		inserted int
+		err      error
+	}{
+		{name: "whole batch fits", prefill: 0, vals: []int{1, 2, 3}, inserted: 3, err: nil},
+		{name: "partial fit", prefill: 2, vals: []int{1, 2, 3}, inserted: 2, err: ErrOverflow},
+		{name: "full queue", prefill: 4, vals: []int{1, 2, 3}, inserted: 0, err: ErrOverflow},
+		{name: "empty batch", prefill: 4, vals: nil, inserted: 0, err: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hooked []int
+			q := New[int](
+				WithCapacity[int](4),
+				WithOnEnqueue(func(v int) { hooked = append(hooked, v) }),
+			)
+			for i := 0; i < tt.prefill; i++ {
+				_ = q.Enqueue(-1)
+			}
+			hooked = nil
+
+			inserted, err := q.EnqueueSlice(tt.vals)
+			if inserted != tt.inserted || !errors.Is(err, tt.err) {
+				t.Fatalf("EnqueueSlice(%v) = (%d, %v), want (%d, %v)", tt.vals, inserted, err, tt.inserted, tt.err)
+			}
+			if size := q.Size(); size != tt.prefill+tt.inserted {
+				t.Errorf("Size after EnqueueSlice = %d, want %d", size, tt.prefill+tt.inserted)
+			}
+
+			want := tt.vals[:tt.inserted]
+			if fmt.Sprint(hooked) != fmt.Sprint(want) {
+				t.Errorf("enqueue hooks saw %v, want %v", hooked, want)
+			}
+			for range tt.prefill {
+				_, _ = q.Dequeue()
+			}
+			for _, expected := range want {
+				if val, _ := q.Dequeue(); val != expected {
+					t.Errorf("Dequeue() = %d, want %d", val, expected)
+				}
+			}
+		})
+	}
+
+	t.Run("closed", func(t *testing.T) {
+		q := New[int]()
+		_ = q.Close()
+		if inserted, err := q.EnqueueSlice([]int{1}); inserted != 0 || !errors.Is(err, ErrClosed) {
+			t.Errorf("EnqueueSlice() on closed queue = (%d, %v), want (0, %v)", inserted, err, ErrClosed)
+		}
+	})
+}
+
+func TestGrow(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		q := newQueue[int]()
+		_ = q.Enqueue(1)
+		_ = q.Enqueue(2)
+
+		if err := q.Grow(100); err != nil {
+			t.Fatalf("Grow(100) = %v, want nil", err)
+		}
+		if c := q.items.cap(); c < 102 {
+			t.Errorf("backing cap after Grow(100) = %d, want at least 102", c)
+		}
+
+		// Growing within the existing storage must not reallocate
+		buf := q.items.buf
+		_ = q.Grow(10)
+		if &q.items.buf[0] != &buf[0] {
+			t.Error("Grow() reallocated although the storage was large enough")
+		}
+
+		for _, expected := range []int{1, 2} {
+			if val, _ := q.Dequeue(); val != expected {
+				t.Errorf("Dequeue() after Grow = %d, want %d", val, expected)
+			}
+		}
+	})
+
+	t.Run("clamped to capacity", func(t *testing.T) {
+		q := newQueue[int](WithCapacity[int](10), WithInitialCapacity[int](2))
+		_ = q.Enqueue(1)
+
+		_ = q.Grow(100)
+		if c := q.items.cap(); c != 10 {
+			t.Errorf("backing cap after Grow(100) = %d, want 10", c)
+		}
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		q := New[int]()
+		if err := q.Grow(-1); !errors.Is(err, ErrInvalidCapacity) {
+			t.Errorf("Grow(-1) = %v, want %v", err, ErrInvalidCapacity)
+		}
+	})
+}
+
+func TestCompact(t *testing.T) {
+	q := newQueue[int]()
+	for i := 0; i < 1000; i++ {
+		_ = q.Enqueue(i)
+	}
+	for i := 0; i < 990; i++ {
+		_, _ = q.Dequeue()
+	}
+	before := q.items.cap()
+
+	q.Compact()
+
+	if c := q.items.cap(); c != 10 || c >= before {
+		t.Errorf("backing cap after Compact() = %d (was %d), want 10", c, before)
+	}
+	if q.items.head != 0 {
+		t.Errorf("head after Compact() = %d, want 0", q.items.head)
+	}
+	for i := 990; i < 1000; i++ {
+		if val, _ := q.Dequeue(); val != i {
+			t.Errorf("Dequeue() after Compact() = %d, want %d", val, i)
+		}
+	}
+
+	// A compacted empty queue still accepts items
+	q.Compact()
+	if err := q.Enqueue(1); err != nil {
+		t.Errorf("Enqueue() after compacting an empty queue = %v, want nil", err)
+	}
+}
+
+func TestAutoShrink(t *testing.T) {
+	t.Run("dequeues", func(t *testing.T) {
+		q := newQueue[int](WithAutoShrink[int](0.25))
+		for i := range 10_000 {
+			_ = q.Enqueue(i)
+		}
+		peak := q.items.cap()
+
+		for i := range 9_990 {
+			if val, _ := q.Dequeue(); val != i {
+				t.Fatalf("Dequeue() = %d, want %d", val, i)
+			}
+			if n, c := q.items.len(), q.items.cap(); c > minGrowSize && n < c/8 {
+				t.Fatalf("cap(items) = %d with %d items, want it to shrink below a quarter full", c, n)
+			}
+		}
+		if c := q.items.cap(); c >= peak/100 {
+			t.Errorf("cap(items) after a large drain = %d (peak %d), want it to shrink", c, peak)
+		}
+		for i := 9_990; i < 10_000; i++ {
+			if val, _ := q.Dequeue(); val != i {
+				t.Errorf("Dequeue() after shrinking = %d, want %d", val, i)
+			}
+		}
+		if err := CheckInvariants[int](q); err != nil {
+			t.Errorf("CheckInvariants() = %v", err)
+		}
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		q := newQueue[int](WithAutoShrink[int](0.5))
+		for i := range 1000 {
+			_ = q.Enqueue(i)
+		}
+		_ = q.Drain()
+		if c := q.items.cap(); c != minGrowSize {
+			t.Errorf("cap(items) after Drain() = %d, want %d", c, minGrowSize)
+		}
+	})
+
+	t.Run("off by default", func(t *testing.T) {
+		q := newQueue[int]()
+		for i := range 1000 {
+			_ = q.Enqueue(i)
+		}
+		before := q.items.cap()
+		_ = q.Drain()
+		if c := q.items.cap(); c != before {
+			t.Errorf("cap(items) after Drain() without WithAutoShrink = %d, want %d", c, before)
+		}
+	})
+
+	for _, ratio := range []float64{0, 1, -0.5, 2, math.NaN()} {
+		t.Run(fmt.Sprintf("%v (should panic)", ratio), func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("WithAutoShrink(%v) should panic, but it didn't", ratio)
+				}
+			}()
+
+			WithAutoShrink[int](ratio)
+		})
+	}
+}
+
+func TestRejectNil(t *testing.T) {
+	type job struct{ id int }
+
+	t.Run("pointer", func(t *testing.T) {
+		q := New[*job](WithRejectNil[*job]())
+
+		if err := q.Enqueue(nil); !errors.Is(err, ErrNilValue) {
+			t.Errorf("Enqueue(nil) = %v, want %v", err, ErrNilValue)
+		}
+		if err := q.EnqueueWait(context.Background(), nil); !errors.Is(err, ErrNilValue) {
+			t.Errorf("EnqueueWait(nil) = %v, want %v", err, ErrNilValue)
+		}
+		if err := q.Enqueue(&job{id: 1}); err != nil {
+			t.Errorf("Enqueue(&job{}) = %v, want nil", err)
+		}
+		if s := q.Stats(); s.Enqueued != 1 || s.Rejected != 0 {
+			t.Errorf("Stats() = %+v, want Enqueued=1 Rejected=0", s)
+		}
+	})
+
+	t.Run("interface", func(t *testing.T) {
+		q := New[error](WithRejectNil[error]())
+
+		if err := q.Enqueue(nil); !errors.Is(err, ErrNilValue) {
+			t.Errorf("Enqueue(nil) = %v, want %v", err, ErrNilValue)
+		}
+		if err := q.Enqueue(ErrOverflow); err != nil {
+			t.Errorf("Enqueue(ErrOverflow) = %v, want nil", err)
+		}
+	})
+
+	t.Run("slice map func", func(t *testing.T) {
+		if err := New[[]int](WithRejectNil[[]int]()).Enqueue(nil); !errors.Is(err, ErrNilValue) {
+			t.Errorf("Enqueue(nil slice) = %v, want %v", err, ErrNilValue)
+		}
+		if err := New[[]int](WithRejectNil[[]int]()).Enqueue([]int{}); err != nil {
+			t.Errorf("Enqueue(empty slice) = %v, want nil", err)
+		}
+		if err := New[map[int]int](WithRejectNil[map[int]int]()).Enqueue(nil); !errors.Is(err, ErrNilValue) {
+			t.Errorf("Enqueue(nil map) = %v, want %v", err, ErrNilValue)
+		}
+		if err := New[func()](WithRejectNil[func()]()).Enqueue(func() {}); err != nil {
+			t.Errorf("Enqueue(func) = %v, want nil", err)
+		}
+	})
+
+	t.Run("sharded", func(t *testing.T) {
+		q := NewSharded[*job](2, WithRejectNil[*job]())
+		if err := q.Enqueue(nil); !errors.Is(err, ErrNilValue) {
+			t.Errorf("Enqueue(nil) = %v, want %v", err, ErrNilValue)
+		}
+		if size := q.Size(); size != 0 {
+			t.Errorf("Size after rejected Enqueue = %d, want 0", size)
+		}
+	})
+
+	t.Run("non-nilable type", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("WithRejectNil[int]() did not panic")
+			}
+		}()
+		WithRejectNil[int]()
+	})
+}
+
+func TestWithValidator(t *testing.T) {
+	errNegative := errors.New("negative")
+	errOdd := errors.New("odd")
+	var calls []string
+	nonNegative := WithValidator(func(v int) error {
+		calls = append(calls, "nonNegative")
+		if v < 0 {
+			return errNegative
+		}
+		return nil
+	})
+	even := WithValidator(func(v int) error {
+		calls = append(calls, "even")
+		if v%2 != 0 {
+			return errOdd
+		}
+		return nil
+	})
+
+	for name, q := range map[string]Queue[int]{
+		"queue":    New(nonNegative, even),
+		"priority": NewPriority(intLess, nonNegative, even),
+		"sharded":  NewSharded(2, nonNegative, even),
+		"expiring": NewExpiring(nonNegative, even),
+	} {
+		t.Run(name, func(t *testing.T) {
+			calls = nil
+			err := q.Enqueue(-1)
+			if !errors.Is(err, ErrInvalidItem) || !errors.Is(err, errNegative) {
+				t.Errorf("Enqueue(-1) = %v, want it to wrap %v and %v", err, ErrInvalidItem, errNegative)
+			}
+			// The first failing validator stops the rest
+			if !slices.Equal(calls, []string{"nonNegative"}) {
+				t.Errorf("validators called = %v, want [nonNegative]", calls)
+			}
+
+			if err := q.EnqueueWait(context.Background(), 3); !errors.Is(err, errOdd) {
+				t.Errorf("EnqueueWait(3) = %v, want it to wrap %v", err, errOdd)
+			}
+			if err := q.EnqueueFrontAll(2, 5); !errors.Is(err, ErrInvalidItem) {
+				t.Errorf("EnqueueFrontAll(2, 5) = %v, want it to wrap %v", err, ErrInvalidItem)
+			}
+			if n, err := q.EnqueueSlice([]int{2, 4, 7, 8}); n != 2 || !errors.Is(err, errOdd) {
+				t.Errorf("EnqueueSlice(2, 4, 7, 8) = (%d, %v), want (2, %v)", n, err, errOdd)
+			}
+
+			// Merge and CopyInto check every item even from a queue they cannot
+			// lock together with q
+			other := NewSPSC[int](4)
+			_, _ = other.EnqueueSlice([]int{6, 9})
+			if err := q.Merge(other); !errors.Is(err, errOdd) {
+				t.Errorf("Merge(6, 9) = %v, want it to wrap %v", err, errOdd)
+			}
+			if err := other.CopyInto(q); !errors.Is(err, errOdd) {
+				t.Errorf("CopyInto() of 6, 9 = %v, want it to wrap %v", err, errOdd)
+			}
+			if got := slices.Collect(other.All()); !slices.Equal(got, []int{6, 9}) {
+				t.Errorf("items of the other queue = %v, want [6 9]", got)
+			}
+
+			if got, _ := q.Snapshot(); !slices.Equal(got, []int{2, 4}) && !slices.Equal(got, []int{4, 2}) {
+				t.Errorf("Snapshot() = %v, want only the valid items 2 and 4", got)
+			}
+			if s := q.Stats(); s.Rejected != 0 {
+				t.Errorf("Stats().Rejected = %d, want 0 for invalid items", s.Rejected)
+			}
+		})
+	}
+
+	t.Run("replace", func(t *testing.T) {
+		q := New(nonNegative)
+		_ = q.Enqueue(1)
+		if err := q.ReplaceFront(-2); !errors.Is(err, errNegative) {
+			t.Errorf("ReplaceFront(-2) = %v, want it to wrap %v", err, errNegative)
+		}
+		if front, _ := q.Peek(); front != 1 {
+			t.Errorf("Peek() after rejected ReplaceFront() = %d, want 1", front)
+		}
+	})
+
+	t.Run("nil validator (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("WithValidator(nil) should panic, but it didn't")
+			}
+		}()
+
+		WithValidator[int](nil)
+	})
+}
+
+func TestDequeueUntil(t *testing.T) {
+	t.Run("match in middle", func(t *testing.T) {
+		var hooked []int
+		q := New[int](WithOnDequeue(func(v int) { hooked = append(hooked, v) }))
+		for i := 1; i <= 5; i++ {
+			_ = q.Enqueue(i)
+		}
+
+		val, err := q.DequeueUntil(func(v int) bool { return v == 3 })
+		if val != 3 || err != nil {
+			t.Fatalf("DequeueUntil() = (%d, %v), want (3, nil)", val, err)
+		}
+		if size := q.Size(); size != 2 {
+			t.Errorf("Size after DequeueUntil() = %d, want 2", size)
+		}
+		if next, _ := q.Peek(); next != 4 {
+			t.Errorf("Peek() after DequeueUntil() = %d, want 4", next)
+		}
+		if fmt.Sprint(hooked) != "[1 2 3]" {
+			t.Errorf("dequeue hooks saw %v, want [1 2 3]", hooked)
+		}
+		if s := q.Stats(); s.Dequeued != 3 {
+			t.Errorf("Stats().Dequeued = %d, want 3", s.Dequeued)
+		}
+	})
+
+	t.Run("match at front", func(t *testing.T) {
+		q := New[int]()
+		_ = q.Enqueue(1)
+		_ = q.Enqueue(2)
+
+		if val, err := q.DequeueUntil(func(int) bool { return true }); val != 1 || err != nil {
+			t.Errorf("DequeueUntil() = (%d, %v), want (1, nil)", val, err)
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		q := New[int]()
+		for i := 1; i <= 3; i++ {
+			_ = q.Enqueue(i)
+		}
+
+		if _, err := q.DequeueUntil(func(v int) bool { return v > 10 }); !errors.Is(err, ErrUnderflow) {
+			t.Errorf("DequeueUntil() = %v, want %v", err, ErrUnderflow)
+		}
+		if size := q.Size(); size != 0 {
+			t.Errorf("Size after unmatched DequeueUntil() = %d, want 0", size)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if _, err := New[int]().DequeueUntil(func(int) bool { return true }); !errors.Is(err, ErrUnderflow) {
+			t.Errorf("DequeueUntil() on empty queue = %v, want %v", err, ErrUnderflow)
+		}
+	})
+}
+
+func TestDequeueMatch(t *testing.T) {
+	t.Run("match in middle", func(t *testing.T) {
+		var hooked []int
+		q := New[int](WithOnDequeue(func(v int) { hooked = append(hooked, v) }))
+		_, _ = q.EnqueueSlice([]int{1, 2, 3, 4, 3})
+
+		val, err := q.DequeueMatch(func(v int) bool { return v == 3 })
+		if val != 3 || err != nil {
+			t.Fatalf("DequeueMatch() = (%d, %v), want (3, nil)", val, err)
+		}
+		if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 2, 4, 3}) {
+			t.Errorf("All() after DequeueMatch() = %v, want [1 2 4 3]", got)
+		}
+		if fmt.Sprint(hooked) != "[3]" {
+			t.Errorf("dequeue hooks saw %v, want [3]", hooked)
+		}
+		if s := q.Stats(); s.Dequeued != 1 {
+			t.Errorf("Stats().Dequeued = %d, want 1", s.Dequeued)
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		q := New[int]()
+		_, _ = q.EnqueueSlice([]int{1, 2, 3})
+		version := q.Version()
+
+		if _, err := q.DequeueMatch(func(v int) bool { return v > 3 }); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DequeueMatch() with no match = %v, want %v", err, ErrNotFound)
+		}
+		if size := q.Size(); size != 3 || q.Version() != version {
+			t.Errorf("Queue changed by failed DequeueMatch(): Size() = %d, want 3", size)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		q := New[int]()
+		if _, err := q.DequeueMatch(func(int) bool { return true }); !errors.Is(err, ErrUnderflow) {
+			t.Errorf("DequeueMatch() on empty queue = %v, want %v", err, ErrUnderflow)
+		}
+	})
+
+	t.Run("other queues", func(t *testing.T) {
+		clock := newFakeClock()
+		expiring := NewExpiring[int](WithClock[int](clock))
+		_ = expiring.EnqueueWithTTL(2, shortTTL)
+		clock.Advance(2 * shortTTL) // DequeueMatch must skip this expired 2
+
+		for name, q := range map[string]Queue[int]{
+			"priority": NewPriority(intLess),
+			"sharded":  NewSharded[int](3),
+			"spsc":     NewSPSC[int](8),
+			"expiring": expiring,
+		} {
+			_, _ = q.EnqueueSlice([]int{5, 4, 2, 6})
+			if val, err := q.DequeueMatch(func(v int) bool { return v == 2 }); val != 2 || err != nil {
+				t.Errorf("%s: DequeueMatch() = (%d, %v), want (2, nil)", name, val, err)
+			}
+			if _, err := q.DequeueMatch(func(v int) bool { return v == 2 }); !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s: second DequeueMatch() = %v, want %v", name, err, ErrNotFound)
+			}
+			if size := q.Size(); size != 3 {
+				t.Errorf("%s: Size() after DequeueMatch() = %d, want 3", name, size)
+			}
+			if err := CheckInvariants(q); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+
+		src := New[int]()
+		_ = src.Enqueue(4)
+		v := NewView(src, func(v int) bool { return v != 4 })
+		if _, err := v.DequeueMatch(func(int) bool { return true }); !errors.Is(err, ErrUnderflow) {
+			t.Errorf("view: DequeueMatch() with no item in view = %v, want %v", err, ErrUnderflow)
+		}
+		_ = src.Enqueue(5)
+		if _, err := v.DequeueMatch(func(v int) bool { return v == 4 }); !errors.Is(err, ErrNotFound) {
+			t.Errorf("view: DequeueMatch() of an item outside the view = %v, want %v", err, ErrNotFound)
+		}
+		if val, err := v.DequeueMatch(func(int) bool { return true }); val != 5 || err != nil {
+			t.Errorf("view: DequeueMatch() = (%d, %v), want (5, nil)", val, err)
+		}
+	})
+}
+
+func TestPeekOrDequeueOr(t *testing.T) {
+	src := New[int]()
+	for name, q := range map[string]Queue[int]{
+		"queue":    New[int](),
+		"priority": NewPriority(intLess),
+		"sharded":  NewSharded[int](2),
+		"expiring": NewExpiring[int](),
+		"spsc":     NewSPSC[int](4),
+		"view":     NewView(src, func(int) bool { return true }),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := q.PeekOr(-1); got != -1 {
+				t.Errorf("PeekOr(-1) on an empty queue = %d, want -1", got)
+			}
+			if got := q.DequeueOr(-1); got != -1 {
+				t.Errorf("DequeueOr(-1) on an empty queue = %d, want -1", got)
+			}
+
+			if name == "view" {
+				_ = src.Enqueue(7)
+			} else {
+				_ = q.Enqueue(7)
+			}
+			if got := q.PeekOr(-1); got != 7 {
+				t.Errorf("PeekOr(-1) = %d, want 7", got)
+			}
+			if size := q.Size(); size != 1 {
+				t.Errorf("Size() after PeekOr() = %d, want 1", size)
+			}
+			if got := q.DequeueOr(-1); got != 7 {
+				t.Errorf("DequeueOr(-1) = %d, want 7", got)
+			}
+			if size := q.Size(); size != 0 {
+				t.Errorf("Size() after DequeueOr() = %d, want 0", size)
+			}
+		})
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	strLen := func(s string) int { return len(s) }
+
+	t.Run("overflow on bytes", func(t *testing.T) {
+		q := New[string](WithMaxBytes(10, strLen))
+
+		_ = q.Enqueue("hello")
+		_ = q.Enqueue("abc")
+		if err := q.Enqueue("xyz"); !errors.Is(err, ErrOverflow) {
+			t.Fatalf("Enqueue() past byte limit with 2 items = %v, want %v", err, ErrOverflow)
+		}
+		if err := q.Enqueue("xy"); err != nil {
+			t.Errorf("Enqueue() filling the byte limit exactly = %v, want nil", err)
+		}
+
+		// Dequeue releases the bytes of the removed item
+		_, _ = q.Dequeue()
+		if err := q.Enqueue("12345"); err != nil {
+			t.Errorf("Enqueue() after Dequeue() = %v, want nil", err)
+		}
+		if size := q.Size(); size != 3 {
+			t.Errorf("Size = %d, want 3", size)
+		}
+	})
+
+	t.Run("many small items", func(t *testing.T) {
+		q := New[string](WithMaxBytes(10, strLen))
+		for i := 0; i < 10; i++ {
+			if err := q.Enqueue(""); err != nil {
+				t.Fatalf("Enqueue(empty) = %v, want nil", err)
+			}
+		}
+		if size := q.Size(); size != 10 {
+			t.Errorf("Size = %d, want 10", size)
+		}
+	})
+
+	t.Run("item larger than limit", func(t *testing.T) {
+		q := New[string](WithMaxBytes(4, strLen), WithCapacity[string](2), WithCircular[string]())
+		_ = q.Enqueue("ab")
+
+		if err := q.Enqueue("abcde"); !errors.Is(err, ErrOverflow) {
+			t.Errorf("Enqueue() of item larger than the limit = %v, want %v", err, ErrOverflow)
+		}
+		if size := q.Size(); size != 1 {
+			t.Errorf("Size after rejected oversized item = %d, want 1", size)
+		}
+	})
+
+	t.Run("with count cap", func(t *testing.T) {
+		q := New[string](WithMaxBytes(100, strLen), WithCapacity[string](2))
+		_ = q.Enqueue("a")
+		_ = q.Enqueue("b")
+		if err := q.Enqueue("c"); !errors.Is(err, ErrOverflow) {
+			t.Errorf("Enqueue() past count cap = %v, want %v", err, ErrOverflow)
+		}
+	})
+
+	t.Run("circular evicts by bytes", func(t *testing.T) {
+		q := New[string](WithMaxBytes(6, strLen), WithCapacity[string](10), WithCircular[string]())
+		for _, s := range []string{"aa", "bb", "cc", "dddd"} {
+			_ = q.Enqueue(s)
+		}
+
+		if s := q.String(); s != "Queue[len=2/cap=10]: [cc dddd]" {
+			t.Errorf("String() = %q, want the newest items within 6 bytes", s)
+		}
+	})
+
+	t.Run("filter releases bytes", func(t *testing.T) {
+		q := New[string](WithMaxBytes(6, strLen))
+		_ = q.Enqueue("aaa")
+		_ = q.Enqueue("bbb")
+		q.Filter(func(s string) bool { return s != "aaa" })
+
+		if err := q.Enqueue("ccc"); err != nil {
+			t.Errorf("Enqueue() after Filter() = %v, want nil", err)
+		}
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		q := NewPriority[string](func(a, b string) bool { return a < b }, WithMaxBytes(4, strLen))
+		_ = q.Enqueue("b")
+		_ = q.Enqueue("aaa")
+
+		_, _ = q.Dequeue()
+		if err := q.Enqueue("ccc"); err != nil {
+			t.Errorf("Enqueue() after Dequeue() = %v, want nil", err)
+		}
+	})
+
+	t.Run("sharded", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("NewSharded() with WithMaxBytes did not panic")
+			}
+		}()
+		NewSharded[string](2, WithMaxBytes(10, strLen))
+	})
+}
+
+func TestSizeBytes(t *testing.T) {
+	strLen := func(s string) int { return len(s) }
+	q := New[string](WithMaxBytes(100, strLen))
+
+	steps := []struct {
+		name string
+		op   func()
+		want int
+	}{
+		{name: "empty", op: func() {}, want: 0},
+		{name: "Enqueue", op: func() { _ = q.Enqueue("hello") }, want: 5},
+		{name: "EnqueueSlice", op: func() { _, _ = q.EnqueueSlice([]string{"a", "abcdefgh", ""}) }, want: 14},
+		{name: "EnqueueFrontAll", op: func() { _ = q.EnqueueFrontAll("xyz") }, want: 17},
+		{name: "Dequeue", op: func() { _, _ = q.Dequeue() }, want: 14},
+		{name: "ReplaceBack", op: func() { _ = q.ReplaceBack("four") }, want: 18},
+		{name: "Remove", op: func() { _, _ = q.Remove(2) }, want: 10},
+		{name: "Filter", op: func() { q.Filter(func(s string) bool { return s != "a" }) }, want: 9},
+		{name: "Drain", op: func() { q.Drain() }, want: 0},
+	}
+	for _, step := range steps {
+		step.op()
+		if got := q.SizeBytes(); got != step.want {
+			t.Errorf("SizeBytes() after %s = %d, want %d", step.name, got, step.want)
+		}
+	}
+
+	if got := New[string]().SizeBytes(); got != 0 {
+		t.Errorf("SizeBytes() without WithMaxBytes = %d, want 0", got)
+	}
+}
+
+func TestReset(t *testing.T) {
+	var hooked int
+	q := New[int](WithCapacity[int](2), WithOnEnqueue(func(int) { hooked++ }))
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+	_ = q.Enqueue(3) // Rejected
+	_ = q.Close()
+
+	q.Reset()
+
+	if size := q.Size(); size != 0 {
+		t.Errorf("Size after Reset() = %d, want 0", size)
+	}
+	if s := q.Stats(); s != (Stats{}) {
+		t.Errorf("Stats() after Reset() = %+v, want zero", s)
+	}
+
+	// The queue is open again, with its capacity and hooks intact
+	for i := 4; i <= 5; i++ {
+		if err := q.Enqueue(i); err != nil {
+			t.Fatalf("Enqueue(%d) after Reset() = %v, want nil", i, err)
+		}
+	}
+	if err := q.Enqueue(6); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Enqueue() past capacity after Reset() = %v, want %v", err, ErrOverflow)
+	}
+	if val, err := q.Dequeue(); val != 4 || err != nil {
+		t.Errorf("Dequeue() after Reset() = (%d, %v), want (4, nil)", val, err)
+	}
+	if hooked != 4 {
+		t.Errorf("enqueue hook calls = %d, want 4", hooked)
+	}
+}
+
+func TestResetWakesEnqueuers(t *testing.T) {
+	q := New[int](WithCapacity[int](1))
+	_ = q.Enqueue(1)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- q.EnqueueWait(context.Background(), 2)
+	}()
+
+	// Wait for the producer to block on the full queue
+	for q.(*queue[int]).notFull.waiters.Load() == 0 {
+		runtime.Gosched()
+	}
+	q.Reset()
+
+	if err := <-done; err != nil {
+		t.Fatalf("EnqueueWait() after Reset() = %v, want nil", err)
+	}
+	if val, _ := q.Dequeue(); val != 2 {
+		t.Errorf("Dequeue() = %d, want 2", val)
+	}
+}
+
+func TestReplaceFrontBack(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func() Queue[int]
+	}{
+		{name: "fifo", newFn: func() Queue[int] { return New[int]() }},
+		{name: "sharded", newFn: func() Queue[int] { return NewSharded[int](3) }},
+		{name: "expiring", newFn: func() Queue[int] { return NewExpiring[int]() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.newFn()
+			if err := q.ReplaceFront(1); !errors.Is(err, ErrUnderflow) {
+				t.Errorf("ReplaceFront() on empty queue = %v, want %v", err, ErrUnderflow)
+			}
+			if err := q.ReplaceBack(1); !errors.Is(err, ErrUnderflow) {
+				t.Errorf("ReplaceBack() on empty queue = %v, want %v", err, ErrUnderflow)
+			}
+
+			_, _ = q.EnqueueSlice([]int{1, 2, 3})
+			if err := q.ReplaceBack(30); err != nil {
+				t.Fatalf("ReplaceBack() = %v, want nil", err)
+			}
+			if val, _ := q.Back(); val != 30 {
+				t.Errorf("Back() after ReplaceBack(30) = %d, want 30", val)
+			}
+			if err := q.ReplaceFront(10); err != nil {
+				t.Fatalf("ReplaceFront() = %v, want nil", err)
+			}
+			if val, _ := q.Peek(); val != 10 {
+				t.Errorf("Peek() after ReplaceFront(10) = %d, want 10", val)
+			}
+
+			// Positions are kept and nothing is added or removed
+			var got []int
+			for v, ok := q.Poll(); ok; v, ok = q.Poll() {
+				got = append(got, v)
+			}
+			if !slices.Equal(got, []int{10, 2, 30}) {
+				t.Errorf("items after replacing = %v, want [10 2 30]", got)
+			}
+		})
+	}
+}
+
+func TestReplaceExpiringKeepsTTL(t *testing.T) {
+	clock := newFakeClock()
+	q := NewExpiring(WithClock[int](clock))
+	_ = q.EnqueueWithTTL(1, shortTTL)
+	_ = q.EnqueueWithTTL(2, longTTL)
+
+	_ = q.ReplaceFront(10)
+	clock.Advance(2 * shortTTL)
+	if val, _ := q.Peek(); val != 2 {
+		t.Errorf("Peek() after replaced front expired = %d, want 2", val)
+	}
+
+	// ReplaceBack skips an expired item at the back
+	_ = q.Enqueue(3)
+	_ = q.EnqueueWithTTL(4, shortTTL)
+	clock.Advance(2 * shortTTL)
+	if err := q.ReplaceBack(30); err != nil {
+		t.Fatalf("ReplaceBack() = %v, want nil", err)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{2, 30}) {
+		t.Errorf("items = %v, want [2 30]", got)
+	}
+}
+
+func TestReplacePriority(t *testing.T) {
+	q := NewPriority(intLess)
+	_, _ = q.EnqueueSlice([]int{1, 5, 3})
+
+	// A replaced front that loses priority moves back
+	_ = q.ReplaceFront(4)
+	if val, _ := q.Peek(); val != 3 {
+		t.Errorf("Peek() after ReplaceFront(4) = %d, want 3", val)
+	}
+	_ = q.ReplaceBack(0)
+	if val, _ := q.Peek(); val != 0 {
+		t.Errorf("Peek() after ReplaceBack(0) = %d, want 0", val)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplaceLimits(t *testing.T) {
+	q := New(WithMaxBytes(10, func(s string) int { return len(s) }))
+	_ = q.Enqueue("abc")
+	_ = q.Enqueue("defg")
+
+	if err := q.ReplaceBack("toolongval"); !errors.Is(err, ErrOverflow) {
+		t.Errorf("ReplaceBack() over byte limit = %v, want %v", err, ErrOverflow)
+	}
+	if err := q.ReplaceBack("abcdefg"); err != nil {
+		t.Errorf("ReplaceBack() within byte limit = %v, want nil", err)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Error(err)
+	}
+
+	p := New(WithRejectNil[*int]())
+	_ = p.Enqueue(new(int))
+	if err := p.ReplaceFront(nil); !errors.Is(err, ErrNilValue) {
+		t.Errorf("ReplaceFront(nil) = %v, want %v", err, ErrNilValue)
+	}
+}
+
+func TestFrontBack(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		q := New[int]()
+		if _, err := q.Front(); !errors.Is(err, ErrUnderflow) {
+			t.Errorf("Front() on empty queue = %v, want %v", err, ErrUnderflow)
+		}
+		if _, err := q.Back(); !errors.Is(err, ErrUnderflow) {
+			t.Errorf("Back() on empty queue = %v, want %v", err, ErrUnderflow)
+		}
+	})
+
+	t.Run("single", func(t *testing.T) {
+		q := New[int]()
+		_ = q.Enqueue(7)
+		if val, err := q.Front(); val != 7 || err != nil {
+			t.Errorf("Front() = (%d, %v), want (7, nil)", val, err)
+		}
+		if val, err := q.Back(); val != 7 || err != nil {
+			t.Errorf("Back() = (%d, %v), want (7, nil)", val, err)
+		}
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		q := newQueue[int](WithCapacity[int](4))
+		for i := 0; i < 4; i++ {
+			_ = q.Enqueue(i)
+		}
+		_, _ = q.Dequeue()
+		_, _ = q.Dequeue()
+		_ = q.Enqueue(4)
+		_ = q.Enqueue(5)
+
+		// The back item now sits before the front item in the buffer
+		if q.items.index(q.items.len()-1) >= q.items.head {
+			t.Fatal("test setup did not wrap the ring")
+		}
+		if val, _ := q.Front(); val != 2 {
+			t.Errorf("Front() = %d, want 2", val)
+		}
+		if val, _ := q.Back(); val != 5 {
+			t.Errorf("Back() = %d, want 5", val)
+		}
+		if size := q.Size(); size != 4 {
+			t.Errorf("Size after Front/Back = %d, want 4", size)
+		}
+	})
+}
+
+func TestPeekN(t *testing.T) {
+	t.Run("fifo", func(t *testing.T) {
+		q := newQueue[int](WithCapacity[int](4))
+		_, _ = q.EnqueueSlice([]int{0, 1, 2, 3})
+		_, _ = q.Dequeue()
+		_ = q.Enqueue(4) // Wraps the ring
+
+		tests := []struct {
+			n    int
+			want []int
+		}{
+			{n: 0, want: []int{}},
+			{n: 2, want: []int{1, 2}},
+			{n: 4, want: []int{1, 2, 3, 4}},
+			{n: 10, want: []int{1, 2, 3, 4}},
+		}
+		for _, tt := range tests {
+			got, err := q.PeekN(tt.n)
+			if err != nil || got == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("PeekN(%d) = (%v, %v), want (%v, nil)", tt.n, got, err, tt.want)
+			}
+		}
+
+		// The result is a copy, and nothing was removed
+		got, _ := q.PeekN(1)
+		got[0] = 99
+		if val, _ := q.Peek(); val != 1 {
+			t.Errorf("Peek() after changing PeekN result = %d, want 1", val)
+		}
+		if size := q.Size(); size != 4 {
+			t.Errorf("Size after PeekN = %d, want 4", size)
+		}
+		if _, err := q.PeekN(-1); !errors.Is(err, ErrNegativeCount) {
+			t.Errorf("PeekN(-1) = %v, want %v", err, ErrNegativeCount)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		for _, n := range []int{0, 3} {
+			got, err := New[int]().PeekN(n)
+			if !errors.Is(err, ErrUnderflow) || got == nil || len(got) != 0 {
+				t.Errorf("PeekN(%d) on empty queue = (%v, %v), want ([], %v)", n, got, err, ErrUnderflow)
+			}
+		}
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		q := NewPriority(intLess)
+		_, _ = q.EnqueueSlice([]int{5, 1, 4, 2, 3})
+		if got, _ := q.PeekN(3); !slices.Equal(got, []int{1, 2, 3}) {
+			t.Errorf("PeekN(3) = %v, want [1 2 3]", got)
+		}
+	})
+
+	t.Run("expiring", func(t *testing.T) {
+		clock := newFakeClock()
+		q := NewExpiring[int](WithClock[int](clock))
+		_ = q.EnqueueWithTTL(1, shortTTL)
+		_ = q.Enqueue(2)
+		_ = q.Enqueue(3)
+		clock.Advance(2 * shortTTL)
+		if got, _ := q.PeekN(5); !slices.Equal(got, []int{2, 3}) {
+			t.Errorf("PeekN(5) = %v, want [2 3]", got)
+		}
+
+		e := NewExpiring[int](WithClock[int](clock))
+		_ = e.EnqueueWithTTL(1, shortTTL)
+		clock.Advance(2 * shortTTL)
+		if _, err := e.PeekN(1); !errors.Is(err, ErrUnderflow) {
+			t.Errorf("PeekN(1) with only expired items = %v, want %v", err, ErrUnderflow)
+		}
+	})
+
+	t.Run("sharded", func(t *testing.T) {
+		q := NewSharded[int](3)
+		_, _ = q.EnqueueSlice([]int{1, 2, 3, 4, 5})
+		got, err := q.PeekN(4)
+		if err != nil || len(got) != 4 {
+			t.Fatalf("PeekN(4) = (%v, %v), want 4 items", got, err)
+		}
+		if drained := q.Drain(); !slices.Equal(got, drained[:4]) {
+			t.Errorf("PeekN(4) = %v, want the first 4 items Drain returns (%v)", got, drained)
+		}
+		if _, err := q.PeekN(1); !errors.Is(err, ErrUnderflow) {
+			t.Errorf("PeekN(1) on empty sharded queue = %v, want %v", err, ErrUnderflow)
+		}
+	})
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		want  int
+		rest  []int
+	}{
+		{name: "front", index: 0, want: 1, rest: []int{2, 3, 4, 5, 6}},
+		{name: "near front", index: 1, want: 2, rest: []int{1, 3, 4, 5, 6}},
+		{name: "middle", index: 3, want: 4, rest: []int{1, 2, 3, 5, 6}},
+		{name: "back", index: 5, want: 6, rest: []int{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Offset the head so the items wrap around the end of the ring
+			q := newQueue[int](WithCapacity[int](8))
+			for i := 0; i < 5; i++ {
+				_ = q.Enqueue(0)
+				_, _ = q.Dequeue()
+			}
+			for i := 1; i <= 6; i++ {
+				_ = q.Enqueue(i)
+			}
+
+			val, err := q.Remove(tt.index)
+			if val != tt.want || err != nil {
+				t.Fatalf("Remove(%d) = (%d, %v), want (%d, nil)", tt.index, val, err, tt.want)
+			}
+			checkRingState(t, 0, &q.items, tt.rest)
+		})
+	}
+
+	t.Run("out of range", func(t *testing.T) {
+		q := New[int]()
+		_ = q.Enqueue(1)
+
+		for _, i := range []int{-1, 1, 5} {
+			if _, err := q.Remove(i); !errors.Is(err, ErrIndexOutOfRange) {
+				t.Errorf("Remove(%d) = %v, want %v", i, err, ErrIndexOutOfRange)
+			}
+		}
+		if size := q.Size(); size != 1 {
+			t.Errorf("Size after failed Remove() = %d, want 1", size)
+		}
+	})
+}
+
+func TestFindIndex(t *testing.T) {
+	variants := []struct {
+		name  string
+		newFn func() Queue[int]
+	}{
+		{name: "queue", newFn: func() Queue[int] { return New[int]() }},
+		{name: "sharded", newFn: func() Queue[int] { return NewSharded[int](3) }},
+		{name: "expiring", newFn: func() Queue[int] { return NewExpiring[int]() }},
+	}
+	tests := []struct {
+		name   string
+		target int
+	}{
+		{name: "front", target: 10},
+		{name: "middle", target: 30},
+		{name: "back", target: 50},
+		{name: "no match", target: 99},
+	}
+
+	for _, v := range variants {
+		for _, tt := range tests {
+			t.Run(v.name+"/"+tt.name, func(t *testing.T) {
+				q := v.newFn()
+				_, _ = q.EnqueueSlice([]int{10, 20, 30, 40, 50})
+				// Sharded queues spread the items, so take the position from All
+				want := slices.Index(slices.Collect(q.All()), tt.target)
+
+				i := q.FindIndex(func(v int) bool { return v == tt.target })
+				if i != want {
+					t.Fatalf("FindIndex(== %d) = %d, want %d", tt.target, i, want)
+				}
+				if i < 0 {
+					return
+				}
+				// The index is valid for Remove
+				if val, err := q.Remove(i); val != tt.target || err != nil {
+					t.Errorf("Remove(%d) = (%d, %v), want (%d, nil)", i, val, err, tt.target)
+				}
+				if j := q.FindIndex(func(v int) bool { return v == tt.target }); j != -1 {
+					t.Errorf("FindIndex(== %d) after Remove() = %d, want -1", tt.target, j)
+				}
+			})
+		}
+	}
+
+	t.Run("expired items skipped", func(t *testing.T) {
+		clock := newFakeClock()
+		q := NewExpiring[int](WithClock[int](clock))
+		_ = q.EnqueueWithTTL(1, shortTTL)
+		_ = q.Enqueue(2)
+		clock.Advance(2 * shortTTL)
+		if i := q.FindIndex(func(v int) bool { return v == 2 }); i != 0 {
+			t.Errorf("FindIndex(== 2) behind an expired item = %d, want 0", i)
+		}
+	})
+}
+
+func TestSwap(t *testing.T) {
+	tests := []struct {
+		name string
+		i, j int
+		want []int
+	}{
+		{name: "front and back", i: 0, j: 4, want: []int{5, 2, 3, 4, 1}},
+		{name: "back and front", i: 4, j: 0, want: []int{5, 2, 3, 4, 1}},
+		{name: "middle", i: 1, j: 3, want: []int{1, 4, 3, 2, 5}},
+		{name: "same index", i: 2, j: 2, want: []int{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Offset the head so the items wrap around the end of the ring
+			q := newQueue[int](WithCapacity[int](6))
+			for i := 0; i < 4; i++ {
+				_ = q.Enqueue(0)
+				_, _ = q.Dequeue()
+			}
+			for i := 1; i <= 5; i++ {
+				_ = q.Enqueue(i)
+			}
+
+			if err := q.Swap(tt.i, tt.j); err != nil {
+				t.Fatalf("Swap(%d, %d) = %v, want nil", tt.i, tt.j, err)
+			}
+			checkRingState(t, 0, &q.items, tt.want)
+		})
+	}
+
+	t.Run("out of range", func(t *testing.T) {
+		q := New[int]()
+		_ = q.Enqueue(1)
+		_ = q.Enqueue(2)
+
+		for _, idx := range [][2]int{{0, 2}, {-1, 0}, {2, 2}} {
+			if err := q.Swap(idx[0], idx[1]); !errors.Is(err, ErrIndexOutOfRange) {
+				t.Errorf("Swap(%d, %d) = %v, want %v", idx[0], idx[1], err, ErrIndexOutOfRange)
+			}
+		}
+		if val, _ := q.Peek(); val != 1 {
+			t.Errorf("Peek() after failed Swap() = %d, want 1", val)
+		}
+	})
+}
+
+func TestDrain(t *testing.T) {
+	var dequeued []int
+	q := newQueue[int](WithOnDequeue(func(v int) { dequeued = append(dequeued, v) }))
+	for i := 1; i <= 3; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	if got := q.Drain(); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Drain() = %v, want [1 2 3]", got)
+	}
+	checkRingState(t, 0, &q.items, nil)
+	if !slices.Equal(dequeued, []int{1, 2, 3}) {
+		t.Errorf("dequeue hook saw %v, want [1 2 3]", dequeued)
+	}
+	if s := q.Stats(); s.Dequeued != 3 {
+		t.Errorf("Stats().Dequeued = %d, want 3", s.Dequeued)
+	}
+	if got := q.Drain(); got == nil || len(got) != 0 {
+		t.Errorf("Drain() on empty queue = %#v, want empty slice", got)
+	}
+
+	// A priority queue drains in priority order
+	pq := NewPriority(intLess)
+	for _, v := range []int{3, 1, 2} {
+		_ = pq.Enqueue(v)
+	}
+	if got := pq.Drain(); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("priority Drain() = %v, want [1 2 3]", got)
+	}
+}
+
+func TestDrainTo(t *testing.T) {
+	t.Run("partial", func(t *testing.T) {
+		// Offset the head so the items wrap around the end of the ring
+		q := newQueue[int](WithCapacity[int](4))
+		for i := 0; i < 3; i++ {
+			_ = q.Enqueue(0)
+			_, _ = q.Dequeue()
+		}
+		for i := 1; i <= 4; i++ {
+			_ = q.Enqueue(i)
+		}
+
+		dst := make([]int, 3)
+		if n := q.DrainTo(dst); n != 3 {
+			t.Fatalf("DrainTo() = %d, want 3", n)
+		}
+		if !slices.Equal(dst, []int{1, 2, 3}) {
+			t.Errorf("DrainTo() copied %v, want [1 2 3]", dst)
+		}
+		checkRingState(t, 0, &q.items, []int{4})
+	})
+
+	t.Run("larger than size", func(t *testing.T) {
+		q := newQueue[int]()
+		_ = q.Enqueue(1)
+		_ = q.Enqueue(2)
+
+		dst := []int{-1, -1, -1}
+		if n := q.DrainTo(dst); n != 2 {
+			t.Fatalf("DrainTo() = %d, want 2", n)
+		}
+		if !slices.Equal(dst, []int{1, 2, -1}) {
+			t.Errorf("DrainTo() left dst = %v, want [1 2 -1]", dst)
+		}
+		checkRingState(t, 0, &q.items, nil)
+	})
+
+	t.Run("wakes enqueuers", func(t *testing.T) {
+		q := New[int](WithCapacity[int](1))
+		_ = q.Enqueue(1)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- q.EnqueueWait(context.Background(), 2)
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		if n := q.DrainTo(make([]int, 1)); n != 1 {
+			t.Fatalf("DrainTo() = %d, want 1", n)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("EnqueueWait() after DrainTo() = %v, want nil", err)
+		}
+	})
+
+	t.Run("empty dst", func(t *testing.T) {
+		q := New[int]()
+		_ = q.Enqueue(1)
+
+		if n := q.DrainTo(nil); n != 0 {
+			t.Errorf("DrainTo(nil) = %d, want 0", n)
+		}
+		if size := q.Size(); size != 1 {
+			t.Errorf("Size after DrainTo(nil) = %d, want 1", size)
+		}
+	})
+}
+
+func TestEnqueueDedupBack(t *testing.T) {
+	eq := func(a, b int) bool { return a == b }
+
+	t.Run("collapses consecutive duplicates", func(t *testing.T) {
+		q := New[int]()
+		for _, v := range []int{1, 1, 2, 2, 2, 1, 3, 3} {
+			_, _ = q.EnqueueDedupBack(v, eq)
+		}
+		if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 2, 1, 3}) {
+			t.Errorf("All() = %v, want [1 2 1 3]", got)
+		}
+	})
+
+	t.Run("non-duplicates all land", func(t *testing.T) {
+		q := New[int]()
+		for i := 1; i <= 5; i++ {
+			if ok, err := q.EnqueueDedupBack(i, eq); !ok || err != nil {
+				t.Errorf("EnqueueDedupBack(%d) = (%v, %v), want (true, nil)", i, ok, err)
+			}
+		}
+		if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 2, 3, 4, 5}) {
+			t.Errorf("All() = %v, want [1 2 3 4 5]", got)
+		}
+	})
+
+	t.Run("duplicate reported", func(t *testing.T) {
+		var enqueued []int
+		q := New[int](WithOnEnqueue(func(v int) { enqueued = append(enqueued, v) }))
+		_, _ = q.EnqueueDedupBack(7, eq)
+
+		if ok, err := q.EnqueueDedupBack(7, eq); ok || err != nil {
+			t.Errorf("EnqueueDedupBack() of duplicate = (%v, %v), want (false, nil)", ok, err)
+		}
+		if !slices.Equal(enqueued, []int{7}) {
+			t.Errorf("enqueue hook saw %v, want [7]", enqueued)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		q := New[int](WithCapacity[int](1))
+		_ = q.Enqueue(1)
+
+		if ok, err := q.EnqueueDedupBack(1, eq); ok || err != nil {
+			t.Errorf("EnqueueDedupBack() of duplicate into full queue = (%v, %v), want (false, nil)", ok, err)
+		}
+		if ok, err := q.EnqueueDedupBack(2, eq); ok || !errors.Is(err, ErrOverflow) {
+			t.Errorf("EnqueueDedupBack() into full queue = (%v, %v), want (false, %v)", ok, err, ErrOverflow)
+		}
+		_ = q.Close()
+		if ok, err := q.EnqueueDedupBack(1, eq); ok || !errors.Is(err, ErrClosed) {
+			t.Errorf("EnqueueDedupBack() into closed queue = (%v, %v), want (false, %v)", ok, err, ErrClosed)
+		}
+	})
+
+	t.Run("atomic", func(t *testing.T) {
+		q := New[int]()
+		var added atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ok, _ := q.EnqueueDedupBack(1, eq); ok {
+					added.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if n := added.Load(); n != 1 || q.Size() != 1 {
+			t.Errorf("concurrent EnqueueDedupBack() added %d items, Size = %d, want 1", n, q.Size())
+		}
+	})
+}
+
+func TestCopyOnPeek(t *testing.T) {
+	t.Run("mutation does not reach the queue", func(t *testing.T) {
+		q := New[[]int](WithCopyOnPeek(slices.Clone[[]int]))
+		_ = q.Enqueue([]int{1, 2})
+		_ = q.Enqueue([]int{3, 4})
+
+		front, _ := q.Peek()
+		front[0] = 99
+		back, _ := q.Back()
+		back[0] = 99
+
+		if val, _ := q.Dequeue(); !slices.Equal(val, []int{1, 2}) {
+			t.Errorf("Dequeue() after mutating Peek() result = %v, want [1 2]", val)
+		}
+		if val, _ := q.Dequeue(); !slices.Equal(val, []int{3, 4}) {
+			t.Errorf("Dequeue() after mutating Back() result = %v, want [3 4]", val)
+		}
+	})
+
+	t.Run("default shares storage", func(t *testing.T) {
+		q := New[[]int]()
+		_ = q.Enqueue([]int{1, 2})
+
+		front, _ := q.Front()
+		front[0] = 99
+		if val, _ := q.Dequeue(); val[0] != 99 {
+			t.Errorf("Dequeue() = %v, want the stored slice returned by Front()", val)
+		}
+	})
+
+	t.Run("nil panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("WithCopyOnPeek(nil) did not panic")
+			}
+		}()
+		WithCopyOnPeek[[]int](nil)
+	})
+}
+
+func TestEnqueueFrontAll(t *testing.T) {
+	t.Run("order", func(t *testing.T) {
+		q := newQueue[int](WithInitialCapacity[int](4))
+		_ = q.Enqueue(4)
+		_ = q.Enqueue(5)
+
+		if err := q.EnqueueFrontAll(1, 2, 3); err != nil {
+			t.Fatalf("EnqueueFrontAll() = %v, want nil", err)
+		}
+		checkRingState(t, 0, &q.items, []int{1, 2, 3, 4, 5})
+		if err := q.EnqueueFrontAll(0); err != nil {
+			t.Fatalf("EnqueueFrontAll() of one item = %v, want nil", err)
+		}
+		checkRingState(t, 1, &q.items, []int{0, 1, 2, 3, 4, 5})
+		if s := q.Stats(); s.Enqueued != 6 || s.PeakSize != 6 {
+			t.Errorf("Stats() = %+v, want Enqueued=6 PeakSize=6", s)
+		}
+	})
+
+	t.Run("overflow rollback", func(t *testing.T) {
+		q := newQueue[int](WithCapacity[int](4))
+		_ = q.Enqueue(3)
+		_ = q.Enqueue(4)
+
+		if err := q.EnqueueFrontAll(0, 1, 2); !errors.Is(err, ErrOverflow) {
+			t.Fatalf("EnqueueFrontAll() past capacity = %v, want %v", err, ErrOverflow)
+		}
+		checkRingState(t, 0, &q.items, []int{3, 4})
+		if s := q.Stats(); s.Rejected != 1 || s.Enqueued != 2 {
+			t.Errorf("Stats() = %+v, want Rejected=1 Enqueued=2", s)
+		}
+		if err := q.EnqueueFrontAll(1, 2); err != nil {
+			t.Errorf("EnqueueFrontAll() filling the queue = %v, want nil", err)
+		}
+	})
+
+	t.Run("circular does not evict", func(t *testing.T) {
+		q := New[int](WithCapacity[int](2), WithCircular[int]())
+		_ = q.Enqueue(1)
+
+		if err := q.EnqueueFrontAll(2, 3); !errors.Is(err, ErrOverflow) {
+			t.Errorf("EnqueueFrontAll() into circular queue = %v, want %v", err, ErrOverflow)
+		}
+	})
+
+	t.Run("byte limit", func(t *testing.T) {
+		q := New[string](WithMaxBytes(4, func(s string) int { return len(s) }))
+		_ = q.Enqueue("a")
+
+		if err := q.EnqueueFrontAll("bb", "cc"); !errors.Is(err, ErrOverflow) {
+			t.Errorf("EnqueueFrontAll() past byte limit = %v, want %v", err, ErrOverflow)
+		}
+		if err := q.EnqueueFrontAll("b", "cc"); err != nil {
+			t.Errorf("EnqueueFrontAll() up to byte limit = %v, want nil", err)
+		}
+	})
+
+	t.Run("rejected values", func(t *testing.T) {
+		q := New[*int](WithRejectNil[*int]())
+		v := 1
+		if err := q.EnqueueFrontAll(&v, nil); !errors.Is(err, ErrNilValue) {
+			t.Errorf("EnqueueFrontAll() with nil = %v, want %v", err, ErrNilValue)
+		}
+		if size := q.Size(); size != 0 {
+			t.Errorf("Size after rejected EnqueueFrontAll() = %d, want 0", size)
+		}
+		_ = q.Close()
+		if err := q.EnqueueFrontAll(&v); !errors.Is(err, ErrClosed) {
+			t.Errorf("EnqueueFrontAll() on closed queue = %v, want %v", err, ErrClosed)
+		}
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		q := NewPriority(intLess)
+		_ = q.Enqueue(2)
+		_ = q.EnqueueFrontAll(3, 1)
+
+		if got := q.Drain(); !slices.Equal(got, []int{1, 2, 3}) {
+			t.Errorf("Drain() = %v, want [1 2 3]", got)
+		}
+	})
+}
+
+func TestEnqueuePush(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func(opts ...Option[int]) Queue[int]
+	}{
+		{name: "queue", newFn: func(opts ...Option[int]) Queue[int] { return New(opts...) }},
+		{name: "sharded", newFn: func(opts ...Option[int]) Queue[int] { return NewSharded(1, opts...) }},
+		{name: "expiring", newFn: func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dlq := New[int]()
+			q := tt.newFn(WithCapacity[int](2), WithDeadLetter(dlq))
+
+			// A queue with room takes the item without evicting
+			for i := 1; i <= 2; i++ {
+				if evicted, didEvict, err := q.EnqueuePush(i); didEvict || err != nil {
+					t.Fatalf("EnqueuePush(%d) = (%d, %v, %v), want (0, false, nil)", i, evicted, didEvict, err)
+				}
+			}
+
+			// A full queue gives up its front item
+			if evicted, didEvict, err := q.EnqueuePush(3); evicted != 1 || !didEvict || err != nil {
+				t.Errorf("EnqueuePush(3) = (%d, %v, %v), want (1, true, nil)", evicted, didEvict, err)
+			}
+			if got := slices.Collect(q.All()); !slices.Equal(got, []int{2, 3}) {
+				t.Errorf("All() = %v, want [2 3]", got)
+			}
+			if size := dlq.Size(); size != 0 {
+				t.Errorf("dead-letter queue received %d items, want 0", size)
+			}
+			if s := q.Stats(); s.Enqueued != 3 || s.Dequeued != 0 || s.Rejected != 0 {
+				t.Errorf("Stats() = %+v, want Enqueued=3 Dequeued=0 Rejected=0", s)
+			}
+
+			_ = q.Close()
+			if _, didEvict, err := q.EnqueuePush(4); didEvict || !errors.Is(err, ErrClosed) {
+				t.Errorf("EnqueuePush() on closed queue = (%v, %v), want (false, %v)", didEvict, err, ErrClosed)
+			}
+			if size := q.Size(); size != 2 {
+				t.Errorf("Size after EnqueuePush() on closed queue = %d, want 2", size)
+			}
+		})
+	}
+
+	t.Run("zero capacity", func(t *testing.T) {
+		q := New[int](WithCapacity[int](0))
+		if _, didEvict, err := q.EnqueuePush(1); didEvict || !errors.Is(err, ErrOverflow) {
+			t.Errorf("EnqueuePush() into zero-capacity queue = (%v, %v), want (false, %v)", didEvict, err, ErrOverflow)
+		}
+	})
+
+	t.Run("byte limit", func(t *testing.T) {
+		q := New[string](WithCapacity[string](2), WithMaxBytes(4, func(s string) int { return len(s) }))
+		_ = q.Enqueue("a")
+		_ = q.Enqueue("bb")
+
+		// Evicting "a" would not make room for "cccc", so nothing is evicted
+		if _, didEvict, err := q.EnqueuePush("cccc"); didEvict || !errors.Is(err, ErrOverflow) {
+			t.Errorf("EnqueuePush() past byte limit = (%v, %v), want (false, %v)", didEvict, err, ErrOverflow)
+		}
+		if evicted, didEvict, err := q.EnqueuePush("cc"); evicted != "a" || !didEvict || err != nil {
+			t.Errorf("EnqueuePush() = (%q, %v, %v), want (%q, true, nil)", evicted, didEvict, err, "a")
+		}
+		if got := slices.Collect(q.All()); !slices.Equal(got, []string{"bb", "cc"}) {
+			t.Errorf("All() = %v, want [bb cc]", got)
+		}
+	})
+
+	t.Run("expired items go first", func(t *testing.T) {
+		clock := newFakeClock()
+		q := NewExpiring(WithCapacity[int](2), WithClock[int](clock))
+		_ = q.EnqueueWithTTL(1, shortTTL)
+		_ = q.Enqueue(2)
+		clock.Advance(shortTTL)
+
+		if _, didEvict, err := q.EnqueuePush(3); didEvict || err != nil {
+			t.Errorf("EnqueuePush() with an expired item = (%v, %v), want (false, nil)", didEvict, err)
+		}
+	})
+}
+
+func TestApproxSize(t *testing.T) {
+	q := New[int](WithCapacity[int](6), WithCircular[int]())
+	steps := []struct {
+		name string
+		op   func()
+	}{
+		{name: "EnqueueSlice", op: func() { _, _ = q.EnqueueSlice([]int{1, 2, 3, 4, 5}) }},
+		{name: "Dequeue", op: func() { _, _ = q.Dequeue() }},
+		{name: "EnqueueFrontAll", op: func() { _ = q.EnqueueFrontAll(0, 1) }},
+		{name: "circular eviction", op: func() { _ = q.Enqueue(6) }},
+		{name: "Filter", op: func() { q.Filter(func(v int) bool { return v%2 == 0 }) }},
+		{name: "Remove", op: func() { _, _ = q.Remove(0) }},
+		{name: "DrainTo", op: func() { q.DrainTo(make([]int, 1)) }},
+		{name: "Reset", op: func() { q.Reset() }},
+	}
+
+	for _, step := range steps {
+		step.op()
+		if approx, size := q.ApproxSize(), q.Size(); approx != size {
+			t.Errorf("after %s: ApproxSize() = %d, want Size() = %d", step.name, approx, size)
+		}
+	}
+
+	t.Run("sharded and expiring", func(t *testing.T) {
+		s := NewSharded[int](2, WithCapacity[int](2), WithCircular[int]())
+		_, _ = s.EnqueueSlice([]int{1, 2, 3})
+		if approx := s.ApproxSize(); approx != 2 {
+			t.Errorf("sharded ApproxSize() = %d, want 2", approx)
+		}
+
+		clock := newFakeClock()
+		e := NewExpiring[int](WithClock[int](clock))
+		_ = e.EnqueueWithTTL(1, shortTTL)
+		_ = e.Enqueue(2)
+		clock.Advance(2 * shortTTL)
+		e.Purge()
+		if approx := e.ApproxSize(); approx != 1 {
+			t.Errorf("expiring ApproxSize() after Purge() = %d, want 1", approx)
+		}
+	})
+}
+
+func TestSizeStress(t *testing.T) {
+	const capacity = 8
+
+	// run calls write from two goroutines and check repeatedly until the
+	// writers have made writes calls between them or check fails
+	run := func(t *testing.T, writes int64, write func(), check func() bool) {
+		stop := make(chan struct{})
+		var done atomic.Int64
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						write()
+						done.Add(1)
+					}
+				}
+			}()
+		}
+		defer func() {
+			close(stop)
+			wg.Wait()
+		}()
+
+		for done.Load() < writes {
+			if !check() {
+				return
+			}
+		}
+	}
+
+	t.Run("matches locked recount", func(t *testing.T) {
+		q := newQueue[int](WithCapacity[int](capacity))
+		run(t, 10000, func() {
+			_, _ = q.EnqueueSlice([]int{1, 2, 3})
+			_, _ = q.Dequeue()
+			q.Filter(func(v int) bool { return v != 2 })
+		}, func() bool {
+			size := q.Size()
+			if size < 0 || size > capacity {
+				t.Errorf("Size() = %d, want within [0, %d]", size, capacity)
+				return false
+			}
+
+			// No write can overlap a read under the read lock
+			q.mu.RLock()
+			size, want := q.Size(), q.items.len()
+			q.mu.RUnlock()
+			if size != want {
+				t.Errorf("Size() under the read lock = %d, want recount %d", size, want)
+				return false
+			}
+			return true
+		})
+	})
+
+	t.Run("skips partial writes", func(t *testing.T) {
+		const n = 4096
+		q := New[int]()
+		src := New[int]()
+		for i := range n {
+			_ = q.Enqueue(i)
+			_ = src.Enqueue(i)
+		}
+
+		// CopyInto empties q and refills it one item at a time under one lock
+		run(t, 200, func() { _ = src.CopyInto(q) }, func() bool {
+			if size := q.Size(); size != n {
+				t.Errorf("Size() during CopyInto = %d, want %d", size, n)
+				return false
+			}
+			return true
+		})
+	})
+}
+
+func TestFlushThreshold(t *testing.T) {
+	t.Run("once per crossing", func(t *testing.T) {
+		var flushes int
+		q := New[int](WithFlushThreshold(3, func(Queue[int]) { flushes++ }))
+		_, _ = q.EnqueueSlice([]int{1, 2})
+		if flushes != 0 {
+			t.Fatalf("flushes below the threshold = %d, want 0", flushes)
+		}
+
+		_ = q.Enqueue(3)
+		_ = q.Enqueue(4)
+		if flushes != 1 {
+			t.Errorf("flushes after reaching and passing the threshold = %d, want 1", flushes)
+		}
+
+		// Staying at or above the threshold does not re-arm the callback
+		_, _ = q.Dequeue()
+		_ = q.Enqueue(5)
+		if flushes != 1 {
+			t.Errorf("flushes without dropping below the threshold = %d, want 1", flushes)
+		}
+
+		_, _ = q.Dequeue()
+		_, _ = q.Dequeue()
+		_ = q.EnqueueFrontAll(6)
+		if flushes != 2 {
+			t.Errorf("flushes after crossing again = %d, want 2", flushes)
+		}
+	})
+
+	t.Run("callback can drain", func(t *testing.T) {
+		var batches [][]int
+		q := New[int](WithFlushThreshold(2, func(q Queue[int]) {
+			batches = append(batches, q.Drain())
+		}))
+		for i := range 5 {
+			_ = q.Enqueue(i)
+		}
+		if got := fmt.Sprint(batches); got != "[[0 1] [2 3]]" {
+			t.Errorf("batches = %s, want [[0 1] [2 3]]", got)
+		}
+		if size := q.Size(); size != 1 {
+			t.Errorf("Size() = %d, want 1", size)
+		}
+	})
+
+	t.Run("callback receives the wrapper", func(t *testing.T) {
+		var got Queue[int]
+		record := WithFlushThreshold(1, func(q Queue[int]) { got = q })
+		queues := map[string]Queue[int]{
+			"blocking": NewBlocking[int](4, record),
+			"deque":    NewDeque[int](record),
+			"sharded":  NewSharded[int](2, record),
+			"expiring": NewExpiring[int](record),
+		}
+		for name, q := range queues {
+			got = nil
+			_ = q.Enqueue(1)
+			if got != q {
+				t.Errorf("%s: callback received %T, want the queue itself", name, got)
+			}
+		}
+	})
+
+	t.Run("split and merge", func(t *testing.T) {
+		var flushed []Queue[int]
+		q := New[int](WithFlushThreshold(2, func(q Queue[int]) { flushed = append(flushed, q) }))
+		_ = q.Enqueue(1)
+		other := New[int]()
+		_, _ = other.EnqueueSlice([]int{2, 3})
+		_ = q.Merge(other)
+		if len(flushed) != 1 || flushed[0] != q {
+			t.Fatalf("flushes after Merge() = %d, want 1 for the queue", len(flushed))
+		}
+
+		// The head reached the threshold while being split off, and reports
+		// it on its first operation once the caller has it
+		head, _ := q.Split(3)
+		_ = head.Enqueue(4)
+		_ = q.Enqueue(5)
+		_ = q.Enqueue(6)
+		if len(flushed) != 3 || flushed[1] != head || flushed[2] != q {
+			t.Errorf("flushes after Split() = %d, want 3 for the head then the queue", len(flushed))
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, opt := range map[string]func(){
+			"zero threshold": func() { WithFlushThreshold(0, func(Queue[int]) {}) },
+			"nil callback":   func() { WithFlushThreshold[int](1, nil) },
+			"retry":          func() { NewRetryQueue(1, WithFlushThreshold(1, func(Queue[int]) {})) },
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%s: did not panic", name)
+					}
+				}()
+				opt()
+			}()
+		}
+	})
+}
+
+// Benchmark tests
+func BenchmarkEnqueue(b *testing.B) {
+	q := New[int]()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = q.Enqueue(i)
+	}
+}
+
+func BenchmarkDequeue(b *testing.B) {
+	q := New[int]()
+	// Pre-populate queue
+	for i := 0; i < b.N; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = q.Dequeue()
+	}
+}
+
+func BenchmarkPeek(b *testing.B) {
+	q := New[int]()
+	_ = q.Enqueue(42)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = q.Peek()
+	}
+}
+
+func BenchmarkSize(b *testing.B) {
+	q := New[int]()
+	for i := 0; i < 1000; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.Size()
+	}
+}
+
+func BenchmarkMixedOperations(b *testing.B) {
+	q := New[int]()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		switch i % 4 {
+		case 0:
+			_ = q.Enqueue(i)
+		case 1:
+			_, _ = q.Dequeue()
+		case 2:
+			_, _ = q.Peek()
+		case 3:
+			q.Size()
+		}
+	}
+}
+
+func BenchmarkEnqueueFill(b *testing.B) {
+	const size = 1024
+
+	b.Run("unlimited", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q := New[int]()
+			for j := 0; j < size; j++ {
+				_ = q.Enqueue(j)
+			}
+		}
+	})
+
+	b.Run("preallocated", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q := New[int](WithCapacity[int](size))
+			for j := 0; j < size; j++ {
+				_ = q.Enqueue(j)
+			}
+		}
+	})
+}
+
+func BenchmarkEnqueueBurst(b *testing.B) {
+	const burst = 1024
+
+	b.Run("without Grow", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q := New[int]()
+			for j := 0; j < burst; j++ {
+				_ = q.Enqueue(j)
+			}
+		}
+	})
+
+	b.Run("with Grow", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q := New[int]()
+			_ = q.Grow(burst)
+			for j := 0; j < burst; j++ {
+				_ = q.Enqueue(j)
+			}
+		}
+	})
+}
+
+func BenchmarkDrain(b *testing.B) {
+	const batch = 64
+
+	b.Run("Drain", func(b *testing.B) {
+		q := New[int]()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < batch; j++ {
+				_ = q.Enqueue(j)
+			}
+			_ = q.Drain()
+		}
+	})
+
+	b.Run("DrainTo", func(b *testing.B) {
+		q := New[int]()
+		dst := make([]int, batch)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < batch; j++ {
+				_ = q.Enqueue(j)
+			}
+			_ = q.DrainTo(dst)
+		}
+	})
+}
+
+// BenchmarkAutoShrink measures bursts that fill the queue and then drain it
+// one item at a time, with and without automatic shrinking.
+func BenchmarkAutoShrink(b *testing.B) {
+	const burst = 4096
+
+	for _, tt := range []struct {
+		name string
+		opts []Option[int]
+	}{
+		{name: "off"},
+		{name: "0.25", opts: []Option[int]{WithAutoShrink[int](0.25)}},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			q := New(tt.opts...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < burst; j++ {
+					_ = q.Enqueue(j)
+				}
+				for j := 0; j < burst; j++ {
+					_, _ = q.Dequeue()
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSizeContended polls the size while other goroutines keep the
+// queue's write lock busy.
+func BenchmarkSizeContended(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		size func(Queue[int]) int
+	}{
+		{name: "Size", size: Queue[int].Size},
+		{name: "ApproxSize", size: Queue[int].ApproxSize},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			q := New[int]()
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+							_ = q.Enqueue(1)
+							_, _ = q.Dequeue()
+						}
+					}
+				}()
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_ = bm.size(q)
+				}
+			})
+			b.StopTimer()
+			close(stop)
+			wg.Wait()
+		})
 	}
 }
@@ -0,0 +1,125 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket that paces dequeues or enqueues. The bucket holds
+// burst tokens, so once it is empty consecutive calls are spaced at least
+// interval apart and a caller that was idle can catch up with at most burst
+// calls at once.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	next     time.Time // When the bucket is full again
+}
+
+// newLimiter returns a limiter that hands out perSecond tokens per second
+// from a bucket holding burst tokens.
+func newLimiter(perSecond float64, burst int) *limiter {
+	return &limiter{interval: time.Duration(float64(time.Second) / perSecond), burst: burst}
+}
+
+// clone returns a limiter with the same rate whose bucket is full. It returns
+// nil for a nil limiter, so queues without a rate limit can call it freely.
+func (l *limiter) clone() *limiter {
+	if l == nil {
+		return nil
+	}
+
+	return &limiter{interval: l.interval, burst: l.burst}
+}
+
+// reserve claims the next token and returns how long after now the caller
+// must wait before using it. Concurrent callers are handed successive tokens.
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	wait := l.delayLocked(now)
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(l.interval)
+
+	return wait
+}
+
+// delay returns how long after now the next token becomes available, without
+// claiming it, or zero if one is available now.
+func (l *limiter) delay(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.delayLocked(now)
+}
+
+// delayLocked implements delay. The caller must hold l.mu.
+func (l *limiter) delayLocked(now time.Time) time.Duration {
+	if !l.next.After(now) {
+		return 0
+	}
+
+	// A token is available while the bucket would fill within burst-1 intervals
+	return max(l.next.Sub(now)-time.Duration(l.burst-1)*l.interval, 0)
+}
+
+// pace waits for a token from l, if there is a limiter, counting the caller
+// in consumers while it waits. The token is used up even if ctx is done
+// first, in which case ctx.Err() is returned.
+func pace(ctx context.Context, l *limiter, clock Clock, consumers *gauge, blocked *bool) error {
+	if l == nil {
+		return nil
+	}
+	d := l.reserve(clock.Now())
+	if d <= 0 {
+		return nil
+	}
+
+	consumers.enter(blocked)
+
+	return sleep(ctx, clock, d)
+}
+
+// sleep waits for d to pass on clock, returning ctx.Err() if ctx is done
+// first.
+func sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	ready := make(chan struct{})
+	timer := clock.AfterFunc(d, func() { close(ready) })
+	defer timer.Stop()
+
+	return waitFor(ctx, ready)
+}
+
+// throttled returns how long until q may take a token for an enqueue, or zero
+// if one is available now or enqueues are not rate limited. The caller must
+// hold the write lock.
+func (q *queue[T]) throttled() time.Duration {
+	if q.enqueueLimit == nil {
+		return 0
+	}
+
+	return q.enqueueLimit.delay(q.clock.Now())
+}
+
+// admit takes the enqueue token that throttled reported available, if
+// enqueues are rate limited. The caller must hold the write lock.
+func (q *queue[T]) admit() {
+	if q.enqueueLimit != nil {
+		q.enqueueLimit.reserve(q.clock.Now())
+	}
+}
+
+// unthrottled lifts q's enqueue rate limit, if it has one, until the returned
+// function is called, so that a queue being restored can be filled at once.
+// The bucket is left full. q must not yet be shared.
+func unthrottled[T any](q Queue[T]) (restore func()) {
+	inner := q.(*queue[T])
+	l := inner.enqueueLimit
+	inner.enqueueLimit = nil
+
+	return func() { inner.enqueueLimit = l }
+}
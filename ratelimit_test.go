@@ -0,0 +1,258 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func(opts ...Option[int]) Queue[int]
+	}{
+		{name: "queue", newFn: func(opts ...Option[int]) Queue[int] { return New(opts...) }},
+		{name: "blocking", newFn: func(opts ...Option[int]) Queue[int] { return NewBlocking(100, opts...) }},
+		{name: "sharded", newFn: func(opts ...Option[int]) Queue[int] { return NewSharded(2, opts...) }},
+		{name: "expiring", newFn: func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			q := tt.newFn(WithClock[int](clock), WithRateLimit[int](5))
+			for i := range 20 {
+				_ = q.Enqueue(i)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var dequeued atomic.Int32
+			go func() {
+				for range q.Channel(ctx) {
+					dequeued.Add(1)
+				}
+			}()
+
+			// Tokens arrive every 200ms, so a second fits five dequeues
+			for range 99 {
+				awaitTimers(t, clock, 1)
+				clock.Advance(10 * time.Millisecond)
+			}
+			awaitTimers(t, clock, 1)
+			awaitCount(t, "dequeues within a second", func() int { return int(dequeued.Load()) }, 5)
+			if n := q.BlockedConsumers(); n != 1 {
+				t.Errorf("BlockedConsumers() while waiting for a token = %d, want 1", n)
+			}
+
+			clock.Advance(10 * time.Millisecond)
+			awaitCount(t, "dequeues after a second", func() int { return int(dequeued.Load()) }, 6)
+		})
+	}
+}
+
+func TestRateLimitCancelled(t *testing.T) {
+	clock := newFakeClock()
+	q := New(WithClock[int](clock), WithRateLimit[int](1))
+	_, _ = q.EnqueueSlice([]int{1, 2, 3})
+
+	if val, err := q.DequeueWait(context.Background()); val != 1 || err != nil {
+		t.Fatalf("DequeueWait() with a full bucket = (%d, %v), want (1, nil)", val, err)
+	}
+
+	// The next token is a second away, so the call waits for it
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := q.DequeueWait(ctx)
+		done <- err
+	}()
+	awaitTimers(t, clock, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("DequeueWait() cancelled while waiting for a token = %v, want %v", err, context.Canceled)
+	}
+	if size := q.Size(); size != 2 {
+		t.Errorf("Size after cancelled DequeueWait() = %d, want 2", size)
+	}
+
+	// Non-blocking dequeues are not paced
+	if val, err := q.Dequeue(); val != 2 || err != nil {
+		t.Errorf("Dequeue() = (%d, %v), want (2, nil)", val, err)
+	}
+}
+
+func TestWithRateLimitPanics(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithRateLimit(%v) did not panic", rate)
+				}
+			}()
+			WithRateLimit[int](rate)
+		}()
+	}
+}
+
+func TestEnqueueRateLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func(opts ...Option[int]) Queue[int]
+	}{
+		{name: "queue", newFn: func(opts ...Option[int]) Queue[int] { return New(opts...) }},
+		{name: "priority", newFn: func(opts ...Option[int]) Queue[int] { return NewPriority(intLess, opts...) }},
+		{name: "expiring", newFn: func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			q := tt.newFn(WithClock[int](clock), WithEnqueueRateLimit[int](10, 3))
+
+			// A full bucket allows a burst of three
+			if n, err := q.EnqueueSlice([]int{1, 2, 3, 4}); n != 3 || !errors.Is(err, ErrRateLimited) {
+				t.Fatalf("EnqueueSlice() of four with a burst of three = (%d, %v), want (3, %v)", n, err, ErrRateLimited)
+			}
+			if q.TryEnqueue(5) {
+				t.Error("TryEnqueue() with an empty bucket = true, want false")
+			}
+			if st := q.Stats(); st.Rejected != 0 {
+				t.Errorf("Stats().Rejected = %d, want 0 for rate-limited items", st.Rejected)
+			}
+
+			// Tokens then arrive every 100ms
+			for i := range 10 {
+				clock.Advance(100 * time.Millisecond)
+				if err := q.Enqueue(i); err != nil {
+					t.Fatalf("Enqueue() after token %d = %v, want nil", i, err)
+				}
+				if err := q.Enqueue(i); !errors.Is(err, ErrRateLimited) {
+					t.Fatalf("second Enqueue() after token %d = %v, want %v", i, err, ErrRateLimited)
+				}
+			}
+
+			// An idle producer catches up with at most a burst
+			clock.Advance(time.Minute)
+			for i := range 3 {
+				if err := q.Enqueue(i); err != nil {
+					t.Fatalf("Enqueue() %d after idling = %v, want nil", i, err)
+				}
+			}
+			if err := q.Enqueue(3); !errors.Is(err, ErrRateLimited) {
+				t.Errorf("Enqueue() past the burst = %v, want %v", err, ErrRateLimited)
+			}
+			if size := q.Size(); size != 16 {
+				t.Errorf("Size() = %d, want 16", size)
+			}
+		})
+	}
+}
+
+func TestEnqueueRateLimitWait(t *testing.T) {
+	for name, newFn := range map[string]func(opts ...Option[int]) Queue[int]{
+		"queue":    func(opts ...Option[int]) Queue[int] { return New(opts...) },
+		"expiring": func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			q := newFn(WithClock[int](clock), WithEnqueueRateLimit[int](2, 1))
+			if err := q.EnqueueWait(context.Background(), 1); err != nil {
+				t.Fatalf("EnqueueWait() with a full bucket = %v, want nil", err)
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- q.EnqueueWait(context.Background(), 2) }()
+			awaitTimers(t, clock, 1)
+			if n := q.BlockedProducers(); n != 1 {
+				t.Errorf("BlockedProducers() while waiting for a token = %d, want 1", n)
+			}
+			if size := q.Size(); size != 1 {
+				t.Errorf("Size() while waiting for a token = %d, want 1", size)
+			}
+
+			clock.Advance(500 * time.Millisecond)
+			if err := <-done; err != nil {
+				t.Errorf("EnqueueWait() once a token arrived = %v, want nil", err)
+			}
+			if size := q.Size(); size != 2 {
+				t.Errorf("Size() after the wait = %d, want 2", size)
+			}
+
+			// Cancelling the wait leaves the queue unchanged
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() { done <- q.EnqueueWait(ctx, 3) }()
+			awaitTimers(t, clock, 1)
+			cancel()
+			if err := <-done; !errors.Is(err, context.Canceled) {
+				t.Errorf("EnqueueWait() cancelled while waiting for a token = %v, want %v", err, context.Canceled)
+			}
+			if size := q.Size(); size != 2 {
+				t.Errorf("Size() after cancelled EnqueueWait() = %d, want 2", size)
+			}
+		})
+	}
+}
+
+func TestEnqueueRateLimitCircular(t *testing.T) {
+	clock := newFakeClock()
+	q := New(WithClock[int](clock), WithCapacity[int](2), WithCircular[int](), WithEnqueueRateLimit[int](1, 2))
+	_, _ = q.EnqueueSlice([]int{1, 2})
+
+	// A refused item does not evict the front item
+	if err := q.Enqueue(3); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Enqueue() = %v, want %v", err, ErrRateLimited)
+	}
+	if _, didEvict, err := q.EnqueuePush(3); didEvict || !errors.Is(err, ErrRateLimited) {
+		t.Errorf("EnqueuePush() = (%v, %v), want (false, %v)", didEvict, err, ErrRateLimited)
+	}
+	if front, _ := q.Peek(); front != 1 {
+		t.Errorf("Peek() = %d, want 1", front)
+	}
+}
+
+func TestEnqueueRateLimitLoad(t *testing.T) {
+	src := New[int]()
+	_, _ = src.EnqueueSlice([]int{1, 2, 3, 4})
+	var buf bytes.Buffer
+	if err := src.PersistTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Loading is not limited, and leaves the bucket full
+	clock := newFakeClock()
+	q, err := LoadFrom(&buf, WithClock[int](clock), WithEnqueueRateLimit[int](1, 2))
+	if err != nil {
+		t.Fatalf("LoadFrom() = %v, want nil", err)
+	}
+	if n, err := q.EnqueueSlice([]int{5, 6, 7}); n != 2 || !errors.Is(err, ErrRateLimited) {
+		t.Errorf("EnqueueSlice() after LoadFrom() = (%d, %v), want (2, %v)", n, err, ErrRateLimited)
+	}
+}
+
+func TestWithEnqueueRateLimitPanics(t *testing.T) {
+	for _, tt := range []struct {
+		rate  float64
+		burst int
+	}{{0, 1}, {-1, 1}, {math.NaN(), 1}, {math.Inf(1), 1}, {1, 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithEnqueueRateLimit(%v, %d) did not panic", tt.rate, tt.burst)
+				}
+			}()
+			WithEnqueueRateLimit[int](tt.rate, tt.burst)
+		}()
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewSharded() with WithEnqueueRateLimit did not panic")
+		}
+	}()
+	NewSharded(2, WithEnqueueRateLimit[int](1, 1))
+}
@@ -0,0 +1,116 @@
+package queue
+
+// Reserver is implemented by queues that let a producer claim room for items
+// before it has them. Queues created by New, NewPriority, NewBlocking,
+// NewDeque and NewUnsafe implement it.
+//
+// Reserved slots count against the capacity until they are committed or
+// cancelled, so other producers, Remaining and EnqueueWait see the queue as
+// fuller, and two producers cannot both be promised the last slot. They are
+// not items: Size does not count them and consumers cannot take them.
+// Reservations belong to the queue rather than to a caller, so a producer
+// must commit or cancel exactly the slots it reserved.
+//
+// Example:
+//
+//	r := q.(queue.Reserver[Job])
+//	if !r.Reserve(len(specs)) {
+//		return errBusy
+//	}
+//	jobs, err := build(specs)
+//	if err != nil {
+//		r.CancelReservation(len(specs))
+//		return err
+//	}
+//	return r.CommitReserved(jobs...)
+type Reserver[T any] interface {
+	// Reserve atomically reserves n slots and reports whether they were
+	// reserved. It returns false, reserving nothing, if the slots do not fit
+	// within the capacity, if n < 0, or if the queue is closed. A circular
+	// queue does not evict items to make room for a reservation. The byte
+	// limit is not reserved; it is checked when the items are committed.
+	Reserve(n int) bool
+
+	// CommitReserved adds vals to the back of the queue, in order, using one
+	// reserved slot for each. Either every item is added or none is: it
+	// returns ErrOverflow if fewer than len(vals) slots are reserved or the
+	// items exceed the byte limit, ErrNilValue, ErrInvalidItem or ErrDuplicate
+	// as EnqueueFrontAll does, or ErrClosed if the queue is closed. On error
+	// the reservation is kept, and the items are neither counted as rejected
+	// nor forwarded to the dead-letter queue.
+	CommitReserved(vals ...T) error
+
+	// CancelReservation releases n reserved slots, or every reserved slot if
+	// fewer are reserved, waking producers waiting for room.
+	CancelReservation(n int)
+}
+
+func (q *queue[T]) Reserve(n int) bool {
+	if n < 0 {
+		return false
+	}
+
+	q.mu.Lock()
+	defer q.unlock()
+
+	if q.closed || !q.fits(n) {
+		return false
+	}
+	q.reserved += n
+
+	return true
+}
+
+func (q *queue[T]) CommitReserved(vals ...T) error {
+	q.mu.Lock()
+	err := q.commitReserved(vals)
+	q.unlock()
+
+	if err != nil {
+		return err
+	}
+	for _, val := range vals {
+		runHooks(q.onEnqueue, val)
+	}
+
+	return nil
+}
+
+// commitReserved implements CommitReserved. The caller must hold the write
+// lock.
+func (q *queue[T]) commitReserved(vals []T) error {
+	if q.closed {
+		return ErrClosed
+	}
+	bytes := 0
+	for _, val := range vals {
+		if err := q.invalid(val); err != nil {
+			return err
+		}
+		bytes += q.itemBytes(val)
+	}
+	if q.duplicates(vals, true) {
+		return ErrDuplicate
+	}
+	if len(vals) > q.reserved || !q.fitsBytes(bytes) {
+		return ErrOverflow
+	}
+
+	q.reserved -= len(vals)
+	for _, val := range vals {
+		q.add(val)
+	}
+
+	return nil
+}
+
+func (q *queue[T]) CancelReservation(n int) {
+	if n <= 0 {
+		return
+	}
+
+	q.mu.Lock()
+	q.reserved -= min(n, q.reserved)
+	q.notFull.broadcast()
+	q.unlock()
+}
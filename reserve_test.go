@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReserve(t *testing.T) {
+	var enqueued []int
+	q := New(WithCapacity[int](4), WithOnEnqueue(func(v int) { enqueued = append(enqueued, v) }))
+	r := q.(Reserver[int])
+	_ = q.Enqueue(1)
+
+	if !r.Reserve(2) {
+		t.Fatal("Reserve(2) with three free slots = false, want true")
+	}
+
+	// Reserved slots count against the capacity but are not items
+	if n := q.Remaining(); n != 1 {
+		t.Errorf("Remaining() with two reserved slots = %d, want 1", n)
+	}
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size() with two reserved slots = %d, want 1", size)
+	}
+	if r.Reserve(2) {
+		t.Error("Reserve(2) with one unreserved slot = true, want false")
+	}
+	_ = q.Enqueue(2)
+	if err := q.Enqueue(3); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Enqueue() into reserved room = %v, want %v", err, ErrOverflow)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Errorf("CheckInvariants() with reserved slots = %v", err)
+	}
+
+	if err := r.CommitReserved(4, 5, 6); !errors.Is(err, ErrOverflow) {
+		t.Errorf("CommitReserved() of more items than reserved = %v, want %v", err, ErrOverflow)
+	}
+	if err := r.CommitReserved(4, 5); err != nil {
+		t.Errorf("CommitReserved(4, 5) = %v, want nil", err)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{1, 2, 4, 5}) {
+		t.Errorf("All() after CommitReserved() = %v, want [1 2 4 5]", got)
+	}
+	if !slices.Equal(enqueued, []int{1, 2, 4, 5}) {
+		t.Errorf("enqueue hooks saw %v, want [1 2 4 5]", enqueued)
+	}
+	if err := r.CommitReserved(6); !errors.Is(err, ErrOverflow) {
+		t.Errorf("CommitReserved() once the reservation is used = %v, want %v", err, ErrOverflow)
+	}
+	if s := q.Stats(); s.Rejected != 1 {
+		t.Errorf("Stats().Rejected = %d, want 1 from Enqueue() alone", s.Rejected)
+	}
+
+	if r.Reserve(-1) {
+		t.Error("Reserve(-1) = true, want false")
+	}
+	_ = q.Close()
+	if r.Reserve(0) {
+		t.Error("Reserve(0) on a closed queue = true, want false")
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReserveCancel(t *testing.T) {
+	q := New(WithCapacity[int](2), WithDedup(func(v int) int { return v }))
+	r := q.(Reserver[int])
+	_ = q.Enqueue(1)
+	r.Reserve(1)
+
+	// A failed commit keeps the reservation
+	if err := r.CommitReserved(1); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("CommitReserved() of a queued key = %v, want %v", err, ErrDuplicate)
+	}
+	if n := q.Remaining(); n != 0 {
+		t.Errorf("Remaining() after a failed commit = %d, want 0", n)
+	}
+
+	// Cancelling wakes a producer waiting for room
+	done := make(chan error, 1)
+	go func() { done <- q.EnqueueWait(context.Background(), 2) }()
+	awaitCount(t, "BlockedProducers()", q.BlockedProducers, 1)
+	r.CancelReservation(5) // Releases the one reserved slot
+	if err := <-done; err != nil {
+		t.Errorf("EnqueueWait() after CancelReservation() = %v, want nil", err)
+	}
+	if err := r.CommitReserved(3); !errors.Is(err, ErrOverflow) {
+		t.Errorf("CommitReserved() after CancelReservation() = %v, want %v", err, ErrOverflow)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReserveContention(t *testing.T) {
+	q := New(WithCapacity[int](10))
+	r := q.(Reserver[int])
+
+	var wg sync.WaitGroup
+	var granted atomic.Int32
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !r.Reserve(1) {
+				return
+			}
+			granted.Add(1)
+			if err := r.CommitReserved(i); err != nil {
+				t.Errorf("CommitReserved(%d) = %v, want nil", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Exactly the capacity is handed out, and every granted slot is filled
+	if n := granted.Load(); n != 10 {
+		t.Errorf("granted %d reservations, want 10", n)
+	}
+	if size := q.Size(); size != 10 {
+		t.Errorf("Size() = %d, want 10", size)
+	}
+	if err := CheckInvariants(q); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,180 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// RetryQueue is a task queue whose items are redelivered until a consumer
+// acknowledges them, up to a limited number of retries.
+//
+// Dequeue returns a Delivery. Calling Ack on it settles the item; calling Nack
+// requeues it for another attempt, or dead-letters it once it has been
+// retried maxRetries times. Dead-lettered items are passed to the hooks
+// registered with WithOnDeadLetter and removed from the queue.
+//
+// An item is removed from the queue while it is being delivered, so it is
+// lost if its consumer neither acks nor nacks it; there is no visibility
+// timeout. A RetryQueue is safe for concurrent use.
+type RetryQueue[T any] struct {
+	q            *queue[attempt[T]]
+	maxRetries   int
+	requeueFront bool
+	onDeadLetter []func(T)
+}
+
+// attempt is an item of a retry queue with the number of times it has been
+// delivered.
+type attempt[T any] struct {
+	val   T
+	count int
+}
+
+// Delivery is an item handed out by RetryQueue.Dequeue, to be settled with
+// Ack or Nack. Only the first call to either has an effect.
+type Delivery[T any] struct {
+	// Value is the item being delivered.
+	Value T
+
+	// Attempt is 1 for the first delivery of the item and increases by one
+	// with every redelivery.
+	Attempt int
+
+	rq      *RetryQueue[T]
+	settled atomic.Bool
+}
+
+// NewRetryQueue creates a retry queue that redelivers each item up to
+// maxRetries times after its first delivery.
+//
+// Options configure the underlying queue as for New. Enqueue and dequeue
+// hooks run for redeliveries too. Nacked items go to the back of the queue
+// unless WithRequeueFront is given.
+//
+// Example:
+//
+//	q := queue.NewRetryQueue[Job](3, queue.WithOnDeadLetter(func(job Job) {
+//		log.Printf("giving up on %v", job)
+//	}))
+//	d, err := q.Dequeue()
+//	if err == nil {
+//		if process(d.Value) != nil {
+//			d.Nack() // Retried up to 3 times, then dead-lettered
+//		} else {
+//			d.Ack()
+//		}
+//	}
+//
+// Panics if maxRetries < 0 or if WithWAL or WithFlushThreshold is given.
+func NewRetryQueue[T any](maxRetries int, opts ...Option[T]) *RetryQueue[T] {
+	if maxRetries < 0 {
+		panic("cannot specify negative max retries")
+	}
+	base := configure(opts)
+	if base.wal != nil {
+		panic("cannot use a write-ahead log with a retry queue")
+	}
+	if base.onFlush != nil {
+		panic("cannot use a flush threshold with a retry queue")
+	}
+
+	return &RetryQueue[T]{
+		q: adapt(base, func(item *attempt[T]) *T {
+			return &item.val
+		}),
+		maxRetries:   maxRetries,
+		requeueFront: base.requeueFront,
+		onDeadLetter: base.onDeadLetter,
+	}
+}
+
+// Enqueue adds an item to the back of the queue for its first delivery.
+// Returns ErrOverflow if the queue is at capacity, or ErrClosed if it is closed.
+func (r *RetryQueue[T]) Enqueue(val T) error {
+	return r.q.Enqueue(attempt[T]{val: val})
+}
+
+// Dequeue removes the front item and returns it as a Delivery.
+// Returns ErrUnderflow if the queue is empty.
+func (r *RetryQueue[T]) Dequeue() (*Delivery[T], error) {
+	item, err := r.q.Dequeue()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.deliver(item), nil
+}
+
+// DequeueWait is like Dequeue but blocks while the queue is empty, as
+// Queue.DequeueWait does.
+func (r *RetryQueue[T]) DequeueWait(ctx context.Context) (*Delivery[T], error) {
+	item, err := r.q.DequeueWait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.deliver(item), nil
+}
+
+func (r *RetryQueue[T]) deliver(item attempt[T]) *Delivery[T] {
+	return &Delivery[T]{Value: item.val, Attempt: item.count + 1, rq: r}
+}
+
+// Size returns the number of items waiting for delivery, not counting items
+// delivered but not yet settled.
+func (r *RetryQueue[T]) Size() int {
+	return r.q.Size()
+}
+
+// Close stops the queue accepting new items. Items nacked after Close are
+// dead-lettered, since they can no longer be requeued.
+func (r *RetryQueue[T]) Close() error {
+	return r.q.Close()
+}
+
+// Stats returns a snapshot of the queue's operation counters. Redeliveries
+// count as enqueues and dequeues.
+func (r *RetryQueue[T]) Stats() Stats {
+	return r.q.Stats()
+}
+
+// Ack settles the delivery as processed.
+func (d *Delivery[T]) Ack() {
+	d.settled.Store(true)
+}
+
+// Nack settles the delivery as failed, requeueing the item for another
+// attempt or, if it has already been retried the maximum number of times,
+// dead-lettering it.
+//
+// If the item cannot be requeued it is dead-lettered too, and Nack returns
+// ErrOverflow or ErrClosed.
+func (d *Delivery[T]) Nack() error {
+	if d.settled.Swap(true) {
+		return nil
+	}
+
+	r := d.rq
+	if d.Attempt > r.maxRetries {
+		r.deadLetter(d.Value)
+		return nil
+	}
+
+	item := attempt[T]{val: d.Value, count: d.Attempt}
+	var err error
+	if r.requeueFront {
+		err = r.q.EnqueueFrontAll(item)
+	} else {
+		err = r.q.Enqueue(item)
+	}
+	if err != nil {
+		r.deadLetter(d.Value)
+	}
+
+	return err
+}
+
+// deadLetter runs the dead-letter hooks for val.
+func (r *RetryQueue[T]) deadLetter(val T) {
+	runHooks(r.onDeadLetter, val)
+}
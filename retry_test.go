@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRetryQueueDeadLetter(t *testing.T) {
+	var dead []string
+	q := NewRetryQueue[string](2, WithOnDeadLetter(func(s string) { dead = append(dead, s) }))
+	_ = q.Enqueue("flaky")
+
+	// Every delivery fails: the first attempt plus two retries
+	for want := 1; want <= 3; want++ {
+		d, err := q.Dequeue()
+		if err != nil {
+			t.Fatalf("Dequeue() on attempt %d = %v, want nil", want, err)
+		}
+		if d.Value != "flaky" || d.Attempt != want {
+			t.Errorf("Dequeue() = %q attempt %d, want %q attempt %d", d.Value, d.Attempt, "flaky", want)
+		}
+		if len(dead) != 0 {
+			t.Fatalf("dead-letter hook fired after %d attempts, want 3", want-1)
+		}
+		if err := d.Nack(); err != nil {
+			t.Errorf("Nack() = %v, want nil", err)
+		}
+	}
+
+	if !slices.Equal(dead, []string{"flaky"}) {
+		t.Errorf("dead-letter hook saw %v, want [flaky]", dead)
+	}
+	if _, err := q.Dequeue(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("Dequeue() after dead-lettering = %v, want %v", err, ErrUnderflow)
+	}
+}
+
+func TestRetryQueueAck(t *testing.T) {
+	var dead []int
+	q := NewRetryQueue[int](0, WithOnDeadLetter(func(v int) { dead = append(dead, v) }))
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+
+	d, _ := q.Dequeue()
+	d.Ack()
+	// Settling twice has no effect
+	if err := d.Nack(); err != nil {
+		t.Errorf("Nack() after Ack() = %v, want nil", err)
+	}
+
+	// With no retries the first failure is final
+	d, _ = q.Dequeue()
+	_ = d.Nack()
+	_ = d.Nack()
+	if !slices.Equal(dead, []int{2}) {
+		t.Errorf("dead-letter hook saw %v, want [2]", dead)
+	}
+	if size := q.Size(); size != 0 {
+		t.Errorf("Size = %d, want 0", size)
+	}
+}
+
+func TestRetryQueueRequeuePosition(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option[int]
+		want []int
+	}{
+		{name: "back", want: []int{2, 3, 1}},
+		{name: "front", opts: []Option[int]{WithRequeueFront[int]()}, want: []int{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewRetryQueue(1, tt.opts...)
+			for i := 1; i <= 3; i++ {
+				_ = q.Enqueue(i)
+			}
+			d, _ := q.Dequeue()
+			_ = d.Nack()
+
+			var got []int
+			for {
+				d, err := q.Dequeue()
+				if err != nil {
+					break
+				}
+				got = append(got, d.Value)
+				d.Ack()
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("delivery order after Nack() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryQueueRequeueFails(t *testing.T) {
+	var dead []int
+	q := NewRetryQueue[int](5, WithCapacity[int](1), WithOnDeadLetter(func(v int) { dead = append(dead, v) }))
+	_ = q.Enqueue(1)
+	d, _ := q.Dequeue()
+	_ = q.Enqueue(2)
+
+	// The item cannot go back into a full queue, so it is dead-lettered
+	if err := d.Nack(); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Nack() into full queue = %v, want %v", err, ErrOverflow)
+	}
+	d, _ = q.Dequeue()
+	_ = q.Close()
+	if err := d.Nack(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Nack() into closed queue = %v, want %v", err, ErrClosed)
+	}
+	if !slices.Equal(dead, []int{1, 2}) {
+		t.Errorf("dead-letter hook saw %v, want [1 2]", dead)
+	}
+}
+
+func TestNewRetryQueuePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewRetryQueue(-1) did not panic")
+		}
+	}()
+	NewRetryQueue[int](-1)
+}
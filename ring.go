@@ -0,0 +1,192 @@
+package queue
+
+// ring is a circular buffer holding items in FIFO order.
+//
+// Logical index 0 is the front of the queue. The buffer never grows on its
+// own; the owning queue decides when and how far to resize it. A ring is not
+// safe for concurrent use and relies on the owning queue for locking.
+type ring[T any] struct {
+	buf   []T
+	head  int
+	count int
+}
+
+func newRing[T any](size int) ring[T] {
+	return ring[T]{buf: make([]T, size)}
+}
+
+// len returns the number of items held.
+func (r *ring[T]) len() int {
+	return r.count
+}
+
+// cap returns the number of items the buffer can hold without resizing.
+func (r *ring[T]) cap() int {
+	return len(r.buf)
+}
+
+// full reports whether the buffer has no free slots.
+func (r *ring[T]) full() bool {
+	return r.count == len(r.buf)
+}
+
+// index maps logical index i to its physical position in buf.
+func (r *ring[T]) index(i int) int {
+	i += r.head
+	if i >= len(r.buf) {
+		i -= len(r.buf)
+	}
+	return i
+}
+
+// at returns the item at logical index i.
+func (r *ring[T]) at(i int) T {
+	return r.buf[r.index(i)]
+}
+
+// set replaces the item at logical index i.
+func (r *ring[T]) set(i int, val T) {
+	r.buf[r.index(i)] = val
+}
+
+// pushBack appends val after the last item. The buffer must not be full.
+func (r *ring[T]) pushBack(val T) {
+	r.buf[r.index(r.count)] = val
+	r.count++
+}
+
+// pushFront inserts val before the first item. The buffer must not be full.
+func (r *ring[T]) pushFront(val T) {
+	r.head = (r.head - 1 + len(r.buf)) % len(r.buf)
+	r.buf[r.head] = val
+	r.count++
+}
+
+// popFront removes and returns the front item. The buffer must not be empty.
+func (r *ring[T]) popFront() T {
+	var zero T
+	val := r.buf[r.head]
+	r.buf[r.head] = zero
+	r.head = r.index(1)
+	r.count--
+	if r.count == 0 {
+		r.head = 0
+	}
+	return val
+}
+
+// front returns up to n items from the front as a slice of the buffer, which
+// stops short of n where the items wrap around to the start of the buffer.
+func (r *ring[T]) front(n int) []T {
+	end := min(r.head+min(n, r.count), len(r.buf))
+	return r.buf[r.head:end]
+}
+
+// truncate drops every item from logical index n onwards, zeroing the
+// vacated slots so they can be garbage collected.
+func (r *ring[T]) truncate(n int) {
+	var zero T
+	for i := n; i < r.count; i++ {
+		r.buf[r.index(i)] = zero
+	}
+	r.count = n
+	if r.count == 0 {
+		r.head = 0
+	}
+}
+
+// remove removes and returns the item at logical index i, shifting whichever
+// side of it is shorter to close the gap. The order of the other items is
+// preserved and the vacated slot is zeroed.
+func (r *ring[T]) remove(i int) T {
+	val := r.at(i)
+	if i < r.count/2 {
+		// Shift the items before i one slot towards the back
+		for j := i; j > 0; j-- {
+			r.set(j, r.at(j-1))
+		}
+		r.popFront()
+		return val
+	}
+
+	// Shift the items after i one slot towards the front
+	for j := i; j < r.count-1; j++ {
+		r.set(j, r.at(j+1))
+	}
+	r.truncate(r.count - 1)
+
+	return val
+}
+
+// reverse reverses the order of the items in place.
+func (r *ring[T]) reverse() {
+	for i, j := 0, r.count-1; i < j; i, j = i+1, j-1 {
+		a, b := r.index(i), r.index(j)
+		r.buf[a], r.buf[b] = r.buf[b], r.buf[a]
+	}
+}
+
+// rotate moves the first k items to the back, preserving their order. A
+// negative k moves the last -k items to the front instead.
+func (r *ring[T]) rotate(k int) {
+	if r.count == 0 {
+		return
+	}
+	k %= r.count
+	if k < 0 {
+		k += r.count
+	}
+	if k == 0 {
+		return
+	}
+
+	// With no free slots the items are already laid out cyclically, so
+	// moving the head is enough
+	if r.full() {
+		r.head = r.index(k)
+		return
+	}
+
+	var zero T
+	if k <= r.count/2 {
+		for ; k > 0; k-- {
+			val := r.buf[r.head]
+			r.buf[r.head] = zero
+			r.head = r.index(1)
+			r.buf[r.index(r.count-1)] = val
+		}
+		return
+	}
+	for k = r.count - k; k > 0; k-- {
+		last := r.index(r.count - 1)
+		val := r.buf[last]
+		r.buf[last] = zero
+		r.head = (r.head - 1 + len(r.buf)) % len(r.buf)
+		r.buf[r.head] = val
+	}
+}
+
+// copyTo copies items in FIFO order into dst and returns the number copied.
+func (r *ring[T]) copyTo(dst []T) int {
+	n := min(len(dst), r.count)
+	if n == 0 {
+		return 0
+	}
+
+	// Items are stored in at most two contiguous runs
+	end := r.head + n
+	if end <= len(r.buf) {
+		return copy(dst, r.buf[r.head:end])
+	}
+	k := copy(dst, r.buf[r.head:])
+	return k + copy(dst[k:n], r.buf[:end-len(r.buf)])
+}
+
+// resize reallocates the buffer to size slots, moving the front to index 0.
+// size must be at least the number of items held.
+func (r *ring[T]) resize(size int) {
+	buf := make([]T, size)
+	r.copyTo(buf)
+	r.buf = buf
+	r.head = 0
+}
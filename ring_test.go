@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRingWraparound(t *testing.T) {
+	r := newRing[int](4)
+
+	// Advance the head so subsequent pushes wrap around the end of buf
+	for i := 1; i <= 3; i++ {
+		r.pushBack(i)
+	}
+	r.popFront()
+	r.popFront()
+	for i := 4; i <= 6; i++ {
+		r.pushBack(i)
+	}
+
+	if !r.full() {
+		t.Fatalf("full() = false, want true (len=%d, cap=%d)", r.len(), r.cap())
+	}
+
+	want := []int{3, 4, 5, 6}
+	for i, expected := range want {
+		if v := r.at(i); v != expected {
+			t.Errorf("at(%d) = %d, want %d", i, v, expected)
+		}
+	}
+
+	got := make([]int, 4)
+	if n := r.copyTo(got); n != 4 {
+		t.Errorf("copyTo() = %d, want 4", n)
+	}
+	for i, expected := range want {
+		if got[i] != expected {
+			t.Errorf("copyTo() at position %d = %d, want %d", i, got[i], expected)
+		}
+	}
+}
+
+func TestRingResizePreservesOrder(t *testing.T) {
+	r := newRing[int](3)
+	r.pushBack(1)
+	r.pushBack(2)
+	r.popFront()
+	r.pushBack(3)
+	r.pushBack(4) // Wraps to index 0
+
+	r.resize(8)
+
+	if r.head != 0 {
+		t.Errorf("head after resize = %d, want 0", r.head)
+	}
+	for i, expected := range []int{2, 3, 4} {
+		if v := r.at(i); v != expected {
+			t.Errorf("at(%d) after resize = %d, want %d", i, v, expected)
+		}
+	}
+}
+
+func TestRingPushFront(t *testing.T) {
+	r := newRing[int](4)
+	r.pushBack(3)
+
+	// Pushing before index 0 wraps the head to the end of buf
+	r.pushFront(2)
+	r.pushFront(1)
+	r.pushBack(4)
+
+	if r.head != 2 {
+		t.Errorf("head = %d, want 2", r.head)
+	}
+	checkRingState(t, 0, &r, []int{1, 2, 3, 4})
+}
+
+func TestCountInvariant(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	q := newQueue[int](WithCapacity[int](64))
+	var model []int
+
+	for step := 0; step < 10000; step++ {
+		switch op := rng.Intn(10); {
+		case op < 5:
+			v := step + 1
+			if q.Enqueue(v) == nil {
+				model = append(model, v)
+			}
+		case op < 9:
+			if _, err := q.Dequeue(); err == nil {
+				model = model[1:]
+			}
+		default:
+			q.Filter(func(v int) bool { return v%3 != 0 })
+			kept := model[:0]
+			for _, v := range model {
+				if v%3 != 0 {
+					kept = append(kept, v)
+				}
+			}
+			model = kept
+		}
+
+		checkRingState(t, step, &q.items, model)
+	}
+}
+
+// checkRingState reports whether r holds exactly want in FIFO order and
+// every slot outside the logical range is zeroed.
+func checkRingState(t *testing.T, step int, r *ring[int], want []int) {
+	t.Helper()
+
+	if r.count != len(want) {
+		t.Fatalf("step %d: count = %d, want %d", step, r.count, len(want))
+	}
+	for i, expected := range want {
+		if v := r.at(i); v != expected {
+			t.Fatalf("step %d: at(%d) = %d, want %d", step, i, v, expected)
+		}
+	}
+	for i := r.count; i < r.cap(); i++ {
+		if v := r.at(i); v != 0 {
+			t.Fatalf("step %d: unused slot %d = %d, want 0", step, i, v)
+		}
+	}
+}
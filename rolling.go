@@ -0,0 +1,52 @@
+package queue
+
+import "time"
+
+// Rolling is a queue that only retains the items enqueued within a trailing
+// time window, such as the requests of the last minute for a rate monitor.
+//
+// It is an Expiring queue whose items are all given the window as their TTL,
+// unless EnqueueWithTTL gives them one of their own. Stale items are discarded
+// by Dequeue and Peek as in any expiring queue, and also by Size and
+// Remaining, so that they count only the items within the window.
+type Rolling[T any] interface {
+	Expiring[T]
+
+	// Prune discards every item older than the window and returns the number
+	// discarded. It is the same as Purge.
+	Prune() int
+}
+
+// NewRolling creates a queue that retains items for window after they are
+// enqueued, stamping each with the time of the queue's Clock. Stale items are
+// reported to the hooks registered with WithOnExpire and counted in
+// Stats.Expired, as in a queue created by NewExpiring.
+//
+// Example:
+//
+//	q := queue.NewRolling[Request](time.Minute)
+//	q.Enqueue(req)
+//	perMinute := q.Size() // Requests enqueued within the last minute
+//
+// Panics if window <= 0 or if WithWAL is given.
+func NewRolling[T any](window time.Duration, opts ...Option[T]) Rolling[T] {
+	if window <= 0 {
+		panic("cannot specify non-positive rolling window")
+	}
+
+	e := newExpiring(opts)
+	e.window = window
+
+	return e
+}
+
+func (e *expiring[T]) Prune() int {
+	return e.Purge()
+}
+
+// pruneRolling discards every expired item if e was created by NewRolling.
+func (e *expiring[T]) pruneRolling() {
+	if e.window > 0 {
+		e.Purge()
+	}
+}
@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRollingEvictsStale(t *testing.T) {
+	clock := newFakeClock()
+	var expired []int
+	q := NewRolling[int](time.Minute, WithClock[int](clock), WithOnExpire(func(v int) { expired = append(expired, v) }))
+
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+	clock.Advance(30 * time.Second)
+	_ = q.Enqueue(3)
+
+	if size := q.Size(); size != 3 {
+		t.Errorf("Size() within the window = %d, want 3", size)
+	}
+
+	clock.Advance(30 * time.Second)
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size() after the window = %d, want 1", size)
+	}
+	if val, err := q.Peek(); val != 3 || err != nil {
+		t.Errorf("Peek() = (%d, %v), want (3, nil)", val, err)
+	}
+	if !slices.Equal(expired, []int{1, 2}) {
+		t.Errorf("expire hook saw %v, want [1 2]", expired)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := q.Dequeue(); err == nil {
+		t.Error("Dequeue() of a stale item succeeded, want ErrUnderflow")
+	}
+	if s := q.Stats(); s.Expired != 3 {
+		t.Errorf("Stats().Expired = %d, want 3", s.Expired)
+	}
+}
+
+func TestRollingEnqueueWait(t *testing.T) {
+	clock := newFakeClock()
+	q := NewRolling[int](time.Second, WithClock[int](clock), WithCapacity[int](1))
+	_ = q.EnqueueWithTTL(1, time.Hour)
+
+	errc := make(chan error, 1)
+	go func() { errc <- q.EnqueueWait(context.Background(), 2) }()
+	awaitCount(t, "BlockedProducers()", q.BlockedProducers, 1)
+
+	// The window of the waiting item starts when it is added, not when it
+	// started waiting
+	clock.Advance(5 * time.Second)
+	if val, err := q.Dequeue(); val != 1 || err != nil {
+		t.Fatalf("Dequeue() = (%d, %v), want (1, nil)", val, err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("EnqueueWait() = %v, want nil", err)
+	}
+	if val, err := q.Peek(); val != 2 || err != nil {
+		t.Errorf("Peek() after EnqueueWait() = (%d, %v), want (2, nil)", val, err)
+	}
+
+	clock.Advance(time.Second)
+	if size := q.Size(); size != 0 {
+		t.Errorf("Size() after the window = %d, want 0", size)
+	}
+}
+
+func TestRollingPrune(t *testing.T) {
+	clock := newFakeClock()
+	q := NewRolling[int](time.Minute, WithClock[int](clock))
+	_, _ = q.EnqueueSlice([]int{1, 2})
+	_ = q.EnqueueWithTTL(3, time.Hour)
+
+	if n := q.Prune(); n != 0 {
+		t.Errorf("Prune() within the window = %d, want 0", n)
+	}
+	v := q.Version()
+
+	clock.Advance(time.Minute)
+	if n := q.Prune(); n != 2 {
+		t.Errorf("Prune() after the window = %d, want 2", n)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{3}) {
+		t.Errorf("All() after Prune() = %v, want [3]", got)
+	}
+	if q.Version() == v {
+		t.Error("Version() unchanged after Prune() discarded items")
+	}
+
+	t.Run("non-positive window (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("NewRolling(0) should panic, but it didn't")
+			}
+		}()
+
+		NewRolling[int](0)
+	})
+}
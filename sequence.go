@@ -0,0 +1,97 @@
+package queue
+
+// SequencedQueue is a FIFO queue that numbers its items in the order they are
+// enqueued, for correlating items across producers and consumers.
+//
+// Sequence numbers start at 1 and increase by one with every successful
+// EnqueueSeq, so they are unique and increasing for the lifetime of the
+// queue, regardless of how often the storage wraps around or items are
+// evicted. A failed enqueue does not use up a number. A SequencedQueue is safe
+// for concurrent use.
+type SequencedQueue[T any] struct {
+	q    *queue[numbered[T]]
+	last uint64 // Last assigned sequence number; guarded by q's write lock
+}
+
+// numbered is an item of a sequenced queue with its sequence number.
+type numbered[T any] struct {
+	val T
+	seq uint64
+}
+
+// NewSequenced creates an empty sequenced queue.
+//
+// Options configure the underlying queue as for New; hooks see the items
+// without their sequence numbers.
+//
+// Example:
+//
+//	q := queue.NewSequenced[Job]()
+//	seq, _ := q.EnqueueSeq(job)
+//	log.Printf("queued job #%d", seq)
+//	job, seq, err := q.DequeueSeq()
+//
+// Panics if WithWAL or WithFlushThreshold is given.
+func NewSequenced[T any](opts ...Option[T]) *SequencedQueue[T] {
+	base := configure(opts)
+	if base.wal != nil {
+		panic("cannot use a write-ahead log with a sequenced queue")
+	}
+	if base.onFlush != nil {
+		panic("cannot use a flush threshold with a sequenced queue")
+	}
+
+	return &SequencedQueue[T]{
+		q: adapt(base, func(item *numbered[T]) *T {
+			return &item.val
+		}),
+	}
+}
+
+// EnqueueSeq adds an item to the back of the queue and returns the sequence
+// number assigned to it.
+// Returns ErrOverflow if the queue is at capacity, or ErrClosed if it is closed.
+func (s *SequencedQueue[T]) EnqueueSeq(val T) (seq uint64, err error) {
+	if s.q.tracer != nil {
+		end := s.q.tracer(s.q.name, OpEnqueue)
+		defer func() { end(traced(err), err) }()
+	}
+
+	s.q.mu.Lock()
+	item := numbered[T]{val: val, seq: s.last + 1}
+	err = s.q.push(item)
+	if err == nil {
+		s.last = item.seq
+	}
+	s.q.unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	runHooks(s.q.onEnqueue, item)
+
+	return item.seq, nil
+}
+
+// DequeueSeq removes the front item and returns it with its sequence number.
+// Returns ErrUnderflow if the queue is empty.
+func (s *SequencedQueue[T]) DequeueSeq() (T, uint64, error) {
+	item, err := s.q.Dequeue()
+	return item.val, item.seq, err
+}
+
+// Size returns the number of items in the queue.
+func (s *SequencedQueue[T]) Size() int {
+	return s.q.Size()
+}
+
+// Close stops the queue accepting new items.
+// Returns ErrClosed if the queue was already closed.
+func (s *SequencedQueue[T]) Close() error {
+	return s.q.Close()
+}
+
+// Stats returns a snapshot of the queue's operation counters.
+func (s *SequencedQueue[T]) Stats() Stats {
+	return s.q.Stats()
+}
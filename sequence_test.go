@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSequencedMonotonic(t *testing.T) {
+	// A small capacity makes the storage wrap around many times
+	q := NewSequenced[int](WithCapacity[int](8))
+	var last uint64
+	for i := range 10_000 {
+		seq, err := q.EnqueueSeq(i)
+		if err != nil {
+			t.Fatalf("EnqueueSeq(%d) = %v, want nil", i, err)
+		}
+		if seq != uint64(i+1) {
+			t.Fatalf("EnqueueSeq(%d) seq = %d, want %d", i, seq, i+1)
+		}
+
+		if q.Size() < 5 {
+			continue
+		}
+		val, seq, err := q.DequeueSeq()
+		if err != nil {
+			t.Fatalf("DequeueSeq() = %v, want nil", err)
+		}
+		if seq <= last || seq != uint64(val+1) {
+			t.Fatalf("DequeueSeq() = %d seq %d after seq %d, want seq %d", val, seq, last, val+1)
+		}
+		last = seq
+	}
+}
+
+func TestSequencedFailuresAndEviction(t *testing.T) {
+	q := NewSequenced[string](WithCapacity[string](1))
+	_, _ = q.EnqueueSeq("a")
+	if seq, err := q.EnqueueSeq("b"); !errors.Is(err, ErrOverflow) || seq != 0 {
+		t.Errorf("EnqueueSeq() into full queue = %d, %v, want 0, %v", seq, err, ErrOverflow)
+	}
+	_, _, _ = q.DequeueSeq()
+	// The failed enqueue did not use up a number
+	if seq, _ := q.EnqueueSeq("c"); seq != 2 {
+		t.Errorf("EnqueueSeq() after a failure = %d, want 2", seq)
+	}
+
+	c := NewSequenced[string](WithCapacity[string](2), WithCircular[string]())
+	for _, s := range []string{"a", "b", "c"} {
+		_, _ = c.EnqueueSeq(s) // "c" evicts "a"
+	}
+	if val, seq, _ := c.DequeueSeq(); val != "b" || seq != 2 {
+		t.Errorf("DequeueSeq() after eviction = %q seq %d, want %q seq 2", val, seq, "b")
+	}
+
+	if _, _, err := NewSequenced[int]().DequeueSeq(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("DequeueSeq() on empty queue = %v, want %v", err, ErrUnderflow)
+	}
+	_ = q.Close()
+	if _, err := q.EnqueueSeq("d"); !errors.Is(err, ErrClosed) {
+		t.Errorf("EnqueueSeq() after Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestSequencedConcurrent(t *testing.T) {
+	const producers, perProducer = 8, 1000
+	var enqueued []int
+	var mu sync.Mutex
+	q := NewSequenced[int](WithOnEnqueue(func(v int) {
+		mu.Lock()
+		enqueued = append(enqueued, v)
+		mu.Unlock()
+	}))
+
+	var wg sync.WaitGroup
+	seqs := make([][]uint64, producers)
+	for p := range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perProducer {
+				seq, _ := q.EnqueueSeq(p*perProducer + i)
+				seqs[p] = append(seqs[p], seq)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Each producer sees increasing numbers, and together they use each once
+	var all []uint64
+	for p, s := range seqs {
+		if !slices.IsSorted(s) {
+			t.Errorf("producer %d received unordered sequence numbers", p)
+		}
+		all = append(all, s...)
+	}
+	slices.Sort(all)
+	for i, seq := range all {
+		if seq != uint64(i+1) {
+			t.Fatalf("sorted sequence numbers have %d at position %d, want %d", seq, i, i+1)
+		}
+	}
+	if len(enqueued) != producers*perProducer {
+		t.Errorf("enqueue hook ran %d times, want %d", len(enqueued), producers*perProducer)
+	}
+
+	// Items come out in sequence order
+	var last uint64
+	for q.Size() > 0 {
+		_, seq, _ := q.DequeueSeq()
+		if seq != last+1 {
+			t.Fatalf("DequeueSeq() seq = %d after %d, want %d", seq, last, last+1)
+		}
+		last = seq
+	}
+}
+
+func TestNewSequencedPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewSequenced(WithWAL(...)) did not panic")
+		}
+	}()
+	NewSequenced(WithWAL[int](&bytes.Buffer{}, encodeInt))
+}
@@ -0,0 +1,1278 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewSharded creates a queue that spreads items across the given number of
+// independently locked shards, reducing lock contention under heavy concurrency.
+//
+// Options apply to every shard, except that the capacity set with WithCapacity
+// is enforced across all shards combined. Enqueue assigns items to shards
+// round-robin and Dequeue takes items from shards round-robin.
+//
+// Strict global FIFO is relaxed: items keep their order within a shard, but an
+// item may be dequeued before an item enqueued earlier into a different shard.
+// Whole-queue operations such as All, ForEach, Filter and String visit the
+// shards one after another and do not observe a single atomic snapshot.
+//
+// Example:
+//
+//	q := queue.NewSharded[int](8)                                // Unlimited capacity
+//	q := queue.NewSharded[int](8, queue.WithCapacity[int](1000)) // 1000 items across all shards
+//
+// Panics if shards < 1 or if WithMaxBytes, WithWAL, WithDedup, WithLIFO or
+// WithEnqueueRateLimit is given.
+func NewSharded[T any](shards int, opts ...Option[T]) Queue[T] {
+	return newSharded(shards, opts...)
+}
+
+type sharded[T any] struct {
+	shards       []*queue[T]
+	opts         []Option[T] // Kept so Split can create a matching queue
+	mu           sync.Mutex  // Serializes SetCapacity and Reset
+	shrinkPolicy ShrinkPolicy
+	circular     bool
+	clock        Clock
+	name         string
+	tracer       TraceFunc
+	deadLetter   func(T)
+	limiter      *limiter
+	onFlush      func(Queue[T]) // Non-nil when a flush threshold is set
+	flushAt      int64
+	aboveFlush   atomic.Bool // Whether the aggregate size was at least flushAt at the last change
+	flushDue     atomic.Bool // Set when the size reaches flushAt, cleared when onFlush runs
+	capacity     atomic.Int64
+	size         atomic.Int64
+	next         atomic.Uint64 // Advanced by every enqueue to pick a shard
+	cursor       atomic.Uint64 // Advanced by every dequeue to pick the first shard tried
+	rejected     atomic.Uint64
+	peak         atomic.Int64
+	waitMu       sync.Mutex // Guards enqueueWait and dequeueWait
+	enqueueWait  WaitStats
+	dequeueWait  WaitStats
+	notFull      signal
+	notEmpty     signal
+	closed       atomic.Bool
+	producers    gauge // Callers blocked in EnqueueWait
+	consumers    gauge // Callers blocked in DequeueWait or DequeueBatchWait
+	subs         subscribers
+}
+
+func newSharded[T any](shards int, opts ...Option[T]) *sharded[T] {
+	if shards < 1 {
+		panic("cannot specify non-positive shard count")
+	}
+
+	base := configure(opts)
+	if base.sizeOf != nil {
+		panic("cannot use a byte limit with a sharded queue")
+	}
+	if base.wal != nil {
+		panic("cannot use a write-ahead log with a sharded queue")
+	}
+	if base.dedup != nil {
+		panic("cannot use dedup with a sharded queue")
+	}
+	if base.lifo {
+		panic("cannot use LIFO order with a sharded queue")
+	}
+	if base.enqueueLimit != nil {
+		panic("cannot use an enqueue rate limit with a sharded queue")
+	}
+	if base.changes != nil {
+		panic("cannot use a change log with a sharded queue")
+	}
+	s := &sharded[T]{
+		shards:       make([]*queue[T], shards),
+		opts:         opts,
+		shrinkPolicy: base.shrinkPolicy,
+		circular:     base.circular,
+		clock:        base.clock,
+		name:         base.name,
+		tracer:       base.tracer,
+		deadLetter:   base.deadLetter,
+		limiter:      base.limiter,
+		onFlush:      base.onFlush,
+		flushAt:      int64(base.flushAt),
+	}
+	s.capacity.Store(int64(base.capacity))
+
+	// The aggregate limit is enforced here, so shards themselves are unlimited
+	// and share any preallocation evenly
+	size := (base.initialSize() + shards - 1) / shards
+	for i := range s.shards {
+		shard := configure(opts)
+		shard.capacity = UnlimitedCapacity
+		// Operations are traced and paced once, by the sharded queue
+		shard.tracer = nil
+		shard.limiter = nil
+		shard.onFlush = nil
+		shard.items = newRing[T](size)
+		s.shards[i] = shard
+	}
+
+	return s
+}
+
+func (s *sharded[T]) Enqueue(val T) (err error) {
+	if s.tracer != nil {
+		end := s.tracer(s.name, OpEnqueue)
+		defer func() { end(traced(err), err) }()
+	}
+
+	return s.enqueue(val)
+}
+
+// enqueue implements Enqueue without tracing.
+func (s *sharded[T]) enqueue(val T) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if err := s.invalid(val); err != nil {
+		return err
+	}
+	if !s.claim() {
+		s.rejected.Add(1)
+		s.forward(val)
+		return ErrOverflow
+	}
+
+	return s.place(val)
+}
+
+// EnqueueDedupBack is not atomic: an item enqueued concurrently may land
+// between the comparison with the back item and the insert.
+func (s *sharded[T]) EnqueueDedupBack(val T, eq func(a, b T) bool) (bool, error) {
+	if s.closed.Load() {
+		return false, ErrClosed
+	}
+	if back, err := s.Back(); err == nil && eq(back, val) {
+		return false, nil
+	}
+	if err := s.enqueue(val); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// EnqueueFrontAll adds vals to the front of the shard the next dequeue tries
+// first. Concurrent dequeues may start from other shards, so the items are
+// not guaranteed to be dequeued before every other item.
+func (s *sharded[T]) EnqueueFrontAll(vals ...T) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	for _, val := range vals {
+		if err := s.invalid(val); err != nil {
+			return err
+		}
+	}
+	n := int64(len(vals))
+	if !s.reserve(n) {
+		s.rejected.Add(1)
+		for _, val := range vals {
+			s.forward(val)
+		}
+		return ErrOverflow
+	}
+
+	// Shards are unlimited, so this only fails if Close raced with the caller
+	shard := s.shards[(s.cursor.Load()+1)%uint64(len(s.shards))]
+	if err := shard.EnqueueFrontAll(vals...); err != nil {
+		s.resize(-n)
+		s.notFull.broadcast()
+		return err
+	}
+	if n > 0 {
+		s.notEmpty.broadcast()
+	}
+	s.flushIfDue()
+
+	return nil
+}
+
+// EnqueuePush hands the room of the evicted item to val, so the aggregate
+// size never drops below the capacity in between. The evicted item comes from
+// the shard the next dequeue tries first. If Close races with the call, the
+// evicted item is still returned, along with ErrClosed.
+func (s *sharded[T]) EnqueuePush(val T) (evicted T, didEvict bool, err error) {
+	if s.closed.Load() {
+		return evicted, false, ErrClosed
+	}
+	if err := s.invalid(val); err != nil {
+		return evicted, false, err
+	}
+	if !s.reserve(1) {
+		if evicted, didEvict = s.takeFront(); !didEvict {
+			s.rejected.Add(1)
+			s.forward(val)
+			return evicted, false, ErrOverflow
+		}
+	}
+
+	return evicted, didEvict, s.place(val)
+}
+
+func (s *sharded[T]) TryEnqueue(val T) bool {
+	return s.enqueue(val) == nil
+}
+
+func (s *sharded[T]) Offer(val T) bool {
+	return s.TryEnqueue(val)
+}
+
+// EnqueueSlice adds items one at a time, so concurrent operations may
+// interleave with the batch.
+func (s *sharded[T]) EnqueueSlice(vals []T) (int, error) {
+	for i, val := range vals {
+		if err := s.enqueue(val); err != nil {
+			return i, err
+		}
+	}
+
+	return len(vals), nil
+}
+
+func (s *sharded[T]) EnqueueWait(ctx context.Context, val T) error {
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in s.producers
+	defer s.producers.leave(&blocked)
+	for {
+		// Register before checking so a concurrent dequeue cannot slip
+		// between the check and the wait unnoticed
+		ready := s.notFull.wait()
+		if s.closed.Load() {
+			s.notFull.done()
+			return ErrClosed
+		}
+		if err := s.invalid(val); err != nil {
+			s.notFull.done()
+			return err
+		}
+		if s.claim() {
+			s.notFull.done()
+			s.waited(&s.enqueueWait, since)
+			return s.place(val)
+		}
+
+		if since.IsZero() {
+			since = s.clock.Now()
+		}
+		s.producers.enter(&blocked)
+		err := waitFor(ctx, ready)
+		s.notFull.done()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *sharded[T]) EnqueueCtx(ctx context.Context, val T) error {
+	return enqueueCtx(ctx, s, val)
+}
+
+// EnqueueAllCtx reserves room for the whole batch at once and then places
+// the items one at a time, so consumers may see some of them before the rest.
+func (s *sharded[T]) EnqueueAllCtx(ctx context.Context, vals ...T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, val := range vals {
+		if err := s.invalid(val); err != nil {
+			return err
+		}
+	}
+	if s.circular {
+		if s.closed.Load() {
+			return ErrClosed
+		}
+		return s.placeAll(vals, true)
+	}
+
+	n := int64(len(vals))
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in s.producers
+	defer s.producers.leave(&blocked)
+	for {
+		ready := s.notFull.wait()
+		if s.closed.Load() {
+			s.notFull.done()
+			return ErrClosed
+		}
+		if c := s.capacity.Load(); c >= 0 && n > c {
+			s.notFull.done()
+			s.rejected.Add(1)
+			for _, val := range vals {
+				s.forward(val)
+			}
+			return ErrOverflow
+		}
+		if s.reserve(n) {
+			s.notFull.done()
+			s.waited(&s.enqueueWait, since)
+			return s.placeAll(vals, false)
+		}
+
+		if since.IsZero() {
+			since = s.clock.Now()
+		}
+		s.producers.enter(&blocked)
+		err := waitFor(ctx, ready)
+		s.notFull.done()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// placeAll adds vals to the shards in round-robin order, claiming room for
+// each first unless it has already been reserved. It stops at the first item
+// that fails, which only happens if Close raced with the caller; the room
+// reserved for the items after it is released.
+func (s *sharded[T]) placeAll(vals []T, claim bool) error {
+	for i, val := range vals {
+		if claim && !s.claim() {
+			return ErrOverflow
+		}
+		if err := s.place(val); err != nil {
+			if !claim {
+				s.resize(-int64(len(vals) - i - 1))
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sharded[T]) EnqueueTimeout(val T, d time.Duration) error {
+	return enqueueTimeout(s, s.clock, val, d)
+}
+
+func (s *sharded[T]) Dequeue() (result T, err error) {
+	if s.tracer != nil {
+		end := s.tracer(s.name, OpDequeue)
+		defer func() { end(traced(err), err) }()
+	}
+
+	result, ok := s.TryDequeue()
+	if !ok {
+		return result, ErrUnderflow
+	}
+
+	return result, nil
+}
+
+// DequeueUntil is not atomic: it dequeues one item at a time, so concurrent
+// operations may interleave with it.
+func (s *sharded[T]) DequeueUntil(pred func(T) bool) (T, error) {
+	for {
+		val, ok := s.TryDequeue()
+		if !ok {
+			return val, ErrUnderflow
+		}
+		if pred(val) {
+			return val, nil
+		}
+	}
+}
+
+// DequeueMatch searches one shard at a time, in the order All visits them, so
+// like Remove it is not atomic: it may miss an item moved between shards
+// concurrently.
+func (s *sharded[T]) DequeueMatch(pred func(T) bool) (T, error) {
+	err := ErrUnderflow
+	for shard := range s.ordered(s.cursor.Load() + 1) {
+		val, shardErr := shard.DequeueMatch(pred)
+		if shardErr == nil {
+			s.resize(-1)
+			s.notFull.broadcast()
+			return val, nil
+		}
+		if errors.Is(shardErr, ErrNotFound) {
+			err = ErrNotFound
+		}
+	}
+
+	var zero T
+	return zero, err
+}
+
+// Drain is not atomic: it drains one shard at a time, so items enqueued
+// concurrently may be left behind.
+func (s *sharded[T]) Drain() []T {
+	result := make([]T, 0, s.Size())
+	for shard := range s.ordered(s.cursor.Add(1)) {
+		result = append(result, shard.Drain()...)
+	}
+	s.drained(len(result))
+
+	return result
+}
+
+// DrainTo is not atomic: it drains one shard at a time, so concurrent
+// operations may interleave with it.
+func (s *sharded[T]) DrainTo(dst []T) int {
+	n := 0
+	for shard := range s.ordered(s.cursor.Add(1)) {
+		if n == len(dst) {
+			break
+		}
+		n += shard.DrainTo(dst[n:])
+	}
+	s.drained(n)
+
+	return n
+}
+
+// drained records that n items were removed from the shards.
+func (s *sharded[T]) drained(n int) {
+	if n == 0 {
+		return
+	}
+
+	s.resize(-int64(n))
+	s.notFull.broadcast()
+}
+
+func (s *sharded[T]) TryDequeue() (T, bool) {
+	for shard := range s.ordered(s.cursor.Add(1)) {
+		if val, ok := shard.TryDequeue(); ok {
+			s.resize(-1)
+			s.notFull.broadcast()
+			return val, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+func (s *sharded[T]) Poll() (T, bool) {
+	return s.TryDequeue()
+}
+
+func (s *sharded[T]) DequeueOr(def T) T {
+	if val, ok := s.TryDequeue(); ok {
+		return val
+	}
+
+	return def
+}
+
+func (s *sharded[T]) DequeueWait(ctx context.Context) (T, error) {
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in s.consumers
+	defer s.consumers.leave(&blocked)
+	if err := pace(ctx, s.limiter, s.clock, &s.consumers, &blocked); err != nil {
+		var zero T
+		return zero, err
+	}
+	for {
+		ready := s.notEmpty.wait()
+		if val, ok := s.TryDequeue(); ok {
+			s.notEmpty.done()
+			s.waited(&s.dequeueWait, since)
+			return val, nil
+		}
+		if s.closed.Load() {
+			s.notEmpty.done()
+
+			var zero T
+			return zero, ErrClosed
+		}
+
+		if since.IsZero() {
+			since = s.clock.Now()
+		}
+		s.consumers.enter(&blocked)
+		err := waitFor(ctx, ready)
+		s.notEmpty.done()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+func (s *sharded[T]) BlockedProducers() int {
+	return s.producers.count()
+}
+
+func (s *sharded[T]) BlockedConsumers() int {
+	return s.consumers.count()
+}
+
+func (s *sharded[T]) DequeueBatchWait(ctx context.Context, n int, maxWait time.Duration) ([]T, error) {
+	return dequeueBatchWait(ctx, s, s.clock, &s.notEmpty, &s.consumers, func() (int, bool) {
+		return s.Size(), s.closed.Load()
+	}, n, maxWait)
+}
+
+func (s *sharded[T]) NotEmpty() <-chan struct{} {
+	// Watch before checking so an enqueue that lands in between still fires
+	// the returned channel
+	ready := s.notEmpty.watch()
+	if s.size.Load() > 0 || s.closed.Load() {
+		return fired
+	}
+
+	return ready
+}
+
+func (s *sharded[T]) NotFull() <-chan struct{} {
+	// Watch before checking so a dequeue that lands in between still fires
+	// the returned channel
+	ready := s.notFull.watch()
+	if c := s.capacity.Load(); c < 0 || s.size.Load() < c || s.circular || s.closed.Load() {
+		return fired
+	}
+
+	return ready
+}
+
+func (s *sharded[T]) DequeueCtx(ctx context.Context) (T, error) {
+	return dequeueCtx(ctx, s)
+}
+
+func (s *sharded[T]) DequeueTimeout(d time.Duration) (T, error) {
+	return dequeueTimeout(s, s.clock, d)
+}
+
+func (s *sharded[T]) Channel(ctx context.Context) <-chan T {
+	return channel(ctx, s)
+}
+
+func (s *sharded[T]) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+
+	for _, shard := range s.shards {
+		_ = shard.Close()
+	}
+	s.notFull.broadcast()
+	s.notEmpty.broadcast()
+
+	return nil
+}
+
+// CloseAndDrain closes every shard before draining any, so no item can be
+// added once draining starts, but concurrent consumers may still take items
+// from shards not yet drained.
+func (s *sharded[T]) CloseAndDrain(fn func(T)) []T {
+	_ = s.Close()
+	result := s.Drain()
+	handOff(result, fn)
+
+	return result
+}
+
+// Reset is not atomic: concurrent operations may observe some shards reset
+// before others.
+func (s *sharded[T]) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, shard := range s.shards {
+		// Subtract what was dropped rather than storing zero, so items placed
+		// concurrently stay counted
+		shard.mu.Lock()
+		n := shard.reset()
+		shard.mu.Unlock()
+		s.resize(-int64(n))
+	}
+	s.rejected.Store(0)
+	s.peak.Store(0)
+	s.waitMu.Lock()
+	s.enqueueWait, s.dequeueWait = WaitStats{}, WaitStats{}
+	s.waitMu.Unlock()
+	s.closed.Store(false)
+	s.notFull.broadcast()
+}
+
+func (s *sharded[T]) Size() int {
+	return int(s.size.Load())
+}
+
+// ApproxSize is as cheap as Size, which reads the same atomic counter.
+func (s *sharded[T]) ApproxSize() int {
+	return s.Size()
+}
+
+// SizeBytes always returns 0, since a sharded queue has no byte limit.
+func (s *sharded[T]) SizeBytes() int {
+	return 0
+}
+
+func (s *sharded[T]) Remaining() int {
+	c := s.capacity.Load()
+	if c == UnlimitedCapacity {
+		return UnlimitedCapacity
+	}
+
+	return int(max(c-s.size.Load(), 0))
+}
+
+func (s *sharded[T]) SetCapacity(n int) error {
+	if n < UnlimitedCapacity {
+		return ErrInvalidCapacity
+	}
+
+	if s.circular && n <= 0 {
+		return ErrInvalidCapacity
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n >= 0 && s.size.Load() > int64(n) && s.shrinkPolicy == ShrinkReject {
+		return ErrCapacityTooSmall
+	}
+
+	// Publish the new limit first so concurrent enqueues respect it while
+	// excess items are dropped
+	s.capacity.Store(int64(n))
+	for n >= 0 && s.size.Load() > int64(n) {
+		if !s.dropFront() {
+			break
+		}
+	}
+	s.notFull.broadcast()
+
+	return nil
+}
+
+// Grow spreads the preallocation evenly across shards, matching round-robin
+// placement.
+func (s *sharded[T]) Grow(n int) error {
+	if n < 0 {
+		return ErrInvalidCapacity
+	}
+
+	if r := s.Remaining(); r != UnlimitedCapacity {
+		n = min(n, r)
+	}
+	per := (n + len(s.shards) - 1) / len(s.shards)
+	for _, shard := range s.shards {
+		_ = shard.Grow(per)
+	}
+
+	return nil
+}
+
+func (s *sharded[T]) Compact() {
+	for _, shard := range s.shards {
+		shard.Compact()
+	}
+}
+
+func (s *sharded[T]) Peek() (result T, err error) {
+	if s.tracer != nil {
+		end := s.tracer(s.name, OpPeek)
+		defer func() { end(traced(err), err) }()
+	}
+
+	for shard := range s.ordered(s.cursor.Load() + 1) {
+		if val, err := shard.Peek(); err == nil {
+			return val, nil
+		}
+	}
+
+	var zero T
+	return zero, ErrUnderflow
+}
+
+func (s *sharded[T]) Front() (T, error) {
+	return s.Peek()
+}
+
+func (s *sharded[T]) PeekOr(def T) T {
+	if val, err := s.Peek(); err == nil {
+		return val
+	}
+
+	return def
+}
+
+// Back returns the back item of the shard that received the latest enqueue,
+// falling back to the other shards in reverse round-robin order.
+func (s *sharded[T]) Back() (T, error) {
+	n := uint64(len(s.shards))
+	latest := s.next.Load() % n
+	for i := uint64(0); i < n; i++ {
+		if val, err := s.shards[(latest+n-i)%n].Back(); err == nil {
+			return val, nil
+		}
+	}
+
+	var zero T
+	return zero, ErrUnderflow
+}
+
+// PeekN is not atomic: it collects items one shard at a time, in the order
+// Drain empties them.
+func (s *sharded[T]) PeekN(n int) ([]T, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+
+	result := []T{}
+	found := false
+	for shard := range s.ordered(s.cursor.Load() + 1) {
+		vals, err := shard.PeekN(n - len(result))
+		if err == nil {
+			found = true
+			result = append(result, vals...)
+		}
+	}
+	if !found {
+		return result, ErrUnderflow
+	}
+
+	return result, nil
+}
+
+// CopyInto copies a consistent view of the shards, as Snapshot takes, and
+// is not atomic with respect to dst.
+func (s *sharded[T]) CopyInto(dst Queue[T]) error {
+	if dst == Queue[T](s) {
+		return nil
+	}
+	vals, _ := s.Snapshot()
+
+	return copyEach(vals, dst)
+}
+
+// ReplaceFront replaces the front item of the first non-empty shard, in the
+// order Peek tries them.
+func (s *sharded[T]) ReplaceFront(val T) error {
+	for shard := range s.ordered(s.cursor.Load() + 1) {
+		if err := shard.ReplaceFront(val); !errors.Is(err, ErrUnderflow) {
+			return err
+		}
+	}
+
+	return ErrUnderflow
+}
+
+// ReplaceBack replaces the back item of the shard Back reads from.
+func (s *sharded[T]) ReplaceBack(val T) error {
+	n := uint64(len(s.shards))
+	latest := s.next.Load() % n
+	for i := uint64(0); i < n; i++ {
+		if err := s.shards[(latest+n-i)%n].ReplaceBack(val); !errors.Is(err, ErrUnderflow) {
+			return err
+		}
+	}
+
+	return ErrUnderflow
+}
+
+func (s *sharded[T]) Filter(keep func(T) bool) int {
+	removed := 0
+	for _, shard := range s.shards {
+		removed += shard.Filter(keep)
+	}
+	s.resize(-int64(removed))
+	if removed > 0 {
+		s.notFull.broadcast()
+	}
+
+	return removed
+}
+
+// FilterCtx holds the write locks of every shard at once, so unlike Filter it
+// either filters all shards or, if ctx is done first, none of them.
+func (s *sharded[T]) FilterCtx(ctx context.Context, keep func(T) bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	locked := s.inLockOrder()
+	for _, shard := range locked {
+		shard.mu.Lock()
+	}
+	unlock := func() {
+		for _, shard := range locked {
+			shard.unlock()
+		}
+	}
+
+	kept := make([][]bool, len(s.shards))
+	visited := 0
+	for i, shard := range s.shards {
+		var err error
+		if kept[i], err = shard.decide(ctx, keep, visited); err != nil {
+			unlock()
+			return 0, err
+		}
+		visited += len(kept[i])
+	}
+	removed := 0
+	for i, shard := range s.shards {
+		removed += shard.filter(func(j int, _ T) bool { return kept[i][j] })
+	}
+	unlock()
+
+	s.resize(-int64(removed))
+	if removed > 0 {
+		s.notFull.broadcast()
+	}
+
+	return removed, nil
+}
+
+// Merge is not atomic: it checks every item up front and then moves them one
+// at a time, so concurrent operations may interleave with it.
+func (s *sharded[T]) Merge(other Queue[T]) error {
+	if other == Queue[T](s) {
+		return nil
+	}
+
+	return mergeEach(s, other)
+}
+
+func (s *sharded[T]) admits(vals []T, replace bool) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	for _, val := range vals {
+		if err := s.invalid(val); err != nil {
+			return err
+		}
+	}
+
+	n := len(vals)
+	if replace {
+		n -= s.Size()
+	} else if s.circular {
+		return nil
+	}
+	if r := s.Remaining(); r != UnlimitedCapacity && n > r {
+		return ErrOverflow
+	}
+
+	return nil
+}
+
+// Split is not atomic: it moves items one at a time in dequeue order, so
+// concurrent operations may interleave with it.
+func (s *sharded[T]) Split(n int) (Queue[T], error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+
+	head := newSharded(len(s.shards), s.opts...)
+	head.capacity.Store(s.capacity.Load())
+	for ; n > 0; n-- {
+		val, ok := s.TryDequeue()
+		if !ok {
+			break
+		}
+		_ = head.enqueue(val)
+	}
+
+	return head, nil
+}
+
+// Reverse reverses the items of each shard independently.
+func (s *sharded[T]) Reverse() {
+	for _, shard := range s.shards {
+		shard.Reverse()
+	}
+}
+
+// Rotate rotates the items of each shard independently by n.
+func (s *sharded[T]) Rotate(n int) {
+	for _, shard := range s.shards {
+		shard.Rotate(n)
+	}
+}
+
+// Shuffle shuffles the items of each shard independently, drawing from r
+// for one shard after another.
+func (s *sharded[T]) Shuffle(r *rand.Rand) {
+	for _, shard := range s.shards {
+		shard.Shuffle(r)
+	}
+}
+
+// Remove counts i in the order All visits items. It is not atomic: concurrent
+// operations may shift which item is at i while the shards are searched.
+func (s *sharded[T]) Remove(i int) (T, error) {
+	if shard, local, ok := s.locate(i); ok {
+		if val, err := shard.Remove(local); err == nil {
+			s.resize(-1)
+			s.notFull.broadcast()
+			return val, nil
+		}
+	}
+
+	var zero T
+	return zero, ErrIndexOutOfRange
+}
+
+// FindIndex counts in the order All visits items. It searches one shard at a
+// time, so like Remove it is not atomic.
+func (s *sharded[T]) FindIndex(pred func(T) bool) int {
+	found, i := false, 0
+	s.ForEach(func(v T) bool {
+		found = pred(v)
+		if !found {
+			i++
+		}
+		return !found
+	})
+	if !found {
+		return -1
+	}
+
+	return i
+}
+
+// Swap counts i and j in the order All visits items and may exchange items
+// between shards. The shards involved are locked while the items are
+// exchanged, but concurrent operations may shift which items are at i and j
+// while they are located.
+func (s *sharded[T]) Swap(i, j int) error {
+	a, ai, okA := s.locate(i)
+	b, bi, okB := s.locate(j)
+	if !okA || !okB {
+		return ErrIndexOutOfRange
+	}
+
+	first, second := lockOrder(a, b)
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	if second != first {
+		second.mu.Lock()
+		defer second.mu.Unlock()
+	}
+
+	if ai >= a.items.len() || bi >= b.items.len() {
+		return ErrIndexOutOfRange
+	}
+	va, vb := a.items.at(ai), b.items.at(bi)
+	a.items.set(ai, vb)
+	b.items.set(bi, va)
+	a.heapify()
+	b.heapify()
+	a.changed()
+	if b != a {
+		b.changed()
+		a.untrack(va)
+		a.track(vb)
+		b.untrack(vb)
+		b.track(va)
+	}
+
+	return nil
+}
+
+// locate returns the shard holding the item at index i, in the order All
+// visits items, and the item's index within that shard.
+func (s *sharded[T]) locate(i int) (*queue[T], int, bool) {
+	if i < 0 {
+		return nil, 0, false
+	}
+	for shard := range s.ordered(s.cursor.Load() + 1) {
+		n := shard.Size()
+		if i < n {
+			return shard, i, true
+		}
+		i -= n
+	}
+
+	return nil, 0, false
+}
+
+func (s *sharded[T]) ForEach(fn func(T) bool) {
+	stopped := false
+	for shard := range s.ordered(s.cursor.Load() + 1) {
+		shard.ForEach(func(v T) bool {
+			stopped = !fn(v)
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+	}
+}
+
+func (s *sharded[T]) ForEachCtx(ctx context.Context, fn func(T) bool) error {
+	stopped := false
+	for shard := range s.ordered(s.cursor.Load() + 1) {
+		err := shard.ForEachCtx(ctx, func(v T) bool {
+			stopped = !fn(v)
+			return !stopped
+		})
+		if err != nil || stopped {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sharded[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for shard := range s.ordered(s.cursor.Load() + 1) {
+			for _, v := range shard.snapshot() {
+				if !yield(v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *sharded[T]) AllIndexed() iter.Seq2[int, T] {
+	return allIndexed(s.All())
+}
+
+// Version is the sum of the versions of the shards, so it increases whenever
+// any shard changes.
+func (s *sharded[T]) Version() uint64 {
+	var total uint64
+	for _, shard := range s.shards {
+		total += shard.Version()
+	}
+
+	return total
+}
+
+// Snapshot holds the read locks of every shard at once, so unlike All it
+// sees a consistent view across shards.
+func (s *sharded[T]) Snapshot() ([]T, uint64) {
+	locked := s.inLockOrder()
+	for _, shard := range locked {
+		shard.mu.RLock()
+		defer shard.mu.RUnlock()
+	}
+
+	var version uint64
+	result := make([]T, 0, s.Size())
+	for shard := range s.ordered(s.cursor.Load() + 1) {
+		for i := range shard.items.len() {
+			result = append(result, shard.items.at(i))
+		}
+		version += shard.version
+	}
+
+	return result, version
+}
+
+// inLockOrder returns the shards in the order Swap locks them, so that
+// operations holding every shard lock at once cannot deadlock with it.
+func (s *sharded[T]) inLockOrder() []*queue[T] {
+	locked := slices.Clone(s.shards)
+	slices.SortFunc(locked, func(a, b *queue[T]) int {
+		if first, _ := lockOrder(a, b); first == a {
+			return -1
+		}
+		return 1
+	})
+
+	return locked
+}
+
+func (s *sharded[T]) Stats() Stats {
+	var total Stats
+	for _, shard := range s.shards {
+		st := shard.Stats()
+		total.Enqueued += st.Enqueued
+		total.Dequeued += st.Dequeued
+		total.CurrentSize += st.CurrentSize
+	}
+
+	// Rejections and the peak only exist at the aggregate level
+	total.Rejected = s.rejected.Load()
+	total.PeakSize = int(s.peak.Load())
+	total.Name = s.name
+	s.waitMu.Lock()
+	total.EnqueueWait, total.DequeueWait = s.enqueueWait, s.dequeueWait
+	s.waitMu.Unlock()
+
+	return total
+}
+
+// waited records in w a blocking call that began to wait at since and has now
+// succeeded, unless since is zero because the call never blocked.
+func (s *sharded[T]) waited(w *WaitStats, since time.Time) {
+	if since.IsZero() {
+		return
+	}
+
+	now := s.clock.Now()
+	s.waitMu.Lock()
+	w.record(since, now)
+	s.waitMu.Unlock()
+}
+
+func (s *sharded[T]) String() string {
+	items := slices.Collect(s.All())
+	return formatQueue(s.name, len(items), int(s.capacity.Load()), func(i int) T {
+		return items[i]
+	})
+}
+
+func (s *sharded[T]) Name() string {
+	return s.name
+}
+
+// reserve claims room for n items against the aggregate capacity,
+// reporting whether they fit.
+func (s *sharded[T]) reserve(n int64) bool {
+	for {
+		size := s.size.Load()
+		if c := s.capacity.Load(); c >= 0 && size+n > c {
+			return false
+		}
+		if s.size.CompareAndSwap(size, size+n) {
+			s.recordPeak(size + n)
+			if n != 0 {
+				s.subs.notify(int(size + n))
+				s.checkFlush(size + n)
+			}
+			return true
+		}
+	}
+}
+
+// resize adjusts the aggregate size by delta and notifies subscribers if it
+// changed.
+func (s *sharded[T]) resize(delta int64) {
+	size := s.size.Add(delta)
+	if delta != 0 {
+		s.subs.notify(int(size))
+		s.checkFlush(size)
+	}
+}
+
+// checkFlush marks the flush callback due if the aggregate size, now size,
+// has just reached the flush threshold. Concurrent changes may be observed out
+// of order, so a crossing can occasionally be reported late or missed.
+func (s *sharded[T]) checkFlush(size int64) {
+	if s.onFlush == nil {
+		return
+	}
+	if size < s.flushAt {
+		s.aboveFlush.Store(false)
+	} else if s.aboveFlush.CompareAndSwap(false, true) {
+		s.flushDue.Store(true)
+	}
+}
+
+// flushIfDue runs the flush callback if it was marked due, once the items
+// that made the size reach the threshold have been placed in their shards.
+func (s *sharded[T]) flushIfDue() {
+	if s.flushDue.CompareAndSwap(true, false) {
+		s.onFlush(s)
+	}
+}
+
+// invalid returns the error adding val fails with before any room is reserved
+// for it, if any. Every shard shares the same configuration.
+func (s *sharded[T]) invalid(val T) error {
+	return s.shards[0].invalid(val)
+}
+
+// place adds val to the next shard in round-robin order. Room must already
+// have been reserved; it is released again if the shard has been closed.
+func (s *sharded[T]) place(val T) error {
+	// Shards are unlimited, so this only fails if Close raced with the caller
+	shard := s.shards[s.next.Add(1)%uint64(len(s.shards))]
+	if err := shard.Enqueue(val); err != nil {
+		s.resize(-1)
+		s.notFull.broadcast()
+		return err
+	}
+	s.notEmpty.broadcast()
+	s.flushIfDue()
+
+	return nil
+}
+
+// claim reserves room for one item, evicting front items of a circular queue
+// to make room, and reports whether room was reserved.
+func (s *sharded[T]) claim() bool {
+	for !s.reserve(1) {
+		if !s.circular || !s.dropFront() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// recordPeak raises the recorded peak size to size if it is larger.
+func (s *sharded[T]) recordPeak(size int64) {
+	for {
+		peak := s.peak.Load()
+		if size <= peak || s.peak.CompareAndSwap(peak, size) {
+			return
+		}
+	}
+}
+
+// dropFront discards the front item of the first non-empty shard without
+// running dequeue hooks, reporting whether an item was dropped. The item is
+// forwarded to the dead-letter queue, if there is one.
+func (s *sharded[T]) dropFront() bool {
+	val, ok := s.takeFront()
+	if ok {
+		s.resize(-1)
+		s.forward(val)
+	}
+
+	return ok
+}
+
+// takeFront removes and returns the front item of the first non-empty shard
+// without running dequeue hooks or adjusting the aggregate size, reporting
+// whether there was one.
+func (s *sharded[T]) takeFront() (T, bool) {
+	for shard := range s.ordered(s.cursor.Load() + 1) {
+		shard.mu.Lock()
+		if shard.items.len() > 0 {
+			val := shard.takeFront()
+			shard.mu.Unlock()
+			return val, true
+		}
+		shard.mu.Unlock()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// forward enqueues a rejected or dropped item into the dead-letter queue, if
+// there is one.
+func (s *sharded[T]) forward(val T) {
+	if s.deadLetter != nil {
+		s.deadLetter(val)
+	}
+}
+
+// ordered returns an iterator over every shard, starting from the shard
+// selected by start and wrapping around.
+func (s *sharded[T]) ordered(start uint64) iter.Seq[*queue[T]] {
+	return func(yield func(*queue[T]) bool) {
+		n := uint64(len(s.shards))
+		for i := uint64(0); i < n; i++ {
+			if !yield(s.shards[(start+i)%n]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,506 @@
+package queue
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestNewSharded(t *testing.T) {
+	q := NewSharded[int](4)
+	if q == nil {
+		t.Fatal("NewSharded() returned nil")
+	}
+
+	if size := q.Size(); size != 0 {
+		t.Errorf("New sharded queue size = %d, want 0", size)
+	}
+
+	t.Run("non-positive shard count (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("NewSharded(0) should panic, but it didn't")
+			}
+		}()
+
+		NewSharded[int](0)
+	})
+}
+
+func TestShardedDeliversAllItems(t *testing.T) {
+	q := NewSharded[int](4)
+	for i := 0; i < 100; i++ {
+		if err := q.Enqueue(i); err != nil {
+			t.Fatalf("Enqueue(%d) error = %v, want nil", i, err)
+		}
+	}
+
+	if size := q.Size(); size != 100 {
+		t.Errorf("Size after enqueues = %d, want 100", size)
+	}
+
+	var got []int
+	for {
+		val, err := q.Dequeue()
+		if errors.Is(err, ErrUnderflow) {
+			break
+		}
+		got = append(got, val)
+	}
+
+	sort.Ints(got)
+	if len(got) != 100 {
+		t.Fatalf("Dequeued %d items, want 100", len(got))
+	}
+	for i, v := range got {
+		if v != i {
+			t.Errorf("sorted dequeued item %d = %d, want %d", i, v, i)
+		}
+	}
+}
+
+func TestShardedSingleShardIsFIFO(t *testing.T) {
+	q := NewSharded[int](1)
+	for i := 0; i < 10; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	for i := 0; i < 10; i++ {
+		if val, _ := q.Dequeue(); val != i {
+			t.Errorf("Dequeue() at position %d = %d, want %d", i, val, i)
+		}
+	}
+}
+
+func TestShardedAggregateCapacity(t *testing.T) {
+	q := NewSharded[int](4, WithCapacity[int](6))
+
+	for i := 0; i < 6; i++ {
+		if err := q.Enqueue(i); err != nil {
+			t.Fatalf("Enqueue(%d) error = %v, want nil", i, err)
+		}
+	}
+
+	if err := q.Enqueue(6); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Enqueue(6) exceeding aggregate capacity error = %v, want ErrOverflow", err)
+	}
+	if r := q.Remaining(); r != 0 {
+		t.Errorf("Remaining() = %d, want 0", r)
+	}
+
+	_, _ = q.Dequeue()
+	if err := q.Enqueue(6); err != nil {
+		t.Errorf("Enqueue(6) after dequeue error = %v, want nil", err)
+	}
+
+	s := q.Stats()
+	if s.Enqueued != 7 || s.Dequeued != 1 || s.Rejected != 1 || s.PeakSize != 6 || s.CurrentSize != 6 {
+		t.Errorf("Stats() = %+v", s)
+	}
+}
+
+func TestShardedWholeQueueOperations(t *testing.T) {
+	q := NewSharded[int](3)
+	for i := 1; i <= 9; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	if removed := q.Filter(func(v int) bool { return v%2 == 0 }); removed != 5 {
+		t.Errorf("Filter() removed = %d, want 5", removed)
+	}
+	if size := q.Size(); size != 4 {
+		t.Errorf("Size after Filter() = %d, want 4", size)
+	}
+
+	sum := 0
+	q.ForEach(func(v int) bool {
+		sum += v
+		return true
+	})
+	if sum != 20 {
+		t.Errorf("ForEach() sum = %d, want 20", sum)
+	}
+
+	count := 0
+	for range q.All() {
+		count++
+	}
+	if count != 4 {
+		t.Errorf("All() yielded %d items, want 4", count)
+	}
+
+	if _, err := q.Peek(); err != nil {
+		t.Errorf("Peek() error = %v, want nil", err)
+	}
+}
+
+func TestShardedSetCapacity(t *testing.T) {
+	q := NewSharded[int](2, WithCapacity[int](4))
+	for i := 0; i < 4; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	if err := q.SetCapacity(2); !errors.Is(err, ErrCapacityTooSmall) {
+		t.Errorf("SetCapacity(2) error = %v, want ErrCapacityTooSmall", err)
+	}
+	if err := q.SetCapacity(-2); !errors.Is(err, ErrInvalidCapacity) {
+		t.Errorf("SetCapacity(-2) error = %v, want ErrInvalidCapacity", err)
+	}
+	if err := q.SetCapacity(UnlimitedCapacity); err != nil {
+		t.Errorf("SetCapacity(UnlimitedCapacity) error = %v, want nil", err)
+	}
+	if err := q.Enqueue(4); err != nil {
+		t.Errorf("Enqueue(4) after unlimiting error = %v, want nil", err)
+	}
+
+	d := NewSharded[int](2, WithShrinkPolicy[int](ShrinkDropOldest))
+	for i := 0; i < 6; i++ {
+		_ = d.Enqueue(i)
+	}
+	if err := d.SetCapacity(2); err != nil {
+		t.Fatalf("SetCapacity(2) with ShrinkDropOldest error = %v, want nil", err)
+	}
+	if size := d.Size(); size != 2 {
+		t.Errorf("Size after shrink = %d, want 2", size)
+	}
+}
+
+func TestShardedConcurrency(t *testing.T) {
+	q := NewSharded[int](8)
+	const numGoroutines = 50
+	const numOperations = 200
+
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			for j := 0; j < numOperations; j++ {
+				_ = q.Enqueue(start*numOperations + j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	results := make(chan int, numGoroutines*numOperations)
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numOperations; j++ {
+				if val, err := q.Dequeue(); err == nil {
+					results <- val
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[int]bool)
+	for val := range results {
+		if seen[val] {
+			t.Errorf("Duplicate value dequeued: %d", val)
+		}
+		seen[val] = true
+	}
+
+	if len(seen) != numGoroutines*numOperations {
+		t.Errorf("Dequeued %d items, want %d", len(seen), numGoroutines*numOperations)
+	}
+	if size := q.Size(); size != 0 {
+		t.Errorf("Size after concurrent dequeues = %d, want 0", size)
+	}
+}
+
+func BenchmarkContended(b *testing.B) {
+	queues := []struct {
+		name string
+		new  func() Queue[int]
+	}{
+		{name: "single-lock", new: func() Queue[int] { return New[int]() }},
+		{name: "sharded", new: func() Queue[int] { return NewSharded[int](16) }},
+	}
+
+	for _, bq := range queues {
+		b.Run(bq.name, func(b *testing.B) {
+			q := bq.new()
+			b.SetParallelism(16)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					_ = q.Enqueue(i)
+					_, _ = q.Dequeue()
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestShardedClose(t *testing.T) {
+	q := NewSharded[int](4, WithCapacity[int](10))
+	_ = q.Enqueue(1)
+
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v, want nil", err)
+	}
+	if err := q.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close() error = %v, want ErrClosed", err)
+	}
+	if err := q.Enqueue(2); !errors.Is(err, ErrClosed) {
+		t.Errorf("Enqueue() after Close() error = %v, want ErrClosed", err)
+	}
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size after Close() = %d, want 1", size)
+	}
+	if val, err := q.Dequeue(); err != nil || val != 1 {
+		t.Errorf("Dequeue() after Close() = %d, %v, want 1, nil", val, err)
+	}
+}
+
+func TestShardedCircular(t *testing.T) {
+	q := NewSharded[int](2, WithCapacity[int](3), WithCircular[int]())
+	for i := 0; i < 10; i++ {
+		if err := q.Enqueue(i); err != nil {
+			t.Fatalf("Enqueue(%d) error = %v, want nil", i, err)
+		}
+	}
+
+	if size := q.Size(); size != 3 {
+		t.Errorf("Size after overwrites = %d, want 3", size)
+	}
+	if s := q.Stats(); s.Rejected != 0 {
+		t.Errorf("Stats().Rejected = %d, want 0", s.Rejected)
+	}
+}
+
+func TestShardedReverse(t *testing.T) {
+	q := NewSharded[int](1)
+	for i := 1; i <= 3; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	q.Reverse()
+
+	for _, expected := range []int{3, 2, 1} {
+		if val, _ := q.Dequeue(); val != expected {
+			t.Errorf("Dequeue() after Reverse() = %d, want %d", val, expected)
+		}
+	}
+}
+
+func TestShardedRotate(t *testing.T) {
+	q := NewSharded[int](1)
+	for i := 1; i <= 3; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	q.Rotate(1)
+
+	for _, expected := range []int{2, 3, 1} {
+		if val, _ := q.Dequeue(); val != expected {
+			t.Errorf("Dequeue() after Rotate(1) = %d, want %d", val, expected)
+		}
+	}
+}
+
+func TestShardedEnqueueSlice(t *testing.T) {
+	q := NewSharded[int](2, WithCapacity[int](3))
+
+	inserted, err := q.EnqueueSlice([]int{1, 2, 3, 4})
+	if inserted != 3 || !errors.Is(err, ErrOverflow) {
+		t.Fatalf("EnqueueSlice() = (%d, %v), want (3, %v)", inserted, err, ErrOverflow)
+	}
+	if size := q.Size(); size != 3 {
+		t.Errorf("Size after EnqueueSlice = %d, want 3", size)
+	}
+}
+
+func TestShardedGrow(t *testing.T) {
+	q := newSharded[int](4, WithCapacity[int](10), WithInitialCapacity[int](0))
+
+	if err := q.Grow(100); err != nil {
+		t.Fatalf("Grow(100) = %v, want nil", err)
+	}
+	total := 0
+	for _, shard := range q.shards {
+		total += shard.items.cap()
+	}
+	if total < 10 || total > 12 {
+		t.Errorf("total backing cap after Grow(100) = %d, want 10 rounded up per shard", total)
+	}
+	if err := q.Grow(-1); !errors.Is(err, ErrInvalidCapacity) {
+		t.Errorf("Grow(-1) = %v, want %v", err, ErrInvalidCapacity)
+	}
+}
+
+func TestShardedCompact(t *testing.T) {
+	q := newSharded[int](2)
+	for i := 0; i < 100; i++ {
+		_ = q.Enqueue(i)
+	}
+	for i := 0; i < 96; i++ {
+		_, _ = q.Dequeue()
+	}
+
+	q.Compact()
+
+	for i, shard := range q.shards {
+		if c, n := shard.items.cap(), shard.items.len(); c != n {
+			t.Errorf("shard %d backing cap after Compact() = %d, want %d", i, c, n)
+		}
+	}
+	if size := q.Size(); size != 4 {
+		t.Errorf("Size after Compact() = %d, want 4", size)
+	}
+}
+
+func TestShardedDequeueUntil(t *testing.T) {
+	q := NewSharded[int](1)
+	for i := 1; i <= 4; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	if val, err := q.DequeueUntil(func(v int) bool { return v == 3 }); val != 3 || err != nil {
+		t.Errorf("DequeueUntil() = (%d, %v), want (3, nil)", val, err)
+	}
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size after DequeueUntil() = %d, want 1", size)
+	}
+}
+
+func TestShardedReset(t *testing.T) {
+	q := NewSharded[int](2, WithCapacity[int](2))
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+	_ = q.Enqueue(3)
+	_ = q.Close()
+
+	q.Reset()
+
+	if s := q.Stats(); s != (Stats{}) {
+		t.Errorf("Stats() after Reset() = %+v, want zero", s)
+	}
+	for i := 1; i <= 2; i++ {
+		if err := q.Enqueue(i); err != nil {
+			t.Fatalf("Enqueue(%d) after Reset() = %v, want nil", i, err)
+		}
+	}
+	if r := q.Remaining(); r != 0 {
+		t.Errorf("Remaining() = %d, want 0", r)
+	}
+}
+
+func TestShardedBack(t *testing.T) {
+	q := NewSharded[int](3)
+	for i := 1; i <= 4; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	if val, err := q.Back(); val != 4 || err != nil {
+		t.Errorf("Back() = (%d, %v), want (4, nil)", val, err)
+	}
+	if _, err := NewSharded[int](3).Back(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("Back() on empty queue = %v, want %v", err, ErrUnderflow)
+	}
+}
+
+func TestShardedRemove(t *testing.T) {
+	q := NewSharded[int](2)
+	for i := 1; i <= 4; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	items := slices.Collect(q.All())
+	if val, err := q.Remove(3); val != items[3] || err != nil {
+		t.Errorf("Remove(3) = (%d, %v), want (%d, nil)", val, err, items[3])
+	}
+	if size := q.Size(); size != 3 {
+		t.Errorf("Size after Remove() = %d, want 3", size)
+	}
+	if _, err := q.Remove(3); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("Remove(3) on 3 items = %v, want %v", err, ErrIndexOutOfRange)
+	}
+}
+
+func TestShardedSwap(t *testing.T) {
+	q := NewSharded[int](2)
+	for i := 1; i <= 4; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	before := slices.Collect(q.All())
+	if err := q.Swap(0, 3); err != nil {
+		t.Fatalf("Swap(0, 3) = %v, want nil", err)
+	}
+
+	after := slices.Collect(q.All())
+	want := slices.Clone(before)
+	want[0], want[3] = want[3], want[0]
+	if !slices.Equal(after, want) {
+		t.Errorf("All() after Swap(0, 3) = %v, want %v", after, want)
+	}
+	if err := q.Swap(0, 4); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("Swap(0, 4) = %v, want %v", err, ErrIndexOutOfRange)
+	}
+}
+
+func TestShardedDrain(t *testing.T) {
+	q := NewSharded[int](3)
+	for i := 1; i <= 5; i++ {
+		_ = q.Enqueue(i)
+	}
+
+	dst := make([]int, 2)
+	if n := q.DrainTo(dst); n != 2 {
+		t.Fatalf("DrainTo() = %d, want 2", n)
+	}
+	got := append(dst, q.Drain()...)
+	slices.Sort(got)
+	if !slices.Equal(got, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("DrainTo() and Drain() returned %v, want every item once", got)
+	}
+	if size := q.Size(); size != 0 {
+		t.Errorf("Size after Drain() = %d, want 0", size)
+	}
+}
+
+func TestShardedEnqueueDedupBack(t *testing.T) {
+	eq := func(a, b int) bool { return a == b }
+	q := NewSharded[int](3)
+	for _, v := range []int{1, 1, 2, 2, 3} {
+		_, _ = q.EnqueueDedupBack(v, eq)
+	}
+
+	if size := q.Size(); size != 3 {
+		t.Errorf("Size = %d, want 3", size)
+	}
+	if val, _ := q.Back(); val != 3 {
+		t.Errorf("Back() = %d, want 3", val)
+	}
+}
+
+func TestShardedEnqueueFrontAll(t *testing.T) {
+	q := NewSharded[int](2, WithCapacity[int](4))
+	_ = q.Enqueue(9)
+
+	if err := q.EnqueueFrontAll(1, 2, 3, 4); !errors.Is(err, ErrOverflow) {
+		t.Errorf("EnqueueFrontAll() past capacity = %v, want %v", err, ErrOverflow)
+	}
+	if err := q.EnqueueFrontAll(1, 2, 3); err != nil {
+		t.Fatalf("EnqueueFrontAll() = %v, want nil", err)
+	}
+	for _, want := range []int{1, 2, 3} {
+		if val, err := q.Dequeue(); val != want || err != nil {
+			t.Errorf("Dequeue() = (%d, %v), want (%d, nil)", val, err, want)
+		}
+	}
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size = %d, want 1", size)
+	}
+}
@@ -0,0 +1,897 @@
+package queue
+
+import (
+	"context"
+	"iter"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewSPSC creates a bounded queue for exactly one producer goroutine and one
+// consumer goroutine, implemented as a lock-free ring buffer whose head and
+// tail indexes are advanced with atomic operations. Enqueue and Dequeue never
+// take a lock: Enqueue returns ErrOverflow while the queue holds capacity
+// items, and Dequeue returns ErrUnderflow while it is empty.
+//
+// The queue is only safe for one producer and one consumer, which may be
+// different goroutines but must each stay the same goroutine, or be
+// otherwise synchronized by the caller:
+//   - The producer calls Enqueue, TryEnqueue, Offer, EnqueueSlice,
+//     EnqueueWait, EnqueueCtx, EnqueueTimeout and Merge
+//   - The consumer calls every other method that reads or changes the items,
+//     from Dequeue and its variants to Peek, Filter, Snapshot, String, Reset
+//     and CloseAndDrain. Channel starts a goroutine that takes over as the
+//     consumer until the channel is closed
+//   - Any goroutine may call Close, Size, ApproxSize, SizeBytes, Remaining,
+//     Grow, Compact, Version, Stats, Subscribe, NotEmpty, BlockedProducers,
+//     BlockedConsumers and Name
+//
+// EnqueueDedupBack, EnqueueFrontAll, EnqueuePush and SetCapacity would need
+// both ends of the buffer at once and return ErrUnsupported. Blocking calls
+// wait without spinning; waking them costs a single atomic load while nobody
+// waits.
+//
+// Example:
+//
+//	q := queue.NewSPSC[Event](1024)
+//	go func() {
+//		for ev := range events {
+//			q.EnqueueWait(ctx, ev) // The only producer
+//		}
+//		q.Close()
+//	}()
+//	for {
+//		ev, err := q.DequeueWait(ctx) // The only consumer
+//		if err != nil {
+//			break
+//		}
+//		handle(ev)
+//	}
+//
+// Panics if capacity < 1.
+func NewSPSC[T any](capacity int) Queue[T] {
+	return newSPSC[T](capacity)
+}
+
+type spsc[T any] struct {
+	buf         []T
+	head        atomic.Uint64 // Index of the front item, advanced only by the consumer
+	_           [56]byte      // Keeps head and tail on separate cache lines
+	tail        atomic.Uint64 // Index one past the back item, advanced only by the producer
+	_           [56]byte
+	edits       atomic.Uint64 // Bumped by the consumer for changes that keep head and tail
+	enqueued    atomic.Uint64
+	dequeued    atomic.Uint64
+	rejected    atomic.Uint64
+	peak        atomic.Int64
+	clock       Clock
+	waitMu      sync.Mutex // Guards enqueueWait and dequeueWait
+	enqueueWait WaitStats
+	dequeueWait WaitStats
+	notFull     signal
+	notEmpty    signal
+	closed      atomic.Bool
+	producers   gauge // Callers blocked in EnqueueWait
+	consumers   gauge // Callers blocked in DequeueWait or DequeueBatchWait
+	subs        subscribers
+}
+
+// singleConsumer lets Merge and Transfer recognize an SPSC queue without
+// naming its type, as readOnly does for views.
+func (*spsc[T]) singleConsumer() {}
+
+func newSPSC[T any](capacity int) *spsc[T] {
+	if capacity < 1 {
+		panic("cannot specify non-positive capacity for an SPSC queue")
+	}
+
+	return &spsc[T]{buf: make([]T, capacity), clock: realClock{}}
+}
+
+// slot returns the storage for the item at absolute index i.
+func (s *spsc[T]) slot(i uint64) *T {
+	return &s.buf[i%uint64(len(s.buf))]
+}
+
+// window returns the absolute indexes of the front item and one past the back
+// item. Only the consumer may rely on the items in between staying put.
+func (s *spsc[T]) window() (uint64, uint64) {
+	head := s.head.Load()
+	return head, s.tail.Load()
+}
+
+// items returns a copy of the items in FIFO order. Only the consumer may call it.
+func (s *spsc[T]) items() ([]T, uint64) {
+	head, tail := s.window()
+	result := make([]T, tail-head)
+	for i := range result {
+		result[i] = *s.slot(head + uint64(i))
+	}
+
+	return result, tail
+}
+
+// store writes vals over the items starting at absolute index head. Only the
+// consumer may call it.
+func (s *spsc[T]) store(head uint64, vals []T) {
+	for i, val := range vals {
+		*s.slot(head + uint64(i)) = val
+	}
+}
+
+// push adds val at the back, reporting whether it fit. Only the producer may
+// call it.
+func (s *spsc[T]) push(val T) bool {
+	return s.pushAll([]T{val})
+}
+
+// pushAll adds vals at the back, all at once if they fit, and reports
+// whether they did. Only the producer may call it.
+func (s *spsc[T]) pushAll(vals []T) bool {
+	n := uint64(len(vals))
+	tail := s.tail.Load()
+	size := tail - s.head.Load()
+	if size+n > uint64(len(s.buf)) {
+		return false
+	}
+	if n == 0 {
+		return true
+	}
+
+	for i, val := range vals {
+		*s.slot(tail + uint64(i)) = val
+	}
+	s.tail.Store(tail + n)
+	s.enqueued.Add(n)
+	if peak := int64(size + n); peak > s.peak.Load() {
+		s.peak.Store(peak)
+	}
+	s.notEmpty.broadcast()
+	s.subs.notify(int(size + n))
+
+	return true
+}
+
+// pop removes and returns the front item, reporting whether there was one.
+// Only the consumer may call it.
+func (s *spsc[T]) pop() (T, bool) {
+	var zero T
+	head, tail := s.window()
+	if head == tail {
+		return zero, false
+	}
+
+	p := s.slot(head)
+	val := *p
+	*p = zero
+	s.head.Store(head + 1)
+	s.dequeued.Add(1)
+	s.removed()
+
+	return val, true
+}
+
+// dropFront zeroes the first n items and advances the head past them, without
+// counting them as dequeued. Only the consumer may call it.
+func (s *spsc[T]) dropFront(head uint64, n int) {
+	if n == 0 {
+		return
+	}
+
+	var zero T
+	for i := range n {
+		*s.slot(head + uint64(i)) = zero
+	}
+	s.head.Store(head + uint64(n))
+	s.removed()
+}
+
+// removed wakes blocked producers and notifies subscribers after the head
+// has advanced.
+func (s *spsc[T]) removed() {
+	s.notFull.broadcast()
+	s.subs.notify(s.Size())
+}
+
+func (s *spsc[T]) Enqueue(val T) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if !s.push(val) {
+		s.rejected.Add(1)
+		return ErrOverflow
+	}
+
+	return nil
+}
+
+// EnqueueDedupBack always returns ErrUnsupported, since the producer cannot
+// read the back item while the consumer may be removing it.
+func (s *spsc[T]) EnqueueDedupBack(T, func(a, b T) bool) (bool, error) {
+	return false, ErrUnsupported
+}
+
+// EnqueueFrontAll always returns ErrUnsupported, since only the consumer may
+// move the front of the queue.
+func (s *spsc[T]) EnqueueFrontAll(...T) error {
+	return ErrUnsupported
+}
+
+// EnqueuePush always returns ErrUnsupported, since the producer cannot evict
+// the front item.
+func (s *spsc[T]) EnqueuePush(T) (evicted T, didEvict bool, err error) {
+	return evicted, false, ErrUnsupported
+}
+
+func (s *spsc[T]) TryEnqueue(val T) bool {
+	return s.Enqueue(val) == nil
+}
+
+func (s *spsc[T]) Offer(val T) bool {
+	return s.TryEnqueue(val)
+}
+
+func (s *spsc[T]) EnqueueSlice(vals []T) (int, error) {
+	for i, val := range vals {
+		if err := s.Enqueue(val); err != nil {
+			return i, err
+		}
+	}
+
+	return len(vals), nil
+}
+
+func (s *spsc[T]) EnqueueWait(ctx context.Context, val T) error {
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in s.producers
+	defer s.producers.leave(&blocked)
+	for {
+		// Register before checking so a dequeue in between still wakes us
+		ready := s.notFull.wait()
+		if s.closed.Load() {
+			s.notFull.done()
+			return ErrClosed
+		}
+		if s.push(val) {
+			s.notFull.done()
+			s.waited(&s.enqueueWait, since)
+			return nil
+		}
+
+		if since.IsZero() {
+			since = s.clock.Now()
+		}
+		s.producers.enter(&blocked)
+		err := waitFor(ctx, ready)
+		s.notFull.done()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *spsc[T]) EnqueueCtx(ctx context.Context, val T) error {
+	return enqueueCtx(ctx, s, val)
+}
+
+// EnqueueAllCtx writes the whole batch before advancing the tail once, so
+// the consumer sees either none of the items or all of them.
+func (s *spsc[T]) EnqueueAllCtx(ctx context.Context, vals ...T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(vals) > len(s.buf) {
+		s.rejected.Add(1)
+		return ErrOverflow
+	}
+
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in s.producers
+	defer s.producers.leave(&blocked)
+	for {
+		ready := s.notFull.wait()
+		if s.closed.Load() {
+			s.notFull.done()
+			return ErrClosed
+		}
+		if s.pushAll(vals) {
+			s.notFull.done()
+			s.waited(&s.enqueueWait, since)
+			return nil
+		}
+
+		if since.IsZero() {
+			since = s.clock.Now()
+		}
+		s.producers.enter(&blocked)
+		err := waitFor(ctx, ready)
+		s.notFull.done()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *spsc[T]) EnqueueTimeout(val T, d time.Duration) error {
+	return enqueueTimeout(s, s.clock, val, d)
+}
+
+func (s *spsc[T]) Dequeue() (T, error) {
+	val, ok := s.pop()
+	if !ok {
+		return val, ErrUnderflow
+	}
+
+	return val, nil
+}
+
+func (s *spsc[T]) DequeueUntil(pred func(T) bool) (T, error) {
+	for {
+		val, ok := s.pop()
+		if !ok {
+			return val, ErrUnderflow
+		}
+		if pred(val) {
+			return val, nil
+		}
+	}
+}
+
+func (s *spsc[T]) DequeueMatch(pred func(T) bool) (T, error) {
+	head, tail := s.window()
+	if head == tail {
+		var zero T
+		return zero, ErrUnderflow
+	}
+
+	for i := range int(tail - head) {
+		if pred(*s.slot(head + uint64(i))) {
+			s.dequeued.Add(1)
+			return s.Remove(i)
+		}
+	}
+
+	var zero T
+	return zero, ErrNotFound
+}
+
+func (s *spsc[T]) Drain() []T {
+	result, _ := s.items()
+	s.drained(len(result))
+
+	return result
+}
+
+func (s *spsc[T]) DrainTo(dst []T) int {
+	head, tail := s.window()
+	n := min(len(dst), int(tail-head))
+	for i := range n {
+		dst[i] = *s.slot(head + uint64(i))
+	}
+	s.drained(n)
+
+	return n
+}
+
+// drained removes the first n items, which the caller has copied out, and
+// counts them as dequeued.
+func (s *spsc[T]) drained(n int) {
+	s.dequeued.Add(uint64(n))
+	s.dropFront(s.head.Load(), n)
+}
+
+func (s *spsc[T]) TryDequeue() (T, bool) {
+	return s.pop()
+}
+
+func (s *spsc[T]) Poll() (T, bool) {
+	return s.pop()
+}
+
+func (s *spsc[T]) DequeueOr(def T) T {
+	if val, ok := s.TryDequeue(); ok {
+		return val
+	}
+
+	return def
+}
+
+func (s *spsc[T]) DequeueWait(ctx context.Context) (T, error) {
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in s.consumers
+	defer s.consumers.leave(&blocked)
+	for {
+		ready := s.notEmpty.wait()
+		if val, ok := s.pop(); ok {
+			s.notEmpty.done()
+			s.waited(&s.dequeueWait, since)
+			return val, nil
+		}
+		if s.closed.Load() {
+			s.notEmpty.done()
+
+			var zero T
+			return zero, ErrClosed
+		}
+
+		if since.IsZero() {
+			since = s.clock.Now()
+		}
+		s.consumers.enter(&blocked)
+		err := waitFor(ctx, ready)
+		s.notEmpty.done()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+func (s *spsc[T]) BlockedProducers() int {
+	return s.producers.count()
+}
+
+func (s *spsc[T]) BlockedConsumers() int {
+	return s.consumers.count()
+}
+
+func (s *spsc[T]) DequeueBatchWait(ctx context.Context, n int, maxWait time.Duration) ([]T, error) {
+	return dequeueBatchWait(ctx, s, s.clock, &s.notEmpty, &s.consumers, func() (int, bool) {
+		return s.Size(), s.closed.Load()
+	}, n, maxWait)
+}
+
+func (s *spsc[T]) NotEmpty() <-chan struct{} {
+	// Watch before checking so an enqueue that lands in between still fires
+	// the returned channel
+	ready := s.notEmpty.watch()
+	if s.Size() > 0 || s.closed.Load() {
+		return fired
+	}
+
+	return ready
+}
+
+func (s *spsc[T]) NotFull() <-chan struct{} {
+	// Watch before checking so a dequeue that lands in between still fires
+	// the returned channel
+	ready := s.notFull.watch()
+	if s.Size() < len(s.buf) || s.closed.Load() {
+		return fired
+	}
+
+	return ready
+}
+
+func (s *spsc[T]) DequeueCtx(ctx context.Context) (T, error) {
+	return dequeueCtx(ctx, s)
+}
+
+func (s *spsc[T]) DequeueTimeout(d time.Duration) (T, error) {
+	return dequeueTimeout(s, s.clock, d)
+}
+
+func (s *spsc[T]) Channel(ctx context.Context) <-chan T {
+	return channel(ctx, s)
+}
+
+func (s *spsc[T]) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+
+	s.notFull.broadcast()
+	s.notEmpty.broadcast()
+
+	return nil
+}
+
+// CloseAndDrain drains the items held once the queue is closed. An enqueue
+// already past its closed check when Close runs may still add an item after
+// draining.
+func (s *spsc[T]) CloseAndDrain(fn func(T)) []T {
+	_ = s.Close()
+	result := s.Drain()
+	handOff(result, fn)
+
+	return result
+}
+
+func (s *spsc[T]) Reset() {
+	head, tail := s.window()
+	s.dropFront(head, int(tail-head))
+	s.enqueued.Store(0)
+	s.dequeued.Store(0)
+	s.rejected.Store(0)
+	s.peak.Store(0)
+	s.waitMu.Lock()
+	s.enqueueWait, s.dequeueWait = WaitStats{}, WaitStats{}
+	s.waitMu.Unlock()
+	s.closed.Store(false)
+	s.notFull.broadcast()
+}
+
+func (s *spsc[T]) Size() int {
+	head, tail := s.window()
+
+	// The head may have moved on after it was read, so the difference can
+	// briefly exceed the capacity
+	return min(int(tail-head), len(s.buf))
+}
+
+// ApproxSize is as cheap as Size, which reads the same atomic indexes.
+func (s *spsc[T]) ApproxSize() int {
+	return s.Size()
+}
+
+// SizeBytes always returns 0, since an SPSC queue has no byte limit.
+func (s *spsc[T]) SizeBytes() int {
+	return 0
+}
+
+func (s *spsc[T]) Remaining() int {
+	return len(s.buf) - s.Size()
+}
+
+// SetCapacity returns ErrUnsupported for any valid n, since the buffer
+// cannot be replaced while the producer and consumer use it.
+func (s *spsc[T]) SetCapacity(n int) error {
+	if n < UnlimitedCapacity {
+		return ErrInvalidCapacity
+	}
+
+	return ErrUnsupported
+}
+
+// Grow does nothing beyond validating n, since the whole buffer is allocated
+// up front.
+func (s *spsc[T]) Grow(n int) error {
+	if n < 0 {
+		return ErrInvalidCapacity
+	}
+
+	return nil
+}
+
+// Compact does nothing, since the buffer has a fixed size.
+func (s *spsc[T]) Compact() {}
+
+func (s *spsc[T]) Peek() (T, error) {
+	head, tail := s.window()
+	if head == tail {
+		var zero T
+		return zero, ErrUnderflow
+	}
+
+	return *s.slot(head), nil
+}
+
+func (s *spsc[T]) Front() (T, error) {
+	return s.Peek()
+}
+
+func (s *spsc[T]) PeekOr(def T) T {
+	if val, err := s.Peek(); err == nil {
+		return val
+	}
+
+	return def
+}
+
+func (s *spsc[T]) Back() (T, error) {
+	head, tail := s.window()
+	if head == tail {
+		var zero T
+		return zero, ErrUnderflow
+	}
+
+	return *s.slot(tail - 1), nil
+}
+
+func (s *spsc[T]) PeekN(n int) ([]T, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+
+	head, tail := s.window()
+	if head == tail {
+		return []T{}, ErrUnderflow
+	}
+	result := make([]T, min(n, int(tail-head)))
+	for i := range result {
+		result[i] = *s.slot(head + uint64(i))
+	}
+
+	return result, nil
+}
+
+func (s *spsc[T]) ReplaceFront(val T) error {
+	head, tail := s.window()
+	if head == tail {
+		return ErrUnderflow
+	}
+
+	*s.slot(head) = val
+	s.edits.Add(1)
+
+	return nil
+}
+
+func (s *spsc[T]) ReplaceBack(val T) error {
+	head, tail := s.window()
+	if head == tail {
+		return ErrUnderflow
+	}
+
+	*s.slot(tail - 1) = val
+	s.edits.Add(1)
+
+	return nil
+}
+
+func (s *spsc[T]) Reverse() {
+	vals, _ := s.items()
+	slices.Reverse(vals)
+	s.rearranged(vals)
+}
+
+func (s *spsc[T]) Rotate(n int) {
+	vals, _ := s.items()
+	if len(vals) == 0 {
+		return
+	}
+	n %= len(vals)
+	if n < 0 {
+		n += len(vals)
+	}
+	s.rearranged(slices.Concat(vals[n:], vals[:n]))
+}
+
+func (s *spsc[T]) Shuffle(r *rand.Rand) {
+	shuffle := rand.Shuffle
+	if r != nil {
+		shuffle = r.Shuffle
+	}
+
+	vals, _ := s.items()
+	shuffle(len(vals), func(i, j int) { vals[i], vals[j] = vals[j], vals[i] })
+	s.rearranged(vals)
+}
+
+// rearranged writes vals, a reordering of the items, back over them.
+func (s *spsc[T]) rearranged(vals []T) {
+	s.store(s.head.Load(), vals)
+	s.edits.Add(1)
+}
+
+func (s *spsc[T]) ForEach(fn func(T) bool) {
+	head, tail := s.window()
+	for i := head; i < tail; i++ {
+		if !fn(*s.slot(i)) {
+			return
+		}
+	}
+}
+
+func (s *spsc[T]) ForEachCtx(ctx context.Context, fn func(T) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	head, tail := s.window()
+	for i := head; i < tail; i++ {
+		if err := interrupted(ctx, int(i-head)); err != nil {
+			return err
+		}
+		if !fn(*s.slot(i)) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s *spsc[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		vals, _ := s.items()
+		for _, v := range vals {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func (s *spsc[T]) AllIndexed() iter.Seq2[int, T] {
+	return allIndexed(s.All())
+}
+
+// Version is the sum of the head and tail indexes and a count of in-place
+// changes, each of which only increases.
+func (s *spsc[T]) Version() uint64 {
+	head, tail := s.window()
+	return head + tail + s.edits.Load()
+}
+
+func (s *spsc[T]) Snapshot() ([]T, uint64) {
+	vals, tail := s.items()
+	return vals, s.head.Load() + tail + s.edits.Load()
+}
+
+func (s *spsc[T]) Filter(keep func(T) bool) int {
+	removed, _ := s.FilterCtx(context.Background(), keep)
+	return removed
+}
+
+// FilterCtx moves the kept items towards the back and then advances the head
+// past the gap, so it never touches the slots the producer writes.
+func (s *spsc[T]) FilterCtx(ctx context.Context, keep func(T) bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// vals is a copy, so compacting it leaves the queue as it was until the
+	// kept items are stored
+	vals, _ := s.items()
+	kept := vals[:0]
+	for i, v := range vals {
+		if err := interrupted(ctx, i); err != nil {
+			return 0, err
+		}
+		if keep(v) {
+			kept = append(kept, v)
+		}
+	}
+	removed := len(vals) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	head := s.head.Load()
+	s.store(head+uint64(removed), kept)
+	s.dropFront(head, removed)
+
+	return removed, nil
+}
+
+func (s *spsc[T]) Remove(i int) (T, error) {
+	head, tail := s.window()
+	if i < 0 || i >= int(tail-head) {
+		var zero T
+		return zero, ErrIndexOutOfRange
+	}
+
+	// Shift the items before i one slot towards the back
+	val := *s.slot(head + uint64(i))
+	for j := head + uint64(i); j > head; j-- {
+		*s.slot(j) = *s.slot(j - 1)
+	}
+	s.dropFront(head, 1)
+
+	return val, nil
+}
+
+func (s *spsc[T]) FindIndex(pred func(T) bool) int {
+	head, tail := s.window()
+	for i := head; i < tail; i++ {
+		if pred(*s.slot(i)) {
+			return int(i - head)
+		}
+	}
+
+	return -1
+}
+
+func (s *spsc[T]) Swap(i, j int) error {
+	head, tail := s.window()
+	n := int(tail - head)
+	if i < 0 || i >= n || j < 0 || j >= n {
+		return ErrIndexOutOfRange
+	}
+	if i == j {
+		return nil
+	}
+
+	a, b := s.slot(head+uint64(i)), s.slot(head+uint64(j))
+	*a, *b = *b, *a
+	s.edits.Add(1)
+
+	return nil
+}
+
+// Merge must be called by the producer. It takes the items of other one at
+// a time, so it must also be the only consumer of other while it runs.
+func (s *spsc[T]) Merge(other Queue[T]) error {
+	if other == Queue[T](s) {
+		return nil
+	}
+
+	return mergeEach(s, other)
+}
+
+func (s *spsc[T]) admits(vals []T, replace bool) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
+	n := len(vals)
+	if replace {
+		n -= s.Size()
+	}
+	if n > s.Remaining() {
+		return ErrOverflow
+	}
+
+	return nil
+}
+
+// Split returns an SPSC queue with the same capacity, whose producer and
+// consumer may differ from those of s.
+func (s *spsc[T]) Split(n int) (Queue[T], error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+
+	head := newSPSC[T](len(s.buf))
+	for ; n > 0; n-- {
+		val, ok := s.pop()
+		if !ok {
+			break
+		}
+		head.push(val)
+	}
+
+	return head, nil
+}
+
+func (s *spsc[T]) CopyInto(dst Queue[T]) error {
+	if dst == Queue[T](s) {
+		return nil
+	}
+	vals, _ := s.items()
+
+	return copyEach(vals, dst)
+}
+
+func (s *spsc[T]) Stats() Stats {
+	st := Stats{
+		Enqueued:    s.enqueued.Load(),
+		Dequeued:    s.dequeued.Load(),
+		Rejected:    s.rejected.Load(),
+		PeakSize:    int(s.peak.Load()),
+		CurrentSize: s.Size(),
+	}
+	s.waitMu.Lock()
+	st.EnqueueWait, st.DequeueWait = s.enqueueWait, s.dequeueWait
+	s.waitMu.Unlock()
+
+	return st
+}
+
+// waited records in w a blocking call that began to wait at since and has now
+// succeeded, unless since is zero because the call never blocked.
+func (s *spsc[T]) waited(w *WaitStats, since time.Time) {
+	if since.IsZero() {
+		return
+	}
+
+	now := s.clock.Now()
+	s.waitMu.Lock()
+	w.record(since, now)
+	s.waitMu.Unlock()
+}
+
+func (s *spsc[T]) String() string {
+	vals, _ := s.items()
+	return formatQueue("", len(vals), len(s.buf), func(i int) T {
+		return vals[i]
+	})
+}
+
+// Name always returns "", since an SPSC queue takes no options.
+func (s *spsc[T]) Name() string {
+	return ""
+}
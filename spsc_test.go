@@ -0,0 +1,144 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestNewSPSC(t *testing.T) {
+	q := NewSPSC[int](2)
+	if q == nil {
+		t.Fatal("NewSPSC() returned nil")
+	}
+	if r := q.Remaining(); r != 2 {
+		t.Errorf("Remaining() = %d, want 2", r)
+	}
+
+	t.Run("non-positive capacity (should panic)", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("NewSPSC(0) should panic, but it didn't")
+			}
+		}()
+
+		NewSPSC[int](0)
+	})
+}
+
+func TestSPSCBounds(t *testing.T) {
+	q := NewSPSC[int](3)
+	if _, err := q.Dequeue(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("Dequeue() on empty queue = %v, want %v", err, ErrUnderflow)
+	}
+
+	// Go round the buffer a few times so the indexes wrap
+	for round := 0; round < 3; round++ {
+		for i := 1; i <= 3; i++ {
+			if err := q.Enqueue(i); err != nil {
+				t.Fatalf("Enqueue(%d) = %v, want nil", i, err)
+			}
+		}
+		if err := q.Enqueue(4); !errors.Is(err, ErrOverflow) {
+			t.Errorf("Enqueue() on full queue = %v, want %v", err, ErrOverflow)
+		}
+		for _, expected := range []int{1, 2, 3} {
+			if val, err := q.Dequeue(); val != expected || err != nil {
+				t.Errorf("Dequeue() = (%d, %v), want (%d, nil)", val, err, expected)
+			}
+		}
+	}
+
+	st := q.Stats()
+	if st.Enqueued != 9 || st.Dequeued != 9 || st.Rejected != 3 || st.PeakSize != 3 {
+		t.Errorf("Stats() = %+v, want 9 enqueued, 9 dequeued, 3 rejected, peak 3", st)
+	}
+}
+
+func TestSPSCConcurrent(t *testing.T) {
+	const n = 10000
+	q := NewSPSC[int](16)
+
+	go func() {
+		for i := 0; i < n; i++ {
+			_ = q.EnqueueWait(context.Background(), i)
+		}
+		_ = q.Close()
+	}()
+
+	for i := 0; ; i++ {
+		val, err := q.DequeueWait(context.Background())
+		if errors.Is(err, ErrClosed) {
+			if i != n {
+				t.Errorf("Dequeued %d items, want %d", i, n)
+			}
+			return
+		}
+		if val != i {
+			t.Fatalf("Dequeue() = %d, want %d", val, i)
+		}
+	}
+}
+
+func TestSPSCConsumerEdits(t *testing.T) {
+	q := NewSPSC[int](5)
+	_, _ = q.EnqueueSlice([]int{1, 2, 3, 4, 5})
+	_, _ = q.Dequeue()
+	_ = q.Enqueue(6) // Wrap around the end of the buffer
+
+	if removed := q.Filter(func(v int) bool { return v%2 == 0 }); removed != 2 {
+		t.Errorf("Filter() = %d, want 2", removed)
+	}
+	if val, err := q.Remove(1); val != 4 || err != nil {
+		t.Errorf("Remove(1) = (%d, %v), want (4, nil)", val, err)
+	}
+	_ = q.Enqueue(7)
+	q.Reverse()
+
+	if got, want := slices.Collect(q.All()), []int{7, 6, 2}; !slices.Equal(got, want) {
+		t.Errorf("All() = %v, want %v", got, want)
+	}
+}
+
+func TestSPSCUnsupported(t *testing.T) {
+	q := NewSPSC[int](2)
+	_ = q.Enqueue(1)
+
+	if _, err := q.EnqueueDedupBack(1, func(a, b int) bool { return a == b }); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("EnqueueDedupBack() = %v, want %v", err, ErrUnsupported)
+	}
+	if _, _, err := q.EnqueuePush(2); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("EnqueuePush() = %v, want %v", err, ErrUnsupported)
+	}
+	if err := q.SetCapacity(4); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("SetCapacity(4) = %v, want %v", err, ErrUnsupported)
+	}
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size() after unsupported calls = %d, want 1", size)
+	}
+	if Supports(q, CapabilityConcurrent) {
+		t.Error("Supports(CapabilityConcurrent) = true for an SPSC queue")
+	}
+}
+
+func BenchmarkSPSC(b *testing.B) {
+	for name, q := range map[string]Queue[int]{
+		"locked": New[int](WithCapacity[int](1024)),
+		"spsc":   NewSPSC[int](1024),
+	} {
+		b.Run(name, func(b *testing.B) {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < b.N; i++ {
+					_ = q.EnqueueWait(context.Background(), i)
+				}
+			}()
+			for i := 0; i < b.N; i++ {
+				_, _ = q.DequeueWait(context.Background())
+			}
+			<-done
+		})
+	}
+}
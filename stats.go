@@ -0,0 +1,85 @@
+package queue
+
+import "time"
+
+// Stats is a point-in-time snapshot of a queue's counters.
+//
+// Counters are maintained internally under the queue lock and accumulate for
+// the lifetime of the queue. A Stats value is a copy; it does not change as
+// the queue is used afterwards.
+//
+// Example:
+//
+//	q := queue.New[int](queue.WithCapacity[int](1))
+//	q.Enqueue(1)
+//	q.Enqueue(2) // Returns ErrOverflow
+//	s := q.Stats()
+//	fmt.Println(s.Enqueued, s.Rejected) // 1 1
+type Stats struct {
+	// Name is the name given with WithName, or "" if the queue is unnamed.
+	Name string
+
+	// Enqueued is the number of items successfully added.
+	Enqueued uint64
+
+	// Dequeued is the number of items successfully removed.
+	Dequeued uint64
+
+	// Rejected is the number of enqueue attempts that failed with ErrOverflow.
+	Rejected uint64
+
+	// Expired is the number of items an expiring queue discarded because
+	// their TTL passed. It is always zero for other queues.
+	Expired uint64
+
+	// LogErrors is the number of records a queue created with WithWAL could not
+	// encode or write. It is always zero for other queues.
+	LogErrors uint64
+
+	// PeakSize is the largest number of items ever held at once.
+	PeakSize int
+
+	// CurrentSize is the number of items held when the snapshot was taken.
+	CurrentSize int
+
+	// EnqueueWait is the time enqueues spent blocked on a full queue, counting
+	// only calls to EnqueueWait (and blocking Enqueue) that then succeeded.
+	EnqueueWait WaitStats
+
+	// DequeueWait is the time dequeues spent blocked on an empty queue,
+	// counting only calls to DequeueWait (and blocking Dequeue) that then
+	// succeeded.
+	DequeueWait WaitStats
+}
+
+// WaitStats summarizes the waits of blocking calls, as measured by the queue's
+// Clock. Calls that succeed without blocking are not counted.
+type WaitStats struct {
+	// Count is the number of calls that blocked before succeeding.
+	Count uint64
+
+	// Total is the time those calls spent blocked, summed.
+	Total time.Duration
+
+	// Max is the longest time a single call spent blocked.
+	Max time.Duration
+}
+
+// record adds a wait that began at since and ended at now.
+func (w *WaitStats) record(since, now time.Time) {
+	d := now.Sub(since)
+	w.Count++
+	w.Total += d
+	w.Max = max(w.Max, d)
+}
+
+func (q *queue[T]) Stats() Stats {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	s := q.stats
+	s.Name = q.name
+	s.CurrentSize = q.items.len()
+
+	return s
+}
@@ -0,0 +1,212 @@
+package queue
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	q := New[int](WithCapacity[int](3))
+
+	// Known sequence: 3 enqueues, 2 rejections, 2 dequeues, 1 enqueue, 1 underflow
+	for i := 0; i < 5; i++ {
+		_ = q.Enqueue(i)
+	}
+	_, _ = q.Dequeue()
+	_, _ = q.Dequeue()
+	_ = q.Enqueue(5)
+	_, _ = q.Dequeue()
+	_, _ = q.Dequeue()
+	_, _ = q.Dequeue() // Underflow, not counted
+
+	s := q.Stats()
+	want := Stats{
+		Enqueued:    4,
+		Dequeued:    4,
+		Rejected:    2,
+		PeakSize:    3,
+		CurrentSize: 0,
+	}
+	if s != want {
+		t.Errorf("Stats() = %+v, want %+v", s, want)
+	}
+}
+
+func TestStatsIsCopy(t *testing.T) {
+	q := New[int]()
+	_ = q.Enqueue(1)
+
+	s := q.Stats()
+	_ = q.Enqueue(2)
+
+	if s.Enqueued != 1 || s.CurrentSize != 1 {
+		t.Errorf("earlier Stats() snapshot changed: %+v", s)
+	}
+	if s := q.Stats(); s.Enqueued != 2 || s.CurrentSize != 2 {
+		t.Errorf("Stats() = %+v, want Enqueued=2 CurrentSize=2", s)
+	}
+}
+
+func TestStatsConcurrent(t *testing.T) {
+	q := New[int](WithCapacity[int](50))
+	const numGoroutines = 20
+	const numOperations = 100
+
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numOperations; j++ {
+				_ = q.Enqueue(j)
+				_ = q.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	s := q.Stats()
+	if total := s.Enqueued + s.Rejected; total != numGoroutines*numOperations {
+		t.Errorf("Enqueued + Rejected = %d, want %d", total, numGoroutines*numOperations)
+	}
+	if s.Enqueued != 50 || s.PeakSize != 50 || s.CurrentSize != 50 {
+		t.Errorf("Stats() = %+v, want Enqueued=50 PeakSize=50 CurrentSize=50", s)
+	}
+}
+
+// awaitWaiter blocks until sig has a registered waiter.
+func awaitWaiter(t *testing.T, sig *signal) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for sig.waiters.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for a blocked caller")
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestStatsWaits(t *testing.T) {
+	tests := []struct {
+		name    string
+		newFn   func(Clock) Queue[int]
+		signals func(Queue[int]) (notFull, notEmpty *signal)
+	}{
+		{
+			name:  "fifo",
+			newFn: func(c Clock) Queue[int] { return New(WithCapacity[int](1), WithClock[int](c)) },
+			signals: func(q Queue[int]) (*signal, *signal) {
+				return &q.(*queue[int]).notFull, &q.(*queue[int]).notEmpty
+			},
+		},
+		{
+			name:  "blocking",
+			newFn: func(c Clock) Queue[int] { return NewBlocking(1, WithClock[int](c)) },
+			signals: func(q Queue[int]) (*signal, *signal) {
+				return &q.(*blocking[int]).notFull, &q.(*blocking[int]).notEmpty
+			},
+		},
+		{
+			name:  "expiring",
+			newFn: func(c Clock) Queue[int] { return NewExpiring(WithCapacity[int](1), WithClock[int](c)) },
+			signals: func(q Queue[int]) (*signal, *signal) {
+				return &q.(*expiring[int]).q.notFull, &q.(*expiring[int]).q.notEmpty
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			q := tt.newFn(clock)
+			notFull, notEmpty := tt.signals(q)
+			ctx := context.Background()
+
+			// Waits that do not block are not counted
+			_ = q.EnqueueWait(ctx, 1)
+			if s := q.Stats(); s.EnqueueWait != (WaitStats{}) {
+				t.Errorf("Stats().EnqueueWait without blocking = %+v, want zero", s.EnqueueWait)
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- q.EnqueueWait(ctx, 2) }()
+			awaitWaiter(t, notFull)
+			clock.Advance(3 * time.Second)
+			_, _ = q.TryDequeue()
+			if err := <-done; err != nil {
+				t.Fatalf("EnqueueWait() = %v, want nil", err)
+			}
+			_, _ = q.TryDequeue()
+
+			for _, d := range []time.Duration{time.Second, 5 * time.Second} {
+				go func() {
+					_, err := q.DequeueWait(ctx)
+					done <- err
+				}()
+				awaitWaiter(t, notEmpty)
+				clock.Advance(d)
+				_ = q.TryEnqueue(3)
+				if err := <-done; err != nil {
+					t.Fatalf("DequeueWait() = %v, want nil", err)
+				}
+			}
+
+			s := q.Stats()
+			want := WaitStats{Count: 1, Total: 3 * time.Second, Max: 3 * time.Second}
+			if s.EnqueueWait != want {
+				t.Errorf("Stats().EnqueueWait = %+v, want %+v", s.EnqueueWait, want)
+			}
+			want = WaitStats{Count: 2, Total: 6 * time.Second, Max: 5 * time.Second}
+			if s.DequeueWait != want {
+				t.Errorf("Stats().DequeueWait = %+v, want %+v", s.DequeueWait, want)
+			}
+		})
+	}
+}
+
+// readClock counts the calls to Now, which blocking calls make only once they
+// are about to wait.
+type readClock struct {
+	*fakeClock
+	reads atomic.Int32
+}
+
+func (c *readClock) Now() time.Time {
+	c.reads.Add(1)
+	return c.fakeClock.Now()
+}
+
+func TestShardedStatsWaits(t *testing.T) {
+	clock := &readClock{fakeClock: newFakeClock()}
+	q := NewSharded(2, WithCapacity[int](1), WithClock[int](clock))
+	done := make(chan error, 1)
+	go func() {
+		_, err := q.DequeueWait(context.Background())
+		done <- err
+	}()
+
+	// Sharded waiters register before checking, so wait for the clock read
+	// made once the consumer has found the queue empty
+	for clock.reads.Load() == 0 {
+		runtime.Gosched()
+	}
+	clock.Advance(2 * time.Second)
+	_ = q.Enqueue(1)
+	if err := <-done; err != nil {
+		t.Fatalf("DequeueWait() = %v, want nil", err)
+	}
+
+	want := WaitStats{Count: 1, Total: 2 * time.Second, Max: 2 * time.Second}
+	if s := q.Stats(); s.DequeueWait != want || s.EnqueueWait != (WaitStats{}) {
+		t.Errorf("Stats() waits = %+v / %+v, want %+v / zero", s.DequeueWait, s.EnqueueWait, want)
+	}
+
+	q.Reset()
+	if s := q.Stats(); s.DequeueWait != (WaitStats{}) {
+		t.Errorf("Stats().DequeueWait after Reset() = %+v, want zero", s.DequeueWait)
+	}
+}
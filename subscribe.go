@@ -0,0 +1,94 @@
+package queue
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// subscribeBuffer is the number of size events a subscriber channel holds
+// before further events are dropped.
+const subscribeBuffer = 16
+
+// subscribers fans size changes out to the channels handed out by Subscribe.
+//
+// Sends never block: an event is dropped for a subscriber whose channel is
+// full. When nobody is subscribed, notify is a single atomic load, so calling
+// it on every mutation costs almost nothing.
+//
+// The zero value is ready to use.
+type subscribers struct {
+	mu    sync.Mutex
+	chans []chan int
+	count atomic.Int32
+}
+
+// subscribe registers a new channel and returns it together with the function
+// that unregisters and closes it.
+func (s *subscribers) subscribe() (<-chan int, func()) {
+	ch := make(chan int, subscribeBuffer)
+
+	s.mu.Lock()
+	s.chans = append(s.chans, ch)
+	s.count.Add(1)
+	s.mu.Unlock()
+
+	return ch, func() { s.unsubscribe(ch) }
+}
+
+// unsubscribe unregisters and closes ch. Later calls for the same channel
+// have no effect.
+func (s *subscribers) unsubscribe(ch chan int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.Index(s.chans, ch)
+	if i < 0 {
+		return
+	}
+	s.chans = slices.Delete(s.chans, i, i+1)
+	s.count.Add(-1)
+	close(ch)
+}
+
+// notify sends size to every subscriber with room in its channel.
+func (s *subscribers) notify(size int) {
+	if s.count.Load() == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ch := range s.chans {
+		select {
+		case ch <- size:
+		default: // The subscriber is behind; drop the event
+		}
+	}
+}
+
+func (q *queue[T]) Subscribe() (<-chan int, func()) {
+	return q.subs.subscribe()
+}
+
+// Subscribe reports changes to the aggregate size. Events are sent after the
+// shared counter changes, which for an enqueue is just before the item is
+// placed in its shard, and events from concurrent operations may arrive out of
+// order.
+func (s *sharded[T]) Subscribe() (<-chan int, func()) {
+	return s.subs.subscribe()
+}
+
+// Subscribe may be called from any goroutine. Events are sent by the
+// producer and the consumer without coordination, so they may arrive out of
+// order.
+func (s *spsc[T]) Subscribe() (<-chan int, func()) {
+	return s.subs.subscribe()
+}
+
+// Subscribe reports the number of stored items, which includes items that
+// have expired but not yet been discarded.
+func (e *expiring[T]) Subscribe() (<-chan int, func()) {
+	return e.q.Subscribe()
+}
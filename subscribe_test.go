@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"slices"
+	"testing"
+)
+
+// pending returns the events already waiting in ch, without blocking.
+func pending(ch <-chan int) []int {
+	var got []int
+	for {
+		select {
+		case size, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, size)
+		default:
+			return got
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	q := New[int]()
+	events, unsubscribe := q.Subscribe()
+
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+	_ = q.Enqueue(3)
+	_, _ = q.Dequeue()
+	q.Reverse() // Leaves the size unchanged
+	_ = q.Enqueue(4)
+	q.Reset()
+	if got, want := pending(events), []int{1, 2, 3, 2, 3, 0}; !slices.Equal(got, want) {
+		t.Errorf("size events = %v, want %v", got, want)
+	}
+
+	unsubscribe()
+	_ = q.Enqueue(5)
+	if _, ok := <-events; ok {
+		t.Error("channel still open after unsubscribing")
+	}
+	// Unsubscribing again has no effect
+	unsubscribe()
+}
+
+func TestSubscribeSlowSubscriber(t *testing.T) {
+	q := New[int]()
+	slow, _ := q.Subscribe()
+	fast, unsubscribe := q.Subscribe()
+	defer unsubscribe()
+
+	// Nobody reads slow, so it fills up and further events are dropped
+	for i := range 3 * subscribeBuffer {
+		if err := q.Enqueue(i); err != nil {
+			t.Fatalf("Enqueue(%d) with a slow subscriber = %v, want nil", i, err)
+		}
+		if got := pending(fast); !slices.Equal(got, []int{i + 1}) {
+			t.Fatalf("events after Enqueue(%d) = %v, want [%d]", i, got, i+1)
+		}
+	}
+	got := pending(slow)
+	if len(got) != subscribeBuffer || got[0] != 1 {
+		t.Errorf("slow subscriber received %v, want the first %d events", got, subscribeBuffer)
+	}
+}
+
+func TestSubscribeVariants(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func(opts ...Option[int]) Queue[int]
+	}{
+		{name: "blocking", newFn: func(opts ...Option[int]) Queue[int] { return NewBlocking(4, opts...) }},
+		{name: "sharded", newFn: func(opts ...Option[int]) Queue[int] { return NewSharded(2, opts...) }},
+		{name: "expiring", newFn: func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.newFn()
+			events, unsubscribe := q.Subscribe()
+
+			_ = q.Enqueue(1)
+			_ = q.Enqueue(2)
+			_, _ = q.Dequeue()
+			_, _ = q.Dequeue()
+			if got, want := pending(events), []int{1, 2, 1, 0}; !slices.Equal(got, want) {
+				t.Errorf("size events = %v, want %v", got, want)
+			}
+
+			unsubscribe()
+			_ = q.Enqueue(4)
+			if _, ok := <-events; ok {
+				t.Error("channel still open after unsubscribing")
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// TimedQueue is a FIFO queue that records when each item was enqueued, for
+// monitoring how long items wait before they are consumed.
+//
+// Enqueue times are read from the Clock given with WithClock, or the system
+// clock by default, and are discarded when items are dequeued. A TimedQueue is
+// safe for concurrent use.
+type TimedQueue[T any] struct {
+	q *queue[stamped[T]]
+}
+
+// stamped is an item of a timed queue with the time it was enqueued.
+type stamped[T any] struct {
+	val T
+	at  time.Time
+}
+
+// NewTimed creates an empty timed queue.
+//
+// Options configure the underlying queue as for New; hooks see the items
+// without their enqueue times.
+//
+// Example:
+//
+//	q := queue.NewTimed[Job]()
+//	q.Enqueue(job)
+//	if age, err := q.OldestAge(); err == nil && age > time.Minute {
+//		log.Printf("jobs are waiting %v to be picked up", age)
+//	}
+//
+// Panics if WithWAL or WithFlushThreshold is given.
+func NewTimed[T any](opts ...Option[T]) *TimedQueue[T] {
+	base := configure(opts)
+	if base.wal != nil {
+		panic("cannot use a write-ahead log with a timed queue")
+	}
+	if base.onFlush != nil {
+		panic("cannot use a flush threshold with a timed queue")
+	}
+
+	return &TimedQueue[T]{
+		q: adapt(base, func(item *stamped[T]) *T {
+			return &item.val
+		}),
+	}
+}
+
+// Enqueue adds an item to the back of the queue, stamped with the current time.
+// Returns ErrOverflow if the queue is at capacity, or ErrClosed if it is closed.
+func (t *TimedQueue[T]) Enqueue(val T) error {
+	return t.q.Enqueue(stamped[T]{val: val, at: t.q.clock.Now()})
+}
+
+// Dequeue removes and returns the front item.
+// Returns ErrUnderflow if the queue is empty.
+func (t *TimedQueue[T]) Dequeue() (T, error) {
+	item, err := t.q.Dequeue()
+	return item.val, err
+}
+
+// DequeueWait is like Dequeue but blocks while the queue is empty, as
+// Queue.DequeueWait does.
+func (t *TimedQueue[T]) DequeueWait(ctx context.Context) (T, error) {
+	item, err := t.q.DequeueWait(ctx)
+	return item.val, err
+}
+
+// OldestAge returns how long the front item has been in the queue.
+// Returns ErrUnderflow if the queue is empty.
+func (t *TimedQueue[T]) OldestAge() (time.Duration, error) {
+	t.q.mu.RLock()
+	defer t.q.mu.RUnlock()
+
+	if t.q.items.len() == 0 {
+		return 0, ErrUnderflow
+	}
+
+	return t.q.clock.Now().Sub(t.q.items.at(0).at), nil
+}
+
+// Size returns the number of items in the queue.
+func (t *TimedQueue[T]) Size() int {
+	return t.q.Size()
+}
+
+// Close stops the queue accepting new items.
+// Returns ErrClosed if the queue was already closed.
+func (t *TimedQueue[T]) Close() error {
+	return t.q.Close()
+}
+
+// Stats returns a snapshot of the queue's operation counters.
+func (t *TimedQueue[T]) Stats() Stats {
+	return t.q.Stats()
+}
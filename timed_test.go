@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimedOldestAge(t *testing.T) {
+	clock := newFakeClock()
+	q := NewTimed[string](WithClock[string](clock))
+
+	if _, err := q.OldestAge(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("OldestAge() on empty queue = %v, want %v", err, ErrUnderflow)
+	}
+
+	_ = q.Enqueue("a")
+	clock.Advance(3 * time.Second)
+	_ = q.Enqueue("b")
+	clock.Advance(2 * time.Second)
+	if age, err := q.OldestAge(); age != 5*time.Second || err != nil {
+		t.Errorf("OldestAge() = (%v, %v), want (5s, nil)", age, err)
+	}
+
+	// Once the front item is gone, the age is that of the next one
+	if val, err := q.Dequeue(); val != "a" || err != nil {
+		t.Fatalf("Dequeue() = (%q, %v), want (%q, nil)", val, err, "a")
+	}
+	if age, _ := q.OldestAge(); age != 2*time.Second {
+		t.Errorf("OldestAge() after Dequeue() = %v, want 2s", age)
+	}
+
+	if val, err := q.DequeueWait(context.Background()); val != "b" || err != nil {
+		t.Fatalf("DequeueWait() = (%q, %v), want (%q, nil)", val, err, "b")
+	}
+	if _, err := q.OldestAge(); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("OldestAge() after draining = %v, want %v", err, ErrUnderflow)
+	}
+}
+
+func TestTimedOptions(t *testing.T) {
+	var enqueued []int
+	q := NewTimed[int](WithCapacity[int](1), WithOnEnqueue(func(v int) { enqueued = append(enqueued, v) }))
+	_ = q.Enqueue(1)
+	if err := q.Enqueue(2); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Enqueue() into full queue = %v, want %v", err, ErrOverflow)
+	}
+	if len(enqueued) != 1 || enqueued[0] != 1 {
+		t.Errorf("enqueue hook saw %v, want [1]", enqueued)
+	}
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size = %d, want 1", size)
+	}
+
+	_ = q.Close()
+	if err := q.Enqueue(3); !errors.Is(err, ErrClosed) {
+		t.Errorf("Enqueue() after Close() = %v, want %v", err, ErrClosed)
+	}
+	if s := q.Stats(); s.Enqueued != 1 || s.Rejected != 1 {
+		t.Errorf("Stats() = %d enqueued, %d rejected, want 1 and 1", s.Enqueued, s.Rejected)
+	}
+}
+
+func TestNewTimedPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewTimed(WithWAL(...)) did not panic")
+		}
+	}()
+	NewTimed(WithWAL[int](&bytes.Buffer{}, encodeInt))
+}
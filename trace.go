@@ -0,0 +1,41 @@
+package queue
+
+// Operation names passed to a TraceFunc.
+const (
+	OpEnqueue = "Enqueue"
+	OpDequeue = "Dequeue"
+	OpPeek    = "Peek"
+)
+
+// TraceFunc is called when a traced operation starts, with the name of the
+// queue given to WithName, or "" if it has none, and the operation's name. It
+// returns a function called when the operation ends.
+//
+// The returned function receives the number of items the operation added,
+// removed or returned, and the error it returned, if any. The pair maps
+// naturally onto a tracing span:
+//
+//	func(name, op string) func(n int, err error) {
+//		_, span := tracer.Start(ctx, "queue."+op)
+//		return func(n int, err error) {
+//			span.SetAttributes(
+//				attribute.String("queue.name", name),
+//				attribute.Int("queue.items", n),
+//			)
+//			if err != nil {
+//				span.RecordError(err)
+//			}
+//			span.End()
+//		}
+//	}
+type TraceFunc func(name, op string) func(n int, err error)
+
+// traced returns the item count reported for an operation on a single item
+// that returned err.
+func traced(err error) int {
+	if err != nil {
+		return 0
+	}
+
+	return 1
+}
@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+type tracedOp struct {
+	op  string
+	n   int
+	err error
+}
+
+// recordTraces returns a TraceFunc that appends every completed operation to ops.
+func recordTraces(ops *[]tracedOp) TraceFunc {
+	return func(_, op string) func(int, error) {
+		return func(n int, err error) {
+			*ops = append(*ops, tracedOp{op: op, n: n, err: err})
+		}
+	}
+}
+
+func checkTraces(t *testing.T, got, want []tracedOp) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("traced %d operations %v, want %d %v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i].op != want[i].op || got[i].n != want[i].n || !errors.Is(got[i].err, want[i].err) {
+			t.Errorf("trace %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWithTracer(t *testing.T) {
+	want := []tracedOp{
+		{op: OpEnqueue, n: 1},
+		{op: OpEnqueue, n: 0, err: ErrOverflow},
+		{op: OpPeek, n: 1},
+		{op: OpDequeue, n: 1},
+		{op: OpDequeue, n: 0, err: ErrUnderflow},
+		{op: OpPeek, n: 0, err: ErrUnderflow},
+	}
+	constructors := []struct {
+		name string
+		new  func(opts ...Option[int]) Queue[int]
+	}{
+		{name: "New", new: New[int]},
+		{name: "NewSharded", new: func(opts ...Option[int]) Queue[int] { return NewSharded(2, opts...) }},
+		{name: "NewExpiring", new: func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) }},
+	}
+
+	for _, c := range constructors {
+		t.Run(c.name, func(t *testing.T) {
+			var ops []tracedOp
+			q := c.new(WithTracer[int](recordTraces(&ops)), WithCapacity[int](1))
+
+			_ = q.Enqueue(1)
+			_ = q.Enqueue(2)
+			_, _ = q.Peek()
+			_, _ = q.Dequeue()
+			_, _ = q.Dequeue()
+			_, _ = q.Front()
+
+			checkTraces(t, ops, want)
+		})
+	}
+}
+
+func TestWithTracerBlocking(t *testing.T) {
+	var ops []tracedOp
+	q := NewBlocking(1, WithTracer[int](recordTraces(&ops)))
+	_ = q.Enqueue(1)
+	_, _ = q.Dequeue()
+	_ = q.Close()
+	_ = q.Enqueue(2)
+
+	// Untraced methods produce no events
+	_ = q.TryEnqueue(3)
+	_, _ = q.TryDequeue()
+
+	checkTraces(t, ops, []tracedOp{
+		{op: OpEnqueue, n: 1},
+		{op: OpDequeue, n: 1},
+		{op: OpEnqueue, n: 0, err: ErrClosed},
+	})
+}
+
+func TestWithTracerName(t *testing.T) {
+	constructors := map[string]func(opts ...Option[int]) Queue[int]{
+		"New":         New[int],
+		"NewSharded":  func(opts ...Option[int]) Queue[int] { return NewSharded(2, opts...) },
+		"NewExpiring": func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) },
+		"NewBlocking": func(opts ...Option[int]) Queue[int] { return NewBlocking(1, opts...) },
+	}
+
+	for name, newFn := range constructors {
+		t.Run(name, func(t *testing.T) {
+			var names []string
+			trace := WithTracer[int](func(name, _ string) func(int, error) {
+				names = append(names, name)
+				return func(int, error) {}
+			})
+
+			q := newFn(trace, WithName[int]("emails"))
+			_ = q.Enqueue(1)
+			_, _ = q.Dequeue()
+			_ = newFn(trace).Enqueue(1)
+
+			if want := []string{"emails", "emails", ""}; !slices.Equal(names, want) {
+				t.Errorf("tracer saw names %q, want %q", names, want)
+			}
+		})
+	}
+}
+
+func TestWithTracerOutsideLock(t *testing.T) {
+	var q Queue[int]
+	q = New(WithTracer[int](func(string, string) func(int, error) {
+		_ = q.Size() // Would deadlock if the lock were held
+		return func(int, error) {
+			_ = q.Size()
+		}
+	}))
+
+	_ = q.Enqueue(1)
+	_, _ = q.Peek()
+	_, _ = q.Dequeue()
+}
+
+func TestWithTracerNilPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("WithTracer(nil) did not panic")
+		}
+	}()
+	WithTracer[int](nil)
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// NewUnsafe creates a queue like New that does no locking, for use by a
+// single goroutine in tight loops where lock overhead matters.
+//
+// The queue is not safe for concurrent use: every call, including reads such
+// as Size and traversals, must come from the same goroutine or be otherwise
+// synchronized by the caller. Blocking operations such as EnqueueWait can
+// only be woken by another goroutine and are of little use here.
+//
+// Example:
+//
+//	q := queue.NewUnsafe[int]()
+//	for i := 0; i < 1000; i++ {
+//		q.Enqueue(i)
+//	}
+func NewUnsafe[T any](opts ...Option[T]) Queue[T] {
+	q := newQueue(opts...)
+	q.mu = noLock{}
+
+	return q
+}
+
+// locker is the lock guarding a queue's state.
+type locker interface {
+	sync.Locker
+	RLock()
+	RUnlock()
+
+	// fresh returns a new unlocked lock of the same kind.
+	fresh() locker
+
+	// stable calls read without the lock and returns its result, reporting
+	// whether no write overlapped the call, in which case the result is what
+	// read would have returned under the read lock.
+	stable(read func() int64) (int64, bool)
+}
+
+// rwLock is the locker used by every queue except those from NewUnsafe. It
+// doubles as a seqlock: seq is odd while the write lock is held, so readers of
+// values that only change under the write lock can tell whether a write
+// overlapped their read.
+type rwLock struct {
+	sync.RWMutex
+	seq atomic.Uint64
+}
+
+func (l *rwLock) Lock() {
+	l.RWMutex.Lock()
+	l.seq.Add(1)
+}
+
+func (l *rwLock) Unlock() {
+	l.seq.Add(1)
+	l.RWMutex.Unlock()
+}
+
+func (*rwLock) fresh() locker {
+	return new(rwLock)
+}
+
+func (l *rwLock) stable(read func() int64) (int64, bool) {
+	seq := l.seq.Load()
+	if seq%2 != 0 {
+		return 0, false
+	}
+	v := read()
+
+	return v, l.seq.Load() == seq
+}
+
+// noLock is a locker that does nothing.
+type noLock struct{}
+
+func (noLock) Lock()         {}
+func (noLock) Unlock()       {}
+func (noLock) RLock()        {}
+func (noLock) RUnlock()      {}
+func (noLock) fresh() locker { return noLock{} }
+
+func (noLock) stable(read func() int64) (int64, bool) { return read(), true }
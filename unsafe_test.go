@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnsafe(t *testing.T) {
+	q := NewUnsafe[int](WithCapacity[int](3))
+	for i := 1; i <= 3; i++ {
+		if err := q.Enqueue(i); err != nil {
+			t.Fatalf("Enqueue(%d) = %v, want nil", i, err)
+		}
+	}
+	if err := q.Enqueue(4); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Enqueue() on full queue = %v, want %v", err, ErrOverflow)
+	}
+
+	for _, expected := range []int{1, 2, 3} {
+		if val, err := q.Dequeue(); val != expected || err != nil {
+			t.Errorf("Dequeue() = (%d, %v), want (%d, nil)", val, err, expected)
+		}
+	}
+}
+
+func TestUnsafeSplit(t *testing.T) {
+	q := NewUnsafe[int]()
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+
+	head, _ := q.Split(1)
+	if _, ok := head.(*queue[int]).mu.(noLock); !ok {
+		t.Error("Split() of an unsafe queue returned a locked queue")
+	}
+	if _, ok := New[int]().(*queue[int]).mu.(noLock); ok {
+		t.Error("New() returned an unlocked queue")
+	}
+}
+
+func BenchmarkUnsafe(b *testing.B) {
+	for name, q := range map[string]Queue[int]{
+		"locked":   New[int](),
+		"unlocked": NewUnsafe[int](),
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = q.Enqueue(i)
+				_, _ = q.Dequeue()
+			}
+		})
+	}
+}
@@ -0,0 +1,642 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// NewView returns a read-only queue of the items of src for which pred
+// returns true, evaluated lazily: the view holds no items of its own, and
+// every call filters the current contents of src.
+//
+// The view is coupled to src in both directions:
+//   - Items added to src appear in the view as soon as they match
+//   - Dequeue and the other removing operations take matching items out of
+//     src, in the order src would dequeue them, and skip the others. They
+//     remove items as Remove does, so src's dequeue hooks do not run and its
+//     Stats do not count them
+//   - Methods that would add, replace or reorder items, change the capacity
+//     or close the queue return ErrReadOnly, or do nothing if they return no
+//     error. TryEnqueue and Offer return false, and Remaining returns 0
+//   - Blocking dequeues wake whenever src gains an item, and return ErrClosed
+//     once src is closed and no matching item is left
+//   - Size, Peek and the other reads filter src on every call, so they take
+//     time proportional to the size of src
+//   - Version, Stats, SizeBytes, Subscribe and Name are those of src; size
+//     events report the size of src, not of the view
+//
+// pred is called under src's lock for some operations and must not call back
+// into src. A view is safe for concurrent use if src is. Removals are atomic
+// except from a sharded src, which is filtered shard by shard. Every read of
+// a view of an SPSC queue reads the items, so the view must be used by the
+// consumer of src only.
+//
+// Example:
+//
+//	jobs := queue.New[Job]()
+//	urgent := queue.NewView(jobs, func(j Job) bool { return j.Urgent })
+//	job, err := urgent.Dequeue() // The first urgent job, removed from jobs
+//
+// Panics if pred is nil, or if src was not created by this package.
+func NewView[T any](src Queue[T], pred func(T) bool) Queue[T] {
+	if pred == nil {
+		panic("cannot specify nil view predicate")
+	}
+
+	// A view of a view filters the underlying queue with both predicates
+	if inner, ok := src.(*view[T]); ok {
+		outer := pred
+		src, pred = inner.src, func(val T) bool { return inner.pred(val) && outer(val) }
+	}
+
+	v := &view[T]{src: src, pred: pred}
+	switch s := src.(type) {
+	case *sharded[T]:
+		v.notEmpty, v.clock = &s.notEmpty, s.clock
+		v.closed = s.closed.Load
+	case *expiring[T]:
+		v.notEmpty, v.clock = &s.q.notEmpty, s.q.clock
+		v.closed = s.q.isClosed
+	case *spsc[T]:
+		v.notEmpty, v.clock = &s.notEmpty, s.clock
+		v.closed = s.closed.Load
+	default:
+		q, ok := asQueue(src)
+		if !ok {
+			panic("cannot create a view of a queue from another package")
+		}
+		v.notEmpty, v.clock = &q.notEmpty, q.clock
+		v.closed = q.isClosed
+	}
+
+	return v
+}
+
+type view[T any] struct {
+	src       Queue[T]
+	pred      func(T) bool
+	notEmpty  *signal     // Broadcast by src whenever it gains an item or is closed
+	closed    func() bool // Reports whether src is closed
+	clock     Clock
+	consumers gauge
+}
+
+// readOnly lets copyEach recognize a view without naming its type, which
+// would make the instantiations of queue and view depend on each other.
+func (v *view[T]) readOnly() {}
+
+// source returns src, for the same reason as readOnly.
+func (v *view[T]) source() Queue[T] {
+	return v.src
+}
+
+// isClosed reports whether q is closed.
+func (q *queue[T]) isClosed() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.closed
+}
+
+// take removes up to n matching items from src, in the order src would
+// dequeue them, and returns them.
+func (v *view[T]) take(n int) []T {
+	if e, ok := v.src.(*expiring[T]); ok {
+		now := e.q.clock.Now()
+		items := takeMatching(e.q.Filter, func(item timed[T]) bool {
+			return !item.expiredAt(now) && v.pred(item.val)
+		}, n)
+		vals := make([]T, len(items))
+		for i, item := range items {
+			vals[i] = item.val
+		}
+		return vals
+	}
+	if q, ok := asQueue(v.src); ok && q.less != nil {
+		return q.takeBest(v.pred, n)
+	}
+
+	return takeMatching(v.src.Filter, v.pred, n)
+}
+
+// takeMatching removes up to n items for which match returns true, in the
+// order filter visits them, and returns them.
+func takeMatching[T any](filter func(keep func(T) bool) int, match func(T) bool, n int) []T {
+	var taken []T
+	if n > 0 {
+		filter(func(val T) bool {
+			if len(taken) < n && match(val) {
+				taken = append(taken, val)
+				return false
+			}
+			return true
+		})
+	}
+
+	return taken
+}
+
+// takeBest removes up to n items of a priority queue for which match returns
+// true, highest priority first, and returns them.
+func (q *queue[T]) takeBest(match func(T) bool, n int) []T {
+	q.mu.Lock()
+	defer q.unlock()
+
+	var taken []T
+	for len(taken) < n {
+		best := -1
+		for i := 0; i < q.items.len(); i++ {
+			if val := q.items.at(i); match(val) && (best < 0 || q.less(val, q.items.at(best))) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		taken = append(taken, q.removeAt(best))
+	}
+
+	return taken
+}
+
+// first returns up to n matching items in the order src would dequeue them.
+func (v *view[T]) first(n int) []T {
+	if n <= 0 {
+		return nil
+	}
+	if q, ok := asQueue(v.src); ok && q.less != nil {
+		q.mu.RLock()
+		var result []T
+		for i := 0; i < q.items.len(); i++ {
+			if val := q.items.at(i); v.pred(val) {
+				result = append(result, q.peeked(val))
+			}
+		}
+		q.mu.RUnlock()
+
+		slices.SortStableFunc(result, func(a, b T) int {
+			if q.less(a, b) {
+				return -1
+			}
+			if q.less(b, a) {
+				return 1
+			}
+			return 0
+		})
+		return result[:min(n, len(result))]
+	}
+
+	var result []T
+	v.ForEach(func(val T) bool {
+		result = append(result, val)
+		return len(result) < n
+	})
+
+	return result
+}
+
+// capacity returns the capacity of src, derived from its size and room.
+func (v *view[T]) capacity() int {
+	r := v.src.Remaining()
+	if r == UnlimitedCapacity {
+		return UnlimitedCapacity
+	}
+
+	return r + v.src.Size()
+}
+
+func (v *view[T]) Enqueue(T) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) Dequeue() (T, error) {
+	if taken := v.take(1); len(taken) > 0 {
+		return taken[0], nil
+	}
+
+	var zero T
+	return zero, ErrUnderflow
+}
+
+// DequeueUntil removes one matching item at a time, so concurrent operations
+// may interleave with it.
+func (v *view[T]) DequeueUntil(pred func(T) bool) (T, error) {
+	for {
+		taken := v.take(1)
+		if len(taken) == 0 {
+			var zero T
+			return zero, ErrUnderflow
+		}
+		if pred(taken[0]) {
+			return taken[0], nil
+		}
+	}
+}
+
+// DequeueMatch returns ErrUnderflow if no item matches the view, and
+// ErrNotFound if none of those matches pred.
+func (v *view[T]) DequeueMatch(pred func(T) bool) (T, error) {
+	matched := false
+	val, err := v.src.DequeueMatch(func(val T) bool {
+		if !v.pred(val) {
+			return false
+		}
+		matched = true
+		return pred(val)
+	})
+	if errors.Is(err, ErrNotFound) && !matched {
+		err = ErrUnderflow
+	}
+
+	return val, err
+}
+
+func (v *view[T]) Drain() []T {
+	if taken := v.take(math.MaxInt); taken != nil {
+		return taken
+	}
+
+	return []T{}
+}
+
+func (v *view[T]) DrainTo(dst []T) int {
+	return copy(dst, v.take(len(dst)))
+}
+
+func (v *view[T]) EnqueueDedupBack(T, func(a, b T) bool) (bool, error) {
+	return false, ErrReadOnly
+}
+
+func (v *view[T]) EnqueueFrontAll(...T) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) EnqueuePush(T) (evicted T, didEvict bool, err error) {
+	return evicted, false, ErrReadOnly
+}
+
+func (v *view[T]) TryEnqueue(T) bool {
+	return false
+}
+
+func (v *view[T]) Offer(T) bool {
+	return false
+}
+
+func (v *view[T]) EnqueueSlice([]T) (int, error) {
+	return 0, ErrReadOnly
+}
+
+func (v *view[T]) TryDequeue() (T, bool) {
+	val, err := v.Dequeue()
+	return val, err == nil
+}
+
+func (v *view[T]) Poll() (T, bool) {
+	return v.TryDequeue()
+}
+
+func (v *view[T]) DequeueOr(def T) T {
+	if val, ok := v.TryDequeue(); ok {
+		return val
+	}
+
+	return def
+}
+
+func (v *view[T]) EnqueueWait(context.Context, T) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) EnqueueCtx(context.Context, T) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) EnqueueAllCtx(context.Context, ...T) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) EnqueueTimeout(T, time.Duration) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) DequeueWait(ctx context.Context) (T, error) {
+	var blocked bool // Set once the call is counted in v.consumers
+	defer v.consumers.leave(&blocked)
+	for {
+		// Register and read the closed state before trying, so that an item
+		// added or a Close in between still wakes us
+		ready := v.notEmpty.wait()
+		closed := v.closed()
+		if taken := v.take(1); len(taken) > 0 {
+			v.notEmpty.done()
+			return taken[0], nil
+		}
+		if closed {
+			v.notEmpty.done()
+
+			var zero T
+			return zero, ErrClosed
+		}
+
+		v.consumers.enter(&blocked)
+		err := waitFor(ctx, ready)
+		v.notEmpty.done()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+func (v *view[T]) DequeueCtx(ctx context.Context) (T, error) {
+	return dequeueCtx(ctx, v)
+}
+
+func (v *view[T]) DequeueTimeout(d time.Duration) (T, error) {
+	return dequeueTimeout(v, v.clock, d)
+}
+
+func (v *view[T]) DequeueBatchWait(ctx context.Context, n int, maxWait time.Duration) ([]T, error) {
+	return dequeueBatchWait(ctx, v, v.clock, v.notEmpty, &v.consumers, v.batchState, n, maxWait)
+}
+
+// batchState reports the number of matching items and whether src is closed,
+// for dequeueBatchWait.
+func (v *view[T]) batchState() (int, bool) {
+	closed := v.closed()
+	return v.Size(), closed
+}
+
+// NotEmpty fires once the view holds a matching item or src is closed, or
+// when src gains an item that does not match, which callers treat as a
+// spurious wakeup.
+func (v *view[T]) NotEmpty() <-chan struct{} {
+	ready := v.notEmpty.watch()
+	if v.closed() || v.Size() > 0 {
+		return fired
+	}
+
+	return ready
+}
+
+// NotFull always returns a closed channel, so that a producer selecting on it
+// goes on to fail with ErrReadOnly instead of blocking forever.
+func (v *view[T]) NotFull() <-chan struct{} {
+	return fired
+}
+
+func (v *view[T]) BlockedProducers() int {
+	return 0
+}
+
+func (v *view[T]) BlockedConsumers() int {
+	return v.consumers.count()
+}
+
+func (v *view[T]) Channel(ctx context.Context) <-chan T {
+	return channel(ctx, v)
+}
+
+func (v *view[T]) Close() error {
+	return ErrReadOnly
+}
+
+// CloseAndDrain does nothing and returns nil, since a view cannot be closed.
+func (v *view[T]) CloseAndDrain(func(T)) []T {
+	return nil
+}
+
+func (v *view[T]) Reset() {}
+
+func (v *view[T]) Size() int {
+	n := 0
+	v.ForEach(func(T) bool {
+		n++
+		return true
+	})
+
+	return n
+}
+
+func (v *view[T]) ApproxSize() int {
+	return v.Size()
+}
+
+func (v *view[T]) SizeBytes() int {
+	return v.src.SizeBytes()
+}
+
+func (v *view[T]) Remaining() int {
+	return 0
+}
+
+func (v *view[T]) SetCapacity(int) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) Grow(int) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) Compact() {}
+
+func (v *view[T]) Peek() (T, error) {
+	if items := v.first(1); len(items) > 0 {
+		return items[0], nil
+	}
+
+	var zero T
+	return zero, ErrUnderflow
+}
+
+func (v *view[T]) Front() (T, error) {
+	return v.Peek()
+}
+
+func (v *view[T]) PeekOr(def T) T {
+	if val, err := v.Peek(); err == nil {
+		return val
+	}
+
+	return def
+}
+
+func (v *view[T]) Back() (T, error) {
+	if items := v.first(math.MaxInt); len(items) > 0 {
+		return items[len(items)-1], nil
+	}
+
+	var zero T
+	return zero, ErrUnderflow
+}
+
+func (v *view[T]) PeekN(n int) ([]T, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+	items := v.first(max(n, 1))
+	if len(items) == 0 {
+		return []T{}, ErrUnderflow
+	}
+
+	return items[:min(n, len(items))], nil
+}
+
+func (v *view[T]) ReplaceFront(T) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) ReplaceBack(T) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) Reverse() {}
+
+func (v *view[T]) Rotate(int) {}
+
+func (v *view[T]) Shuffle(*rand.Rand) {}
+
+func (v *view[T]) ForEach(fn func(T) bool) {
+	v.src.ForEach(func(val T) bool {
+		return !v.pred(val) || fn(val)
+	})
+}
+
+func (v *view[T]) ForEachCtx(ctx context.Context, fn func(T) bool) error {
+	return v.src.ForEachCtx(ctx, func(val T) bool {
+		return !v.pred(val) || fn(val)
+	})
+}
+
+func (v *view[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for val := range v.src.All() {
+			if v.pred(val) && !yield(val) {
+				return
+			}
+		}
+	}
+}
+
+func (v *view[T]) AllIndexed() iter.Seq2[int, T] {
+	return allIndexed(v.All())
+}
+
+func (v *view[T]) Version() uint64 {
+	return v.src.Version()
+}
+
+func (v *view[T]) Snapshot() ([]T, uint64) {
+	items, version := v.src.Snapshot()
+	return slices.DeleteFunc(items, func(val T) bool { return !v.pred(val) }), version
+}
+
+func (v *view[T]) Subscribe() (<-chan int, func()) {
+	return v.src.Subscribe()
+}
+
+func (v *view[T]) Filter(keep func(T) bool) int {
+	return v.src.Filter(func(val T) bool {
+		return !v.pred(val) || keep(val)
+	})
+}
+
+func (v *view[T]) FilterCtx(ctx context.Context, keep func(T) bool) (int, error) {
+	return v.src.FilterCtx(ctx, func(val T) bool {
+		return !v.pred(val) || keep(val)
+	})
+}
+
+// Remove counts i over matching items only, in the order All visits them.
+func (v *view[T]) Remove(i int) (T, error) {
+	var removed T
+	if i >= 0 {
+		n := 0
+		found := v.src.Filter(func(val T) bool {
+			if !v.pred(val) {
+				return true
+			}
+			n++
+			if n-1 != i {
+				return true
+			}
+			removed = val
+			return false
+		})
+		if found > 0 {
+			return removed, nil
+		}
+	}
+
+	return removed, ErrIndexOutOfRange
+}
+
+// FindIndex counts over matching items only, as Remove does.
+func (v *view[T]) FindIndex(pred func(T) bool) int {
+	i, found := 0, -1
+	v.ForEach(func(val T) bool {
+		if pred(val) {
+			found = i
+			return false
+		}
+		i++
+		return true
+	})
+
+	return found
+}
+
+func (v *view[T]) Swap(int, int) error {
+	return ErrReadOnly
+}
+
+func (v *view[T]) Merge(Queue[T]) error {
+	return ErrReadOnly
+}
+
+// Split removes the first n matching items from src and returns them in a new
+// queue of unlimited capacity.
+func (v *view[T]) Split(n int) (Queue[T], error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+
+	head := New[T]()
+	_, _ = head.EnqueueSlice(v.take(n))
+
+	return head, nil
+}
+
+func (v *view[T]) CopyInto(dst Queue[T]) error {
+	items, _ := v.Snapshot()
+	return copyEach(items, dst)
+}
+
+func (v *view[T]) Stats() Stats {
+	return v.src.Stats()
+}
+
+func (v *view[T]) PersistTo(w io.Writer) error {
+	items, _ := v.Snapshot()
+	return writeSnapshot(w, v.capacity(), len(items), func(i int) T {
+		return items[i]
+	})
+}
+
+func (v *view[T]) String() string {
+	items, _ := v.Snapshot()
+	return formatQueue(v.src.Name(), len(items), v.capacity(), func(i int) T {
+		return items[i]
+	})
+}
+
+func (v *view[T]) Name() string {
+	return v.src.Name()
+}
@@ -0,0 +1,229 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func isEven(v int) bool { return v%2 == 0 }
+
+func TestViewDequeue(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func() Queue[int]
+	}{
+		{name: "queue", newFn: func() Queue[int] { return New[int]() }},
+		{name: "sharded", newFn: func() Queue[int] { return NewSharded[int](1) }},
+		{name: "expiring", newFn: func() Queue[int] { return NewExpiring[int]() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := tt.newFn()
+			_, _ = src.EnqueueSlice([]int{1, 2, 3, 4, 5, 6})
+			v := NewView(src, isEven)
+
+			if size := v.Size(); size != 3 {
+				t.Errorf("Size() = %d, want 3", size)
+			}
+			if val, err := v.Peek(); val != 2 || err != nil {
+				t.Errorf("Peek() = (%d, %v), want (2, nil)", val, err)
+			}
+
+			// Dequeue skips odd items and removes the even ones from src
+			if val, err := v.Dequeue(); val != 2 || err != nil {
+				t.Errorf("Dequeue() = (%d, %v), want (2, nil)", val, err)
+			}
+			if got := slices.Collect(src.All()); !slices.Equal(got, []int{1, 3, 4, 5, 6}) {
+				t.Errorf("src after Dequeue() = %v, want [1 3 4 5 6]", got)
+			}
+
+			// Items added to src show up in the view
+			_ = src.Enqueue(8)
+			_ = src.Enqueue(9)
+			if got := v.Drain(); !slices.Equal(got, []int{4, 6, 8}) {
+				t.Errorf("Drain() = %v, want [4 6 8]", got)
+			}
+			if _, err := v.Dequeue(); !errors.Is(err, ErrUnderflow) {
+				t.Errorf("Dequeue() with no matching item = %v, want %v", err, ErrUnderflow)
+			}
+			if got := slices.Collect(src.All()); !slices.Equal(got, []int{1, 3, 5, 9}) {
+				t.Errorf("src after Drain() = %v, want [1 3 5 9]", got)
+			}
+		})
+	}
+}
+
+func TestViewPriority(t *testing.T) {
+	src := NewPriority(intLess)
+	_, _ = src.EnqueueSlice([]int{7, 4, 1, 8, 2, 5})
+	v := NewView(src, isEven)
+
+	if got, _ := v.PeekN(2); !slices.Equal(got, []int{2, 4}) {
+		t.Errorf("PeekN(2) = %v, want [2 4]", got)
+	}
+	if back, _ := v.Back(); back != 8 {
+		t.Errorf("Back() = %d, want 8", back)
+	}
+	if got := v.Drain(); !slices.Equal(got, []int{2, 4, 8}) {
+		t.Errorf("Drain() = %v, want [2 4 8]", got)
+	}
+	if got := src.Drain(); !slices.Equal(got, []int{1, 5, 7}) {
+		t.Errorf("src.Drain() = %v, want [1 5 7]", got)
+	}
+	if !Supports(v, CapabilityPriority) {
+		t.Error("Supports(view of priority queue, CapabilityPriority) = false, want true")
+	}
+}
+
+func TestViewExpiring(t *testing.T) {
+	clock := newFakeClock()
+	src := NewExpiring(WithClock[int](clock))
+	_ = src.EnqueueWithTTL(2, shortTTL)
+	_ = src.Enqueue(4)
+	clock.Advance(shortTTL)
+	v := NewView[int](src, isEven)
+
+	if size := v.Size(); size != 1 {
+		t.Errorf("Size() with an expired item = %d, want 1", size)
+	}
+	if val, err := v.Dequeue(); val != 4 || err != nil {
+		t.Errorf("Dequeue() = (%d, %v), want (4, nil)", val, err)
+	}
+}
+
+func TestViewSPSC(t *testing.T) {
+	src := NewSPSC[int](4)
+	_, _ = src.EnqueueSlice([]int{1, 2, 3, 4})
+	v := NewView(src, isEven)
+
+	if size := v.Size(); size != 2 {
+		t.Errorf("Size() = %d, want 2", size)
+	}
+	if val, err := v.Dequeue(); val != 2 || err != nil {
+		t.Errorf("Dequeue() = (%d, %v), want (2, nil)", val, err)
+	}
+	if got := slices.Collect(src.All()); !slices.Equal(got, []int{1, 3, 4}) {
+		t.Errorf("src items after Dequeue() = %v, want [1 3 4]", got)
+	}
+
+	_ = src.Close()
+	if val, err := v.DequeueWait(context.Background()); val != 4 || err != nil {
+		t.Errorf("DequeueWait() after src.Close() = (%d, %v), want (4, nil)", val, err)
+	}
+	if _, err := v.DequeueWait(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("DequeueWait() with no matching item left = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestViewReadOnly(t *testing.T) {
+	src := New(WithCapacity[int](10))
+	_, _ = src.EnqueueSlice([]int{1, 2, 3, 4})
+	v := NewView(src, isEven)
+
+	errs := map[string]error{
+		"Enqueue":         v.Enqueue(6),
+		"EnqueueFrontAll": v.EnqueueFrontAll(6),
+		"EnqueueWait":     v.EnqueueWait(context.Background(), 6),
+		"ReplaceFront":    v.ReplaceFront(6),
+		"Swap":            v.Swap(0, 1),
+		"Merge":           v.Merge(New[int]()),
+		"SetCapacity":     v.SetCapacity(1),
+		"Close":           v.Close(),
+		"CopyInto":        New[int]().CopyInto(v),
+	}
+	for name, err := range errs {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s() on a view = %v, want %v", name, err, ErrReadOnly)
+		}
+	}
+	if v.TryEnqueue(6) {
+		t.Error("TryEnqueue() on a view = true, want false")
+	}
+	v.Reverse()
+	v.Reset()
+	if got := slices.Collect(src.All()); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("src after changes through the view = %v, want [1 2 3 4]", got)
+	}
+	if s := v.String(); s != "Queue[len=2/cap=10]: [2 4]" {
+		t.Errorf("String() = %q, want %q", s, "Queue[len=2/cap=10]: [2 4]")
+	}
+}
+
+func TestViewRemoveAndFilter(t *testing.T) {
+	src := New[int]()
+	_, _ = src.EnqueueSlice([]int{1, 2, 3, 4, 5, 6})
+	v := NewView(src, isEven)
+
+	if i := v.FindIndex(func(val int) bool { return val == 6 }); i != 2 {
+		t.Errorf("FindIndex(6) = %d, want 2", i)
+	}
+	if val, err := v.Remove(1); val != 4 || err != nil {
+		t.Errorf("Remove(1) = (%d, %v), want (4, nil)", val, err)
+	}
+	if _, err := v.Remove(2); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("Remove(2) = %v, want %v", err, ErrIndexOutOfRange)
+	}
+
+	// Filter only ever removes matching items
+	if n := v.Filter(func(int) bool { return false }); n != 2 {
+		t.Errorf("Filter() = %d, want 2", n)
+	}
+	if got := slices.Collect(src.All()); !slices.Equal(got, []int{1, 3, 5}) {
+		t.Errorf("src after Filter() = %v, want [1 3 5]", got)
+	}
+
+	// A view of a view matches both predicates
+	_, _ = src.EnqueueSlice([]int{10, 12})
+	tens := NewView(v, func(val int) bool { return val >= 10 && val%10 == 0 })
+	if got := tens.Drain(); !slices.Equal(got, []int{10}) {
+		t.Errorf("Drain() on a view of a view = %v, want [10]", got)
+	}
+}
+
+func TestViewDequeueWait(t *testing.T) {
+	src := New[int]()
+	v := NewView(src, isEven)
+
+	done := make(chan int, 1)
+	go func() {
+		val, _ := v.DequeueWait(context.Background())
+		done <- val
+	}()
+	awaitCount(t, "blocked consumers", v.BlockedConsumers, 1)
+
+	// A non-matching item wakes the consumer, which goes back to waiting
+	_ = src.Enqueue(1)
+	_ = src.Enqueue(2)
+	if val := <-done; val != 2 {
+		t.Errorf("DequeueWait() = %d, want 2", val)
+	}
+
+	// Closing src ends the wait once no matching item is left
+	errc := make(chan error, 1)
+	go func() {
+		_, err := v.DequeueWait(context.Background())
+		errc <- err
+	}()
+	awaitCount(t, "blocked consumers", v.BlockedConsumers, 1)
+	_ = src.Close()
+	if err := <-errc; !errors.Is(err, ErrClosed) {
+		t.Errorf("DequeueWait() after src.Close() = %v, want %v", err, ErrClosed)
+	}
+
+	if _, err := v.DequeueTimeout(time.Millisecond); !errors.Is(err, ErrClosed) {
+		t.Errorf("DequeueTimeout() on closed src = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestNewViewPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewView(q, nil) did not panic")
+		}
+	}()
+	NewView(New[int](), nil)
+}
@@ -0,0 +1,485 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// signal is a broadcast notification that blocked callers select on.
+//
+// Waiters register with wait before re-checking their condition, and then
+// block on the returned channel. broadcast wakes every registered waiter by
+// closing the channel. When nobody is waiting, broadcast is a single atomic
+// load, so signalling on every mutation costs almost nothing.
+//
+// The zero value is ready to use.
+type signal struct {
+	mu      sync.Mutex
+	ch      chan struct{}
+	waiters atomic.Int32
+	watched atomic.Bool // Set by watch until the next broadcast
+}
+
+// gauge counts the callers currently blocked in one kind of waiting operation.
+// A caller enters the first time it blocks and leaves when the operation
+// returns, so wakeups that find the condition still unmet do not make the
+// count flicker. The zero value is ready to use.
+type gauge struct {
+	n atomic.Int32
+}
+
+// enter counts the caller as blocked, unless *blocked shows it already is.
+func (g *gauge) enter(blocked *bool) {
+	if !*blocked {
+		*blocked = true
+		g.n.Add(1)
+	}
+}
+
+// leave stops counting the caller if enter counted it.
+func (g *gauge) leave(blocked *bool) {
+	if *blocked {
+		g.n.Add(-1)
+	}
+}
+
+// count returns the number of callers currently counted.
+func (g *gauge) count() int {
+	return int(g.n.Load())
+}
+
+// fired is a closed channel handed out when a watched condition already holds.
+var fired = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// wait registers the caller as a waiter and returns the channel closed by the
+// next broadcast. Every call must be paired with a call to done.
+func (s *signal) wait() <-chan struct{} {
+	s.waiters.Add(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+
+	return s.ch
+}
+
+// watch returns the channel closed by the next broadcast without registering
+// a waiter, for callers that may abandon the channel instead of calling done.
+func (s *signal) watch() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+	s.watched.Store(true)
+
+	return s.ch
+}
+
+// done unregisters a waiter added by wait.
+func (s *signal) done() {
+	s.waiters.Add(-1)
+}
+
+// broadcast wakes every waiter registered since the previous broadcast.
+func (s *signal) broadcast() {
+	if s.waiters.Load() == 0 && !s.watched.Load() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+	s.watched.Store(false)
+}
+
+func (q *queue[T]) EnqueueWait(ctx context.Context, val T) error {
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in q.producers
+	defer q.producers.leave(&blocked)
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.unlock()
+			return ErrClosed
+		}
+		if err := q.invalid(val); err != nil {
+			q.unlock()
+			return err
+		}
+		if q.duplicate(val) {
+			q.unlock()
+			return ErrDuplicate
+		}
+		if d := q.throttled(); d > 0 {
+			since = q.blockedSince(since)
+			q.producers.enter(&blocked)
+			q.unlock()
+
+			if err := sleep(ctx, q.clock, d); err != nil {
+				return err
+			}
+			continue
+		}
+		if q.accepts(val) {
+			q.admit()
+			q.add(val)
+			q.waited(&q.stats.EnqueueWait, since)
+			q.unlock()
+
+			runHooks(q.onEnqueue, val)
+			return nil
+		}
+		ready := q.notFull.wait()
+		since = q.blockedSince(since)
+		q.producers.enter(&blocked)
+		q.unlock()
+
+		err := waitFor(ctx, ready)
+		q.notFull.done()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (q *queue[T]) EnqueueAllCtx(ctx context.Context, vals ...T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	bytes := 0
+	for _, val := range vals {
+		bytes += q.itemBytes(val)
+	}
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in q.producers
+	defer q.producers.leave(&blocked)
+	for {
+		q.mu.Lock()
+		if err := q.checkBatch(vals, bytes); err != nil {
+			q.unlock()
+			return err
+		}
+		if q.circular || q.fits(len(vals)) && q.fitsBytes(bytes) {
+			for _, val := range vals {
+				q.accepts(val) // Makes room in a circular queue
+				q.add(val)
+			}
+			q.waited(&q.stats.EnqueueWait, since)
+			q.unlock()
+
+			for _, val := range vals {
+				runHooks(q.onEnqueue, val)
+			}
+			return nil
+		}
+		ready := q.notFull.wait()
+		since = q.blockedSince(since)
+		q.producers.enter(&blocked)
+		q.unlock()
+
+		err := waitFor(ctx, ready)
+		q.notFull.done()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkBatch returns the error EnqueueAllCtx fails with before waiting for
+// room for vals, which total bytes, if any: ErrClosed, ErrNilValue,
+// ErrDuplicate, or ErrOverflow if they could never fit, in which case they
+// are forwarded to the dead-letter queue. The caller must hold the write lock.
+func (q *queue[T]) checkBatch(vals []T, bytes int) error {
+	if q.closed {
+		return ErrClosed
+	}
+	never := false
+	for _, val := range vals {
+		if err := q.invalid(val); err != nil {
+			return err
+		}
+		never = never || q.circular && q.sizeOf != nil && q.itemBytes(val) > q.maxBytes
+	}
+	if q.duplicates(vals, true) {
+		return ErrDuplicate
+	}
+	if !q.circular {
+		never = q.capacity >= 0 && len(vals) > q.capacity || q.sizeOf != nil && bytes > q.maxBytes
+	}
+	if !never {
+		return nil
+	}
+
+	q.stats.Rejected++
+	for _, val := range vals {
+		q.drop(val)
+	}
+
+	return ErrOverflow
+}
+
+func (q *queue[T]) BlockedProducers() int {
+	return q.producers.count()
+}
+
+func (q *queue[T]) BlockedConsumers() int {
+	return q.consumers.count()
+}
+
+func (q *queue[T]) EnqueueCtx(ctx context.Context, val T) error {
+	return enqueueCtx(ctx, q, val)
+}
+
+// enqueueCtx implements EnqueueCtx in terms of EnqueueWait.
+func enqueueCtx[T any](ctx context.Context, q Queue[T], val T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return q.EnqueueWait(ctx, val)
+}
+
+func (q *queue[T]) EnqueueTimeout(val T, d time.Duration) error {
+	return enqueueTimeout(q, q.clock, val, d)
+}
+
+// enqueueTimeout implements EnqueueTimeout in terms of EnqueueWait.
+func enqueueTimeout[T any](q Queue[T], clock Clock, val T, d time.Duration) error {
+	if d <= 0 {
+		return q.Enqueue(val)
+	}
+
+	ctx, cancel := withTimeout(clock, d)
+	defer cancel()
+
+	err := q.EnqueueWait(ctx, val)
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%w: timed out after %v: %w", ErrOverflow, d, context.Cause(ctx))
+	}
+
+	return err
+}
+
+func (q *queue[T]) DequeueWait(ctx context.Context) (T, error) {
+	var since time.Time // Set when the call first blocks
+	var blocked bool    // Set once the call is counted in q.consumers
+	defer q.consumers.leave(&blocked)
+	if err := pace(ctx, q.limiter, q.clock, &q.consumers, &blocked); err != nil {
+		var zero T
+		return zero, err
+	}
+	for {
+		q.mu.Lock()
+		if result, ok := q.pop(); ok {
+			q.waited(&q.stats.DequeueWait, since)
+			q.unlock()
+
+			runHooks(q.onDequeue, result)
+			return result, nil
+		}
+		if q.closed {
+			q.unlock()
+
+			var zero T
+			return zero, ErrClosed
+		}
+		ready := q.notEmpty.wait()
+		since = q.blockedSince(since)
+		q.consumers.enter(&blocked)
+		q.unlock()
+
+		err := waitFor(ctx, ready)
+		q.notEmpty.done()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+func (q *queue[T]) NotEmpty() <-chan struct{} {
+	q.mu.Lock()
+	defer q.unlock()
+
+	if q.items.len() > 0 || q.closed {
+		return fired
+	}
+
+	return q.notEmpty.watch()
+}
+
+// NotFull counts a queue with a byte limit as full once the limit is reached,
+// even if a smaller item might still fit.
+func (q *queue[T]) NotFull() <-chan struct{} {
+	q.mu.Lock()
+	defer q.unlock()
+
+	if q.closed || q.circular || q.fits(1) && (q.sizeOf == nil || q.bytes < q.maxBytes) {
+		return fired
+	}
+
+	return q.notFull.watch()
+}
+
+func (q *queue[T]) DequeueCtx(ctx context.Context) (T, error) {
+	return dequeueCtx(ctx, q)
+}
+
+// dequeueCtx implements DequeueCtx in terms of DequeueWait.
+func dequeueCtx[T any](ctx context.Context, q Queue[T]) (T, error) {
+	if err := ctx.Err(); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return q.DequeueWait(ctx)
+}
+
+func (q *queue[T]) DequeueTimeout(d time.Duration) (T, error) {
+	return dequeueTimeout(q, q.clock, d)
+}
+
+// dequeueTimeout implements DequeueTimeout in terms of DequeueWait.
+func dequeueTimeout[T any](q Queue[T], clock Clock, d time.Duration) (T, error) {
+	if d <= 0 {
+		return q.Dequeue()
+	}
+
+	ctx, cancel := withTimeout(clock, d)
+	defer cancel()
+
+	result, err := q.DequeueWait(ctx)
+	if err != nil && ctx.Err() != nil {
+		return result, fmt.Errorf("%w: timed out after %v: %w", ErrUnderflow, d, context.Cause(ctx))
+	}
+
+	return result, err
+}
+
+// blockedSince returns since, or the current time if since is zero because
+// the call is about to block for the first time.
+func (q *queue[T]) blockedSince(since time.Time) time.Time {
+	if since.IsZero() {
+		return q.clock.Now()
+	}
+
+	return since
+}
+
+// waited records in w a blocking call that began to wait at since and has now
+// succeeded, unless since is zero because the call never blocked. The caller
+// must hold the write lock.
+func (q *queue[T]) waited(w *WaitStats, since time.Time) {
+	if !since.IsZero() {
+		w.record(since, q.clock.Now())
+	}
+}
+
+func (q *queue[T]) DequeueBatchWait(ctx context.Context, n int, maxWait time.Duration) ([]T, error) {
+	return dequeueBatchWait(ctx, q, q.clock, &q.notEmpty, &q.consumers, q.batchState, n, maxWait)
+}
+
+// batchState reports the number of items held and whether the queue is
+// closed, for dequeueBatchWait.
+func (q *queue[T]) batchState() (int, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.items.len(), q.closed
+}
+
+// dequeueBatchWait implements DequeueBatchWait in terms of DrainTo. state
+// reports the number of items available and whether q is closed, notEmpty
+// must be broadcast whenever an item is added, and consumers counts the
+// callers blocked waiting for items.
+func dequeueBatchWait[T any](ctx context.Context, q Queue[T], clock Clock, notEmpty *signal,
+	consumers *gauge, state func() (int, bool), n int, maxWait time.Duration) ([]T, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+	if n == 0 {
+		return []T{}, nil
+	}
+
+	var expired <-chan struct{}
+	if maxWait > 0 {
+		timeout, cancel := withTimeout(clock, maxWait)
+		defer cancel()
+		expired = timeout.Done()
+	}
+
+	var blocked bool // Set once the call is counted in consumers
+	defer consumers.leave(&blocked)
+	for {
+		// Register before checking so an item added in between still wakes us
+		ready := notEmpty.wait()
+		size, closed := state()
+		timedOut := maxWait <= 0 || isDone(expired)
+		if size >= n || (size > 0 && (closed || timedOut)) {
+			notEmpty.done()
+
+			// Another consumer may have taken the items in the meantime
+			batch := borrow(q, min(size, n))
+			if taken := q.DrainTo(batch); taken > 0 {
+				return batch[:taken], nil
+			}
+			Release(q, batch)
+			continue
+		}
+		if closed {
+			notEmpty.done()
+			return nil, ErrClosed
+		}
+		if timedOut {
+			notEmpty.done()
+			return nil, fmt.Errorf("%w: timed out after %v: %w", ErrUnderflow, maxWait, context.DeadlineExceeded)
+		}
+
+		consumers.enter(&blocked)
+		select {
+		case <-ready:
+		case <-expired:
+		case <-ctx.Done():
+			notEmpty.done()
+			return nil, ctx.Err()
+		}
+		notEmpty.done()
+	}
+}
+
+// isDone reports whether ch is closed. A nil ch is never done.
+func isDone(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitFor blocks until ready is closed or ctx is done.
+func waitFor(ctx context.Context, ready <-chan struct{}) error {
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
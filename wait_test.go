@@ -0,0 +1,762 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEnqueueWait(t *testing.T) {
+	q := New[int](WithCapacity[int](1))
+	_ = q.Enqueue(1)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- q.EnqueueWait(context.Background(), 2)
+	}()
+
+	// The producer must stay blocked while the queue is full
+	select {
+	case err := <-done:
+		t.Fatalf("EnqueueWait() returned %v on a full queue, want it to block", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if val, _ := q.Dequeue(); val != 1 {
+		t.Errorf("Dequeue() = %d, want 1", val)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("EnqueueWait() error = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("EnqueueWait() did not unblock after Dequeue()")
+	}
+
+	if val, _ := q.Dequeue(); val != 2 {
+		t.Errorf("Dequeue() = %d, want 2", val)
+	}
+}
+
+func TestEnqueueWaitCancelled(t *testing.T) {
+	q := New[int](WithCapacity[int](0))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- q.EnqueueWait(ctx, 1)
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("EnqueueWait() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("EnqueueWait() did not return after cancellation")
+	}
+
+	if s := q.Stats(); s.Rejected != 0 {
+		t.Errorf("Stats().Rejected = %d, want 0 (waiting is not a rejection)", s.Rejected)
+	}
+}
+
+func TestEnqueueTimeout(t *testing.T) {
+	t.Run("success before timeout", func(t *testing.T) {
+		q := New[int](WithCapacity[int](1))
+		_ = q.Enqueue(1)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_, _ = q.Dequeue()
+		}()
+
+		if err := q.EnqueueTimeout(2, time.Second); err != nil {
+			t.Errorf("EnqueueTimeout() error = %v, want nil", err)
+		}
+		if val, _ := q.Peek(); val != 2 {
+			t.Errorf("Peek() = %d, want 2", val)
+		}
+	})
+
+	t.Run("timeout expired", func(t *testing.T) {
+		q := New[int](WithCapacity[int](1))
+		_ = q.Enqueue(1)
+
+		err := q.EnqueueTimeout(2, 10*time.Millisecond)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("EnqueueTimeout() error = %v, want context.DeadlineExceeded", err)
+		}
+		if !errors.Is(err, ErrOverflow) {
+			t.Errorf("EnqueueTimeout() error = %v, want ErrOverflow", err)
+		}
+		if size := q.Size(); size != 1 {
+			t.Errorf("Size after timeout = %d, want 1", size)
+		}
+	})
+
+	t.Run("zero duration", func(t *testing.T) {
+		q := New[int](WithCapacity[int](1))
+
+		if err := q.EnqueueTimeout(1, 0); err != nil {
+			t.Errorf("EnqueueTimeout(1, 0) error = %v, want nil", err)
+		}
+
+		// A full queue fails immediately without a deadline
+		err := q.EnqueueTimeout(2, 0)
+		if !errors.Is(err, ErrOverflow) {
+			t.Errorf("EnqueueTimeout(2, 0) error = %v, want ErrOverflow", err)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("EnqueueTimeout(2, 0) error = %v, want no deadline error", err)
+		}
+	})
+}
+
+func TestShardedEnqueueWait(t *testing.T) {
+	q := NewSharded[int](4, WithCapacity[int](2))
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(2)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- q.EnqueueWait(context.Background(), 3)
+	}()
+
+	_, _ = q.Dequeue()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("EnqueueWait() error = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("EnqueueWait() did not unblock after Dequeue()")
+	}
+
+	if err := q.EnqueueTimeout(4, 10*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("EnqueueTimeout() on full sharded queue error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestEnqueueAllCtx(t *testing.T) {
+	for name, q := range map[string]Queue[int]{
+		"queue":   New[int](WithCapacity[int](3)),
+		"sharded": NewSharded[int](2, WithCapacity[int](3)),
+		"spsc":    NewSPSC[int](3),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_ = q.Enqueue(1)
+			_ = q.Enqueue(2)
+
+			done := make(chan error, 1)
+			go func() {
+				done <- q.EnqueueAllCtx(context.Background(), 3, 4)
+			}()
+
+			// Only one slot is free, so nothing may be added yet
+			select {
+			case err := <-done:
+				t.Fatalf("EnqueueAllCtx() returned %v without room for the batch, want it to block", err)
+			case <-time.After(20 * time.Millisecond):
+			}
+			if size := q.Size(); size != 2 {
+				t.Fatalf("Size() while EnqueueAllCtx() waits = %d, want 2", size)
+			}
+
+			// Drain concurrently until the whole batch has arrived
+			var got []int
+			for len(got) < 4 {
+				if val, err := q.DequeueTimeout(time.Second); err == nil {
+					got = append(got, val)
+				} else {
+					t.Fatalf("DequeueTimeout() error = %v, want nil", err)
+				}
+			}
+			if err := <-done; err != nil {
+				t.Errorf("EnqueueAllCtx() error = %v, want nil", err)
+			}
+			if name != "sharded" && !slices.Equal(got, []int{1, 2, 3, 4}) {
+				t.Errorf("Dequeued %v, want [1 2 3 4]", got)
+			}
+
+			if err := q.EnqueueAllCtx(context.Background(), 1, 2, 3, 4); !errors.Is(err, ErrOverflow) {
+				t.Errorf("EnqueueAllCtx() of a batch above capacity = %v, want %v", err, ErrOverflow)
+			}
+		})
+	}
+}
+
+func TestEnqueueAllCtxCancelled(t *testing.T) {
+	q := New[int](WithCapacity[int](2))
+	_ = q.Enqueue(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := q.EnqueueAllCtx(ctx, 2, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("EnqueueAllCtx() error = %v, want context.DeadlineExceeded", err)
+	}
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{1}) {
+		t.Errorf("All() after cancelled EnqueueAllCtx() = %v, want [1]", got)
+	}
+	if err := q.EnqueueAllCtx(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("EnqueueAllCtx() with a done context = %v, want context.DeadlineExceeded", err)
+	}
+	if size := q.Size(); size != 1 {
+		t.Errorf("Size() after EnqueueAllCtx() with a done context = %d, want 1", size)
+	}
+}
+
+func TestDequeueWait(t *testing.T) {
+	q := New[int]()
+
+	done := make(chan int, 1)
+	go func() {
+		val, err := q.DequeueWait(context.Background())
+		if err != nil {
+			t.Errorf("DequeueWait() error = %v, want nil", err)
+		}
+		done <- val
+	}()
+
+	select {
+	case val := <-done:
+		t.Fatalf("DequeueWait() returned %d on an empty queue, want it to block", val)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	_ = q.Enqueue(42)
+
+	select {
+	case val := <-done:
+		if val != 42 {
+			t.Errorf("DequeueWait() = %d, want 42", val)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DequeueWait() did not unblock after Enqueue()")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := q.DequeueWait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("DequeueWait() with cancelled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestDequeueTimeout(t *testing.T) {
+	t.Run("item produced mid-wait", func(t *testing.T) {
+		q := New[int]()
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = q.Enqueue(7)
+		}()
+
+		val, err := q.DequeueTimeout(time.Second)
+		if err != nil {
+			t.Errorf("DequeueTimeout() error = %v, want nil", err)
+		}
+		if val != 7 {
+			t.Errorf("DequeueTimeout() = %d, want 7", val)
+		}
+	})
+
+	t.Run("timeout expired", func(t *testing.T) {
+		q := New[int]()
+
+		val, err := q.DequeueTimeout(10 * time.Millisecond)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("DequeueTimeout() error = %v, want context.DeadlineExceeded", err)
+		}
+		if !errors.Is(err, ErrUnderflow) {
+			t.Errorf("DequeueTimeout() error = %v, want ErrUnderflow", err)
+		}
+		if val != 0 {
+			t.Errorf("DequeueTimeout() value = %d, want 0 (zero value)", val)
+		}
+	})
+
+	t.Run("zero duration", func(t *testing.T) {
+		q := New[int]()
+
+		_, err := q.DequeueTimeout(0)
+		if !errors.Is(err, ErrUnderflow) {
+			t.Errorf("DequeueTimeout(0) error = %v, want ErrUnderflow", err)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("DequeueTimeout(0) error = %v, want no deadline error", err)
+		}
+
+		_ = q.Enqueue(1)
+		if val, err := q.DequeueTimeout(0); err != nil || val != 1 {
+			t.Errorf("DequeueTimeout(0) = %d, %v, want 1, nil", val, err)
+		}
+	})
+}
+
+func TestShardedDequeueWait(t *testing.T) {
+	q := NewSharded[int](4)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = q.Enqueue(5)
+	}()
+
+	val, err := q.DequeueTimeout(time.Second)
+	if err != nil || val != 5 {
+		t.Errorf("DequeueTimeout() = %d, %v, want 5, nil", val, err)
+	}
+
+	if _, err := q.DequeueTimeout(10 * time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DequeueTimeout() on empty sharded queue error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestCloseWakesWaiters(t *testing.T) {
+	full := New[int](WithCapacity[int](0))
+	empty := New[int]()
+
+	producer := make(chan error, 1)
+	consumer := make(chan error, 1)
+	go func() {
+		producer <- full.EnqueueWait(context.Background(), 1)
+	}()
+	go func() {
+		_, err := empty.DequeueWait(context.Background())
+		consumer <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	_ = full.Close()
+	_ = empty.Close()
+
+	for name, done := range map[string]chan error{"EnqueueWait": producer, "DequeueWait": consumer} {
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrClosed) {
+				t.Errorf("%s() error = %v, want ErrClosed", name, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s() did not return after Close()", name)
+		}
+	}
+
+	if err := full.EnqueueTimeout(1, 10*time.Millisecond); !errors.Is(err, ErrClosed) {
+		t.Errorf("EnqueueTimeout() on closed queue error = %v, want ErrClosed", err)
+	}
+}
+
+func TestNotEmpty(t *testing.T) {
+	q := New[int]()
+
+	ready := q.NotEmpty()
+	select {
+	case <-ready:
+		t.Fatal("NotEmpty() fired on an empty queue")
+	default:
+	}
+
+	woken := make(chan struct{})
+	go func() {
+		<-ready
+		close(woken)
+	}()
+
+	// Give the waiter a chance to block before the first enqueue
+	time.Sleep(10 * time.Millisecond)
+	_ = q.Enqueue(1)
+
+	select {
+	case <-woken:
+	case <-time.After(time.Second):
+		t.Fatal("NotEmpty() waiter did not wake on the first enqueue")
+	}
+
+	// While items remain, a fresh channel is already closed
+	select {
+	case <-q.NotEmpty():
+	default:
+		t.Error("NotEmpty() did not fire on a non-empty queue")
+	}
+
+	// Draining the queue re-arms it
+	_, _ = q.Dequeue()
+	select {
+	case <-q.NotEmpty():
+		t.Error("NotEmpty() fired after the queue was drained")
+	default:
+	}
+}
+
+func TestNotEmptyClose(t *testing.T) {
+	q := New[int]()
+	ready := q.NotEmpty()
+
+	_ = q.Close()
+
+	select {
+	case <-ready:
+	case <-time.After(time.Second):
+		t.Fatal("NotEmpty() channel not closed by Close()")
+	}
+	select {
+	case <-q.NotEmpty():
+	default:
+		t.Error("NotEmpty() did not fire on a closed queue")
+	}
+}
+
+func TestNotFull(t *testing.T) {
+	for name, q := range map[string]Queue[int]{
+		"queue":   New[int](WithCapacity[int](1)),
+		"sharded": NewSharded[int](2, WithCapacity[int](1)),
+		"spsc":    NewSPSC[int](1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_ = q.Enqueue(1)
+			ready := q.NotFull()
+			select {
+			case <-ready:
+				t.Fatal("NotFull() fired on a full queue")
+			default:
+			}
+
+			// A producer waits on NotFull instead of retrying
+			produced := make(chan error, 1)
+			go func() {
+				<-ready
+				produced <- q.Enqueue(2)
+			}()
+
+			time.Sleep(10 * time.Millisecond)
+			if val, _ := q.Dequeue(); val != 1 {
+				t.Errorf("Dequeue() = %d, want 1", val)
+			}
+
+			select {
+			case err := <-produced:
+				if err != nil {
+					t.Errorf("Enqueue() after NotFull() fired = %v, want nil", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("NotFull() waiter did not wake on Dequeue()")
+			}
+
+			// Filling the queue again re-arms it
+			select {
+			case <-q.NotFull():
+				t.Error("NotFull() fired after the queue filled up again")
+			default:
+			}
+		})
+	}
+}
+
+func TestNotFullAlwaysReady(t *testing.T) {
+	closed := New[int](WithCapacity[int](0))
+	_ = closed.Close()
+	for name, q := range map[string]Queue[int]{
+		"unlimited": New[int](),
+		"circular":  New[int](WithCapacity[int](1), WithCircular[int]()),
+		"closed":    closed,
+	} {
+		_ = q.Enqueue(1)
+		select {
+		case <-q.NotFull():
+		default:
+			t.Errorf("NotFull() of %s queue did not fire", name)
+		}
+	}
+}
+
+func TestNotEmptyConcurrent(t *testing.T) {
+	for name, q := range map[string]Queue[int]{
+		"queue":   New[int](),
+		"sharded": NewSharded[int](4),
+	} {
+		t.Run(name, func(t *testing.T) {
+			const waiters = 8
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			for range waiters {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ready := q.NotEmpty()
+					start <- struct{}{}
+					<-ready
+				}()
+			}
+			for range waiters {
+				<-start
+			}
+
+			_ = q.Enqueue(1)
+
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("NotEmpty() waiters did not all wake on the first enqueue")
+			}
+		})
+	}
+}
+
+func TestCtxOperationsCancelled(t *testing.T) {
+	for name, q := range map[string]Queue[int]{
+		"queue":   New[int](),
+		"sharded": NewSharded[int](2),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			if err := q.EnqueueCtx(ctx, 1); !errors.Is(err, context.Canceled) {
+				t.Errorf("EnqueueCtx() with cancelled context = %v, want %v", err, context.Canceled)
+			}
+			if size := q.Size(); size != 0 {
+				t.Errorf("Size after cancelled EnqueueCtx = %d, want 0", size)
+			}
+
+			_ = q.Enqueue(2)
+			if _, err := q.DequeueCtx(ctx); !errors.Is(err, context.Canceled) {
+				t.Errorf("DequeueCtx() with cancelled context = %v, want %v", err, context.Canceled)
+			}
+			if size := q.Size(); size != 1 {
+				t.Errorf("Size after cancelled DequeueCtx = %d, want 1", size)
+			}
+
+			if s := q.Stats(); s.Enqueued != 1 || s.Dequeued != 0 {
+				t.Errorf("Stats() = %+v, want Enqueued=1 Dequeued=0", s)
+			}
+		})
+	}
+}
+
+func TestCtxOperations(t *testing.T) {
+	q := New[int](WithCapacity[int](1))
+	ctx := context.Background()
+
+	if err := q.EnqueueCtx(ctx, 1); err != nil {
+		t.Fatalf("EnqueueCtx() = %v, want nil", err)
+	}
+
+	// A full queue blocks until the deadline like EnqueueWait
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := q.EnqueueCtx(timeout, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("EnqueueCtx() on full queue = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if val, err := q.DequeueCtx(ctx); val != 1 || err != nil {
+		t.Errorf("DequeueCtx() = (%d, %v), want (1, nil)", val, err)
+	}
+}
+
+// batchResult is the outcome of a DequeueBatchWait call run in a goroutine.
+type batchResult struct {
+	items []int
+	err   error
+}
+
+func TestDequeueBatchWait(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func(Clock) Queue[int]
+	}{
+		{name: "fifo", newFn: func(c Clock) Queue[int] { return New(WithClock[int](c)) }},
+		{name: "sharded", newFn: func(c Clock) Queue[int] { return NewSharded(2, WithClock[int](c)) }},
+		{name: "expiring", newFn: func(c Clock) Queue[int] { return NewExpiring(WithClock[int](c)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("full batch", func(t *testing.T) {
+				clock := newFakeClock()
+				q := tt.newFn(clock)
+				_ = q.Enqueue(1)
+
+				done := make(chan batchResult, 1)
+				go func() {
+					items, err := q.DequeueBatchWait(context.Background(), 3, time.Hour)
+					done <- batchResult{items, err}
+				}()
+
+				// The batch fills long before maxWait, which never passes
+				awaitTimers(t, clock, 1)
+				_, _ = q.EnqueueSlice([]int{2, 3, 4})
+				res := <-done
+				if res.err != nil || len(res.items) != 3 {
+					t.Fatalf("DequeueBatchWait() = (%v, %v), want 3 items", res.items, res.err)
+				}
+				if size := q.Size(); size != 1 {
+					t.Errorf("Size after full batch = %d, want 1", size)
+				}
+			})
+
+			t.Run("partial batch", func(t *testing.T) {
+				clock := newFakeClock()
+				q := tt.newFn(clock)
+
+				done := make(chan batchResult, 1)
+				go func() {
+					items, err := q.DequeueBatchWait(context.Background(), 10, time.Second)
+					done <- batchResult{items, err}
+				}()
+
+				awaitTimers(t, clock, 1)
+				_ = q.Enqueue(1)
+				_ = q.Enqueue(2)
+				clock.Advance(time.Second)
+				res := <-done
+				if res.err != nil || !slices.Equal(res.items, []int{1, 2}) {
+					t.Errorf("DequeueBatchWait() after maxWait = (%v, %v), want ([1 2], nil)", res.items, res.err)
+				}
+			})
+
+			t.Run("empty", func(t *testing.T) {
+				clock := newFakeClock()
+				q := tt.newFn(clock)
+
+				done := make(chan batchResult, 1)
+				go func() {
+					items, err := q.DequeueBatchWait(context.Background(), 2, time.Second)
+					done <- batchResult{items, err}
+				}()
+
+				awaitTimers(t, clock, 1)
+				clock.Advance(time.Second)
+				res := <-done
+				if !errors.Is(res.err, ErrUnderflow) || !errors.Is(res.err, context.DeadlineExceeded) {
+					t.Errorf("DequeueBatchWait() on empty queue = %v, want %v wrapping %v", res.err, ErrUnderflow, context.DeadlineExceeded)
+				}
+			})
+		})
+	}
+}
+
+func TestDequeueBatchWaitEdgeCases(t *testing.T) {
+	q := New[int]()
+	ctx := context.Background()
+
+	if _, err := q.DequeueBatchWait(ctx, -1, time.Second); !errors.Is(err, ErrNegativeCount) {
+		t.Errorf("DequeueBatchWait(-1) = %v, want %v", err, ErrNegativeCount)
+	}
+	if items, err := q.DequeueBatchWait(ctx, 0, time.Second); err != nil || len(items) != 0 {
+		t.Errorf("DequeueBatchWait(0) = (%v, %v), want no items", items, err)
+	}
+
+	// Without maxWait it returns what is there at once
+	if _, err := q.DequeueBatchWait(ctx, 2, 0); !errors.Is(err, ErrUnderflow) {
+		t.Errorf("DequeueBatchWait() with no wait on empty queue = %v, want %v", err, ErrUnderflow)
+	}
+	_ = q.Enqueue(1)
+	if items, err := q.DequeueBatchWait(ctx, 2, 0); err != nil || !slices.Equal(items, []int{1}) {
+		t.Errorf("DequeueBatchWait() with no wait = (%v, %v), want ([1], nil)", items, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := q.DequeueBatchWait(cancelled, 2, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("DequeueBatchWait() with cancelled ctx = %v, want %v", err, context.Canceled)
+	}
+
+	// A closed queue hands over what it has, then reports ErrClosed
+	_, _ = q.EnqueueSlice([]int{2, 3})
+	_ = q.Close()
+	if items, err := q.DequeueBatchWait(ctx, 5, time.Hour); err != nil || !slices.Equal(items, []int{2, 3}) {
+		t.Errorf("DequeueBatchWait() on closed queue = (%v, %v), want ([2 3], nil)", items, err)
+	}
+	if _, err := q.DequeueBatchWait(ctx, 5, time.Hour); !errors.Is(err, ErrClosed) {
+		t.Errorf("DequeueBatchWait() on closed empty queue = %v, want %v", err, ErrClosed)
+	}
+}
+
+// awaitCount waits until count returns want, failing the test after a second.
+func awaitCount(t *testing.T, name string, count func() int, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for count() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %d, want %d", name, count(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBlockedCallers(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func(opts ...Option[int]) Queue[int]
+	}{
+		{name: "queue", newFn: func(opts ...Option[int]) Queue[int] { return New(opts...) }},
+		{name: "sharded", newFn: func(opts ...Option[int]) Queue[int] { return NewSharded(2, opts...) }},
+		{name: "expiring", newFn: func(opts ...Option[int]) Queue[int] { return NewExpiring(opts...) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/producers", func(t *testing.T) {
+			q := tt.newFn(WithCapacity[int](2))
+			_, _ = q.EnqueueSlice([]int{1, 2})
+
+			const producers = 3
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			for i := range producers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_ = q.EnqueueWait(ctx, 10+i)
+				}()
+			}
+			awaitCount(t, "BlockedProducers()", q.BlockedProducers, producers)
+			if n := q.BlockedConsumers(); n != 0 {
+				t.Errorf("BlockedConsumers() = %d, want 0", n)
+			}
+
+			// Making room lets exactly one producer through
+			_, _ = q.Dequeue()
+			awaitCount(t, "BlockedProducers() after Dequeue()", q.BlockedProducers, producers-1)
+
+			cancel()
+			wg.Wait()
+			if n := q.BlockedProducers(); n != 0 {
+				t.Errorf("BlockedProducers() after cancelling = %d, want 0", n)
+			}
+		})
+
+		t.Run(tt.name+"/consumers", func(t *testing.T) {
+			q := tt.newFn()
+			var wg sync.WaitGroup
+			wg.Add(3)
+			for range 2 {
+				go func() {
+					defer wg.Done()
+					_, _ = q.DequeueWait(context.Background())
+				}()
+			}
+			go func() {
+				defer wg.Done()
+				_, _ = q.DequeueBatchWait(context.Background(), 5, time.Hour)
+			}()
+			awaitCount(t, "BlockedConsumers()", q.BlockedConsumers, 3)
+
+			_ = q.Close()
+			wg.Wait()
+			if n := q.BlockedConsumers(); n != 0 {
+				t.Errorf("BlockedConsumers() after Close() = %d, want 0", n)
+			}
+		})
+	}
+}
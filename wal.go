@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Record kinds in a write-ahead log. An enqueue record is followed by a
+// varint length and the encoded item; a dequeue record has no payload.
+const (
+	walEnqueue byte = 1
+	walDequeue byte = 2
+)
+
+// wal appends a record for every item added to the back of a queue and every
+// item removed from its front.
+type wal[T any] struct {
+	w      io.Writer
+	encode func(T) ([]byte, error)
+	buf    []byte // Reused record buffer; guarded by the queue's write lock
+}
+
+// enqueued logs that val was added to the back of the queue, reporting whether
+// the record was written. The caller must hold the write lock.
+func (l *wal[T]) enqueued(val T) bool {
+	data, err := l.encode(val)
+	if err != nil {
+		return false
+	}
+	l.buf = append(l.buf[:0], walEnqueue)
+	l.buf = binary.AppendUvarint(l.buf, uint64(len(data)))
+	l.buf = append(l.buf, data...)
+
+	return l.write()
+}
+
+// dequeued logs that the front item was removed, reporting whether the record
+// was written. The caller must hold the write lock.
+func (l *wal[T]) dequeued() bool {
+	l.buf = append(l.buf[:0], walDequeue)
+	return l.write()
+}
+
+// write writes the record in buf with a single call to the underlying writer.
+func (l *wal[T]) write() bool {
+	_, err := l.w.Write(l.buf)
+	return err == nil
+}
+
+// logEnqueue records val in the write-ahead log, if there is one.
+// The caller must hold the write lock.
+func (q *queue[T]) logEnqueue(val T) {
+	if q.wal != nil && !q.wal.enqueued(val) {
+		q.stats.LogErrors++
+	}
+}
+
+// logDequeue records a removal from the front in the write-ahead log, if
+// there is one. The caller must hold the write lock.
+func (q *queue[T]) logDequeue() {
+	if q.wal != nil && !q.wal.dequeued() {
+		q.stats.LogErrors++
+	}
+}
+
+// Replay reconstructs a queue from a log written by WithWAL. It applies every
+// logged enqueue and dequeue, in order, to a new queue created by New with
+// opts, which should match the options of the logged queue.
+//
+// A record cut short at the end of the log, as left by a crash during a write,
+// is ignored. Passing WithWAL in opts writes the replayed operations to a new
+// log, which can then replace the old one.
+//
+// Example:
+//
+//	f, _ := os.Open("jobs.wal")
+//	q, err := queue.Replay(f, func(b []byte) (Job, error) {
+//		var job Job
+//		err := json.Unmarshal(b, &job)
+//		return job, err
+//	})
+//
+// decode must not retain the slice it is given.
+//
+// Returns ErrInvalidLog if r does not hold a valid log or an item cannot be
+// decoded, or any error from replaying an enqueue into the new queue.
+func Replay[T any](r io.Reader, decode func([]byte) (T, error), opts ...Option[T]) (Queue[T], error) {
+	q := New(opts...)
+	defer unthrottled(q)()
+	br := bufio.NewReader(r)
+	var data bytes.Buffer
+	for n := 0; ; n++ {
+		kind, err := br.ReadByte()
+		if err == io.EOF {
+			return q, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case walEnqueue:
+			size, err := binary.ReadUvarint(br)
+			if err != nil {
+				return tornRecord(q, err)
+			}
+			// The size is not trusted for allocation, as the log may be corrupt
+			data.Reset()
+			if _, err := io.CopyN(&data, br, int64(size)); err != nil {
+				return tornRecord(q, err)
+			}
+			val, err := decode(data.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("%w: record %d: %w", ErrInvalidLog, n, err)
+			}
+			if err := q.Enqueue(val); err != nil {
+				return nil, fmt.Errorf("record %d: %w", n, err)
+			}
+		case walDequeue:
+			if _, ok := q.TryDequeue(); !ok {
+				return nil, fmt.Errorf("%w: record %d dequeues from an empty queue", ErrInvalidLog, n)
+			}
+		default:
+			return nil, fmt.Errorf("%w: record %d has unknown kind %d", ErrInvalidLog, n, kind)
+		}
+	}
+}
+
+// tornRecord returns q if err shows that the last record was cut short, and
+// err otherwise.
+func tornRecord[T any](q Queue[T], err error) (Queue[T], error) {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return q, nil
+	}
+
+	return nil, err
+}
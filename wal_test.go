@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func encodeInt(v int) ([]byte, error) {
+	return strconv.AppendInt(nil, int64(v), 10), nil
+}
+
+func decodeInt(b []byte) (int, error) {
+	return strconv.Atoi(string(b))
+}
+
+func TestReplayMatchesQueue(t *testing.T) {
+	var log bytes.Buffer
+	opts := []Option[int]{WithCapacity[int](4), WithCircular[int]()}
+	q := New(append(opts, WithWAL(&log, encodeInt))...)
+
+	_, _ = q.EnqueueSlice([]int{1, 2, 3})
+	_, _ = q.Dequeue()
+	_ = q.Enqueue(4)
+	_ = q.Enqueue(5)
+	_ = q.Enqueue(6) // Evicts 2
+	_, _ = q.DequeueUntil(func(v int) bool { return v == 4 })
+	_ = q.Enqueue(7)
+
+	// The queue is lost here; only the log survives
+	want := slices.Collect(q.All())
+	replayed, err := Replay(bytes.NewReader(log.Bytes()), decodeInt, opts...)
+	if err != nil {
+		t.Fatalf("Replay() = %v, want nil", err)
+	}
+	if got := slices.Collect(replayed.All()); !slices.Equal(got, want) {
+		t.Errorf("replayed items = %v, want %v", got, want)
+	}
+}
+
+func TestReplayTornRecord(t *testing.T) {
+	var log bytes.Buffer
+	q := New(WithWAL(&log, encodeInt))
+	_ = q.Enqueue(1)
+	_ = q.Enqueue(22)
+
+	// A crash during the last write leaves part of its record behind
+	torn := log.Bytes()[:log.Len()-1]
+	replayed, err := Replay(bytes.NewReader(torn), decodeInt)
+	if err != nil {
+		t.Fatalf("Replay() of torn log = %v, want nil", err)
+	}
+	if got := slices.Collect(replayed.All()); !slices.Equal(got, []int{1}) {
+		t.Errorf("replayed items = %v, want [1]", got)
+	}
+}
+
+func TestReplayCompacts(t *testing.T) {
+	var log bytes.Buffer
+	q := New(WithWAL(&log, encodeInt))
+	for i := 1; i <= 5; i++ {
+		_ = q.Enqueue(i)
+	}
+	_, _ = q.TryDequeue()
+	_, _ = q.TryDequeue()
+
+	// Replaying into a new log leaves only the records of the surviving items
+	var compacted bytes.Buffer
+	if _, err := Replay(&log, decodeInt, WithWAL(&compacted, encodeInt)); err != nil {
+		t.Fatalf("Replay() = %v, want nil", err)
+	}
+	replayed, err := Replay(&compacted, decodeInt)
+	if err != nil {
+		t.Fatalf("Replay() of compacted log = %v, want nil", err)
+	}
+	if got := slices.Collect(replayed.All()); !slices.Equal(got, []int{3, 4, 5}) {
+		t.Errorf("replayed items = %v, want [3 4 5]", got)
+	}
+}
+
+func TestReplayInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		log  []byte
+	}{
+		{name: "unknown kind", log: []byte{9}},
+		{name: "dequeue from empty", log: []byte{walDequeue}},
+		{name: "undecodable item", log: []byte{walEnqueue, 1, 'x'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Replay(bytes.NewReader(tt.log), decodeInt); !errors.Is(err, ErrInvalidLog) {
+				t.Errorf("Replay() = %v, want %v", err, ErrInvalidLog)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWALErrorsCounted(t *testing.T) {
+	q := New(WithWAL[int](failingWriter{}, encodeInt))
+
+	// Operations succeed even though nothing can be logged
+	if err := q.Enqueue(1); err != nil {
+		t.Errorf("Enqueue() with a failing log = %v, want nil", err)
+	}
+	if _, err := q.Dequeue(); err != nil {
+		t.Errorf("Dequeue() with a failing log = %v, want nil", err)
+	}
+	if s := q.Stats(); s.LogErrors != 2 {
+		t.Errorf("Stats().LogErrors = %d, want 2", s.LogErrors)
+	}
+
+	var log bytes.Buffer
+	q = New(WithWAL(&log, func(int) ([]byte, error) { return nil, errors.New("bad item") }))
+	_ = q.Enqueue(1)
+	if s := q.Stats(); s.LogErrors != 1 || log.Len() != 0 {
+		t.Errorf("Stats().LogErrors = %d with %d bytes logged, want 1 with 0", s.LogErrors, log.Len())
+	}
+}
+
+func TestWALPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{name: "nil writer", fn: func() { WithWAL[int](nil, encodeInt) }},
+		{name: "nil encode", fn: func() { WithWAL[int](&bytes.Buffer{}, nil) }},
+		{name: "sharded", fn: func() { NewSharded(2, WithWAL[int](&bytes.Buffer{}, encodeInt)) }},
+		{name: "expiring", fn: func() { NewExpiring(WithWAL[int](&bytes.Buffer{}, encodeInt)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}